@@ -0,0 +1,144 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// junitParser reads JUnit XML. Both a <testsuites> root and a bare <testsuite> root are accepted;
+// nested suites are flattened with each testcase attributed to its innermost suite.
+type junitParser struct{}
+
+type junitTestsuites struct {
+	Suites []junitTestsuite `xml:"testsuite"`
+}
+
+type junitTestsuite struct {
+	Name       string           `xml:"name,attr"`
+	File       string           `xml:"file,attr"`
+	Properties []property       `xml:"properties>property"`
+	Testcases  []junitTestcase  `xml:"testcase"`
+	Suites     []junitTestsuite `xml:"testsuite"`
+}
+
+type junitTestcase struct {
+	Name       string         `xml:"name,attr"`
+	Classname  string         `xml:"classname,attr"`
+	File       string         `xml:"file,attr"`
+	Time       string         `xml:"time,attr"`
+	Properties []property     `xml:"properties>property"`
+	Failures   []junitFailure `xml:"failure"`
+	Errors     []junitFailure `xml:"error"`
+	Skipped    *junitSkipped  `xml:"skipped"`
+	SystemOut  []string       `xml:"system-out"`
+	SystemErr  []string       `xml:"system-err"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (junitParser) Format() string { return "junit" }
+
+func (junitParser) Detect(data []byte) bool {
+	root := xmlRoot(data)
+	return root == "testsuites" || root == "testsuite"
+}
+
+func (junitParser) Parse(data []byte) ([]Result, error) {
+	var suites junitTestsuites
+	var suite junitTestsuite
+	root, err := decodeXMLRoot("junit", data, map[string]any{
+		"testsuites": &suites,
+		"testsuite":  &suite,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == "testsuite" {
+		suites.Suites = []junitTestsuite{suite}
+	}
+
+	var results []Result
+	for _, suite := range suites.Suites {
+		results, err = appendJUnitSuite(results, suite, "", nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func appendJUnitSuite(results []Result, suite junitTestsuite, file string, props map[string]string) ([]Result, error) {
+	if suite.File != "" {
+		file = suite.File
+	}
+	props = mergeProperties(props, suite.Properties)
+
+	for _, tc := range suite.Testcases {
+		result, err := junitResult(tc, suite.Name, file, props)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	for _, child := range suite.Suites {
+		var err error
+		results, err = appendJUnitSuite(results, child, file, props)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func junitResult(tc junitTestcase, suiteName, file string, props map[string]string) (Result, error) {
+	result := Result{
+		Name:       tc.Name,
+		Classname:  tc.Classname,
+		File:       file,
+		Testsuite:  suiteName,
+		Status:     "pass",
+		Stdout:     strings.Join(tc.SystemOut, "\n"),
+		Stderr:     strings.Join(tc.SystemErr, "\n"),
+		Properties: mergeProperties(props, tc.Properties),
+	}
+	if tc.File != "" {
+		result.File = tc.File
+	}
+
+	var err error
+	if result.Time, err = optionalSeconds(tc.Time); err != nil {
+		return Result{}, fmt.Errorf("junit: invalid time %q of testcase %q", tc.Time, tc.Name)
+	}
+
+	switch {
+	case len(tc.Failures) > 0:
+		result.Status = "fail"
+		setJUnitFailure(&result, tc.Failures[0])
+	case len(tc.Errors) > 0:
+		result.Status = "error"
+		setJUnitFailure(&result, tc.Errors[0])
+	case tc.Skipped != nil:
+		result.Status = "skip"
+		result.Output = tc.Skipped.Message
+		if result.Output == "" {
+			result.Output = strings.TrimSpace(tc.Skipped.Text)
+		}
+	}
+	return result, nil
+}
+
+func setJUnitFailure(result *Result, f junitFailure) {
+	result.FailureMessage = f.Message
+	result.FailureType = f.Type
+	result.StackTrace = strings.TrimSpace(f.Text)
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// xunitParser reads xUnit.net v2 XML results. Testcases are attributed to their test assembly
+// and their traits become properties.
+type xunitParser struct{}
+
+type xunitAssemblies struct {
+	Assemblies []xunitAssembly `xml:"assembly"`
+}
+
+type xunitAssembly struct {
+	Name        string            `xml:"name,attr"`
+	Collections []xunitCollection `xml:"collection"`
+}
+
+type xunitCollection struct {
+	Tests []xunitTest `xml:"test"`
+}
+
+type xunitTest struct {
+	Name    string     `xml:"name,attr"`
+	Type    string     `xml:"type,attr"`
+	Time    string     `xml:"time,attr"`
+	Result  string     `xml:"result,attr"`
+	Traits  []property `xml:"traits>trait"`
+	Output  string     `xml:"output"`
+	Reason  string     `xml:"reason"`
+	Failure struct {
+		ExceptionType string `xml:"exception-type,attr"`
+		Message       string `xml:"message"`
+		StackTrace    string `xml:"stack-trace"`
+	} `xml:"failure"`
+}
+
+func (xunitParser) Format() string { return "xunit" }
+
+func (xunitParser) Detect(data []byte) bool {
+	root := xmlRoot(data)
+	return root == "assemblies" || root == "assembly"
+}
+
+func (xunitParser) Parse(data []byte) ([]Result, error) {
+	var assemblies xunitAssemblies
+	var assembly xunitAssembly
+	root, err := decodeXMLRoot("xunit", data, map[string]any{
+		"assemblies": &assemblies,
+		"assembly":   &assembly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == "assembly" {
+		assemblies.Assemblies = []xunitAssembly{assembly}
+	}
+
+	var results []Result
+	for _, assembly := range assemblies.Assemblies {
+		for _, collection := range assembly.Collections {
+			for _, test := range collection.Tests {
+				result, err := xunitResult(test, assemblyName(assembly.Name))
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, result)
+			}
+		}
+	}
+	return results, nil
+}
+
+func xunitResult(test xunitTest, assembly string) (Result, error) {
+	result := Result{
+		Name:       test.Name,
+		Classname:  test.Type,
+		Testsuite:  assembly,
+		Stdout:     strings.TrimSpace(test.Output),
+		Properties: mergeProperties(nil, test.Traits),
+	}
+
+	switch test.Result {
+	case "Pass":
+		result.Status = "pass"
+	case "Fail":
+		result.Status = "fail"
+		result.FailureMessage = strings.TrimSpace(test.Failure.Message)
+		result.FailureType = test.Failure.ExceptionType
+		result.StackTrace = strings.TrimSpace(test.Failure.StackTrace)
+	case "Skip", "NotRun":
+		result.Status = "skip"
+		result.Output = strings.TrimSpace(test.Reason)
+	default:
+		return Result{}, fmt.Errorf("xunit: unknown result %q of test %q", test.Result, test.Name)
+	}
+
+	var err error
+	if result.Time, err = optionalSeconds(test.Time); err != nil {
+		return Result{}, fmt.Errorf("xunit: invalid time %q of test %q", test.Time, test.Name)
+	}
+	return result, nil
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/cephei8/greener/reporting/greener-reporter-cli/internal/report"
 	"github.com/google/uuid"
 	"github.com/urfave/cli/v3"
 )
@@ -45,6 +46,8 @@ type SessionResponse struct {
 }
 
 type TestcaseRequest struct {
+	Id                *string        `json:"id,omitempty"`
+	ParentId          *string        `json:"parentId,omitempty"`
 	SessionId         string         `json:"sessionId"`
 	TestcaseName      string         `json:"testcaseName"`
 	TestcaseClassname *string        `json:"testcaseClassname,omitempty"`
@@ -52,6 +55,13 @@ type TestcaseRequest struct {
 	Testsuite         *string        `json:"testsuite,omitempty"`
 	Status            TestcaseStatus `json:"status"`
 	Output            *string        `json:"output,omitempty"`
+	FailureMessage    *string        `json:"failureMessage,omitempty"`
+	FailureType       *string        `json:"failureType,omitempty"`
+	StackTrace        *string        `json:"stackTrace,omitempty"`
+	Stdout            *string        `json:"stdout,omitempty"`
+	Stderr            *string        `json:"stderr,omitempty"`
+	Attempt           *int           `json:"attempt,omitempty"`
+	RetryOf           *string        `json:"retryOf,omitempty"`
 	Baggage           map[string]any `json:"baggage,omitempty"`
 }
 
@@ -335,7 +345,21 @@ func createTestcaseAction(ctx context.Context, cmd *cli.Command) error {
 		}
 	}
 
+	optional := func(flag string) *string {
+		if v := cmd.String(flag); v != "" {
+			return &v
+		}
+		return nil
+	}
+
+	var attempt *int
+	if a := cmd.Int("attempt"); a != 0 {
+		attempt = &a
+	}
+
 	testcase := TestcaseRequest{
+		Id:                optional("id"),
+		ParentId:          optional("parent-id"),
 		SessionId:         sessionID,
 		TestcaseName:      name,
 		TestcaseClassname: classname,
@@ -343,6 +367,13 @@ func createTestcaseAction(ctx context.Context, cmd *cli.Command) error {
 		Testsuite:         testsuite,
 		Status:            status,
 		Output:            output,
+		FailureMessage:    optional("failure-message"),
+		FailureType:       optional("failure-type"),
+		StackTrace:        optional("stack-trace"),
+		Stdout:            optional("stdout"),
+		Stderr:            optional("stderr"),
+		Attempt:           attempt,
+		RetryOf:           optional("retry-of"),
 		Baggage:           baggage,
 	}
 
@@ -355,6 +386,103 @@ func createTestcaseAction(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+func createTestcasesAction(ctx context.Context, cmd *cli.Command) error {
+	endpoint, err := getRequiredGlobalFlag(cmd, "endpoint")
+	if err != nil {
+		return err
+	}
+
+	apiKey, err := getRequiredGlobalFlag(cmd, "api-key")
+	if err != nil {
+		return err
+	}
+
+	sessionID := cmd.String("session-id")
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return fmt.Errorf("invalid session ID format: %w", err)
+	}
+
+	format := cmd.String("format")
+	if _, ok := report.Lookup(format); format != "" && !ok {
+		return fmt.Errorf("unknown report format %q, expected one of: %s", format, strings.Join(report.Formats(), ", "))
+	}
+
+	files := cmd.Args().Slice()
+	if len(files) == 0 {
+		return fmt.Errorf("at least one report file is required (use '-' for stdin)")
+	}
+
+	var testcases []TestcaseRequest
+	for _, file := range files {
+		var data []byte
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", file, err)
+		}
+
+		results, err := report.Parse(format, data)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", file, err)
+		}
+		for _, result := range results {
+			testcases = append(testcases, reportTestcase(sessionID, result))
+		}
+	}
+
+	if len(testcases) == 0 {
+		fmt.Println("No test results to report")
+		return nil
+	}
+
+	if err := NewClient(endpoint, apiKey).CreateTestcases(testcases); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create testcases: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Successfully reported %d testcases\n", len(testcases))
+	return nil
+}
+
+// reportTestcase maps a parsed report result to a testcase; its time and properties go to the baggage.
+func reportTestcase(sessionID string, result report.Result) TestcaseRequest {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+
+	testcase := TestcaseRequest{
+		SessionId:         sessionID,
+		TestcaseName:      result.Name,
+		TestcaseClassname: optional(result.Classname),
+		TestcaseFile:      optional(result.File),
+		Testsuite:         optional(result.Testsuite),
+		Status:            TestcaseStatus(result.Status),
+		Output:            optional(result.Output),
+		FailureMessage:    optional(result.FailureMessage),
+		FailureType:       optional(result.FailureType),
+		StackTrace:        optional(result.StackTrace),
+		Stdout:            optional(result.Stdout),
+		Stderr:            optional(result.Stderr),
+	}
+
+	if result.Time != nil || len(result.Properties) > 0 {
+		testcase.Baggage = map[string]any{}
+		if result.Time != nil {
+			testcase.Baggage["time"] = *result.Time
+		}
+		if len(result.Properties) > 0 {
+			testcase.Baggage["properties"] = result.Properties
+		}
+	}
+	return testcase
+}
+
 func main() {
 	cmd := &cli.Command{
 		Name:  "greener-reporter-cli",
@@ -418,6 +546,42 @@ func main() {
 								Name:  "output",
 								Usage: "Output from the test case",
 							},
+							&cli.StringFlag{
+								Name:  "failure-message",
+								Usage: "Failure message of the test case",
+							},
+							&cli.StringFlag{
+								Name:  "failure-type",
+								Usage: "Failure type, e.g. the exception class",
+							},
+							&cli.StringFlag{
+								Name:  "stack-trace",
+								Usage: "Stack trace of the failure",
+							},
+							&cli.StringFlag{
+								Name:  "stdout",
+								Usage: "Standard output of the test case",
+							},
+							&cli.StringFlag{
+								Name:  "stderr",
+								Usage: "Standard error of the test case",
+							},
+							&cli.StringFlag{
+								Name:  "id",
+								Usage: "ID of the test case (optional, will be generated if not provided)",
+							},
+							&cli.StringFlag{
+								Name:  "parent-id",
+								Usage: "ID of the parent test case, for subtests and steps",
+							},
+							&cli.IntFlag{
+								Name:  "attempt",
+								Usage: "Attempt number of a retried test case (1 starts a new test case)",
+							},
+							&cli.StringFlag{
+								Name:  "retry-of",
+								Usage: "ID of the test case this attempt retries",
+							},
 							&cli.StringFlag{
 								Name:  "classname",
 								Usage: "Class name of the test case",
@@ -441,6 +605,23 @@ func main() {
 							},
 						},
 					},
+					{
+						Name:      "testcases",
+						Usage:     "Create test cases from report files (JUnit XML, TRX, NUnit, xUnit.net, TAP, CTRF)",
+						ArgsUsage: "FILE...",
+						Action:    createTestcasesAction,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "session-id",
+								Usage:    "Session ID for the test cases",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "Report format (" + strings.Join(report.Formats(), ", ") + "), detected from the content if not set",
+							},
+						},
+					},
 				},
 			},
 		},
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -70,13 +71,15 @@ type SessionResponse struct {
 }
 
 type TestcaseRequest struct {
+	Id                string         `json:"id,omitempty"`
+	ParentId          string         `json:"parentId,omitempty"`
 	SessionId         string         `json:"sessionId"`
 	TestcaseName      string         `json:"testcaseName"`
 	TestcaseClassname string         `json:"testcaseClassname,omitempty"`
 	TestcaseFile      string         `json:"testcaseFile,omitempty"`
 	Testsuite         string         `json:"testsuite,omitempty"`
 	Status            string         `json:"status"`
-	Output            string         `json:"output,omitempty"`
+	Stdout            string         `json:"stdout,omitempty"`
 	Baggage           map[string]any `json:"baggage,omitempty"`
 }
 
@@ -90,10 +93,14 @@ type TestResultKey struct {
 }
 
 type TestResult struct {
-	Package string
-	Test    string
-	Status  string
-	Output  strings.Builder
+	Id       string
+	ParentId string
+	Package  string
+	Test     string
+	Status   string
+	Output   strings.Builder
+	// Subtests finish before their parent; they are held here and reported after it.
+	Subtests []*TestResult
 }
 
 func main() {
@@ -324,12 +331,14 @@ func (r *Reporter) batch() {
 			}
 
 			batch = append(batch, TestcaseRequest{
+				Id:                result.Id,
+				ParentId:          result.ParentId,
 				SessionId:         r.sessionID,
 				TestcaseName:      result.Test,
 				TestcaseClassname: result.Package,
 				Testsuite:         result.Package,
 				Status:            result.Status,
-				Output:            result.Output.String(),
+				Stdout:            result.Output.String(),
 			})
 
 			if len(batch) >= 100 {
@@ -361,6 +370,7 @@ func (r *Reporter) handleEvent(ev Event, verbose bool) {
 			return
 		} else {
 			result = &TestResult{
+				Id:      newUUID(),
 				Package: ev.Package,
 				Test:    ev.Test,
 				Status:  statusError,
@@ -373,21 +383,52 @@ func (r *Reporter) handleEvent(ev Event, verbose bool) {
 	case actionRun:
 	case actionPass:
 		result.Status = statusPass
-		r.resultsChan <- result
-		delete(r.results, key)
+		r.complete(key, result)
 	case actionFail:
 		result.Status = statusFail
-		r.resultsChan <- result
-		delete(r.results, key)
+		r.complete(key, result)
 	case actionSkip:
 		result.Status = statusSkip
-		r.resultsChan <- result
-		delete(r.results, key)
+		r.complete(key, result)
 	case actionOutput:
 		result.Output.WriteString(ev.Output)
 	}
 }
 
+// complete reports a finished test. A subtest (TestFoo/case) is attached to its running
+// parent so that the parent is reported first and the subtest can reference its ID.
+func (r *Reporter) complete(key TestResultKey, result *TestResult) {
+	delete(r.results, key)
+
+	if idx := strings.LastIndex(result.Test, "/"); idx != -1 {
+		parentKey := TestResultKey{Package: result.Package, Test: result.Test[:idx]}
+		if parent, ok := r.results[parentKey]; ok {
+			result.ParentId = parent.Id
+			parent.Subtests = append(parent.Subtests, result)
+			return
+		}
+	}
+
+	r.emit(result)
+}
+
+func (r *Reporter) emit(result *TestResult) {
+	r.resultsChan <- result
+	for _, subtest := range result.Subtests {
+		r.emit(subtest)
+	}
+}
+
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
 func run(ctx context.Context, c *cli.Command) error {
 	endpoint := c.String(ingressEndpointFlag)
 	apiKey := c.String(ingressAPIKeyFlag)
@@ -70,6 +70,11 @@ class Reporter {
         status,
         output,
         baggage,
+        failureMessage = null,
+        failureType = null,
+        stackTrace = null,
+        stdout = null,
+        stderr = null,
     ) {
         if (this.isShutdown) {
             throw new Error("Reporter has been shut down");
@@ -108,6 +113,18 @@ class Reporter {
         if (baggage !== null && typeof baggage !== "string") {
             throw new Error("baggage must be a nullable string");
         }
+        const failureFields = [
+            ["failureMessage", "failure_message", failureMessage],
+            ["failureType", "failure_type", failureType],
+            ["stackTrace", "stack_trace", stackTrace],
+            ["stdout", "stdout", stdout],
+            ["stderr", "stderr", stderr],
+        ];
+        for (const [, name, value] of failureFields) {
+            if (value !== null && typeof value !== "string") {
+                throw new Error(`${name} must be a nullable string`);
+            }
+        }
 
         const testcase = {
             sessionId: sessionId,
@@ -127,6 +144,11 @@ class Reporter {
         if (output !== null) {
             testcase.output = output;
         }
+        for (const [key, , value] of failureFields) {
+            if (value !== null) {
+                testcase[key] = value;
+            }
+        }
         if (baggage !== null) {
             try {
                 testcase.baggage = JSON.parse(baggage);
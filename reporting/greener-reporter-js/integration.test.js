@@ -91,6 +91,11 @@ describe.each(fixtureNames)("fixture test [%s]", (fixtureName) => {
                             p.status,
                             null,
                             null,
+                            p.failureMessage ?? null,
+                            p.failureType ?? null,
+                            p.stackTrace ?? null,
+                            p.stdout ?? null,
+                            p.stderr ?? null,
                         );
                     }
                 } else if (r.status === "error") {
@@ -104,6 +109,11 @@ describe.each(fixtureNames)("fixture test [%s]", (fixtureName) => {
                             p.status,
                             null,
                             null,
+                            p.failureMessage ?? null,
+                            p.failureType ?? null,
+                            p.stackTrace ?? null,
+                            p.stdout ?? null,
+                            p.stderr ?? null,
                         );
                     }
                     await reporter.shutdown();
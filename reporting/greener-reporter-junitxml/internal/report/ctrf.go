@@ -0,0 +1,115 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ctrfParser reads Common Test Report Format (CTRF) JSON reports.
+type ctrfParser struct{}
+
+type ctrfReport struct {
+	Results *struct {
+		Tests []ctrfTest `json:"tests"`
+	} `json:"results"`
+}
+
+type ctrfTest struct {
+	Name     string          `json:"name"`
+	Status   string          `json:"status"`
+	Duration *float64        `json:"duration"`
+	Message  string          `json:"message"`
+	Trace    string          `json:"trace"`
+	Suite    json.RawMessage `json:"suite"`
+	FilePath string          `json:"filePath"`
+	Stdout   []string        `json:"stdout"`
+	Stderr   []string        `json:"stderr"`
+	Tags     []string        `json:"tags"`
+}
+
+func (ctrfParser) Format() string { return "ctrf" }
+
+func (ctrfParser) Detect(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return false
+	}
+	var report ctrfReport
+	return json.Unmarshal(data, &report) == nil && report.Results != nil && report.Results.Tests != nil
+}
+
+func (ctrfParser) Parse(data []byte) ([]Result, error) {
+	var report ctrfReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("ctrf: %w", err)
+	}
+	if report.Results == nil {
+		return nil, fmt.Errorf("ctrf: missing results")
+	}
+
+	results := make([]Result, 0, len(report.Results.Tests))
+	for _, test := range report.Results.Tests {
+		result := Result{
+			Name:   test.Name,
+			File:   test.FilePath,
+			Stdout: strings.Join(test.Stdout, "\n"),
+			Stderr: strings.Join(test.Stderr, "\n"),
+		}
+
+		switch test.Status {
+		case "passed":
+			result.Status = "pass"
+		case "failed":
+			result.Status = "fail"
+		case "skipped", "pending":
+			result.Status = "skip"
+		case "other":
+			result.Status = "error"
+		default:
+			return nil, fmt.Errorf("ctrf: unknown status %q of test %q", test.Status, test.Name)
+		}
+		if result.Status == "skip" {
+			result.Output = test.Message
+		} else {
+			result.FailureMessage = test.Message
+			result.StackTrace = test.Trace
+		}
+
+		suite, err := ctrfSuite(test.Suite)
+		if err != nil {
+			return nil, fmt.Errorf("ctrf: invalid suite of test %q", test.Name)
+		}
+		result.Testsuite = suite
+
+		if test.Duration != nil {
+			t := *test.Duration / 1000
+			result.Time = &t
+		}
+		if len(test.Tags) > 0 {
+			result.Properties = map[string]string{"tags": strings.Join(test.Tags, ",")}
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ctrfSuite reads a suite given either as a string or, in newer spec versions, as a list of
+// nested suite names, which are joined with " > ".
+func ctrfSuite(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	var suite string
+	if err := json.Unmarshal(raw, &suite); err == nil {
+		return suite, nil
+	}
+
+	var suites []string
+	if err := json.Unmarshal(raw, &suites); err != nil {
+		return "", err
+	}
+	return strings.Join(suites, " > "), nil
+}
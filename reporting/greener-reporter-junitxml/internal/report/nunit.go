@@ -0,0 +1,131 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nunitParser reads NUnit 3 test results (TestResult.xml). Testcases are attributed to their
+// test assembly; the properties of a test case (e.g. Category) become its properties.
+type nunitParser struct{}
+
+type nunitTestRun struct {
+	Suites []nunitTestSuite `xml:"test-suite"`
+}
+
+type nunitTestSuite struct {
+	Type      string           `xml:"type,attr"`
+	Name      string           `xml:"name,attr"`
+	Suites    []nunitTestSuite `xml:"test-suite"`
+	Testcases []nunitTestCase  `xml:"test-case"`
+}
+
+type nunitTestCase struct {
+	Name       string     `xml:"name,attr"`
+	FullName   string     `xml:"fullname,attr"`
+	Classname  string     `xml:"classname,attr"`
+	Result     string     `xml:"result,attr"`
+	Label      string     `xml:"label,attr"`
+	Duration   string     `xml:"duration,attr"`
+	Properties []property `xml:"properties>property"`
+	Failure    struct {
+		Message    string `xml:"message"`
+		StackTrace string `xml:"stack-trace"`
+	} `xml:"failure"`
+	Reason struct {
+		Message string `xml:"message"`
+	} `xml:"reason"`
+	Output string `xml:"output"`
+}
+
+func (nunitParser) Format() string { return "nunit" }
+
+func (nunitParser) Detect(data []byte) bool {
+	return xmlRoot(data) == "test-run"
+}
+
+func (nunitParser) Parse(data []byte) ([]Result, error) {
+	var run nunitTestRun
+	if _, err := decodeXMLRoot("nunit", data, map[string]any{"test-run": &run}); err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, suite := range run.Suites {
+		var err error
+		results, err = appendNUnitSuite(results, suite, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func appendNUnitSuite(results []Result, suite nunitTestSuite, assembly string) ([]Result, error) {
+	if suite.Type == "Assembly" {
+		assembly = assemblyName(suite.Name)
+	}
+
+	for _, tc := range suite.Testcases {
+		result, err := nunitResult(tc, assembly)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	for _, child := range suite.Suites {
+		var err error
+		results, err = appendNUnitSuite(results, child, assembly)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func nunitResult(tc nunitTestCase, assembly string) (Result, error) {
+	result := Result{
+		Name:       tc.Name,
+		Classname:  tc.Classname,
+		Testsuite:  assembly,
+		Status:     nunitStatus(tc.Result, tc.Label),
+		Stdout:     strings.TrimSpace(tc.Output),
+		Properties: mergeProperties(nil, tc.Properties),
+	}
+	if result.Status == "" {
+		return Result{}, fmt.Errorf("nunit: unknown result %q of test case %q", tc.Result, tc.FullName)
+	}
+
+	switch result.Status {
+	case "fail", "error":
+		result.FailureMessage = strings.TrimSpace(tc.Failure.Message)
+		result.StackTrace = strings.TrimSpace(tc.Failure.StackTrace)
+	case "skip":
+		result.Output = strings.TrimSpace(tc.Reason.Message)
+	}
+
+	var err error
+	if result.Time, err = optionalSeconds(tc.Duration); err != nil {
+		return Result{}, fmt.Errorf("nunit: invalid duration %q of test case %q", tc.Duration, tc.FullName)
+	}
+	return result, nil
+}
+
+// nunitStatus maps a test case result and its label, e.g. Failed/Error, to a status.
+func nunitStatus(result, label string) string {
+	switch result {
+	case "Passed", "Warning":
+		return "pass"
+	case "Failed":
+		switch label {
+		case "Error", "Cancelled", "Invalid":
+			return "error"
+		}
+		return "fail"
+	case "Skipped", "Inconclusive":
+		return "skip"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,161 @@
+package report
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// trxParser reads Visual Studio test results (.trx), as written by `dotnet test --logger trx`.
+// Testcases are attributed to their test assembly.
+type trxParser struct{}
+
+type trxTestRun struct {
+	Definitions []trxUnitTest       `xml:"TestDefinitions>UnitTest"`
+	Results     []trxUnitTestResult `xml:"Results>UnitTestResult"`
+}
+
+type trxUnitTest struct {
+	ID         string        `xml:"id,attr"`
+	Storage    string        `xml:"storage,attr"`
+	Method     trxTestMethod `xml:"TestMethod"`
+	Properties []trxProperty `xml:"Properties>Property"`
+	Categories []trxCategory `xml:"TestCategory>TestCategoryItem"`
+}
+
+type trxCategory struct {
+	Name string `xml:"TestCategory,attr"`
+}
+
+type trxTestMethod struct {
+	ClassName string `xml:"className,attr"`
+	Name      string `xml:"name,attr"`
+}
+
+type trxProperty struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type trxUnitTestResult struct {
+	TestID   string `xml:"testId,attr"`
+	TestName string `xml:"testName,attr"`
+	Duration string `xml:"duration,attr"`
+	Outcome  string `xml:"outcome,attr"`
+	Output   struct {
+		StdOut    string `xml:"StdOut"`
+		StdErr    string `xml:"StdErr"`
+		ErrorInfo struct {
+			Message    string `xml:"Message"`
+			StackTrace string `xml:"StackTrace"`
+		} `xml:"ErrorInfo"`
+	} `xml:"Output"`
+}
+
+func (trxParser) Format() string { return "trx" }
+
+func (trxParser) Detect(data []byte) bool {
+	return xmlRoot(data) == "TestRun"
+}
+
+func (trxParser) Parse(data []byte) ([]Result, error) {
+	var run trxTestRun
+	if _, err := decodeXMLRoot("trx", data, map[string]any{"TestRun": &run}); err != nil {
+		return nil, err
+	}
+
+	definitions := make(map[string]trxUnitTest, len(run.Definitions))
+	for _, def := range run.Definitions {
+		definitions[def.ID] = def
+	}
+
+	results := make([]Result, 0, len(run.Results))
+	for _, r := range run.Results {
+		def := definitions[r.TestID]
+		result := Result{
+			Name:       r.TestName,
+			Classname:  def.Method.ClassName,
+			Testsuite:  assemblyName(def.Storage),
+			Status:     trxStatus(r.Outcome),
+			Stdout:     strings.TrimSpace(r.Output.StdOut),
+			Stderr:     strings.TrimSpace(r.Output.StdErr),
+			Properties: trxProperties(def),
+		}
+		if result.Status == "" {
+			return nil, fmt.Errorf("trx: unknown outcome %q of test %q", r.Outcome, r.TestName)
+		}
+
+		message := strings.TrimSpace(r.Output.ErrorInfo.Message)
+		if result.Status == "skip" {
+			result.Output = message
+		} else {
+			result.FailureMessage = message
+			result.StackTrace = strings.TrimSpace(r.Output.ErrorInfo.StackTrace)
+		}
+
+		if r.Duration != "" {
+			d, err := parseTRXDuration(r.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("trx: invalid duration %q of test %q", r.Duration, r.TestName)
+			}
+			result.Time = &d
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func trxStatus(outcome string) string {
+	switch outcome {
+	case "Passed", "PassedButRunAborted", "Warning", "Completed":
+		return "pass"
+	case "Failed":
+		return "fail"
+	case "Error", "Timeout", "Aborted":
+		return "error"
+	case "NotExecuted", "NotRunnable", "Inconclusive", "Pending", "Disconnected", "InProgress":
+		return "skip"
+	default:
+		return ""
+	}
+}
+
+func trxProperties(def trxUnitTest) map[string]string {
+	if len(def.Properties) == 0 && len(def.Categories) == 0 {
+		return nil
+	}
+
+	props := make(map[string]string, len(def.Properties)+1)
+	for _, p := range def.Properties {
+		props[p.Key] = p.Value
+	}
+	if len(def.Categories) > 0 {
+		categories := make([]string, 0, len(def.Categories))
+		for _, c := range def.Categories {
+			categories = append(categories, c.Name)
+		}
+		props["category"] = strings.Join(categories, ",")
+	}
+	return props
+}
+
+// parseTRXDuration converts a "hh:mm:ss.fffffff" duration to seconds.
+func parseTRXDuration(s string) (float64, error) {
+	var h, m int
+	var sec float64
+	if _, err := fmt.Sscanf(s, "%d:%d:%f", &h, &m, &sec); err != nil {
+		return 0, err
+	}
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+	return d.Seconds() + sec, nil
+}
+
+// assemblyName returns the file name of a test assembly path, which may use Windows separators.
+func assemblyName(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Base(strings.ReplaceAll(p, `\`, "/"))
+}
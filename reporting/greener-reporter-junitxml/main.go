@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +11,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/cephei8/reporting/greener-reporter-junitxml/internal/report"
 	"github.com/urfave/cli/v3"
 )
 
@@ -19,53 +19,13 @@ const (
 	ingressEndpointFlag    = "ingress-endpoint"
 	ingressAPIKeyFlag      = "ingress-api-key"
 	xmlFileFlag            = "xml-file"
+	formatFlag             = "format"
 	sessionIDFlag          = "session-id"
 	sessionDescriptionFlag = "session-description"
 	sessionLabelsFlag      = "session-labels"
 	sessionBaggageFlag     = "session-baggage"
 )
 
-type TestSuites struct {
-	XMLName    xml.Name    `xml:"testsuites"`
-	TestSuites []TestSuite `xml:"testsuite"`
-}
-
-type TestSuite struct {
-	Name      string     `xml:"name,attr"`
-	Tests     int        `xml:"tests,attr"`
-	Failures  int        `xml:"failures,attr"`
-	Errors    int        `xml:"errors,attr"`
-	Skipped   int        `xml:"skipped,attr"`
-	Time      string     `xml:"time,attr"`
-	Timestamp string     `xml:"timestamp,attr"`
-	TestCases []TestCase `xml:"testcase"`
-}
-
-type TestCase struct {
-	Name      string   `xml:"name,attr"`
-	Classname string   `xml:"classname,attr"`
-	Time      string   `xml:"time,attr"`
-	Failure   *Failure `xml:"failure,omitempty"`
-	Error     *Error   `xml:"error,omitempty"`
-	Skipped   *Skipped `xml:"skipped,omitempty"`
-}
-
-type Failure struct {
-	Message string `xml:"message,attr,omitempty"`
-	Type    string `xml:"type,attr,omitempty"`
-	Content string `xml:",chardata"`
-}
-
-type Error struct {
-	Message string `xml:"message,attr,omitempty"`
-	Type    string `xml:"type,attr,omitempty"`
-	Content string `xml:",chardata"`
-}
-
-type Skipped struct {
-	Message string `xml:"message,attr,omitempty"`
-}
-
 type Label struct {
 	Key   string `json:"key"`
 	Value string `json:"value,omitempty"`
@@ -90,6 +50,11 @@ type TestcaseRequest struct {
 	Testsuite         string         `json:"testsuite,omitempty"`
 	Status            string         `json:"status"`
 	Output            string         `json:"output,omitempty"`
+	FailureMessage    string         `json:"failureMessage,omitempty"`
+	FailureType       string         `json:"failureType,omitempty"`
+	StackTrace        string         `json:"stackTrace,omitempty"`
+	Stdout            string         `json:"stdout,omitempty"`
+	Stderr            string         `json:"stderr,omitempty"`
 	Baggage           map[string]any `json:"baggage,omitempty"`
 }
 
@@ -158,7 +123,7 @@ func (r *Reporter) createSession() error {
 	}
 
 	if req.Description == "" {
-		req.Description = "JUnit XML test report"
+		req.Description = "Test report"
 	}
 
 	body, err := json.Marshal(req)
@@ -195,34 +160,36 @@ func (r *Reporter) createSession() error {
 	return nil
 }
 
-func (r *Reporter) submitResults(testsuites TestSuites) error {
+func (r *Reporter) submitResults(results []report.Result) error {
 	var testcases []TestcaseRequest
 
-	for _, suite := range testsuites.TestSuites {
-		for _, tc := range suite.TestCases {
-			status := "pass"
-			var output string
-
-			if tc.Failure != nil {
-				status = "fail"
-				output = fmt.Sprintf("Failure: %s\n%s", tc.Failure.Message, tc.Failure.Content)
-			} else if tc.Error != nil {
-				status = "error"
-				output = fmt.Sprintf("Error: %s\n%s", tc.Error.Message, tc.Error.Content)
-			} else if tc.Skipped != nil {
-				status = "skip"
-				output = tc.Skipped.Message
-			}
+	for _, result := range results {
+		testcase := TestcaseRequest{
+			SessionId:         r.sessionId,
+			TestcaseName:      result.Name,
+			TestcaseClassname: result.Classname,
+			TestcaseFile:      result.File,
+			Testsuite:         result.Testsuite,
+			Status:            result.Status,
+			Output:            result.Output,
+			FailureMessage:    result.FailureMessage,
+			FailureType:       result.FailureType,
+			StackTrace:        result.StackTrace,
+			Stdout:            result.Stdout,
+			Stderr:            result.Stderr,
+		}
 
-			testcases = append(testcases, TestcaseRequest{
-				SessionId:         r.sessionId,
-				TestcaseName:      tc.Name,
-				TestcaseClassname: tc.Classname,
-				Testsuite:         suite.Name,
-				Status:            status,
-				Output:            output,
-			})
+		if result.Time != nil || len(result.Properties) > 0 {
+			testcase.Baggage = map[string]any{}
+			if result.Time != nil {
+				testcase.Baggage["time"] = *result.Time
+			}
+			if len(result.Properties) > 0 {
+				testcase.Baggage["properties"] = result.Properties
+			}
 		}
+
+		testcases = append(testcases, testcase)
 	}
 
 	if len(testcases) == 0 {
@@ -266,6 +233,7 @@ func run(ctx context.Context, c *cli.Command) error {
 	endpoint := c.String(ingressEndpointFlag)
 	apiKey := c.String(ingressAPIKeyFlag)
 	xmlFile := c.String(xmlFileFlag)
+	format := c.String(formatFlag)
 	sessionID := c.String(sessionIDFlag)
 	sessionDescription := c.String(sessionDescriptionFlag)
 	sessionLabelsStr := c.String(sessionLabelsFlag)
@@ -280,33 +248,37 @@ func run(ctx context.Context, c *cli.Command) error {
 		}
 	}
 
-	reporter := NewReporter(endpoint, apiKey, sessionID, sessionDescription, sessionLabels, sessionBaggage)
-
-	if err := reporter.createSession(); err != nil {
-		return fmt.Errorf("create session: %w", err)
+	if _, ok := report.Lookup(format); format != "" && !ok {
+		return fmt.Errorf("unknown report format %q, expected one of: %s", format, strings.Join(report.Formats(), ", "))
 	}
 
-	var xmlData []byte
+	var data []byte
 	var err error
 
 	if xmlFile == "-" {
-		xmlData, err = io.ReadAll(os.Stdin)
+		data, err = io.ReadAll(os.Stdin)
 		if err != nil {
 			return fmt.Errorf("read from stdin: %w", err)
 		}
 	} else {
-		xmlData, err = os.ReadFile(xmlFile)
+		data, err = os.ReadFile(xmlFile)
 		if err != nil {
 			return fmt.Errorf("read file %s: %w", xmlFile, err)
 		}
 	}
 
-	var testsuites TestSuites
-	if err := xml.Unmarshal(xmlData, &testsuites); err != nil {
-		return fmt.Errorf("parse XML: %w", err)
+	results, err := report.Parse(format, data)
+	if err != nil {
+		return fmt.Errorf("parse report: %w", err)
 	}
 
-	if err := reporter.submitResults(testsuites); err != nil {
+	reporter := NewReporter(endpoint, apiKey, sessionID, sessionDescription, sessionLabels, sessionBaggage)
+
+	if err := reporter.createSession(); err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+
+	if err := reporter.submitResults(results); err != nil {
 		return fmt.Errorf("submit results: %w", err)
 	}
 
@@ -316,7 +288,7 @@ func run(ctx context.Context, c *cli.Command) error {
 func main() {
 	cmd := &cli.Command{
 		Name:  "greener-reporter-junitxml",
-		Usage: "Report JUnit XML test results to Greener",
+		Usage: "Report JUnit XML and other test report formats to Greener",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:     ingressEndpointFlag,
@@ -332,10 +304,15 @@ func main() {
 			},
 			&cli.StringFlag{
 				Name:     xmlFileFlag,
-				Usage:    "Path to JUnit XML file (use '-' for stdin)",
+				Usage:    "Path to the report file (use '-' for stdin)",
 				Aliases:  []string{"f"},
 				Required: true,
 			},
+			&cli.StringFlag{
+				Name:    formatFlag,
+				Usage:   "Report format (" + strings.Join(report.Formats(), ", ") + "), detected from the content if not set",
+				Sources: cli.EnvVars("GREENER_REPORT_FORMAT"),
+			},
 			&cli.StringFlag{
 				Name:    sessionIDFlag,
 				Usage:   "Session ID (optional, will be generated if not provided)",
@@ -150,6 +150,11 @@ class Reporter:
         status: TestcaseStatus,
         output: Optional[str],
         baggage: Optional[dict],
+        failure_message: Optional[str] = None,
+        failure_type: Optional[str] = None,
+        stack_trace: Optional[str] = None,
+        stdout: Optional[str] = None,
+        stderr: Optional[str] = None,
     ):
         if self._closed:
             raise Error(
@@ -168,6 +173,14 @@ class Reporter:
             "output": output,
             "baggage": baggage,
         }
+        failure_data = {
+            "failureMessage": failure_message,
+            "failureType": failure_type,
+            "stackTrace": stack_trace,
+            "stdout": stdout,
+            "stderr": stderr,
+        }
+        testcase_data.update({k: v for k, v in failure_data.items() if v is not None})
 
         self._testcase_batch.append(testcase_data)
 
@@ -41,6 +41,11 @@ class CallReportItem(BaseModel):
     testsuite: Optional[str]
     status: CallReportStatus
     output: Optional[str]
+    failure_message: Optional[str] = None
+    failure_type: Optional[str] = None
+    stack_trace: Optional[str] = None
+    stdout: Optional[str] = None
+    stderr: Optional[str] = None
     baggage: Optional[dict]
 
 class CallReportPayload(BaseModel):
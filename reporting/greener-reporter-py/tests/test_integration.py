@@ -104,6 +104,11 @@ async def test_integration(reporter, servermock_context, servermock_calls, serve
                     }[p.status],
                     None,
                     None,
+                    failure_message=p.failure_message,
+                    failure_type=p.failure_type,
+                    stack_trace=p.stack_trace,
+                    stdout=p.stdout,
+                    stderr=p.stderr,
                 )
 
         async def success_handler():
@@ -31,7 +31,9 @@ void greener_reporter_testcase_create(
     struct greener_reporter *reporter, const char *session_id,
     const char *testcase_name, const char *testcase_classname,
     const char *testcase_file, const char *testsuite, const char *status,
-    const char *output, const char *baggage,
+    const char *output, const char *failure_message, const char *failure_type,
+    const char *stack_trace, const char *testcase_stdout,
+    const char *testcase_stderr, const char *baggage,
     const struct greener_reporter_error **error);
 
 void greener_reporter_session_delete(
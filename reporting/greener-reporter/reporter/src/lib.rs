@@ -58,6 +58,17 @@ fn set_error(err: ReporterError, err_result: *mut *const GreenerReporterError) {
     }
 }
 
+/// Copies a nullable C string.
+///
+/// # Safety
+/// The caller must ensure that the pointer is valid if not null.
+unsafe fn optional_string(s: *const c_char) -> Option<String> {
+    if s.is_null() {
+        return None;
+    }
+    Some(unsafe { CStr::from_ptr(s) }.to_string_lossy().to_string())
+}
+
 /// Creates a new Reporter instance.
 ///
 /// # Safety
@@ -249,6 +260,11 @@ pub unsafe extern "C" fn greener_reporter_testcase_create(
     testsuite: *const c_char,
     status: *const c_char,
     output: *const c_char,
+    failure_message: *const c_char,
+    failure_type: *const c_char,
+    stack_trace: *const c_char,
+    testcase_stdout: *const c_char,
+    testcase_stderr: *const c_char,
     baggage: *const c_char,
     error: *mut *const GreenerReporterError,
 ) {
@@ -308,6 +324,11 @@ pub unsafe extern "C" fn greener_reporter_testcase_create(
     } else {
         None
     };
+    let failure_message = unsafe { optional_string(failure_message) };
+    let failure_type = unsafe { optional_string(failure_type) };
+    let stack_trace = unsafe { optional_string(stack_trace) };
+    let stdout = unsafe { optional_string(testcase_stdout) };
+    let stderr = unsafe { optional_string(testcase_stderr) };
     let baggage = if !baggage.is_null() {
         let json_str = unsafe { CStr::from_ptr(baggage) }
             .to_string_lossy()
@@ -352,6 +373,11 @@ pub unsafe extern "C" fn greener_reporter_testcase_create(
         testsuite,
         status,
         output,
+        failure_message,
+        failure_type,
+        stack_trace,
+        stdout,
+        stderr,
         baggage,
     };
 
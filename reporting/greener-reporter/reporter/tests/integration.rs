@@ -183,6 +183,11 @@ fn make_call(reporter: &Reporter, call: &Value, responses: &Value) {
                         _ => TestcaseStatus::Pass,
                     },
                     output: None,
+                    failure_message: tc["failureMessage"].as_str().map(|s| s.to_string()),
+                    failure_type: tc["failureType"].as_str().map(|s| s.to_string()),
+                    stack_trace: tc["stackTrace"].as_str().map(|s| s.to_string()),
+                    stdout: tc["stdout"].as_str().map(|s| s.to_string()),
+                    stderr: tc["stderr"].as_str().map(|s| s.to_string()),
                     baggage: None,
                 };
                 let result = reporter.add_testcase(testcase);
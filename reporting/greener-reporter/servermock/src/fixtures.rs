@@ -142,6 +142,45 @@ pub fn create_fixtures() -> Vec<(String, Fixture)> {
                 },
             },
         ),
+        (
+            "reportFailure".to_string(),
+            Fixture {
+                calls: vec![Call {
+                    func: "report".to_string(),
+                    payload: serde_json::json!({
+                        "testcases": [
+                            {
+                                "sessionId": "16af52dc-3296-4249-be93-3aaef3a85111",
+                                "testcaseName": "test_some_logic",
+                                "testcaseClassname": "my_class",
+                                "testcaseFile": "my_file.py",
+                                "testsuite": "some test suite",
+                                "status": "fail",
+                                "output": null,
+                                "failureMessage": "assert 1 == 2",
+                                "failureType": "AssertionError",
+                                "stackTrace": "my_file.py:10: AssertionError",
+                                "stdout": "some output",
+                                "stderr": "some error output",
+                                "baggage": null
+                            }
+                        ]
+                    }),
+                }],
+                responses: Responses {
+                    create_session_response: Response {
+                        status: "success".to_string(),
+                        payload: Some(serde_json::json!({
+                            "id": "16af52dc-3296-4249-be93-3aaef3a85845"
+                        })),
+                    },
+                    report_response: Response {
+                        status: "success".to_string(),
+                        payload: None,
+                    },
+                },
+            },
+        ),
         (
             "reportNameOnly".to_string(),
             Fixture {
@@ -1,5 +1,5 @@
 use serde_json::Value;
-use std::ffi::{CStr, CString};
+use std::ffi::{c_char, CStr, CString};
 use std::ptr;
 use tests_ffi::*;
 
@@ -241,6 +241,16 @@ fn make_call(reporter: *mut greener_reporter, call: &Value, responses: &str) {
                         testsuite_ptr = cstr.as_ptr();
                     }
 
+                    let failure_c: Vec<Option<CString>> =
+                        ["failureMessage", "failureType", "stackTrace", "stdout", "stderr"]
+                            .iter()
+                            .map(|k| p[*k].as_str().map(|s| CString::new(s).unwrap()))
+                            .collect();
+                    let failure_ptrs: Vec<*const c_char> = failure_c
+                        .iter()
+                        .map(|c| c.as_ref().map_or(ptr::null(), |c| c.as_ptr()))
+                        .collect();
+
                     let mut error: *const greener_reporter_error = ptr::null();
                     greener_reporter_testcase_create(
                         reporter,
@@ -251,6 +261,11 @@ fn make_call(reporter: *mut greener_reporter, call: &Value, responses: &str) {
                         testsuite_ptr,
                         status_c.as_ptr(),
                         ptr::null(),
+                        failure_ptrs[0],
+                        failure_ptrs[1],
+                        failure_ptrs[2],
+                        failure_ptrs[3],
+                        failure_ptrs[4],
                         ptr::null(),
                         &mut error as *mut _,
                     );
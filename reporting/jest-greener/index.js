@@ -1,6 +1,19 @@
 const { Reporter } = require("greener-reporter");
 const path = require("path");
 
+// failureFields returns the failure message, type and stack trace of a failed test
+// or test file, in the order createTestcase takes them.
+function failureFields(error, stackTrace) {
+    if (!error) {
+        return [null, null, stackTrace || null];
+    }
+    return [
+        typeof error.message === "string" ? error.message : null,
+        typeof error.name === "string" ? error.name : null,
+        stackTrace || (typeof error.stack === "string" ? error.stack : null),
+    ];
+}
+
 class GreenerReporter {
     constructor(globalConfig, reporterOptions, reporterContext) {
         this._reporter = null;
@@ -67,6 +80,7 @@ class GreenerReporter {
                         "error",
                         null,
                         null,
+                        ...failureFields(fileResults.testExecError),
                     );
                 }
             }
@@ -86,6 +100,13 @@ class GreenerReporter {
 
     onTestCaseResult(test, testCaseResult) {
         let status = { passed: "pass", failed: "fail" }[testCaseResult.status];
+        let failure = [];
+        if (status === "fail") {
+            failure = failureFields(
+                (testCaseResult.failureDetails || [])[0],
+                testCaseResult.failureMessages.join("\n"),
+            );
+        }
 
         try {
             this._reporter.createTestcase(
@@ -97,6 +118,7 @@ class GreenerReporter {
                 status,
                 null,
                 null,
+                ...failure,
             );
         } catch (error) {
             const endpoint =
@@ -116,6 +116,9 @@ function GreenerReporter(runner, options = {}) {
                 "fail",
                 null,
                 null,
+                err && typeof err.message === "string" ? err.message : null,
+                err && typeof err.name === "string" ? err.name : null,
+                err && typeof err.stack === "string" ? err.stack : null,
             );
         } catch (error) {
             const endpoint =
@@ -161,6 +161,13 @@ class GreenerReporter:
 
         yield
 
+    @pytest.hookimpl(wrapper=True)
+    def pytest_runtest_makereport(self, item, call):
+        report = yield
+        if call.excinfo is not None and report.failed:
+            report.greener_failure_type = call.excinfo.typename
+        return report
+
     @pytest.hookimpl(wrapper=True)
     def pytest_runtest_logreport(self, report):
         status = None
@@ -177,6 +184,7 @@ class GreenerReporter:
 
         if status:
             tc_file, tc_classname, tc_name = _parse_nodeid(report.nodeid)
+            failure_message, failure_type, stack_trace = _parse_failure(report)
             try:
                 future = asyncio.run_coroutine_threadsafe(
                     self.reporter.create_testcase(
@@ -188,6 +196,11 @@ class GreenerReporter:
                         status,
                         report.longreprtext,
                         None,
+                        failure_message=failure_message,
+                        failure_type=failure_type,
+                        stack_trace=stack_trace,
+                        stdout=report.capstdout or None,
+                        stderr=report.capstderr or None,
                     ),
                     self._loop
                 )
@@ -207,3 +220,14 @@ def _parse_nodeid(address: str) -> tuple[str, Optional[str], str]:
     tc_name = names[-1] + possible_open_bracket + params
 
     return tc_file, tc_classname, tc_name
+
+
+def _parse_failure(report) -> tuple[Optional[str], Optional[str], Optional[str]]:
+    if not report.failed:
+        return None, None, None
+
+    reprcrash = getattr(report.longrepr, "reprcrash", None)
+    failure_message = reprcrash.message if reprcrash is not None else None
+    failure_type = getattr(report, "greener_failure_type", None)
+
+    return failure_message, failure_type, report.longreprtext or None
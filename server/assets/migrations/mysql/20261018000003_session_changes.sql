@@ -0,0 +1,20 @@
+-- migrate:up
+
+CREATE TABLE session_changes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    session_id BINARY(16) NOT NULL,
+    field VARCHAR(32) NOT NULL,
+    `key` VARCHAR(255),
+    old_value TEXT,
+    new_value TEXT,
+    user_id BINARY(16) NOT NULL,
+    api_key_id BINARY(16),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (api_key_id) REFERENCES apikeys(id) ON DELETE SET NULL
+);
+
+CREATE INDEX ix_session_changes_session_id ON session_changes(session_id);
+
+-- migrate:down
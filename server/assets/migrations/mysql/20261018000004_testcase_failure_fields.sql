@@ -0,0 +1,11 @@
+-- migrate:up
+
+ALTER TABLE testcases ADD COLUMN failure_message LONGTEXT;
+ALTER TABLE testcases ADD COLUMN failure_type VARCHAR(255);
+ALTER TABLE testcases ADD COLUMN stack_trace LONGTEXT;
+ALTER TABLE testcases ADD COLUMN stdout LONGTEXT;
+ALTER TABLE testcases ADD COLUMN stderr LONGTEXT;
+
+CREATE INDEX ix_testcases_failure_type ON testcases(failure_type);
+
+-- migrate:down
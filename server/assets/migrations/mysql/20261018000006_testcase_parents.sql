@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE testcases ADD COLUMN parent_id BINARY(16);
+
+CREATE INDEX ix_testcases_parent_id ON testcases(parent_id);
+
+-- migrate:down
@@ -0,0 +1,19 @@
+-- migrate:up
+
+CREATE TABLE audit_log (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    action VARCHAR(64) NOT NULL,
+    outcome VARCHAR(16) NOT NULL,
+    actor_user_id BINARY(16),
+    actor_name VARCHAR(255) NOT NULL,
+    actor_api_key_id BINARY(16),
+    source_ip VARCHAR(45),
+    target_type VARCHAR(32),
+    target_id VARCHAR(255),
+    details TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ix_audit_log_created_at ON audit_log(created_at);
+
+-- migrate:down
@@ -0,0 +1,6 @@
+-- migrate:up
+
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN sessions_revoked_at TIMESTAMP NULL;
+
+-- migrate:down
@@ -0,0 +1,14 @@
+-- migrate:up
+
+CREATE TABLE login_failures (
+    kind VARCHAR(16) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NULL,
+    PRIMARY KEY (kind, name)
+);
+
+CREATE INDEX ix_login_failures_last_failed_at ON login_failures(last_failed_at);
+
+-- migrate:down
@@ -0,0 +1,13 @@
+-- migrate:up
+
+CREATE TABLE quota_usage (
+    user_id BINARY(16) NOT NULL,
+    project VARCHAR(255) NOT NULL DEFAULT '',
+    day VARCHAR(10) NOT NULL,
+    testcases BIGINT NOT NULL DEFAULT 0,
+    output_bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, project, day),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- migrate:down
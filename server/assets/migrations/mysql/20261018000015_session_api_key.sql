@@ -0,0 +1,5 @@
+-- migrate:up
+
+ALTER TABLE sessions ADD COLUMN api_key_id BINARY(16);
+
+-- migrate:down
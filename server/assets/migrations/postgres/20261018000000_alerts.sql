@@ -0,0 +1,35 @@
+-- migrate:up
+
+CREATE TABLE alert_rules (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    query TEXT NOT NULL,
+    aggregate VARCHAR(32) NOT NULL,
+    operator VARCHAR(8) NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    window_seconds BIGINT NOT NULL,
+    state VARCHAR(32) NOT NULL DEFAULT 'ok',
+    last_value DOUBLE PRECISION,
+    last_evaluated_at TIMESTAMP WITH TIME ZONE,
+    state_changed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id UUID NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ix_alert_rules_user_id ON alert_rules(user_id);
+
+
+CREATE TABLE alert_events (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id UUID NOT NULL,
+    state VARCHAR(32) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ix_alert_events_rule_id ON alert_events(rule_id);
+
+-- migrate:down
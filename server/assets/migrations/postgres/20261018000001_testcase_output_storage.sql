@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE testcases ADD COLUMN output_data BYTEA;
+ALTER TABLE testcases ADD COLUMN output_encoding VARCHAR(16);
+ALTER TABLE testcases ADD COLUMN output_ref VARCHAR(255);
+
+-- migrate:down
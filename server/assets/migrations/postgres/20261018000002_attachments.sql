@@ -0,0 +1,21 @@
+-- migrate:up
+
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL,
+    testcase_id UUID,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_ref VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id UUID NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (testcase_id) REFERENCES testcases(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ix_attachments_session_id ON attachments(session_id);
+CREATE INDEX ix_attachments_testcase_id ON attachments(testcase_id);
+
+-- migrate:down
@@ -0,0 +1,20 @@
+-- migrate:up
+
+CREATE TABLE session_changes (
+    id BIGSERIAL PRIMARY KEY,
+    session_id UUID NOT NULL,
+    field VARCHAR(32) NOT NULL,
+    key VARCHAR(255),
+    old_value TEXT,
+    new_value TEXT,
+    user_id UUID NOT NULL,
+    api_key_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (api_key_id) REFERENCES apikeys(id) ON DELETE SET NULL
+);
+
+CREATE INDEX ix_session_changes_session_id ON session_changes(session_id);
+
+-- migrate:down
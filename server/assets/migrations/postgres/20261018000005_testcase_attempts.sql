@@ -0,0 +1,13 @@
+-- migrate:up
+
+ALTER TABLE testcases ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE testcases ADD COLUMN retry_of UUID;
+ALTER TABLE testcases ADD COLUMN superseded BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Make room for the "flaky" status between "fail" and "pass" so that
+-- MIN(status) keeps aggregating to the worst outcome.
+UPDATE testcases SET status = status + 1 WHERE status >= 2;
+
+CREATE INDEX ix_testcases_session_id_name ON testcases(session_id, name);
+
+-- migrate:down
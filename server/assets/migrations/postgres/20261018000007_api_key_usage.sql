@@ -0,0 +1,16 @@
+-- migrate:up
+
+CREATE TABLE api_key_usage (
+    api_key_id UUID NOT NULL,
+    day VARCHAR(10) NOT NULL,
+    user_id UUID NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    testcases BIGINT NOT NULL DEFAULT 0,
+    output_bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, day),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ix_api_key_usage_user_id_day ON api_key_usage(user_id, day);
+
+-- migrate:down
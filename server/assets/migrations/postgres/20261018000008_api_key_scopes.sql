@@ -0,0 +1,12 @@
+-- migrate:up
+
+ALTER TABLE apikeys ADD COLUMN scope VARCHAR(16) NOT NULL DEFAULT 'ingest';
+ALTER TABLE apikeys ADD COLUMN project VARCHAR(255);
+ALTER TABLE apikeys ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE apikeys ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE apikeys ADD COLUMN last_used_ip VARCHAR(45);
+ALTER TABLE apikeys ADD COLUMN previous_secret_salt BYTEA;
+ALTER TABLE apikeys ADD COLUMN previous_secret_hash BYTEA;
+ALTER TABLE apikeys ADD COLUMN previous_secret_expires_at TIMESTAMP WITH TIME ZONE;
+
+-- migrate:down
@@ -0,0 +1,5 @@
+-- migrate:up
+
+ALTER TABLE apikeys ADD COLUMN secret_algorithm VARCHAR(16) NOT NULL DEFAULT 'pbkdf2';
+
+-- migrate:down
@@ -0,0 +1,8 @@
+-- migrate:up
+
+ALTER TABLE users ADD COLUMN auth_source VARCHAR(32);
+ALTER TABLE users ADD COLUMN external_id VARCHAR(255);
+
+CREATE UNIQUE INDEX ix_users_auth_source_external_id ON users(auth_source, external_id);
+
+-- migrate:down
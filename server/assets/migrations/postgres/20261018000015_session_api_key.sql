@@ -0,0 +1,5 @@
+-- migrate:up
+
+ALTER TABLE sessions ADD COLUMN api_key_id UUID;
+
+-- migrate:down
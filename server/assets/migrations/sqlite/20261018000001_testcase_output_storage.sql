@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE testcases ADD COLUMN output_data BLOB;
+ALTER TABLE testcases ADD COLUMN output_encoding TEXT;
+ALTER TABLE testcases ADD COLUMN output_ref TEXT;
+
+-- migrate:down
@@ -0,0 +1,20 @@
+-- migrate:up
+
+CREATE TABLE session_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    field TEXT NOT NULL,
+    key TEXT,
+    old_value TEXT,
+    new_value TEXT,
+    user_id TEXT NOT NULL,
+    api_key_id TEXT,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (api_key_id) REFERENCES apikeys(id) ON DELETE SET NULL
+);
+
+CREATE INDEX ix_session_changes_session_id ON session_changes(session_id);
+
+-- migrate:down
@@ -0,0 +1,11 @@
+-- migrate:up
+
+ALTER TABLE testcases ADD COLUMN failure_message TEXT;
+ALTER TABLE testcases ADD COLUMN failure_type TEXT;
+ALTER TABLE testcases ADD COLUMN stack_trace TEXT;
+ALTER TABLE testcases ADD COLUMN stdout TEXT;
+ALTER TABLE testcases ADD COLUMN stderr TEXT;
+
+CREATE INDEX ix_testcases_failure_type ON testcases(failure_type);
+
+-- migrate:down
@@ -0,0 +1,16 @@
+-- migrate:up
+
+CREATE TABLE api_key_usage (
+    api_key_id TEXT NOT NULL,
+    day TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    testcases INTEGER NOT NULL DEFAULT 0,
+    output_bytes INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, day),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ix_api_key_usage_user_id_day ON api_key_usage(user_id, day);
+
+-- migrate:down
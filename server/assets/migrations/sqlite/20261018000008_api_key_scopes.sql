@@ -0,0 +1,12 @@
+-- migrate:up
+
+ALTER TABLE apikeys ADD COLUMN scope VARCHAR(16) NOT NULL DEFAULT 'ingest';
+ALTER TABLE apikeys ADD COLUMN project VARCHAR(255);
+ALTER TABLE apikeys ADD COLUMN expires_at TEXT;
+ALTER TABLE apikeys ADD COLUMN last_used_at TEXT;
+ALTER TABLE apikeys ADD COLUMN last_used_ip VARCHAR(45);
+ALTER TABLE apikeys ADD COLUMN previous_secret_salt BLOB;
+ALTER TABLE apikeys ADD COLUMN previous_secret_hash BLOB;
+ALTER TABLE apikeys ADD COLUMN previous_secret_expires_at TEXT;
+
+-- migrate:down
@@ -0,0 +1,19 @@
+-- migrate:up
+
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    actor_user_id TEXT,
+    actor_name TEXT NOT NULL,
+    actor_api_key_id TEXT,
+    source_ip TEXT,
+    target_type TEXT,
+    target_id TEXT,
+    details TEXT,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ix_audit_log_created_at ON audit_log(created_at);
+
+-- migrate:down
@@ -0,0 +1,6 @@
+-- migrate:up
+
+ALTER TABLE users ADD COLUMN disabled_at TEXT;
+ALTER TABLE users ADD COLUMN sessions_revoked_at TEXT;
+
+-- migrate:down
@@ -0,0 +1,14 @@
+-- migrate:up
+
+CREATE TABLE login_failures (
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TEXT NOT NULL,
+    locked_until TEXT,
+    PRIMARY KEY (kind, name)
+);
+
+CREATE INDEX ix_login_failures_last_failed_at ON login_failures(last_failed_at);
+
+-- migrate:down
@@ -0,0 +1,13 @@
+-- migrate:up
+
+CREATE TABLE quota_usage (
+    user_id TEXT NOT NULL,
+    project TEXT NOT NULL DEFAULT '',
+    day TEXT NOT NULL,
+    testcases INTEGER NOT NULL DEFAULT 0,
+    output_bytes INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, project, day),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- migrate:down
@@ -0,0 +1,9 @@
+-- migrate:up
+
+-- The status a testcase was reported with, before its children rolled up into status,
+-- so that a parent can be recomputed when a child is retried.
+ALTER TABLE testcases ADD COLUMN reported_status INTEGER;
+
+UPDATE testcases SET reported_status = status;
+
+-- migrate:down
@@ -5,8 +5,8 @@ if (typeof Prism !== "undefined") {
         keyword: /\b(?:and|or|offset|limit|start_date|end_date)\b/i,
         function: /\b(?:group_by|group)\b/i,
         identifier:
-            /\b(?:session_id|id|name|status|classname|testsuite|file)\b/i,
-        status: /\b(?:pass|fail|error|skip)\b/i,
+            /\b(?:session_id|id|name|status|classname|testsuite|file|failure_type)\b/i,
+        status: /\b(?:pass|fail|error|skip|flaky)\b/i,
         operator: /!=|=/,
         punctuation: /[(),]/,
     };
@@ -20,6 +20,7 @@ const suggestions = [
     { label: "classname", type: "field", desc: "Test class name" },
     { label: "testsuite", type: "field", desc: "Test suite name" },
     { label: "file", type: "field", desc: "File path" },
+    { label: "failure_type", type: "field", desc: "Failure type" },
     { label: "and", type: "keyword", desc: "Logical AND" },
     { label: "or", type: "keyword", desc: "Logical OR" },
     { label: "offset", type: "keyword", desc: "Skip first N results" },
@@ -50,6 +51,7 @@ const suggestions = [
     { label: "fail", type: "value", desc: "Failed status" },
     { label: "error", type: "value", desc: "Error status" },
     { label: "skip", type: "value", desc: "Skipped status" },
+    { label: "flaky", type: "value", desc: "Passed on retry" },
     { label: '#"label"', type: "tag", desc: "Tag/label query", insert: '#""' },
 ];
 
@@ -2,18 +2,26 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/sha256"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/user"
 	"time"
 
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/blob"
 	"github.com/cephei8/greener/server/core/dbutil"
+	"github.com/cephei8/greener/server/core/lockout"
 	"github.com/cephei8/greener/server/core/model/db"
-	"github.com/google/uuid"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/labstack/echo/v4"
+	gommonlog "github.com/labstack/gommon/log"
+	"github.com/uptrace/bun"
 	"github.com/urfave/cli/v3"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 func main() {
@@ -44,12 +52,114 @@ func main() {
 					},
 					&cli.StringFlag{
 						Name:  "role",
-						Usage: "User role (editor or viewer)",
+						Usage: "User role (admin, editor or viewer)",
 						Value: "viewer",
 					},
+					&cli.IntFlag{
+						Name:  "password-min-length",
+						Usage: "Reject passwords shorter than this",
+					},
+					&cli.IntFlag{
+						Name:  "password-min-classes",
+						Usage: "Reject passwords with fewer character classes (lowercase, uppercase, digits, other) than this",
+					},
+					&cli.BoolFlag{
+						Name:  "password-reject-username",
+						Usage: "Reject passwords containing the username",
+					},
 				},
 				Action: createUserAction,
 			},
+			{
+				Name:  "unlock-user",
+				Usage: "End the lockout of a user after too many failed logins",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "username",
+						Usage:    "User name",
+						Required: true,
+					},
+				},
+				Action: unlockUserAction,
+			},
+			{
+				Name:  "purge",
+				Usage: "Delete sessions (with their labels and testcases) according to a retention policy",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only report what would be deleted",
+					},
+					&cli.StringFlag{
+						Name:  "max-age",
+						Usage: "Delete sessions older than this (e.g. 90d, 720h)",
+					},
+					&cli.IntFlag{
+						Name:  "max-sessions",
+						Usage: "Maximum number of sessions to keep per label combination",
+					},
+					&cli.StringSliceFlag{
+						Name:  "group-labels",
+						Usage: "Label keys forming a combination for --max-sessions",
+					},
+					&cli.StringFlag{
+						Name:  "keep",
+						Usage: "Sessions to always keep, e.g. branch=main:50,release:10",
+					},
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Usage: "Number of sessions deleted per batch",
+						Value: retention.DefaultBatchSize,
+					},
+					&cli.StringFlag{
+						Name:  "output-store-dir",
+						Usage: "Directory testcase output is offloaded to (offloaded output of purged testcases is deleted)",
+					},
+					&cli.StringFlag{
+						Name:  "attachments-dir",
+						Usage: "Directory attachments are stored in (attachments of purged sessions are deleted)",
+					},
+				},
+				Action: purgeAction,
+			},
+			{
+				Name:  "audit",
+				Usage: "Inspect the audit log",
+				Commands: []*cli.Command{
+					{
+						Name:  "export",
+						Usage: "Export audit log entries, oldest first",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "Output format (jsonl or csv)",
+								Value: audit.FormatJSONL,
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "File to write to (default: standard output)",
+							},
+							&cli.StringFlag{
+								Name:  "since",
+								Usage: "Only entries at or after this time (RFC 3339 or YYYY-MM-DD)",
+							},
+							&cli.StringFlag{
+								Name:  "until",
+								Usage: "Only entries before this time (RFC 3339 or YYYY-MM-DD)",
+							},
+							&cli.StringFlag{
+								Name:  "action",
+								Usage: "Only this action and the actions under it, e.g. apikey",
+							},
+							&cli.StringFlag{
+								Name:  "actor",
+								Usage: "Only entries of this actor",
+							},
+						},
+						Action: auditExportAction,
+					},
+				},
+			},
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			url := cmd.String("db-url")
@@ -77,48 +187,219 @@ func createUserAction(ctx context.Context, cmd *cli.Command) error {
 	password := cmd.String("password")
 	roleStr := cmd.String("role")
 
-	role := model_db.UserRole(roleStr)
-	if role != model_db.RoleEditor && role != model_db.RoleViewer {
-		return fmt.Errorf("invalid role: %s (must be 'editor' or 'viewer')", roleStr)
+	role, err := model_db.ParseUserRole(roleStr)
+	if err != nil {
+		return fmt.Errorf("invalid role: %s (must be 'admin', 'editor' or 'viewer')", roleStr)
 	}
 
+	policy := core.PasswordPolicy{
+		MinLength:      cmd.Int("password-min-length"),
+		MinClasses:     cmd.Int("password-min-classes"),
+		RejectUsername: cmd.Bool("password-reject-username"),
+	}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	// the CLI does not guard logins or cache API keys
+	security := core.NewSecurity(lockout.Policy{}, policy, 0)
+
 	db, err := dbutil.Init(url)
 	if err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer db.Close()
 
-	salt, passwordHash, err := hashPassword(password)
+	if _, err := core.CreateUser(ctx, db, security, gommonlog.New("greener-admin"), cliActor(), username, password, role); err != nil {
+		return fmt.Errorf("failed to create user: %s", errorMessage(err))
+	}
+
+	fmt.Printf("User created successfully: %s (role: %s)\n", username, role)
+	return nil
+}
+
+func unlockUserAction(ctx context.Context, cmd *cli.Command) error {
+	username := cmd.String("username")
+
+	db, err := dbutil.Init(cmd.String("db-url"))
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
+	defer db.Close()
 
-	user := &model_db.User{
-		ID:           model_db.BinaryUUID(uuid.New()),
-		Username:     username,
-		PasswordSalt: salt,
-		PasswordHash: passwordHash,
-		Role:         role,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+	var u model_db.User
+	err = db.NewSelect().
+		Model(&u).
+		Column("id").
+		Where("? = ?", bun.Ident("username"), username).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %s not found", username)
 	}
-	_, err = db.NewInsert().Model(user).Exec(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create user: %w", err)
+		return fmt.Errorf("failed to find user: %w", err)
 	}
 
-	fmt.Printf("User created successfully: %s (role: %s)\n", username, role)
+	_, unlocked, err := core.UnlockUser(ctx, db, core.NewSecurity(lockout.Policy{}, core.PasswordPolicy{}, 0), gommonlog.New("greener-admin"), cliActor(), u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to unlock user: %s", errorMessage(err))
+	}
+	if !unlocked {
+		fmt.Printf("User %s was not locked out\n", username)
+		return nil
+	}
+	fmt.Printf("User unlocked successfully: %s\n", username)
+	return nil
+}
+
+func purgeAction(ctx context.Context, cmd *cli.Command) error {
+	url := cmd.String("db-url")
+	dryRun := cmd.Bool("dry-run")
+
+	policy := retention.Policy{
+		MaxSessions: cmd.Int("max-sessions"),
+		GroupLabels: cmd.StringSlice("group-labels"),
+		BatchSize:   cmd.Int("batch-size"),
+	}
+
+	if maxAge := cmd.String("max-age"); maxAge != "" {
+		d, err := core.ParseDuration(maxAge)
+		if err != nil {
+			return err
+		}
+		policy.MaxAge = d
+	}
+
+	keep, err := retention.ParseKeepRules(cmd.String("keep"))
+	if err != nil {
+		return err
+	}
+	policy.Keep = keep
+
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if policy.IsEmpty() {
+		return fmt.Errorf("retention policy is empty: specify --max-age and/or --max-sessions")
+	}
+
+	db, err := dbutil.Init(url)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	var stores retention.Stores
+	if dir := cmd.String("output-store-dir"); dir != "" {
+		store, err := blob.NewFSStore(dir)
+		if err != nil {
+			return err
+		}
+		stores.Outputs, err = output.NewStorage(output.Options{Store: store})
+		if err != nil {
+			return err
+		}
+	}
+	if dir := cmd.String("attachments-dir"); dir != "" {
+		stores.Attachments, err = blob.NewFSStore(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	report, err := retention.NewPurger(db, policy, stores).Purge(ctx, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to purge: %w", err)
+	}
+
+	for _, id := range report.SessionIDs {
+		fmt.Printf("  %s\n", id)
+	}
+	fmt.Println(report)
+
+	if !dryRun && report.Sessions > 0 {
+		err := audit.Record(ctx, db, cliActor(), audit.Event{
+			Action:  audit.ActionSessionPurge,
+			Details: report.String(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record audit entry: %w", err)
+		}
+	}
 	return nil
 }
 
-func hashPassword(password string) (salt, hash []byte, err error) {
-	saltBytes := make([]byte, 32)
-	_, err = rand.Read(saltBytes)
+func auditExportAction(ctx context.Context, cmd *cli.Command) error {
+	filter := audit.Filter{
+		Action: cmd.String("action"),
+		Actor:  cmd.String("actor"),
+	}
+	var err error
+	if since := cmd.String("since"); since != "" {
+		if filter.Since, err = parseTime(since); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if until := cmd.String("until"); until != "" {
+		if filter.Until, err = parseTime(until); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	format := cmd.String("format")
+	if format != audit.FormatJSONL && format != audit.FormatCSV {
+		return fmt.Errorf("invalid format: %s (must be 'jsonl' or 'csv')", format)
+	}
+
+	db, err := dbutil.Init(cmd.String("db-url"))
 	if err != nil {
-		return nil, nil, err
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
+	defer db.Close()
 
-	hashBytes := pbkdf2.Key([]byte(password), saltBytes, 100000, 32, sha256.New)
+	out := os.Stdout
+	if path := cmd.String("output"); path != "" {
+		out, err = os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
 
-	return saltBytes, hashBytes, nil
+	count, err := audit.Export(ctx, db, out, format, filter)
+	if err != nil {
+		return fmt.Errorf("failed to export audit log: %w", err)
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d audit entries to %s\n", count, out.Name())
+	}
+	return nil
+}
+
+// parseTime parses an RFC 3339 time or a date, which means midnight UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// cliActor is the actor of changes made with this utility, named after the OS user running it.
+func cliActor() audit.Actor {
+	name := "greener-admin"
+	if u, err := user.Current(); err == nil {
+		name += ":" + u.Username
+	}
+	return audit.Actor{Name: name}
+}
+
+// errorMessage returns the message of the *echo.HTTPError returned by core functions.
+func errorMessage(err error) string {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return fmt.Sprint(he.Message)
+	}
+	return err.Error()
 }
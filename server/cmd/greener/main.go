@@ -1,34 +1,127 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cephei8/greener/server/assets"
 	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/alerts"
+	"github.com/cephei8/greener/server/core/attachments"
+	"github.com/cephei8/greener/server/core/blob"
+	"github.com/cephei8/greener/server/core/clientip"
 	"github.com/cephei8/greener/server/core/dbutil"
+	"github.com/cephei8/greener/server/core/ldap"
+	"github.com/cephei8/greener/server/core/lockout"
 	"github.com/cephei8/greener/server/core/mcp"
+	"github.com/cephei8/greener/server/core/metrics"
+	model_db "github.com/cephei8/greener/server/core/model/db"
 	"github.com/cephei8/greener/server/core/oauth"
+	"github.com/cephei8/greener/server/core/oidc"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/queue"
+	"github.com/cephei8/greener/server/core/quota"
+	"github.com/cephei8/greener/server/core/retention"
 	"github.com/cephei8/greener/server/core/sse"
+	"github.com/cephei8/greener/server/core/tracing"
 	"github.com/caarlos0/env/v11"
 	"github.com/gorilla/sessions"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/uptrace/bun"
 )
 
 type Config struct {
-	DatabaseURL                 string `env:"GREENER_DATABASE_URL"`
-	AuthSecret                  string `env:"GREENER_AUTH_SECRET"`
-	AuthIssuer                  string `env:"GREENER_AUTH_ISSUER"`
-	Port                        int    `env:"GREENER_PORT" envDefault:"8080"`
-	Verbose                     bool   `env:"GREENER_VERBOSE_OUTPUT"`
-	AllowUnauthenticatedViewers bool   `env:"GREENER_ALLOW_UNAUTHENTICATED_VIEWERS"`
+	DatabaseURL                 string        `env:"GREENER_DATABASE_URL"`
+	AuthSecret                  string        `env:"GREENER_AUTH_SECRET"`
+	AuthIssuer                  string        `env:"GREENER_AUTH_ISSUER"`
+	Port                        int           `env:"GREENER_PORT" envDefault:"8080"`
+	GRPCPort                    int           `env:"GREENER_GRPC_PORT"`
+	Verbose                     bool          `env:"GREENER_VERBOSE_OUTPUT"`
+	AllowUnauthenticatedViewers bool          `env:"GREENER_ALLOW_UNAUTHENTICATED_VIEWERS"`
+	TrustedProxies              []string      `env:"GREENER_TRUSTED_PROXIES"`
+	AlertInterval               time.Duration `env:"GREENER_ALERT_INTERVAL" envDefault:"1m"`
+	AlertWebhookURL             string        `env:"GREENER_ALERT_WEBHOOK_URL"`
+	MetricsBearerToken          string        `env:"GREENER_METRICS_BEARER_TOKEN"`
+	MetricsPassRateLabels       []string      `env:"GREENER_METRICS_PASS_RATE_LABELS"`
+	TracingEnabled              bool          `env:"GREENER_TRACING_ENABLED"`
+	RetentionMaxAge             string        `env:"GREENER_RETENTION_MAX_AGE"`
+	RetentionMaxSessions        int           `env:"GREENER_RETENTION_MAX_SESSIONS"`
+	RetentionGroupLabels        []string      `env:"GREENER_RETENTION_GROUP_LABELS"`
+	RetentionKeep               string        `env:"GREENER_RETENTION_KEEP"`
+	RetentionInterval           time.Duration `env:"GREENER_RETENTION_INTERVAL" envDefault:"1h"`
+	OutputCompression           string        `env:"GREENER_OUTPUT_COMPRESSION" envDefault:"gzip"`
+	OutputMaxSize               int           `env:"GREENER_OUTPUT_MAX_SIZE" envDefault:"1048576"`
+	OutputStoreDir              string        `env:"GREENER_OUTPUT_STORE_DIR"`
+	OutputOffloadThreshold      int           `env:"GREENER_OUTPUT_OFFLOAD_THRESHOLD" envDefault:"4096"`
+	AttachmentsDir              string        `env:"GREENER_ATTACHMENTS_DIR"`
+	AttachmentMaxSize           int64         `env:"GREENER_ATTACHMENT_MAX_SIZE" envDefault:"10485760"`
+	IngressKeyRateLimit         float64       `env:"GREENER_INGRESS_KEY_RATE_LIMIT"`
+	IngressKeyBurst             int           `env:"GREENER_INGRESS_KEY_BURST"`
+	IngressUserRateLimit        float64       `env:"GREENER_INGRESS_USER_RATE_LIMIT"`
+	IngressUserBurst            int           `env:"GREENER_INGRESS_USER_BURST"`
+	IngressProjectRateLimit     float64       `env:"GREENER_INGRESS_PROJECT_RATE_LIMIT"`
+	IngressProjectBurst         int           `env:"GREENER_INGRESS_PROJECT_BURST"`
+	IngressDailyTestcases       int64         `env:"GREENER_INGRESS_DAILY_TESTCASES"`
+	IngressDailyOutputBytes     int64         `env:"GREENER_INGRESS_DAILY_OUTPUT_BYTES"`
+	IngressProjectTestcases     int64         `env:"GREENER_INGRESS_PROJECT_DAILY_TESTCASES"`
+	IngressProjectOutputBytes   int64         `env:"GREENER_INGRESS_PROJECT_DAILY_OUTPUT_BYTES"`
+	IngressMaxDecompressedSize  int64         `env:"GREENER_INGRESS_MAX_DECOMPRESSED_SIZE" envDefault:"268435456"`
+	IngressQueueDir             string        `env:"GREENER_INGRESS_QUEUE_DIR"`
+	IngressQueueWorkers         int           `env:"GREENER_INGRESS_QUEUE_WORKERS" envDefault:"4"`
+	IngressQueueBatchSize       int           `env:"GREENER_INGRESS_QUEUE_BATCH_SIZE" envDefault:"100"`
+	ShutdownTimeout             time.Duration `env:"GREENER_SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	APIKeyCacheSize             int           `env:"GREENER_API_KEY_CACHE_SIZE" envDefault:"10000"`
+	LoginFreeAttempts           int           `env:"GREENER_LOGIN_FREE_ATTEMPTS" envDefault:"3"`
+	LoginMaxAttempts            int           `env:"GREENER_LOGIN_MAX_ATTEMPTS" envDefault:"10"`
+	LoginIPFreeAttempts         int           `env:"GREENER_LOGIN_IP_FREE_ATTEMPTS" envDefault:"20"`
+	LoginIPMaxAttempts          int           `env:"GREENER_LOGIN_IP_MAX_ATTEMPTS" envDefault:"100"`
+	LoginDelay                  time.Duration `env:"GREENER_LOGIN_DELAY" envDefault:"1s"`
+	LoginMaxDelay               time.Duration `env:"GREENER_LOGIN_MAX_DELAY" envDefault:"1m"`
+	LoginLockout                time.Duration `env:"GREENER_LOGIN_LOCKOUT" envDefault:"15m"`
+	PasswordMinLength           int           `env:"GREENER_PASSWORD_MIN_LENGTH"`
+	PasswordMinClasses          int           `env:"GREENER_PASSWORD_MIN_CLASSES"`
+	PasswordRejectUsername      bool          `env:"GREENER_PASSWORD_REJECT_USERNAME"`
+	OIDCIssuer                  string        `env:"GREENER_OIDC_ISSUER"`
+	OIDCClientID                string        `env:"GREENER_OIDC_CLIENT_ID"`
+	OIDCClientSecret            string        `env:"GREENER_OIDC_CLIENT_SECRET"`
+	OIDCName                    string        `env:"GREENER_OIDC_NAME" envDefault:"SSO"`
+	OIDCScopes                  []string      `env:"GREENER_OIDC_SCOPES" envDefault:"profile,email"`
+	OIDCUsernameClaim           string        `env:"GREENER_OIDC_USERNAME_CLAIM" envDefault:"preferred_username"`
+	OIDCGroupsClaim             string        `env:"GREENER_OIDC_GROUPS_CLAIM" envDefault:"groups"`
+	OIDCAdminGroups             []string      `env:"GREENER_OIDC_ADMIN_GROUPS"`
+	OIDCEditorGroups            []string      `env:"GREENER_OIDC_EDITOR_GROUPS"`
+	OIDCViewerGroups            []string      `env:"GREENER_OIDC_VIEWER_GROUPS"`
+	OIDCDefaultRole             string        `env:"GREENER_OIDC_DEFAULT_ROLE" envDefault:"viewer"`
+	LDAPURL                     string        `env:"GREENER_LDAP_URL"`
+	LDAPStartTLS                bool          `env:"GREENER_LDAP_START_TLS"`
+	LDAPCACert                  string        `env:"GREENER_LDAP_CA_CERT"`
+	LDAPBindDN                  string        `env:"GREENER_LDAP_BIND_DN"`
+	LDAPBindPassword            string        `env:"GREENER_LDAP_BIND_PASSWORD"`
+	LDAPBaseDN                  string        `env:"GREENER_LDAP_BASE_DN"`
+	LDAPUserFilter              string        `env:"GREENER_LDAP_USER_FILTER" envDefault:"(uid={username})"`
+	LDAPUsernameAttribute       string        `env:"GREENER_LDAP_USERNAME_ATTRIBUTE" envDefault:"uid"`
+	LDAPGroupBaseDN             string        `env:"GREENER_LDAP_GROUP_BASE_DN"`
+	LDAPGroupFilter             string        `env:"GREENER_LDAP_GROUP_FILTER"`
+	LDAPAdminGroups             []string      `env:"GREENER_LDAP_ADMIN_GROUPS"`
+	LDAPEditorGroups            []string      `env:"GREENER_LDAP_EDITOR_GROUPS"`
+	LDAPViewerGroups            []string      `env:"GREENER_LDAP_VIEWER_GROUPS"`
+	LDAPDefaultRole             string        `env:"GREENER_LDAP_DEFAULT_ROLE" envDefault:"viewer"`
 }
 
 type Template struct {
@@ -53,8 +146,107 @@ func main() {
 	flag.StringVar(&cfg.AuthSecret, "auth-secret", cfg.AuthSecret, "Authentication secret key")
 	flag.StringVar(&cfg.AuthIssuer, "base-url", cfg.AuthIssuer, "External base URL")
 	flag.IntVar(&cfg.Port, "port", cfg.Port, "Port to listen on")
+	flag.IntVar(&cfg.GRPCPort, "grpc-port", cfg.GRPCPort, "Port to serve the gRPC ingress API on (default: the HTTP port)")
 	flag.BoolVar(&cfg.Verbose, "verbose", cfg.Verbose, "Enable verbose output")
 	flag.BoolVar(&cfg.AllowUnauthenticatedViewers, "allow-unauthenticated-viewers", cfg.AllowUnauthenticatedViewers, "Allow unauthenticated users to view data")
+	flag.Func("trusted-proxies", "Comma-separated IP addresses or CIDR ranges of reverse proxies whose X-Forwarded-For headers are trusted", func(s string) error {
+		cfg.TrustedProxies = strings.Split(s, ",")
+		return nil
+	})
+	flag.DurationVar(&cfg.AlertInterval, "alert-interval", cfg.AlertInterval, "Interval between alert rule evaluations")
+	flag.StringVar(&cfg.AlertWebhookURL, "alert-webhook-url", cfg.AlertWebhookURL, "URL to POST alert notifications to")
+	flag.StringVar(&cfg.MetricsBearerToken, "metrics-bearer-token", cfg.MetricsBearerToken, "Bearer token required to access /metrics")
+	flag.Func("metrics-pass-rate-labels", "Comma-separated label keys to export latest pass rate gauges for", func(s string) error {
+		cfg.MetricsPassRateLabels = strings.Split(s, ",")
+		return nil
+	})
+	flag.BoolVar(&cfg.TracingEnabled, "tracing", cfg.TracingEnabled, "Export OpenTelemetry traces over OTLP (configured via OTEL_* variables)")
+	flag.StringVar(&cfg.RetentionMaxAge, "retention-max-age", cfg.RetentionMaxAge, "Delete sessions older than this (e.g. 90d)")
+	flag.IntVar(&cfg.RetentionMaxSessions, "retention-max-sessions", cfg.RetentionMaxSessions, "Maximum number of sessions to keep per label combination")
+	flag.Func("retention-group-labels", "Comma-separated label keys forming a combination for --retention-max-sessions", func(s string) error {
+		cfg.RetentionGroupLabels = strings.Split(s, ",")
+		return nil
+	})
+	flag.StringVar(&cfg.RetentionKeep, "retention-keep", cfg.RetentionKeep, "Sessions to always keep, e.g. branch=main:50")
+	flag.DurationVar(&cfg.RetentionInterval, "retention-interval", cfg.RetentionInterval, "Interval between retention purges")
+	flag.StringVar(&cfg.OutputCompression, "output-compression", cfg.OutputCompression, "Compression for stored testcase output (gzip, zstd or identity)")
+	flag.IntVar(&cfg.OutputMaxSize, "output-max-size", cfg.OutputMaxSize, "Maximum testcase output size in bytes; longer output is truncated (0 disables)")
+	flag.StringVar(&cfg.OutputStoreDir, "output-store-dir", cfg.OutputStoreDir, "Directory to offload large testcase output to")
+	flag.IntVar(&cfg.OutputOffloadThreshold, "output-offload-threshold", cfg.OutputOffloadThreshold, "Compressed output size in bytes above which output is offloaded")
+	flag.StringVar(&cfg.AttachmentsDir, "attachments-dir", cfg.AttachmentsDir, "Directory to store attachments in (enables attachment upload)")
+	flag.Int64Var(&cfg.AttachmentMaxSize, "attachment-max-size", cfg.AttachmentMaxSize, "Maximum size in bytes of attachments uploaded in one request")
+	flag.Float64Var(&cfg.IngressKeyRateLimit, "ingress-key-rate-limit", cfg.IngressKeyRateLimit, "Ingress requests per second allowed per API key (0 disables)")
+	flag.IntVar(&cfg.IngressKeyBurst, "ingress-key-burst", cfg.IngressKeyBurst, "Ingress request burst allowed per API key")
+	flag.Float64Var(&cfg.IngressUserRateLimit, "ingress-user-rate-limit", cfg.IngressUserRateLimit, "Ingress requests per second allowed for all API keys of a user (0 disables)")
+	flag.IntVar(&cfg.IngressUserBurst, "ingress-user-burst", cfg.IngressUserBurst, "Ingress request burst allowed for all API keys of a user")
+	flag.Float64Var(&cfg.IngressProjectRateLimit, "ingress-project-rate-limit", cfg.IngressProjectRateLimit, "Ingress requests per second allowed for the API keys of a user restricted to a project (0 disables)")
+	flag.IntVar(&cfg.IngressProjectBurst, "ingress-project-burst", cfg.IngressProjectBurst, "Ingress request burst allowed for the API keys of a user restricted to a project")
+	flag.Int64Var(&cfg.IngressDailyTestcases, "ingress-daily-testcases", cfg.IngressDailyTestcases, "Testcases a user may report per day (0 disables)")
+	flag.Int64Var(&cfg.IngressDailyOutputBytes, "ingress-daily-output-bytes", cfg.IngressDailyOutputBytes, "Testcase output bytes a user may report per day (0 disables)")
+	flag.Int64Var(&cfg.IngressProjectTestcases, "ingress-project-daily-testcases", cfg.IngressProjectTestcases, "Testcases the API keys of a user restricted to a project may report per day (0 disables)")
+	flag.Int64Var(&cfg.IngressProjectOutputBytes, "ingress-project-daily-output-bytes", cfg.IngressProjectOutputBytes, "Testcase output bytes the API keys of a user restricted to a project may report per day (0 disables)")
+	flag.Int64Var(&cfg.IngressMaxDecompressedSize, "ingress-max-decompressed-size", cfg.IngressMaxDecompressedSize, "Maximum size in bytes of a compressed ingress request body once decompressed")
+	flag.StringVar(&cfg.IngressQueueDir, "ingress-queue-dir", cfg.IngressQueueDir, "Directory to journal ingress requests in before storing them asynchronously (enables the ingress queue)")
+	flag.IntVar(&cfg.IngressQueueWorkers, "ingress-queue-workers", cfg.IngressQueueWorkers, "Number of workers storing queued ingress requests")
+	flag.IntVar(&cfg.IngressQueueBatchSize, "ingress-queue-batch-size", cfg.IngressQueueBatchSize, "Maximum number of queued ingress requests a worker stores at once")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "Time to finish requests and drain the ingress queue on shutdown")
+	flag.IntVar(&cfg.APIKeyCacheSize, "api-key-cache-size", cfg.APIKeyCacheSize, "Number of API keys whose verified secrets are cached (0 disables)")
+	flag.IntVar(&cfg.LoginFreeAttempts, "login-free-attempts", cfg.LoginFreeAttempts, "Failed logins of a username before it has to wait between attempts")
+	flag.IntVar(&cfg.LoginMaxAttempts, "login-max-attempts", cfg.LoginMaxAttempts, "Failed logins of a username that lock it out (0 disables)")
+	flag.IntVar(&cfg.LoginIPFreeAttempts, "login-ip-free-attempts", cfg.LoginIPFreeAttempts, "Failed logins and invalid API keys of an IP address before it has to wait between attempts")
+	flag.IntVar(&cfg.LoginIPMaxAttempts, "login-ip-max-attempts", cfg.LoginIPMaxAttempts, "Failed logins and invalid API keys of an IP address that lock it out (0 disables)")
+	flag.DurationVar(&cfg.LoginDelay, "login-delay", cfg.LoginDelay, "Wait after the first failed attempt beyond the free ones, doubled after every further one (0 disables)")
+	flag.DurationVar(&cfg.LoginMaxDelay, "login-max-delay", cfg.LoginMaxDelay, "Maximum wait between failed attempts")
+	flag.DurationVar(&cfg.LoginLockout, "login-lockout", cfg.LoginLockout, "How long lockouts last and failed attempts are counted")
+	flag.IntVar(&cfg.PasswordMinLength, "password-min-length", cfg.PasswordMinLength, "Minimum length of new passwords")
+	flag.IntVar(&cfg.PasswordMinClasses, "password-min-classes", cfg.PasswordMinClasses, "Minimum number of character classes (lowercase, uppercase, digits, other) in new passwords")
+	flag.BoolVar(&cfg.PasswordRejectUsername, "password-reject-username", cfg.PasswordRejectUsername, "Reject new passwords containing the username")
+	flag.StringVar(&cfg.OIDCIssuer, "oidc-issuer", cfg.OIDCIssuer, "OpenID Connect issuer URL (enables single sign-on)")
+	flag.StringVar(&cfg.OIDCClientID, "oidc-client-id", cfg.OIDCClientID, "OpenID Connect client ID")
+	flag.StringVar(&cfg.OIDCClientSecret, "oidc-client-secret", cfg.OIDCClientSecret, "OpenID Connect client secret")
+	flag.StringVar(&cfg.OIDCName, "oidc-name", cfg.OIDCName, "Name of the identity provider shown on the login page")
+	flag.Func("oidc-scopes", "Comma-separated scopes to request in addition to openid", func(s string) error {
+		cfg.OIDCScopes = strings.Split(s, ",")
+		return nil
+	})
+	flag.StringVar(&cfg.OIDCUsernameClaim, "oidc-username-claim", cfg.OIDCUsernameClaim, "ID token claim users are named after")
+	flag.StringVar(&cfg.OIDCGroupsClaim, "oidc-groups-claim", cfg.OIDCGroupsClaim, "ID token claim listing the groups of users")
+	flag.Func("oidc-admin-groups", "Comma-separated groups whose members get the admin role", func(s string) error {
+		cfg.OIDCAdminGroups = strings.Split(s, ",")
+		return nil
+	})
+	flag.Func("oidc-editor-groups", "Comma-separated groups whose members get the editor role", func(s string) error {
+		cfg.OIDCEditorGroups = strings.Split(s, ",")
+		return nil
+	})
+	flag.Func("oidc-viewer-groups", "Comma-separated groups whose members get the viewer role", func(s string) error {
+		cfg.OIDCViewerGroups = strings.Split(s, ",")
+		return nil
+	})
+	flag.StringVar(&cfg.OIDCDefaultRole, "oidc-default-role", cfg.OIDCDefaultRole, "Role of users in no mapped group (none rejects them)")
+	flag.StringVar(&cfg.LDAPURL, "ldap-url", cfg.LDAPURL, "LDAP directory URL, ldap:// or ldaps:// (enables LDAP login)")
+	flag.BoolVar(&cfg.LDAPStartTLS, "ldap-start-tls", cfg.LDAPStartTLS, "Upgrade ldap:// connections with StartTLS")
+	flag.StringVar(&cfg.LDAPCACert, "ldap-ca-cert", cfg.LDAPCACert, "PEM file of the CA certificates verifying the directory (default: system roots)")
+	flag.StringVar(&cfg.LDAPBindDN, "ldap-bind-dn", cfg.LDAPBindDN, "DN to bind as to search users (default: anonymous)")
+	flag.StringVar(&cfg.LDAPBindPassword, "ldap-bind-password", cfg.LDAPBindPassword, "Password of the bind DN")
+	flag.StringVar(&cfg.LDAPBaseDN, "ldap-base-dn", cfg.LDAPBaseDN, "DN to search users under")
+	flag.StringVar(&cfg.LDAPUserFilter, "ldap-user-filter", cfg.LDAPUserFilter, "Filter finding users, {username} is replaced with the login name")
+	flag.StringVar(&cfg.LDAPUsernameAttribute, "ldap-username-attribute", cfg.LDAPUsernameAttribute, "Attribute users are named after")
+	flag.StringVar(&cfg.LDAPGroupBaseDN, "ldap-group-base-dn", cfg.LDAPGroupBaseDN, "DN to search groups under (default: read memberOf of users)")
+	flag.StringVar(&cfg.LDAPGroupFilter, "ldap-group-filter", cfg.LDAPGroupFilter, "Filter finding the groups of users, {dn} and {username} are replaced")
+	flag.Func("ldap-admin-groups", "Comma-separated groups whose members get the admin role", func(s string) error {
+		cfg.LDAPAdminGroups = strings.Split(s, ",")
+		return nil
+	})
+	flag.Func("ldap-editor-groups", "Comma-separated groups whose members get the editor role", func(s string) error {
+		cfg.LDAPEditorGroups = strings.Split(s, ",")
+		return nil
+	})
+	flag.Func("ldap-viewer-groups", "Comma-separated groups whose members get the viewer role", func(s string) error {
+		cfg.LDAPViewerGroups = strings.Split(s, ",")
+		return nil
+	})
+	flag.StringVar(&cfg.LDAPDefaultRole, "ldap-default-role", cfg.LDAPDefaultRole, "Role of users in no mapped group (none rejects them)")
 	flag.Parse()
 
 	issuer := cfg.AuthIssuer
@@ -74,6 +266,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	if cfg.AlertInterval <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: alert interval must be positive, got %s\n", cfg.AlertInterval)
+		fmt.Fprintf(os.Stderr, "Usage: Set GREENER_ALERT_INTERVAL or use --alert-interval flag, e.g. 1m\n")
+		os.Exit(1)
+	}
+
+	if cfg.RetentionInterval <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: retention interval must be positive, got %s\n", cfg.RetentionInterval)
+		fmt.Fprintf(os.Stderr, "Usage: Set GREENER_RETENTION_INTERVAL or use --retention-interval flag, e.g. 1h\n")
+		os.Exit(1)
+	}
+
 	if err := core.Migrate(cfg.DatabaseURL, cfg.Verbose); err != nil {
 		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
 		os.Exit(1)
@@ -86,14 +290,179 @@ func main() {
 	}
 	defer db.Close()
 
+	if cfg.TracingEnabled {
+		shutdown, err := tracing.Setup(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize tracing: %v\n", err)
+			os.Exit(1)
+		}
+		defer shutdown(context.Background())
+
+		tracing.InstrumentDB(db)
+	}
+
+	outputOpts := output.Options{
+		Encoding:         cfg.OutputCompression,
+		MaxSize:          cfg.OutputMaxSize,
+		OffloadThreshold: cfg.OutputOffloadThreshold,
+	}
+	if cfg.OutputStoreDir != "" {
+		outputOpts.Store, err = blob.NewFSStore(cfg.OutputStoreDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize output store: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	outputs, err := output.NewStorage(outputOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid output storage configuration: %v\n", err)
+		os.Exit(1)
+	}
+	retentionStores := retention.Stores{Outputs: outputs}
+
+	var attachmentService *attachments.Service
+	if cfg.AttachmentsDir != "" {
+		attachmentStore, err := blob.NewFSStore(cfg.AttachmentsDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize attachment store: %v\n", err)
+			os.Exit(1)
+		}
+		attachmentService = attachments.NewService(db, attachmentStore, cfg.AttachmentMaxSize)
+		retentionStores.Attachments = attachmentStore
+	}
+
+	ingressLimits := quota.Limits{
+		KeyRate:                 cfg.IngressKeyRateLimit,
+		KeyBurst:                cfg.IngressKeyBurst,
+		UserRate:                cfg.IngressUserRateLimit,
+		UserBurst:               cfg.IngressUserBurst,
+		ProjectRate:             cfg.IngressProjectRateLimit,
+		ProjectBurst:            cfg.IngressProjectBurst,
+		DailyTestcases:          cfg.IngressDailyTestcases,
+		DailyOutputBytes:        cfg.IngressDailyOutputBytes,
+		ProjectDailyTestcases:   cfg.IngressProjectTestcases,
+		ProjectDailyOutputBytes: cfg.IngressProjectOutputBytes,
+	}
+	if err := ingressLimits.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid ingress limits: %v\n", err)
+		os.Exit(1)
+	}
+	ingressLimiter := quota.NewLimiter(db, ingressLimits)
+
+	loginPolicy := lockout.Policy{
+		Users:     lockout.Limit{FreeAttempts: cfg.LoginFreeAttempts, MaxAttempts: cfg.LoginMaxAttempts},
+		IPs:       lockout.Limit{FreeAttempts: cfg.LoginIPFreeAttempts, MaxAttempts: cfg.LoginIPMaxAttempts},
+		BaseDelay: cfg.LoginDelay,
+		MaxDelay:  cfg.LoginMaxDelay,
+		Lockout:   cfg.LoginLockout,
+	}
+	if err := loginPolicy.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid login policy: %v\n", err)
+		os.Exit(1)
+	}
+
+	passwordPolicy := core.PasswordPolicy{
+		MinLength:      cfg.PasswordMinLength,
+		MinClasses:     cfg.PasswordMinClasses,
+		RejectUsername: cfg.PasswordRejectUsername,
+	}
+	if err := passwordPolicy.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid password policy: %v\n", err)
+		os.Exit(1)
+	}
+	security := core.NewSecurity(loginPolicy, passwordPolicy, cfg.APIKeyCacheSize)
+
+	queryService := core.NewQueryServiceWithOutputStorage(db, outputs)
+
 	oauthServer := oauth.NewServer(db, issuer)
 
+	var oidcProvider *oidc.Provider
+	if cfg.OIDCIssuer != "" {
+		defaultRole := model_db.UserRole(cfg.OIDCDefaultRole)
+		if cfg.OIDCDefaultRole == "none" {
+			defaultRole = ""
+		}
+		oidcProvider, err = oidc.NewProvider(context.Background(), db, cfg.OIDCName, oidc.Config{
+			Issuer:        cfg.OIDCIssuer,
+			ClientID:      cfg.OIDCClientID,
+			ClientSecret:  cfg.OIDCClientSecret,
+			RedirectURL:   strings.TrimSuffix(issuer, "/") + "/login/oidc/callback",
+			Scopes:        cfg.OIDCScopes,
+			UsernameClaim: cfg.OIDCUsernameClaim,
+			GroupsClaim:   cfg.OIDCGroupsClaim,
+			Roles: core.RoleMapping{
+				AdminGroups:  cfg.OIDCAdminGroups,
+				EditorGroups: cfg.OIDCEditorGroups,
+				ViewerGroups: cfg.OIDCViewerGroups,
+				DefaultRole:  defaultRole,
+			},
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize OpenID Connect: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	authenticators := []core.Authenticator{core.LocalAuthenticator{}}
+	if cfg.LDAPURL != "" {
+		ldapAuthenticator, err := newLDAPAuthenticator(db, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize LDAP: %v\n", err)
+			os.Exit(1)
+		}
+		authenticators = append(authenticators, ldapAuthenticator)
+	}
+
 	sseHub := sse.NewHub()
 	go sseHub.Run()
 
-	mcpServer := mcp.NewMCPServer(db, sseHub)
+	metrics.RegisterSSEClients(sseHub.TotalClientCount)
+	metrics.RegisterDBStats(db.DB)
+	if len(cfg.MetricsPassRateLabels) > 0 {
+		metrics.Registry.MustRegister(metrics.NewPassRateCollector(db, cfg.MetricsPassRateLabels))
+	}
+
+	mcpServer := mcp.NewMCPServerWithQueryService(db, sseHub, queryService)
+
+	alertNotifier := alerts.MultiNotifier{alerts.LogNotifier{}}
+	if cfg.AlertWebhookURL != "" {
+		alertNotifier = append(alertNotifier, alerts.NewWebhookNotifier(cfg.AlertWebhookURL))
+	}
+	alertEngine := alerts.NewEngine(db, alertNotifier, cfg.AlertInterval)
+	go alertEngine.Run(context.Background())
+
+	retentionPolicy := retention.Policy{
+		MaxSessions: cfg.RetentionMaxSessions,
+		GroupLabels: cfg.RetentionGroupLabels,
+	}
+	if cfg.RetentionMaxAge != "" {
+		retentionPolicy.MaxAge, err = core.ParseDuration(cfg.RetentionMaxAge)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid retention max age: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	retentionPolicy.Keep, err = retention.ParseKeepRules(cfg.RetentionKeep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid retention keep rules: %v\n", err)
+		os.Exit(1)
+	}
+	if err := retentionPolicy.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid retention policy: %v\n", err)
+		os.Exit(1)
+	}
+	if !retentionPolicy.IsEmpty() {
+		go retention.NewPurger(db, retentionPolicy, retentionStores).Run(context.Background(), cfg.RetentionInterval)
+	}
+
+	ipExtractor, err := clientip.Extractor(cfg.TrustedProxies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid trusted proxies: %v\n", err)
+		os.Exit(1)
+	}
 
 	e := echo.New()
+	e.IPExtractor = ipExtractor
 
 	funcMap := template.FuncMap{
 		"sub": func(a, b int) int { return a - b },
@@ -116,19 +485,31 @@ func main() {
 		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/query_editor.html", "templates/testcases.html")...))
 	templates["testcase_detail.html"] = template.Must(template.New("").
 		Funcs(funcMap).
-		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/testcase_detail.html")...))
+		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/components/attachments.html", "templates/testcase_detail.html")...))
 	templates["sessions.html"] = template.Must(template.New("").
 		Funcs(funcMap).
 		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/query_editor.html", "templates/sessions.html")...))
 	templates["session_detail.html"] = template.Must(template.New("").
 		Funcs(funcMap).
-		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/session_detail.html")...))
+		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/components/attachments.html", "templates/components/testcase_tree.html", "templates/session_detail.html")...))
 	templates["groups.html"] = template.Must(template.New("").
 		Funcs(funcMap).
 		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/query_editor.html", "templates/groups.html")...))
 	templates["apikeys.html"] = template.Must(template.New("").
 		Funcs(funcMap).
-		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/apikeys.html")...))
+		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/apikeys_table.html", "templates/apikeys.html")...))
+	templates["alerts.html"] = template.Must(template.New("").
+		Funcs(funcMap).
+		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/alerts.html")...))
+	templates["audit.html"] = template.Must(template.New("").
+		Funcs(funcMap).
+		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/audit.html")...))
+	templates["users.html"] = template.Must(template.New("").
+		Funcs(funcMap).
+		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/users_table.html", "templates/users.html")...))
+	templates["change_password.html"] = template.Must(template.New("").
+		Funcs(funcMap).
+		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/change_password.html")...))
 	templates["oauth_authorize.html"] = template.Must(template.New("").
 		Funcs(funcMap).
 		ParseFS(assets.TemplatesFS, append(componentTemplates, "templates/oauth_authorize.html")...))
@@ -143,28 +524,47 @@ func main() {
 	templates["groups_table.html"] = template.Must(template.New("groups_table.html").
 		Funcs(funcMap).
 		ParseFS(assets.TemplatesFS, append(tableComponents, "templates/groups_table.html")...))
-
-	queryService := core.NewQueryService(db)
+	templates["apikeys_table.html"] = template.Must(template.New("apikeys_table.html").
+		Funcs(funcMap).
+		ParseFS(assets.TemplatesFS, "templates/apikeys_table.html"))
+	templates["users_table.html"] = template.Must(template.New("users_table.html").
+		Funcs(funcMap).
+		ParseFS(assets.TemplatesFS, "templates/users_table.html"))
 
 	e.Renderer = &Template{templates: templates}
+	if cfg.TracingEnabled {
+		e.Use(tracing.Middleware())
+	}
 	e.Use(middleware.RequestLogger())
+	e.Use(metrics.Middleware())
 	e.Use(middleware.Recover())
 	e.Use(session.Middleware(sessions.NewCookieStore([]byte(cfg.AuthSecret))))
 	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			c.Set("db", db)
+			c.Set("security", security)
 			c.Set("queryService", queryService)
 			c.Set("allowUnauthenticatedViewers", cfg.AllowUnauthenticatedViewers)
+			c.Set("authenticators", authenticators)
+			if oidcProvider != nil {
+				c.Set("ssoName", oidcProvider.Name())
+			}
 			return next(c)
 		}
 	})
+	e.Use(core.CheckUserSession(db))
 
 	e.GET("/static/*", echo.WrapHandler(http.FileServer(http.FS(assets.StaticFS))))
+	e.GET("/metrics", metrics.Handler(cfg.MetricsBearerToken))
 
 	e.GET("/", core.IndexHandler)
 	e.GET("/login", core.LoginPageHandler)
 	e.POST("/login", core.LoginHandler)
 	e.POST("/logout", core.LogoutHandler)
+	if oidcProvider != nil {
+		e.GET("/login/oidc", oidcProvider.LoginHandler)
+		e.GET("/login/oidc/callback", oidcProvider.CallbackHandler)
+	}
 
 	e.GET("/.well-known/oauth-authorization-server", oauthServer.MetadataHandler)
 	e.GET("/oauth/authorize", oauthServer.AuthorizePageHandler)
@@ -178,21 +578,165 @@ func main() {
 	e.GET("/sessions", core.SessionsHandler)
 	e.POST("/sessions/query", core.SessionsHandler)
 	e.GET("/sessions/:id/details", core.SessionDetailHandler)
+	e.POST("/sessions/:id/edit", core.EditSessionHandler)
+	e.POST("/sessions/:id/labels", core.SetSessionLabelHandler)
+	e.DELETE("/sessions/:id/labels", core.DeleteSessionLabelHandler)
 	e.GET("/groups", core.GroupsHandler)
 	e.POST("/groups/query", core.GroupsHandler)
 	e.GET("/api-keys", core.APIKeysHandler)
 	e.POST("/api-keys/create", core.CreateAPIKeyHandler)
+	e.POST("/api-keys/:id/rotate", core.RotateAPIKeyHandler)
 	e.DELETE("/api-keys/:id", core.DeleteAPIKeyHandler)
+	if attachmentService != nil {
+		e.GET("/attachments/:id", attachmentService.DownloadHandler)
+	}
+	e.GET("/alerts", alertEngine.PageHandler)
+	e.POST("/alerts/create", alertEngine.CreateRuleHandler)
+	e.DELETE("/alerts/:id", alertEngine.DeleteRuleHandler)
+	e.GET("/audit", core.AuditLogHandler)
+	e.GET("/audit/export", core.AuditExportHandler)
+	e.GET("/users", core.UsersHandler)
+	e.POST("/users/create", core.CreateUserHandler)
+	e.POST("/users/:id/role", core.SetUserRoleHandler)
+	e.POST("/users/:id/disable", core.DisableUserHandler)
+	e.POST("/users/:id/enable", core.EnableUserHandler)
+	e.POST("/users/:id/password", core.ResetUserPasswordHandler)
+	e.POST("/users/:id/revoke", core.RevokeUserHandler)
+	e.POST("/users/:id/unlock", core.UnlockUserHandler)
+	e.GET("/account/password", core.ChangePasswordPageHandler)
+	e.POST("/account/password", core.ChangePasswordHandler)
 
 	apiV1 := e.Group("/api/v1")
 	apiV1.GET("/sse/events", sse.NewHandler(sseHub))
 	apiV1.POST("/sse/set-primary", sse.NewSetPrimaryHandler(sseHub))
-	apiV1.Any("/mcp", mcpServer.EchoHandler(), oauthServer.BearerAuthMiddleware())
+	apiV1.Any("/mcp", mcpServer.EchoHandler(), core.APIKeyOrBearerAuth(db, security, oauthServer.BearerAuthMiddleware()))
+
+	usersAPI := core.NewUsersAPI(db, security)
+	apiV1Users := apiV1.Group("/users", core.APIKeyAuth(db, security, model_db.ScopeAdmin), core.RequireAdminUser(db))
+	apiV1Users.GET("", usersAPI.List)
+	apiV1Users.POST("", usersAPI.Create)
+	apiV1Users.GET("/:id", usersAPI.Get)
+	apiV1Users.PATCH("/:id", usersAPI.Update)
+	apiV1Users.POST("/:id/password", usersAPI.SetPassword)
+	apiV1Users.POST("/:id/revoke", usersAPI.Revoke)
+	apiV1Users.POST("/:id/unlock", usersAPI.Unlock)
 
-	ingressHandler := core.NewIngressHandler(db)
-	apiV1Ingress := apiV1.Group("/ingress", core.APIKeyAuth(db))
+	ingressHandler := core.NewIngressHandler(db, outputs)
+	var ingressQueue *queue.Queue
+	if cfg.IngressQueueDir != "" {
+		ingressQueue, err = ingressHandler.OpenQueue(queue.Options{
+			Dir:       cfg.IngressQueueDir,
+			Workers:   cfg.IngressQueueWorkers,
+			BatchSize: cfg.IngressQueueBatchSize,
+		}, e.Logger)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open ingress queue: %v\n", err)
+			os.Exit(1)
+		}
+		metrics.RegisterIngressQueueDepth(ingressQueue.Depth)
+		if attachmentService != nil {
+			attachmentService.AwaitSessions(ingressHandler.AwaitSession)
+		}
+	}
+	apiV1Ingress := apiV1.Group("/ingress", metrics.IngressErrors(), core.APIKeyAuth(db, security, model_db.ScopeIngest), core.IngressLimits(ingressLimiter), core.DecompressRequest(cfg.IngressMaxDecompressedSize))
 	apiV1Ingress.POST("/sessions", ingressHandler.CreateSession)
+	apiV1Ingress.PATCH("/sessions/:id", ingressHandler.PatchSession)
 	apiV1Ingress.POST("/testcases", ingressHandler.CreateTestcases)
+	apiV1Ingress.POST("/junit", ingressHandler.UploadJUnit)
+	apiV1Ingress.POST("/reports", ingressHandler.UploadReport)
+	if attachmentService != nil {
+		apiV1Ingress.POST("/attachments", attachmentService.UploadHandler)
+	}
+
+	apiV1OTLP := apiV1.Group("/otlp", metrics.IngressErrors(), core.APIKeyAuth(db, security, model_db.ScopeIngest), core.IngressLimits(ingressLimiter), core.DecompressRequest(cfg.IngressMaxDecompressedSize))
+	apiV1OTLP.POST("/v1/traces", ingressHandler.ExportTraces)
+	apiV1OTLP.POST("/v1/logs", ingressHandler.ExportLogs)
+
+	grpcServer := core.NewGRPCServer(ingressHandler, security, ingressLimiter, e.Logger)
+	if cfg.GRPCPort != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to listen on gRPC port: %v\n", err)
+			os.Exit(1)
+		}
+		go func() {
+			e.Logger.Fatal(grpcServer.Serve(listener))
+		}()
+	} else {
+		e.Pre(core.GRPCHandler(grpcServer))
+	}
+
+	server := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Port), Protocols: new(http.Protocols)}
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
+	go func() {
+		if err := e.StartServer(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-signalCtx.Done()
+	stop()
 
-	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", cfg.Port)))
+	// stop accepting requests first, so that the queue is not fed while it drains
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		e.Logger.Errorf("Failed to shut down the HTTP server: %v", err)
+	}
+	if cfg.GRPCPort != 0 {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+	if ingressQueue != nil {
+		if err := ingressQueue.Drain(shutdownCtx); err != nil {
+			e.Logger.Warnf("Ingress queue not drained, the rest is stored after the next start: %v", err)
+		}
+	}
+}
+
+func newLDAPAuthenticator(db *bun.DB, cfg Config) (*ldap.Authenticator, error) {
+	var tlsConfig *tls.Config
+	if cfg.LDAPCACert != "" {
+		pem, err := os.ReadFile(cfg.LDAPCACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.LDAPCACert)
+		}
+		tlsConfig = &tls.Config{RootCAs: roots}
+	}
+	defaultRole := model_db.UserRole(cfg.LDAPDefaultRole)
+	if cfg.LDAPDefaultRole == "none" {
+		defaultRole = ""
+	}
+	return ldap.NewAuthenticator(db, ldap.Config{
+		URL:               cfg.LDAPURL,
+		StartTLS:          cfg.LDAPStartTLS,
+		TLSConfig:         tlsConfig,
+		BindDN:            cfg.LDAPBindDN,
+		BindPassword:      cfg.LDAPBindPassword,
+		BaseDN:            cfg.LDAPBaseDN,
+		UserFilter:        cfg.LDAPUserFilter,
+		UsernameAttribute: cfg.LDAPUsernameAttribute,
+		GroupBaseDN:       cfg.LDAPGroupBaseDN,
+		GroupFilter:       cfg.LDAPGroupFilter,
+		Roles: core.RoleMapping{
+			AdminGroups:  cfg.LDAPAdminGroups,
+			EditorGroups: cfg.LDAPEditorGroups,
+			ViewerGroups: cfg.LDAPViewerGroups,
+			DefaultRole:  defaultRole,
+		},
+	})
 }
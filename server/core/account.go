@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/lockout"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+func ChangePasswordPageHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return c.Redirect(http.StatusFound, "/login")
+	}
+
+	return c.Render(http.StatusOK, "change_password.html", map[string]any{
+		"ActivePage":      "account",
+		"IsAuthenticated": true,
+		"IsAdmin":         IsAdmin(c),
+	})
+}
+
+// ChangePasswordHandler changes the password of the logged in user, who has to confirm the
+// current one. Other web sessions of the user are signed out; this one stays logged in.
+func ChangePasswordHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return c.HTML(http.StatusUnauthorized, `<div class="alert alert-error">Unauthorized</div>`)
+	}
+	actor := audit.RequestActor(c)
+	if actor.UserID == nil {
+		return c.HTML(http.StatusUnauthorized, `<div class="alert alert-error">Unauthorized</div>`)
+	}
+
+	current := c.FormValue("current_password")
+	password := c.FormValue("new_password")
+	if password == "" {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">New password is required</div>`)
+	}
+	if password != c.FormValue("confirm_password") {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Passwords do not match</div>`)
+	}
+
+	db := c.Get("db").(*bun.DB)
+	sec := getSecurity(c)
+	ctx := c.Request().Context()
+
+	user, err := loadUser(ctx, db, c.Logger(), *actor.UserID)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+	if user.AuthSource != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Your password is managed by your identity provider</div>`)
+	}
+	if err := sec.passwordPolicy.Check(user.Username, password); err != nil {
+		return userErrorHTML(c, err)
+	}
+
+	// the current password can be guessed here as well as on the login page
+	subjects := []lockout.Subject{lockout.User(user.Username), lockout.IP(clientip.FromContext(c))}
+	if err := sec.loginGuard.Check(ctx, db, subjects...); err != nil {
+		var blocked *lockout.BlockedError
+		if !errors.As(err, &blocked) {
+			c.Logger().Errorf("Failed to check failed logins of %s: %v", user.Username, err)
+			return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to change password</div>`)
+		}
+		c.Response().Header().Set("Retry-After", strconv.Itoa(blocked.RetryAfterSeconds()))
+		return c.HTML(http.StatusTooManyRequests, `<div class="alert alert-error">Too many failed attempts, try again later</div>`)
+	}
+	if !checkPassword(user, current) {
+		audit.RecordRequest(c, db, actor, audit.Event{
+			Action:     audit.ActionUserPasswordChange,
+			Failed:     true,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID.String(),
+			Details:    "invalid current password",
+		})
+		failLogin(c, db, user, user.Username, subjects)
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Current password is incorrect</div>`)
+	}
+
+	now := time.Now()
+	if err := storePassword(ctx, db, user, password, now); err != nil {
+		c.Logger().Errorf("Failed to change password: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to change password</div>`)
+	}
+	audit.RecordRequest(c, db, actor, audit.Event{
+		Action:     audit.ActionUserPasswordChange,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.String(),
+	})
+
+	// the password change revoked the sessions started before now, except this one
+	sess.Values["login_at"] = now.Unix()
+	sess.Save(c.Request(), c.Response())
+
+	return c.HTML(http.StatusOK, `<div class="alert alert-success">Your password was changed. Your other sessions were signed out.</div>`)
+}
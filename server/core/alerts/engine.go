@@ -0,0 +1,234 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/query"
+	"github.com/uptrace/bun"
+)
+
+var ErrNoData = errors.New("no data in window")
+
+type Stats struct {
+	TotalCount   int64 `bun:"total_count"`
+	PassCount    int64 `bun:"pass_count"`
+	FailureCount int64 `bun:"failure_count"`
+	SkipCount    int64 `bun:"skip_count"`
+	FlakyCount   int64 `bun:"flaky_count"`
+}
+
+type Evaluation struct {
+	Rule     *model_db.AlertRule
+	Value    float64
+	Breached bool
+	State    model_db.AlertState
+	Changed  bool
+}
+
+type Engine struct {
+	db       *bun.DB
+	notifier Notifier
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewEngine(db *bun.DB, notifier Notifier, interval time.Duration) *Engine {
+	return &Engine{
+		db:       db,
+		notifier: notifier,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.EvaluateAll(ctx); err != nil {
+			log.Printf("alerts: evaluation failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Engine) EvaluateAll(ctx context.Context) error {
+	var rules []model_db.AlertRule
+	if err := e.db.NewSelect().Model(&rules).Scan(ctx); err != nil {
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+
+	for i := range rules {
+		if _, err := e.Evaluate(ctx, &rules[i]); err != nil && !errors.Is(err, ErrNoData) {
+			log.Printf("alerts: failed to evaluate rule %s (%s): %v", rules[i].ID, rules[i].Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) Evaluate(ctx context.Context, rule *model_db.AlertRule) (*Evaluation, error) {
+	queryAST, err := ParseRuleQuery(rule.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	now := e.now()
+	since := now.Add(-time.Duration(rule.WindowSeconds) * time.Second)
+
+	q, err := core.BuildTestcaseStatsQuery(e.db, queryAST, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var stats Stats
+	if err := q.Scan(ctx, &stats); err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+
+	value, err := AggregateValue(rule.Aggregate, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	breached, err := Compare(rule.Operator, value, rule.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	eval := &Evaluation{
+		Rule:     rule,
+		Value:    value,
+		Breached: breached,
+		State:    rule.State,
+	}
+
+	switch {
+	case breached && rule.State != model_db.AlertStateFiring:
+		eval.State = model_db.AlertStateFiring
+		eval.Changed = true
+	case !breached && rule.State == model_db.AlertStateFiring:
+		eval.State = model_db.AlertStateResolved
+		eval.Changed = true
+	}
+
+	rule.LastValue = &value
+	rule.LastEvaluatedAt = &now
+	rule.UpdatedAt = now
+	columns := []string{"last_value", "last_evaluated_at", "updated_at"}
+	if eval.Changed {
+		rule.State = eval.State
+		rule.StateChangedAt = &now
+		columns = append(columns, "state", "state_changed_at")
+	}
+
+	err = e.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model(rule).
+			Column(columns...).
+			Where("? = ?", bun.Ident("id"), rule.ID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !eval.Changed {
+			return nil
+		}
+
+		event := &model_db.AlertEvent{
+			RuleID:    rule.ID,
+			State:     eval.State,
+			Value:     value,
+			CreatedAt: now,
+		}
+		_, err = tx.NewInsert().Model(event).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save alert state: %w", err)
+	}
+
+	if eval.Changed && e.notifier != nil {
+		notification := Notification{
+			RuleID:    rule.ID.String(),
+			RuleName:  rule.Name,
+			Query:     rule.Query,
+			Aggregate: string(rule.Aggregate),
+			Operator:  string(rule.Operator),
+			Threshold: rule.Threshold,
+			Window:    FormatWindow(rule.WindowSeconds),
+			State:     string(eval.State),
+			Value:     value,
+			Timestamp: now,
+		}
+		if err := e.notifier.Notify(ctx, notification); err != nil {
+			log.Printf("alerts: failed to send notification for rule %s: %v", rule.ID, err)
+		}
+	}
+
+	return eval, nil
+}
+
+func ParseRuleQuery(queryStr string) (query.Query, error) {
+	if queryStr == "" {
+		return query.Query{}, nil
+	}
+
+	parsedQuery, err := query.NewParser(queryStr).Parse()
+	if err != nil {
+		return query.Query{}, fmt.Errorf("invalid query: %w", err)
+	}
+
+	if parsedQuery.GroupQuery != nil {
+		return query.Query{}, fmt.Errorf("invalid query: group_by is not supported in alert rules")
+	}
+
+	return parsedQuery, nil
+}
+
+func AggregateValue(aggregate model_db.AlertAggregate, stats Stats) (float64, error) {
+	switch aggregate {
+	case model_db.AggregateFailureCount:
+		return float64(stats.FailureCount), nil
+	case model_db.AggregateFlakyCount:
+		return float64(stats.FlakyCount), nil
+	case model_db.AggregateTestcaseCount:
+		return float64(stats.TotalCount), nil
+	case model_db.AggregatePassRate:
+		executed := stats.TotalCount - stats.SkipCount
+		if executed == 0 {
+			return 0, ErrNoData
+		}
+		return float64(stats.PassCount) * 100 / float64(executed), nil
+	default:
+		return 0, fmt.Errorf("unknown aggregate: %s", aggregate)
+	}
+}
+
+func Compare(op model_db.AlertOperator, value, threshold float64) (bool, error) {
+	switch op {
+	case model_db.OperatorLess:
+		return value < threshold, nil
+	case model_db.OperatorLessEqual:
+		return value <= threshold, nil
+	case model_db.OperatorGreater:
+		return value > threshold, nil
+	case model_db.OperatorGreaterEqual:
+		return value >= threshold, nil
+	default:
+		return false, fmt.Errorf("unknown operator: %s", op)
+	}
+}
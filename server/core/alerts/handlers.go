@@ -0,0 +1,225 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+type RuleView struct {
+	ID              string
+	Name            string
+	Query           string
+	Condition       string
+	State           string
+	LastValue       string
+	LastEvaluatedAt string
+	CreatedAt       string
+}
+
+func (e *Engine) PageHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return c.Redirect(http.StatusFound, "/login")
+	}
+
+	var rules []model_db.AlertRule
+	err := e.db.NewSelect().
+		Model(&rules).
+		OrderExpr("created_at DESC").
+		Scan(context.Background())
+	if err != nil {
+		c.Logger().Errorf("Failed to load alert rules: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load alert rules")
+	}
+
+	ruleViews := make([]RuleView, len(rules))
+	for i, rule := range rules {
+		ruleViews[i] = newRuleView(rule)
+	}
+
+	role, _ := sess.Values["role"].(string)
+	isViewer := role == string(model_db.RoleViewer)
+
+	return c.Render(http.StatusOK, "alerts.html", map[string]any{
+		"Rules":           ruleViews,
+		"ActivePage":      "alerts",
+		"IsViewer":        isViewer,
+		"IsAuthenticated": true,
+		"IsAdmin":         core.IsAdmin(c),
+	})
+}
+
+func (e *Engine) CreateRuleHandler(c echo.Context) error {
+	userID, ok := editorUserID(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<span>Editor role is required to manage alert rules</span>`)
+	}
+
+	name := strings.TrimSpace(c.FormValue("name"))
+	queryStr := strings.TrimSpace(c.FormValue("query"))
+	aggregate := model_db.AlertAggregate(c.FormValue("aggregate"))
+	operator := model_db.AlertOperator(c.FormValue("operator"))
+
+	if name == "" {
+		return c.HTML(http.StatusBadRequest, `<span>Name is required</span>`)
+	}
+
+	if _, err := ParseRuleQuery(queryStr); err != nil {
+		return c.HTML(http.StatusBadRequest, fmt.Sprintf("<span>%s</span>", html.EscapeString(err.Error())))
+	}
+
+	if _, err := AggregateValue(aggregate, Stats{TotalCount: 1}); err != nil {
+		return c.HTML(http.StatusBadRequest, fmt.Sprintf("<span>%s</span>", html.EscapeString(err.Error())))
+	}
+
+	if _, err := Compare(operator, 0, 0); err != nil {
+		return c.HTML(http.StatusBadRequest, fmt.Sprintf("<span>%s</span>", html.EscapeString(err.Error())))
+	}
+
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(c.FormValue("threshold")), 64)
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, `<span>Threshold must be a number</span>`)
+	}
+
+	window, err := ParseWindow(c.FormValue("window"))
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, fmt.Sprintf("<span>%s</span>", html.EscapeString(err.Error())))
+	}
+
+	now := time.Now()
+	rule := &model_db.AlertRule{
+		ID:            model_db.BinaryUUID(uuid.New()),
+		Name:          name,
+		Query:         queryStr,
+		Aggregate:     aggregate,
+		Operator:      operator,
+		Threshold:     threshold,
+		WindowSeconds: int64(window / time.Second),
+		State:         model_db.AlertStateOK,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		UserID:        userID,
+	}
+
+	_, err = e.db.NewInsert().Model(rule).Exec(context.Background())
+	if err != nil {
+		c.Logger().Errorf("Failed to insert alert rule: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<span>Failed to create alert rule</span>`)
+	}
+
+	audit.RecordRequest(c, e.db, audit.RequestActor(c), audit.Event{
+		Action:     audit.ActionAlertRuleCreate,
+		TargetType: audit.TargetAlertRule,
+		TargetID:   rule.ID.String(),
+		Details:    name,
+	})
+
+	c.Response().Header().Set("HX-Redirect", "/alerts")
+	return c.NoContent(http.StatusOK)
+}
+
+func (e *Engine) DeleteRuleHandler(c echo.Context) error {
+	if _, ok := editorUserID(c); !ok {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Editor role is required to manage alert rules</div>`)
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Invalid ID</div>`)
+	}
+
+	res, err := e.db.NewDelete().
+		Model((*model_db.AlertRule)(nil)).
+		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(id)).
+		Exec(context.Background())
+	if err != nil {
+		c.Logger().Errorf("Failed to delete alert rule: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to delete alert rule</div>`)
+	}
+
+	if n, _ := res.RowsAffected(); n > 0 {
+		audit.RecordRequest(c, e.db, audit.RequestActor(c), audit.Event{
+			Action:     audit.ActionAlertRuleDelete,
+			TargetType: audit.TargetAlertRule,
+			TargetID:   id.String(),
+		})
+	}
+
+	c.Response().Header().Set("HX-Redirect", "/alerts")
+	return c.NoContent(http.StatusOK)
+}
+
+func editorUserID(c echo.Context) (model_db.BinaryUUID, bool) {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return model_db.BinaryUUID(uuid.Nil), false
+	}
+
+	role, _ := sess.Values["role"].(string)
+	if role == string(model_db.RoleViewer) {
+		return model_db.BinaryUUID(uuid.Nil), false
+	}
+
+	userIDStr, _ := sess.Values["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return model_db.BinaryUUID(uuid.Nil), false
+	}
+
+	return model_db.BinaryUUID(userID), true
+}
+
+func newRuleView(rule model_db.AlertRule) RuleView {
+	view := RuleView{
+		ID:    rule.ID.String(),
+		Name:  rule.Name,
+		Query: rule.Query,
+		Condition: fmt.Sprintf("%s %s %s over %s",
+			rule.Aggregate, rule.Operator, strconv.FormatFloat(rule.Threshold, 'f', -1, 64), FormatWindow(rule.WindowSeconds)),
+		State:     string(rule.State),
+		CreatedAt: rule.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if rule.LastValue != nil {
+		view.LastValue = strconv.FormatFloat(*rule.LastValue, 'f', 2, 64)
+	}
+	if rule.LastEvaluatedAt != nil {
+		view.LastEvaluatedAt = rule.LastEvaluatedAt.Format("2006-01-02 15:04:05")
+	}
+	return view
+}
+
+func ParseWindow(s string) (time.Duration, error) {
+	d, err := core.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("invalid window: %s (examples: 30m, 24h, 7d)", strings.TrimSpace(s))
+	}
+	return d, nil
+}
+
+func FormatWindow(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	str := d.String()
+	if strings.HasSuffix(str, "m0s") {
+		str = strings.TrimSuffix(str, "0s")
+	}
+	if strings.HasSuffix(str, "h0m") {
+		str = strings.TrimSuffix(str, "0m")
+	}
+	return str
+}
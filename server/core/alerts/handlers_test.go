@@ -0,0 +1,44 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{input: "24h", expected: 24 * time.Hour},
+		{input: "30m", expected: 30 * time.Minute},
+		{input: "7d", expected: 7 * 24 * time.Hour},
+		{input: "10s", expectErr: true},
+		{input: "0d", expectErr: true},
+		{input: "abc", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := ParseWindow(tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, d)
+		})
+	}
+}
+
+func TestFormatWindow(t *testing.T) {
+	assert.Equal(t, "1d", FormatWindow(86400))
+	assert.Equal(t, "7d", FormatWindow(7*86400))
+	assert.Equal(t, "1h", FormatWindow(3600))
+	assert.Equal(t, "30m", FormatWindow(1800))
+	assert.Equal(t, "1h30m", FormatWindow(5400))
+}
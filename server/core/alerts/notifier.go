@@ -0,0 +1,87 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+type Notification struct {
+	RuleID    string    `json:"ruleId"`
+	RuleName  string    `json:"ruleName"`
+	Query     string    `json:"query"`
+	Aggregate string    `json:"aggregate"`
+	Operator  string    `json:"operator"`
+	Threshold float64   `json:"threshold"`
+	Window    string    `json:"window"`
+	State     string    `json:"state"`
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	log.Printf(
+		"alerts: rule %q is %s: %s = %.2f (threshold %s %.2f over %s)",
+		n.RuleName, n.State, n.Aggregate, n.Value, n.Operator, n.Threshold, n.Window,
+	)
+	return nil
+}
+
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(ctx context.Context, n Notification) error {
+	var firstErr error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,126 @@
+package core_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/cephei8/greener/server/core/alerts"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type recordingNotifier struct {
+	notifications []alerts.Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n alerts.Notification) error {
+	r.notifications = append(r.notifications, n)
+	return nil
+}
+
+func (s *BaseSuite) TestAlertEngineEvaluate() {
+	ctx := context.Background()
+	now := time.Now()
+
+	rule := &model_db.AlertRule{
+		ID:            model_db.BinaryUUID(uuid.New()),
+		Name:          "main pass rate",
+		Query:         `#"branch" = "main"`,
+		Aggregate:     model_db.AggregatePassRate,
+		Operator:      model_db.OperatorLess,
+		Threshold:     95,
+		WindowSeconds: int64((24 * time.Hour) / time.Second),
+		State:         model_db.AlertStateOK,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		UserID:        s.userID,
+	}
+	_, err := s.db.NewInsert().Model(rule).Exec(ctx)
+	s.Require().NoError(err)
+	defer s.db.NewDelete().Model(rule).Where("? = ?", bun.Ident("id"), rule.ID).Exec(ctx)
+
+	notifier := &recordingNotifier{}
+	engine := alerts.NewEngine(s.db, notifier, time.Minute)
+
+	// session1 and session3 are on main: 3 of 4 testcases pass
+	eval, err := engine.Evaluate(ctx, rule)
+	s.Require().NoError(err)
+	s.InDelta(75.0, eval.Value, 0.001)
+	s.True(eval.Breached)
+	s.True(eval.Changed)
+	s.Equal(model_db.AlertStateFiring, eval.State)
+	s.Require().Len(notifier.notifications, 1)
+	s.Equal("firing", notifier.notifications[0].State)
+
+	eval, err = engine.Evaluate(ctx, rule)
+	s.Require().NoError(err)
+	s.False(eval.Changed)
+	s.Len(notifier.notifications, 1)
+
+	rule.Threshold = 50
+	eval, err = engine.Evaluate(ctx, rule)
+	s.Require().NoError(err)
+	s.False(eval.Breached)
+	s.Equal(model_db.AlertStateResolved, eval.State)
+	s.Require().Len(notifier.notifications, 2)
+	s.Equal("resolved", notifier.notifications[1].State)
+
+	var stored model_db.AlertRule
+	err = s.db.NewSelect().Model(&stored).Where("? = ?", bun.Ident("id"), rule.ID).Scan(ctx)
+	s.Require().NoError(err)
+	s.Equal(model_db.AlertStateResolved, stored.State)
+	s.Require().NotNil(stored.LastValue)
+	s.InDelta(75.0, *stored.LastValue, 0.001)
+
+	var events []model_db.AlertEvent
+	err = s.db.NewSelect().Model(&events).Where("? = ?", bun.Ident("rule_id"), rule.ID).Order("id").Scan(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(events, 2)
+	s.Equal(model_db.AlertStateFiring, events[0].State)
+	s.Equal(model_db.AlertStateResolved, events[1].State)
+}
+
+func (s *BaseSuite) TestAlertEngineAggregates() {
+	ctx := context.Background()
+	engine := alerts.NewEngine(s.db, nil, time.Minute)
+
+	tests := []struct {
+		name      string
+		query     string
+		aggregate model_db.AlertAggregate
+		expected  float64
+	}{
+		{name: "failure count all", query: "", aggregate: model_db.AggregateFailureCount, expected: 2},
+		{name: "testcase count all", query: "", aggregate: model_db.AggregateTestcaseCount, expected: 6},
+		{name: "flaky count all", query: "", aggregate: model_db.AggregateFlakyCount, expected: 0},
+		{name: "failure count staging", query: `#"env" = "staging"`, aggregate: model_db.AggregateFailureCount, expected: 1},
+		{name: "pass rate production", query: `#"env" = "production"`, aggregate: model_db.AggregatePassRate, expected: 75},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			rule := &model_db.AlertRule{
+				ID:            model_db.BinaryUUID(uuid.New()),
+				Name:          tt.name,
+				Query:         tt.query,
+				Aggregate:     tt.aggregate,
+				Operator:      model_db.OperatorGreater,
+				Threshold:     1000,
+				WindowSeconds: 3600,
+				State:         model_db.AlertStateOK,
+				CreatedAt:     time.Now(),
+				UpdatedAt:     time.Now(),
+				UserID:        s.userID,
+			}
+			_, err := s.db.NewInsert().Model(rule).Exec(ctx)
+			s.Require().NoError(err)
+			defer s.db.NewDelete().Model(rule).Where("? = ?", bun.Ident("id"), rule.ID).Exec(ctx)
+
+			eval, err := engine.Evaluate(ctx, rule)
+			s.Require().NoError(err)
+			s.InDelta(tt.expected, eval.Value, 0.001)
+			s.False(eval.Breached)
+		})
+	}
+}
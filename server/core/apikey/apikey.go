@@ -0,0 +1,81 @@
+// Package apikey hashes and formats API key secrets and caches their verification.
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PBKDF2Iterations is the work factor of model_db.SecretPBKDF2 secrets.
+const PBKDF2Iterations = 100000
+
+// TokenPrefix starts the compact form of API keys with model_db.SecretSHA256 secrets.
+const TokenPrefix = "grk_"
+
+var ErrInvalidToken = errors.New("invalid API key token")
+
+// Hash hashes secret with salt using algorithm. Secrets of an unknown algorithm are
+// hashed with PBKDF2, the algorithm of keys created before algorithms were recorded.
+func Hash(algorithm model_db.SecretAlgorithm, secret string, salt []byte) []byte {
+	if algorithm == model_db.SecretSHA256 {
+		// the secret has 256 bits of entropy, so a single keyed hash cannot be brute-forced
+		mac := hmac.New(sha256.New, salt)
+		mac.Write([]byte(secret))
+		return mac.Sum(nil)
+	}
+	return pbkdf2.Key([]byte(secret), salt, PBKDF2Iterations, 32, sha256.New)
+}
+
+// NewSecret generates a secret with 256 bits of entropy and a salt, and hashes it with algorithm.
+func NewSecret(algorithm model_db.SecretAlgorithm) (secret string, salt []byte, hash []byte, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, nil, err
+	}
+	if algorithm == model_db.SecretSHA256 {
+		secret = base64.RawURLEncoding.EncodeToString(raw)
+	} else {
+		secret = base64.URLEncoding.EncodeToString(raw)
+	}
+
+	salt = make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return "", nil, nil, err
+	}
+	return secret, salt, Hash(algorithm, secret, salt), nil
+}
+
+// FormatToken returns the compact form of an API key: the prefix, the key ID in hex and the secret.
+func FormatToken(id uuid.UUID, secret string) string {
+	return TokenPrefix + hex.EncodeToString(id[:]) + "_" + secret
+}
+
+// ParseToken parses the compact form of an API key.
+func ParseToken(token string) (uuid.UUID, string, error) {
+	rest, ok := strings.CutPrefix(token, TokenPrefix)
+	if !ok {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	idHex, secret, ok := strings.Cut(rest, "_")
+	if !ok || secret == "" {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	idBytes, err := hex.DecodeString(idHex)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	id, err := uuid.FromBytes(idBytes)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	return id, secret, nil
+}
@@ -0,0 +1,129 @@
+package apikey
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	salt := []byte("salt")
+	for _, algorithm := range []model_db.SecretAlgorithm{model_db.SecretPBKDF2, model_db.SecretSHA256} {
+		hash := Hash(algorithm, "secret", salt)
+		assert.Len(t, hash, 32)
+		assert.Equal(t, hash, Hash(algorithm, "secret", salt))
+		assert.NotEqual(t, hash, Hash(algorithm, "other", salt))
+		assert.NotEqual(t, hash, Hash(algorithm, "secret", []byte("pepper")))
+	}
+	assert.NotEqual(t, Hash(model_db.SecretPBKDF2, "secret", salt), Hash(model_db.SecretSHA256, "secret", salt))
+	// keys stored before algorithms were recorded use PBKDF2
+	assert.Equal(t, Hash(model_db.SecretPBKDF2, "secret", salt), Hash("", "secret", salt))
+
+	secret, salt, hash, err := NewSecret(model_db.SecretSHA256)
+	require.NoError(t, err)
+	assert.Len(t, secret, 43)
+	assert.Equal(t, Hash(model_db.SecretSHA256, secret, salt), hash)
+}
+
+func TestToken(t *testing.T) {
+	id := uuid.New()
+	token := FormatToken(id, "c2VjcmV0_-x")
+	assert.True(t, strings.HasPrefix(token, TokenPrefix))
+
+	parsedID, secret, err := ParseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, id, parsedID)
+	assert.Equal(t, "c2VjcmV0_-x", secret)
+
+	for _, invalid := range []string{
+		"",
+		"grk_",
+		"grk_" + strings.Repeat("0", 32),
+		"grk_" + strings.Repeat("0", 32) + "_",
+		"grk_xyz_secret",
+		"grk_0000_secret",
+		"key_" + strings.Repeat("0", 32) + "_secret",
+	} {
+		_, _, err := ParseToken(invalid)
+		assert.ErrorIs(t, err, ErrInvalidToken, invalid)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+	a, b, d := uuid.New(), uuid.New(), uuid.New()
+	hash := []byte("hash")
+
+	assert.False(t, c.Verified(a, "secret", hash))
+	c.Add(a, "secret", hash)
+	assert.True(t, c.Verified(a, "secret", hash))
+	assert.False(t, c.Verified(a, "wrong", hash))
+	// the key was rotated or deleted and recreated
+	assert.False(t, c.Verified(a, "secret", []byte("rotated")))
+
+	c.Remove(a)
+	assert.False(t, c.Verified(a, "secret", hash))
+
+	// the least recently used key is evicted
+	c.Add(a, "a", hash)
+	c.Add(b, "b", hash)
+	assert.True(t, c.Verified(a, "a", hash))
+	c.Add(d, "d", hash)
+	assert.Equal(t, 2, c.Len())
+	assert.True(t, c.Verified(a, "a", hash))
+	assert.False(t, c.Verified(b, "b", hash))
+	assert.True(t, c.Verified(d, "d", hash))
+
+	var disabled *Cache
+	assert.Nil(t, NewCache(0))
+	disabled.Add(a, "a", hash)
+	assert.False(t, disabled.Verified(a, "a", hash))
+	assert.Equal(t, 0, disabled.Len())
+}
+
+func BenchmarkVerify(b *testing.B) {
+	id := uuid.New()
+	for _, algorithm := range []model_db.SecretAlgorithm{model_db.SecretPBKDF2, model_db.SecretSHA256} {
+		secret, salt, hash, err := NewSecret(algorithm)
+		require.NoError(b, err)
+
+		b.Run(fmt.Sprintf("%s/uncached", algorithm), func(b *testing.B) {
+			for b.Loop() {
+				Hash(algorithm, secret, salt)
+			}
+		})
+
+		c := NewCache(DefaultCacheSize)
+		c.Add(id, secret, hash)
+		b.Run(fmt.Sprintf("%s/cached", algorithm), func(b *testing.B) {
+			for b.Loop() {
+				if !c.Verified(id, secret, hash) {
+					b.Fatal("secret is not cached")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCacheParallel(b *testing.B) {
+	c := NewCache(DefaultCacheSize)
+	hash := []byte("hash")
+	ids := make([]uuid.UUID, 1000)
+	for i := range ids {
+		ids[i] = uuid.New()
+		c.Add(ids[i], "secret", hash)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Verified(ids[i%len(ids)], "secret", hash)
+			i++
+		}
+	})
+}
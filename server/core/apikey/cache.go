@@ -0,0 +1,128 @@
+package apikey
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"sync"
+
+	"github.com/cephei8/greener/server/core/metrics"
+	"github.com/google/uuid"
+)
+
+const DefaultCacheSize = 10000
+
+// Cache remembers secrets that were verified against the stored hash of their key, so that
+// a slow hash is computed once per key rather than once per request. Secrets are kept only as
+// a fingerprint keyed with a random per-process key. An entry counts only for the stored hash
+// it was verified against, so it stops matching once the key is rotated or deleted, even by
+// another instance; Remove frees it right away. The least recently used entries are evicted
+// beyond the size of the cache. A nil *Cache caches nothing.
+type Cache struct {
+	size int
+	key  []byte
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[uuid.UUID]*list.Element
+}
+
+type cacheEntry struct {
+	id          uuid.UUID
+	fingerprint []byte
+	hash        []byte
+}
+
+// NewCache returns a cache of up to size keys, or nil if size is not positive.
+func NewCache(size int) *Cache {
+	if size <= 0 {
+		return nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("apikey: failed to generate cache key: " + err.Error())
+	}
+	return &Cache{
+		size:    size,
+		key:     key,
+		order:   list.New(),
+		entries: make(map[uuid.UUID]*list.Element),
+	}
+}
+
+func (c *Cache) fingerprint(secret string) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(secret))
+	return mac.Sum(nil)
+}
+
+// Verified reports whether secret of key id was verified against hash before.
+func (c *Cache) Verified(id uuid.UUID, secret string, hash []byte) bool {
+	if c == nil {
+		return false
+	}
+	fingerprint := c.fingerprint(secret)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[id]
+	if !ok {
+		metrics.APIKeyCacheLookupsTotal.WithLabelValues("miss").Inc()
+		return false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if subtle.ConstantTimeCompare(entry.fingerprint, fingerprint) != 1 || subtle.ConstantTimeCompare(entry.hash, hash) != 1 {
+		metrics.APIKeyCacheLookupsTotal.WithLabelValues("miss").Inc()
+		return false
+	}
+	c.order.MoveToFront(elem)
+	metrics.APIKeyCacheLookupsTotal.WithLabelValues("hit").Inc()
+	return true
+}
+
+// Add records that secret of key id matches hash, replacing what was recorded for the key.
+func (c *Cache) Add(id uuid.UUID, secret string, hash []byte) {
+	if c == nil {
+		return
+	}
+	entry := &cacheEntry{id: id, fingerprint: c.fingerprint(secret), hash: append([]byte(nil), hash...)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[id] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).id)
+	}
+}
+
+// Remove forgets the secrets of key id.
+func (c *Cache) Remove(id uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// Len returns the number of cached keys.
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -2,21 +2,24 @@ package core
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
 	"html"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/cephei8/greener/server/core/apikey"
+	"github.com/cephei8/greener/server/core/audit"
 	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/quota"
 	"github.com/google/uuid"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 	"github.com/uptrace/bun"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 func APIKeysHandler(c echo.Context) error {
@@ -41,45 +44,23 @@ func APIKeysHandler(c echo.Context) error {
 	}
 
 	db := c.Get("db").(*bun.DB)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
-	var apiKeys []model_db.APIKey
-	err = db.NewSelect().
-		Model(&apiKeys).
-		Where("? = ?", bun.Ident("user_id"), model_db.BinaryUUID(userId)).
-		OrderExpr("created_at DESC").
-		Scan(ctx)
+	apiKeyViews, err := loadAPIKeyViews(ctx, db, userId)
 	if err != nil {
 		c.Logger().Errorf("Failed to load API keys: %v", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load API keys")
 	}
 
-	type APIKeyView struct {
-		ID          string
-		Description string
-		CreatedAt   string
-	}
-
-	apiKeyViews := make([]APIKeyView, len(apiKeys))
-	for i, key := range apiKeys {
-		description := ""
-		if key.Description != nil {
-			description = *key.Description
-		}
-		apiKeyViews[i] = APIKeyView{
-			ID:          key.ID.String(),
-			Description: description,
-			CreatedAt:   key.CreatedAt.Format("2006-01-02 15:04:05"),
-		}
-	}
-
 	role, _ := sess.Values["role"].(string)
 	isViewer := role == string(model_db.RoleViewer)
 
 	return c.Render(http.StatusOK, "apikeys.html", map[string]any{
-		"APIKeys":    apiKeyViews,
-		"ActivePage": "apikeys",
-		"IsViewer":   isViewer,
+		"APIKeys":         apiKeyViews,
+		"ActivePage":      "apikeys",
+		"IsViewer":        isViewer,
+		"IsAuthenticated": true,
+		"IsAdmin":         IsAdmin(c),
 	})
 }
 
@@ -111,36 +92,70 @@ func CreateAPIKeyHandler(c echo.Context) error {
 
 	description := c.FormValue("description")
 
-	db := c.Get("db").(*bun.DB)
-	ctx := context.Background()
+	scope := model_db.ScopeIngest
+	if scopeStr := c.FormValue("scope"); scopeStr != "" {
+		scope, err = model_db.ParseAPIKeyScope(scopeStr)
+		if err != nil {
+			return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Invalid scope</div>`)
+		}
+	}
 
-	secret := make([]byte, 32)
-	if _, err := rand.Read(secret); err != nil {
-		c.Logger().Errorf("Failed to generate secret: %v", err)
-		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to generate API key</div>`)
+	project := strings.TrimSpace(c.FormValue("project"))
+	if utf8.RuneCountInString(project) > maxProjectLength {
+		return c.HTML(http.StatusBadRequest, fmt.Sprintf(`<div class="alert alert-error">Project is longer than %d characters</div>`, maxProjectLength))
+	}
+
+	algorithm := model_db.SecretPBKDF2
+	switch c.FormValue("format") {
+	case "", "standard":
+	case "fast":
+		algorithm = model_db.SecretSHA256
+	default:
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Invalid key format</div>`)
 	}
-	secretStr := base64.URLEncoding.EncodeToString(secret)
 
-	salt := make([]byte, 32)
-	if _, err := rand.Read(salt); err != nil {
-		c.Logger().Errorf("Failed to generate salt: %v", err)
+	var expiresAt *time.Time
+	if expiresStr := c.FormValue("expires_at"); expiresStr != "" {
+		date, err := time.Parse("2006-01-02", expiresStr)
+		if err != nil {
+			return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Invalid expiry date</div>`)
+		}
+		// the key is valid through the whole expiry day
+		expires := date.AddDate(0, 0, 1)
+		if !expires.After(time.Now()) {
+			return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Expiry date must not be in the past</div>`)
+		}
+		expiresAt = &expires
+	}
+
+	db := c.Get("db").(*bun.DB)
+	ctx := c.Request().Context()
+
+	secretStr, salt, secretHash, err := apikey.NewSecret(algorithm)
+	if err != nil {
+		c.Logger().Errorf("Failed to generate secret: %v", err)
 		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to generate API key</div>`)
 	}
-	secretHash := pbkdf2.Key([]byte(secretStr), salt, 100000, 32, sha256.New)
 
 	id := uuid.New()
 	apiKey := &model_db.APIKey{
-		ID:         model_db.BinaryUUID(id),
-		SecretSalt: salt,
-		SecretHash: secretHash,
-		UserID:     model_db.BinaryUUID(userId),
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ID:              model_db.BinaryUUID(id),
+		SecretSalt:      salt,
+		SecretHash:      secretHash,
+		SecretAlgorithm: algorithm,
+		Scope:           scope,
+		ExpiresAt:       expiresAt,
+		UserID:          model_db.BinaryUUID(userId),
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
 	if description != "" {
 		apiKey.Description = &description
 	}
+	if project != "" {
+		apiKey.Project = &project
+	}
 
 	_, err = db.NewInsert().Model(apiKey).Exec(ctx)
 	if err != nil {
@@ -148,83 +163,124 @@ func CreateAPIKeyHandler(c echo.Context) error {
 		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to create API key</div>`)
 	}
 
-	keyData := map[string]string{
-		"apiKeyId":     id.String(),
-		"apiKeySecret": secretStr,
+	details := "scope=" + string(scope)
+	if project != "" {
+		details += " project=" + project
+	}
+	if expiresAt != nil {
+		details += " expires_at=" + expiresAt.UTC().Format(time.RFC3339)
 	}
-	keyJSON, _ := json.Marshal(keyData)
-	key := base64.StdEncoding.EncodeToString(keyJSON)
+	audit.RecordRequest(c, db, audit.RequestActor(c), audit.Event{
+		Action:     audit.ActionAPIKeyCreate,
+		TargetType: audit.TargetAPIKey,
+		TargetID:   id.String(),
+		Details:    details,
+	})
+
+	c.Response().Header().Set("Content-Type", "text/html")
+	return c.HTML(http.StatusOK, apiKeySecretHTML("API Key Created", apiKey, secretStr, ""))
+}
 
-	descDisplay := description
-	if descDisplay == "" {
-		descDisplay = "No description"
+// RotateAPIKeyHandler issues a new secret for an API key. The previous secret stays valid
+// for the grace period given by the "grace_hours" form value, so that clients can be updated.
+func RotateAPIKeyHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return c.HTML(http.StatusUnauthorized, `<div class="alert alert-error">Unauthorized</div>`)
 	}
 
-	html := fmt.Sprintf(`
-		<h3 class="font-bold text-lg mb-4">API Key Created</h3>
-		<div role="alert" class="alert alert-warning mb-4">
-			<svg xmlns="http://www.w3.org/2000/svg" class="h-6 w-6 shrink-0 stroke-current" fill="none" viewBox="0 0 24 24">
-				<path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M12 9v2m0 4h.01m-6.938 4h13.856c1.54 0 2.502-1.667 1.732-3L13.732 4c-.77-1.333-2.694-1.333-3.464 0L3.34 16c-.77 1.333.192 3 1.732 3z" />
-			</svg>
-			<span><strong>Warning:</strong> The key will not be accessible again, copy it now.</span>
-		</div>
+	userIDStr, ok := sess.Values["user_id"].(string)
+	if !ok {
+		sess.Values["authenticated"] = false
+		sess.Save(c.Request(), c.Response())
+		return c.HTML(http.StatusUnauthorized, `<div class="alert alert-error">Unauthorized</div>`)
+	}
 
-		<div class="space-y-3">
-			<div>
-				<label class="label">
-					<span class="label-text font-semibold">ID</span>
-				</label>
-				<div class="font-mono text-sm bg-base-200 p-2 rounded">%s</div>
-			</div>
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.Logger().Errorf("Invalid user_id in session: %v", err)
+		sess.Values["authenticated"] = false
+		sess.Save(c.Request(), c.Response())
+		return c.HTML(http.StatusUnauthorized, `<div class="alert alert-error">Unauthorized</div>`)
+	}
 
-			<div>
-				<label class="label">
-					<span class="label-text font-semibold">Description</span>
-				</label>
-				<div class="text-sm bg-base-200 p-2 rounded">%s</div>
-			</div>
+	role, _ := sess.Values["role"].(string)
+	if role == string(model_db.RoleViewer) {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Viewers cannot rotate API keys. Editor role is required.</div>`)
+	}
 
-			<div>
-				<label class="label">
-					<span class="label-text font-semibold">Created At</span>
-				</label>
-				<div class="text-sm bg-base-200 p-2 rounded">%s</div>
-			</div>
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-error">Invalid ID</div>`)
+	}
 
-			<div>
-				<label class="label">
-					<span class="label-text font-semibold">API Key</span>
-				</label>
-				<div class="flex gap-2">
-					<input
-						type="text"
-						value="%s"
-						class="input input-bordered flex-1 font-mono text-xs"
-						readonly />
-					<button
-						type="button"
-						class="btn btn-primary"
-						onclick="copyToClipboard('%s')">
-						Copy
-					</button>
-				</div>
-			</div>
-		</div>
+	graceHours := 0
+	if graceStr := c.FormValue("grace_hours"); graceStr != "" {
+		graceHours, err = strconv.Atoi(graceStr)
+		if err != nil || graceHours < 0 || graceHours > maxRotationGraceHours {
+			return c.HTML(http.StatusBadRequest, fmt.Sprintf(`<div class="alert alert-error">Grace period must be between 0 and %d hours</div>`, maxRotationGraceHours))
+		}
+	}
 
-		<div class="modal-action">
-			<button
-				type="button"
-				class="btn btn-primary"
-				hx-get="/api-keys"
-				hx-target="body"
-				hx-push-url="true">
-				Done
-			</button>
-		</div>
-	`, id.String(), descDisplay, apiKey.CreatedAt.Format("2006-01-02 15:04:05"), key, key)
+	db := c.Get("db").(*bun.DB)
+	ctx := c.Request().Context()
+
+	var apiKey model_db.APIKey
+	err = db.NewSelect().
+		Model(&apiKey).
+		Where("? = ? AND ? = ?", bun.Ident("id"), model_db.BinaryUUID(id), bun.Ident("user_id"), model_db.BinaryUUID(userID)).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.HTML(http.StatusNotFound, `<div class="alert alert-error">API key not found</div>`)
+	}
+	if err != nil {
+		c.Logger().Errorf("Failed to load API key: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to rotate API key</div>`)
+	}
+
+	secretStr, salt, secretHash, err := apikey.NewSecret(apiKey.SecretAlgorithm)
+	if err != nil {
+		c.Logger().Errorf("Failed to generate secret: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to rotate API key</div>`)
+	}
+
+	now := time.Now()
+	apiKey.PreviousSecretSalt, apiKey.PreviousSecretHash, apiKey.PreviousSecretExpiresAt = nil, nil, nil
+	if graceHours > 0 {
+		graceEnd := now.Add(time.Duration(graceHours) * time.Hour)
+		apiKey.PreviousSecretSalt = apiKey.SecretSalt
+		apiKey.PreviousSecretHash = apiKey.SecretHash
+		apiKey.PreviousSecretExpiresAt = &graceEnd
+	}
+	apiKey.SecretSalt = salt
+	apiKey.SecretHash = secretHash
+	apiKey.UpdatedAt = now
+
+	_, err = db.NewUpdate().
+		Model(&apiKey).
+		Column("secret_salt", "secret_hash", "previous_secret_salt", "previous_secret_hash", "previous_secret_expires_at", "updated_at").
+		Where("? = ?", bun.Ident("id"), apiKey.ID).
+		Exec(ctx)
+	if err != nil {
+		c.Logger().Errorf("Failed to rotate API key: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to rotate API key</div>`)
+	}
+	getSecurity(c).verifiedSecrets.Remove(id)
+
+	audit.RecordRequest(c, db, audit.RequestActor(c), audit.Event{
+		Action:     audit.ActionAPIKeyRotate,
+		TargetType: audit.TargetAPIKey,
+		TargetID:   id.String(),
+		Details:    fmt.Sprintf("grace_hours=%d", graceHours),
+	})
+
+	notice := "The previous secret was revoked."
+	if apiKey.PreviousSecretExpiresAt != nil {
+		notice = fmt.Sprintf("The previous secret remains valid until %s.", apiKey.PreviousSecretExpiresAt.Format("2006-01-02 15:04:05"))
+	}
 
 	c.Response().Header().Set("Content-Type", "text/html")
-	return c.HTML(http.StatusOK, html)
+	return c.HTML(http.StatusOK, apiKeySecretHTML("API Key Rotated", &apiKey, secretStr, notice))
 }
 
 func DeleteAPIKeyHandler(c echo.Context) error {
@@ -257,9 +313,9 @@ func DeleteAPIKeyHandler(c echo.Context) error {
 	}
 
 	db := c.Get("db").(*bun.DB)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
-	_, err = db.NewDelete().
+	res, err := db.NewDelete().
 		Model((*model_db.APIKey)(nil)).
 		Where("? = ? AND ? = ?", bun.Ident("id"), model_db.BinaryUUID(id), bun.Ident("user_id"), model_db.BinaryUUID(userID)).
 		Exec(ctx)
@@ -267,70 +323,211 @@ func DeleteAPIKeyHandler(c echo.Context) error {
 		c.Logger().Errorf("Failed to delete API key: %v", err)
 		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to delete API key</div>`)
 	}
+	getSecurity(c).verifiedSecrets.Remove(id)
+
+	if n, _ := res.RowsAffected(); n > 0 {
+		audit.RecordRequest(c, db, audit.RequestActor(c), audit.Event{
+			Action:     audit.ActionAPIKeyDelete,
+			TargetType: audit.TargetAPIKey,
+			TargetID:   id.String(),
+		})
+	}
+
+	apiKeyViews, err := loadAPIKeyViews(ctx, db, userID)
+	if err != nil {
+		c.Logger().Errorf("Failed to load API keys: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to load API keys</div>`)
+	}
 
+	role, _ := sess.Values["role"].(string)
+
+	return c.Render(http.StatusOK, "apikeys_table.html", map[string]any{
+		"APIKeys":  apiKeyViews,
+		"IsViewer": role == string(model_db.RoleViewer),
+	})
+}
+
+const (
+	// maxProjectLength matches the width of the project column.
+	maxProjectLength = 255
+	// maxRotationGraceHours limits how long a rotated secret may stay valid.
+	maxRotationGraceHours = 30 * 24
+)
+
+type APIKeyView struct {
+	ID          string
+	Description string
+	Scope       model_db.APIKeyScope
+	Fast        bool
+	Project     string
+	CreatedAt   string
+	ExpiresAt   string
+	Expired     bool
+	LastUsedAt  string
+	LastUsedIP  string
+	// GraceUntil is when the previous secret of a rotated key stops being valid
+	GraceUntil string
+	Usage      quota.Counters
+}
+
+// loadAPIKeyViews returns the API keys of a user with their usage of today, newest first.
+func loadAPIKeyViews(ctx context.Context, db *bun.DB, userID uuid.UUID) ([]APIKeyView, error) {
 	var apiKeys []model_db.APIKey
-	err = db.NewSelect().
+	err := db.NewSelect().
 		Model(&apiKeys).
 		Where("? = ?", bun.Ident("user_id"), model_db.BinaryUUID(userID)).
 		OrderExpr("created_at DESC").
 		Scan(ctx)
 	if err != nil {
-		c.Logger().Errorf("Failed to load API keys: %v", err)
-		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-error">Failed to load API keys</div>`)
+		return nil, err
 	}
 
-	tableHTML := ""
-	if len(apiKeys) > 0 {
-		tableHTML = `<div class="overflow-x-auto">
-			<table class="table table-zebra w-full">
-				<thead>
-					<tr>
-						<th class="w-80">ID</th>
-						<th>Description</th>
-						<th class="w-48">Created At</th>
-						<th class="w-24">Actions</th>
-					</tr>
-				</thead>
-				<tbody>`
-
-		for _, key := range apiKeys {
-			description := "No description"
-			if key.Description != nil {
-				description = html.EscapeString(*key.Description)
-			}
-			htmlRow := fmt.Sprintf(`
-					<tr>
-						<td class="font-mono text-xs">%s</td>
-						<td>%s</td>
-						<td class="text-sm">%s</td>
-						<td>
-							<button
-								class="btn btn-sm btn-error"
-								hx-delete="/api-keys/%s"
-								hx-target="#apikeys-table"
-								hx-confirm="Are you sure you want to delete this API key?">
-								Delete
-							</button>
-						</td>
-					</tr>`,
-				html.EscapeString(key.ID.String()),
-				description,
-				html.EscapeString(key.CreatedAt.Format("2006-01-02 15:04:05")),
-				html.EscapeString(key.ID.String()),
-			)
-			tableHTML += htmlRow
+	now := time.Now()
+	usage, err := quota.KeyUsage(ctx, db, userID, quota.Day(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key usage: %w", err)
+	}
+
+	format := func(t *time.Time) string {
+		if t == nil {
+			return ""
 		}
+		return t.Format("2006-01-02 15:04:05")
+	}
 
-		tableHTML += `
-				</tbody>
-			</table>
-		</div>`
-	} else {
-		tableHTML = `<div class="text-center py-12 text-gray-500">
-			<p>No API keys found. Create one to get started.</p>
-		</div>`
+	views := make([]APIKeyView, len(apiKeys))
+	for i, key := range apiKeys {
+		view := APIKeyView{
+			ID:         key.ID.String(),
+			Scope:      key.Scope,
+			Fast:       key.SecretAlgorithm == model_db.SecretSHA256,
+			CreatedAt:  key.CreatedAt.Format("2006-01-02 15:04:05"),
+			ExpiresAt:  format(key.ExpiresAt),
+			Expired:    key.ExpiresAt != nil && !now.Before(*key.ExpiresAt),
+			LastUsedAt: format(key.LastUsedAt),
+			Usage:      usage[uuid.UUID(key.ID)],
+		}
+		if key.Description != nil {
+			view.Description = *key.Description
+		}
+		if key.Project != nil {
+			view.Project = *key.Project
+		}
+		if key.LastUsedIP != nil {
+			view.LastUsedIP = *key.LastUsedIP
+		}
+		if key.PreviousSecretExpiresAt != nil && now.Before(*key.PreviousSecretExpiresAt) {
+			view.GraceUntil = format(key.PreviousSecretExpiresAt)
+		}
+		views[i] = view
 	}
+	return views, nil
+}
 
-	c.Response().Header().Set("Content-Type", "text/html")
-	return c.HTML(http.StatusOK, tableHTML)
+// apiKeySecretHTML renders the modal content that shows a new secret of apiKey once.
+func apiKeySecretHTML(title string, apiKey *model_db.APIKey, secret string, notice string) string {
+	key := formatAPIKey(apiKey, secret)
+
+	descDisplay := "No description"
+	if apiKey.Description != nil && *apiKey.Description != "" {
+		descDisplay = *apiKey.Description
+	}
+	projectDisplay := "All projects"
+	if apiKey.Project != nil {
+		projectDisplay = *apiKey.Project
+	}
+	expiresDisplay := "Never"
+	if apiKey.ExpiresAt != nil {
+		expiresDisplay = apiKey.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	if notice != "" {
+		notice = fmt.Sprintf(`<div role="alert" class="alert alert-info mb-4"><span>%s</span></div>`, html.EscapeString(notice))
+	}
+
+	return fmt.Sprintf(`
+		<h3 class="font-bold text-lg mb-4">%s</h3>
+		<div role="alert" class="alert alert-warning mb-4">
+			<svg xmlns="http://www.w3.org/2000/svg" class="h-6 w-6 shrink-0 stroke-current" fill="none" viewBox="0 0 24 24">
+				<path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M12 9v2m0 4h.01m-6.938 4h13.856c1.54 0 2.502-1.667 1.732-3L13.732 4c-.77-1.333-2.694-1.333-3.464 0L3.34 16c-.77 1.333.192 3 1.732 3z" />
+			</svg>
+			<span><strong>Warning:</strong> The key will not be accessible again, copy it now.</span>
+		</div>
+		%s
+
+		<div class="space-y-3">
+			<div>
+				<label class="label">
+					<span class="label-text font-semibold">ID</span>
+				</label>
+				<div class="font-mono text-sm bg-base-200 p-2 rounded">%s</div>
+			</div>
+
+			<div>
+				<label class="label">
+					<span class="label-text font-semibold">Description</span>
+				</label>
+				<div class="text-sm bg-base-200 p-2 rounded">%s</div>
+			</div>
+
+			<div class="grid grid-cols-3 gap-3">
+				<div>
+					<label class="label">
+						<span class="label-text font-semibold">Scope</span>
+					</label>
+					<div class="text-sm bg-base-200 p-2 rounded">%s</div>
+				</div>
+				<div>
+					<label class="label">
+						<span class="label-text font-semibold">Project</span>
+					</label>
+					<div class="text-sm bg-base-200 p-2 rounded">%s</div>
+				</div>
+				<div>
+					<label class="label">
+						<span class="label-text font-semibold">Expires At</span>
+					</label>
+					<div class="text-sm bg-base-200 p-2 rounded">%s</div>
+				</div>
+			</div>
+
+			<div>
+				<label class="label">
+					<span class="label-text font-semibold">Created At</span>
+				</label>
+				<div class="text-sm bg-base-200 p-2 rounded">%s</div>
+			</div>
+
+			<div>
+				<label class="label">
+					<span class="label-text font-semibold">API Key</span>
+				</label>
+				<div class="flex gap-2">
+					<input
+						type="text"
+						value="%s"
+						class="input input-bordered flex-1 font-mono text-xs"
+						readonly />
+					<button
+						type="button"
+						class="btn btn-primary"
+						onclick="copyToClipboard('%s')">
+						Copy
+					</button>
+				</div>
+			</div>
+		</div>
+
+		<div class="modal-action">
+			<button
+				type="button"
+				class="btn btn-primary"
+				hx-get="/api-keys"
+				hx-target="body"
+				hx-push-url="true">
+				Done
+			</button>
+		</div>
+	`, html.EscapeString(title), notice, apiKey.ID.String(), html.EscapeString(descDisplay),
+		html.EscapeString(string(apiKey.Scope)), html.EscapeString(projectDisplay), expiresDisplay,
+		apiKey.CreatedAt.Format("2006-01-02 15:04:05"), key, key)
 }
@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/apikey"
+	"github.com/cephei8/greener/server/core/lockout"
 	model_db "github.com/cephei8/greener/server/core/model/db"
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
@@ -23,6 +25,10 @@ func (t *apiKeyTestRenderer) Render(w io.Writer, name string, data interface{},
 	return nil
 }
 
+// testSecurity protects the handlers of setupAPIKeyContext without limiting logins. Tests of
+// other policies set their own Security.
+var testSecurity = core.NewSecurity(lockout.Policy{}, core.PasswordPolicy{}, apikey.DefaultCacheSize)
+
 func setupAPIKeyContext(t *testing.T, method, path string, body string, authenticated bool, userID string, role string, db *bun.DB) (echo.Context, *httptest.ResponseRecorder) {
 	e := echo.New()
 	e.Renderer = &apiKeyTestRenderer{}
@@ -47,6 +53,7 @@ func setupAPIKeyContext(t *testing.T, method, path string, body string, authenti
 	c.Set("_session_store", store)
 	c.Set("session", sess)
 	c.Set("db", db)
+	c.Set("security", testSecurity)
 
 	return c, rec
 }
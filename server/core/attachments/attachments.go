@@ -0,0 +1,259 @@
+package attachments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/blob"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+const DefaultMaxSize = 10 << 20
+
+// multipartOverhead is allowed on top of the attachment size for form fields and part headers.
+const multipartOverhead = 1 << 20
+
+type Service struct {
+	db      *bun.DB
+	store   blob.Store
+	maxSize int64
+	// awaitSession is set by AwaitSessions
+	awaitSession func(ctx context.Context, sessionID uuid.UUID)
+}
+
+func NewService(db *bun.DB, store blob.Store, maxSize int64) *Service {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Service{db: db, store: store, maxSize: maxSize}
+}
+
+// AwaitSessions makes uploads wait with await for the session they are attached to, so that
+// they find sessions and testcases that are still queued.
+func (s *Service) AwaitSessions(await func(ctx context.Context, sessionID uuid.UUID)) {
+	s.awaitSession = await
+}
+
+type AttachmentResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type UploadResponse struct {
+	Attachments []AttachmentResponse `json:"attachments"`
+}
+
+func storageRef(sessionID, attachmentID model_db.BinaryUUID) string {
+	return sessionID.String() + "/" + attachmentID.String()
+}
+
+func contentType(header *multipart.FileHeader) string {
+	if ct := header.Header.Get("Content-Type"); ct != "" && ct != "application/octet-stream" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err == nil {
+			return mediaType
+		}
+	}
+	if ct := mime.TypeByExtension(filepath.Ext(header.Filename)); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err == nil {
+			return mediaType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// UploadHandler stores files from the "file" parts of a multipart request.
+// The "sessionId" field is required; "testcaseId" links the files to a testcase of that session.
+func (s *Service) UploadHandler(c echo.Context) error {
+	userID := core.GetUserId(c)
+	ctx := c.Request().Context()
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, s.maxSize+multipartOverhead)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments exceed the size limit of %d bytes", s.maxSize))
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid multipart request")
+	}
+	defer form.RemoveAll()
+
+	sessionUUID, err := uuid.Parse(c.FormValue("sessionId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Cannot parse session ID")
+	}
+	sessionID := model_db.BinaryUUID(sessionUUID)
+	if s.awaitSession != nil {
+		s.awaitSession(ctx, sessionUUID)
+	}
+
+	var session model_db.Session
+	err = s.db.NewSelect().
+		Model(&session).
+		Where("? = ?", bun.Ident("id"), sessionID).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown session ID")
+		}
+		c.Logger().Errorf("Failed to find session: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify session")
+	}
+	if session.UserID != userID {
+		return echo.NewHTTPError(http.StatusBadRequest, "Session not found")
+	}
+	if err := core.CheckSessionProject(ctx, s.db, c.Logger(), sessionID); err != nil {
+		return err
+	}
+
+	var testcaseID *model_db.BinaryUUID
+	if testcaseIDStr := c.FormValue("testcaseId"); testcaseIDStr != "" {
+		testcaseUUID, err := uuid.Parse(testcaseIDStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Cannot parse testcase ID")
+		}
+		id := model_db.BinaryUUID(testcaseUUID)
+
+		exists, err := s.db.NewSelect().
+			Model((*model_db.Testcase)(nil)).
+			Where("? = ?", bun.Ident("id"), id).
+			Where("? = ?", bun.Ident("session_id"), sessionID).
+			Exists(ctx)
+		if err != nil {
+			c.Logger().Errorf("Failed to find testcase: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify testcase")
+		}
+		if !exists {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown testcase ID")
+		}
+		testcaseID = &id
+	}
+
+	files := form.File["file"]
+	if len(files) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "No files in request")
+	}
+
+	var total int64
+	for _, header := range files {
+		total += header.Size
+	}
+	if total > s.maxSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments exceed the size limit of %d bytes", s.maxSize))
+	}
+
+	resp := UploadResponse{Attachments: []AttachmentResponse{}}
+	now := time.Now()
+
+	for _, header := range files {
+		attachment := &model_db.Attachment{
+			ID:          model_db.BinaryUUID(uuid.New()),
+			SessionID:   sessionID,
+			TestcaseID:  testcaseID,
+			Name:        filepath.Base(header.Filename),
+			ContentType: contentType(header),
+			Size:        header.Size,
+			CreatedAt:   now,
+			UserID:      userID,
+		}
+		attachment.StorageRef = storageRef(sessionID, attachment.ID)
+
+		if err := s.storeFile(c, attachment, header); err != nil {
+			c.Logger().Errorf("Failed to store attachment: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store attachment")
+		}
+
+		if _, err := s.db.NewInsert().Model(attachment).Exec(ctx); err != nil {
+			c.Logger().Errorf("Failed to insert attachment: %v", err)
+			_ = s.store.Delete(ctx, attachment.StorageRef)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create attachment")
+		}
+
+		resp.Attachments = append(resp.Attachments, AttachmentResponse{
+			ID:   attachment.ID.String(),
+			Name: attachment.Name,
+			Size: attachment.Size,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+func (s *Service) storeFile(c echo.Context, attachment *model_db.Attachment, header *multipart.FileHeader) error {
+	f, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Put(c.Request().Context(), attachment.StorageRef, data)
+}
+
+func (s *Service) DownloadHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	auth, _ := sess.Values["authenticated"].(bool)
+
+	if !auth && !core.AllowUnauthenticatedViewers(c) {
+		return c.Redirect(http.StatusFound, "/login")
+	}
+
+	attachmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid attachment ID")
+	}
+
+	ctx := c.Request().Context()
+
+	var attachment model_db.Attachment
+	err = s.db.NewSelect().
+		Model(&attachment).
+		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(attachmentID)).
+		Scan(ctx)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Attachment not found")
+	}
+
+	data, err := s.store.Get(ctx, attachment.StorageRef)
+	if err != nil {
+		c.Logger().Errorf("Failed to fetch attachment: %v", err)
+		if errors.Is(err, blob.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Attachment not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch attachment")
+	}
+
+	// Only images known to be safe are shown inline; everything else
+	// (including HTML and SVG) is served as a download.
+	disposition := "attachment"
+	contentType := "application/octet-stream"
+	if core.IsInlineImage(attachment.ContentType) {
+		contentType = attachment.ContentType
+		if c.QueryParam("download") == "" {
+			disposition = "inline"
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	return c.Blob(http.StatusOK, contentType, data)
+}
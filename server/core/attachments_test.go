@@ -0,0 +1,167 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/attachments"
+	"github.com/cephei8/greener/server/core/blob"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+)
+
+type uploadFile struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+func (s *BaseSuite) uploadAttachments(svc *attachments.Service, fields map[string]string, files ...uploadFile) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		s.Require().NoError(w.WriteField(k, v))
+	}
+	for _, f := range files {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="file"; filename="`+f.name+`"`)
+		h.Set("Content-Type", f.contentType)
+		part, err := w.CreatePart(h)
+		s.Require().NoError(err)
+		_, err = part.Write(f.data)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(w.Close())
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingress/attachments", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", s.userID)
+
+	if err := svc.UploadHandler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func (s *BaseSuite) downloadAttachment(svc *attachments.Service, id string) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/attachments/"+id, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+
+	store := sessions.NewCookieStore([]byte("test-secret"))
+	sess, _ := store.Get(req, "session")
+	sess.Values["authenticated"] = true
+	c.Set("_session_store", store)
+	c.Set("session", sess)
+
+	if err := svc.DownloadHandler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func (s *BaseSuite) TestAttachments() {
+	ctx := context.Background()
+
+	storeDir := s.T().TempDir()
+	store, err := blob.NewFSStore(storeDir)
+	s.Require().NoError(err)
+	svc := attachments.NewService(s.db, store, 1024)
+
+	sessionID := s.createRetentionSession(ctx, time.Now().Add(-96*time.Hour), map[string]string{"attachments": "test"})
+	s.purgeOnCleanup(sessionID, retention.Stores{Attachments: store})
+	sessionIDStr := uuid.UUID(sessionID).String()
+
+	png := []byte("\x89PNG\r\n\x1a\nfake")
+	rec := s.uploadAttachments(svc,
+		map[string]string{"sessionId": sessionIDStr, "testcaseId": s.testcase1Id.String()},
+		uploadFile{name: "screenshot.png", contentType: "image/png", data: png},
+	)
+	s.Equal(http.StatusBadRequest, rec.Code, "testcase of another session")
+
+	testcaseID := s.createRetentionTestcase(ctx, sessionID)
+
+	rec = s.uploadAttachments(svc,
+		map[string]string{"sessionId": sessionIDStr, "testcaseId": testcaseID.String()},
+		uploadFile{name: "screenshot.png", contentType: "image/png", data: png},
+		uploadFile{name: "report.html", contentType: "text/html", data: []byte("<script>alert(1)</script>")},
+	)
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var resp attachments.UploadResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Require().Len(resp.Attachments, 2)
+
+	rec = s.uploadAttachments(svc,
+		map[string]string{"sessionId": sessionIDStr},
+		uploadFile{name: "run.log", contentType: "", data: []byte("session log")},
+	)
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = s.uploadAttachments(svc,
+		map[string]string{"sessionId": sessionIDStr},
+		uploadFile{name: "huge.bin", contentType: "application/octet-stream", data: bytes.Repeat([]byte("x"), 2048)},
+	)
+	s.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = s.uploadAttachments(svc, map[string]string{"sessionId": uuid.NewString()},
+		uploadFile{name: "a.txt", contentType: "text/plain", data: []byte("a")},
+	)
+	s.Equal(http.StatusBadRequest, rec.Code)
+
+	querySvc := core.NewQueryService(s.db)
+
+	testcase, err := querySvc.GetTestcase(ctx, s.userID, testcaseID)
+	s.Require().NoError(err)
+	s.Require().Len(testcase.Attachments, 2)
+	s.Equal("screenshot.png", testcase.Attachments[0].Name)
+	s.Equal("image/png", testcase.Attachments[0].ContentType)
+	s.Equal(int64(len(png)), testcase.Attachments[0].Size)
+	s.True(testcase.Attachments[0].IsImage())
+	s.False(testcase.Attachments[1].IsImage())
+
+	session, err := querySvc.GetSession(ctx, s.userID, uuid.UUID(sessionID))
+	s.Require().NoError(err)
+	s.Require().Len(session.Attachments, 1)
+	s.Equal("run.log", session.Attachments[0].Name)
+
+	rec = s.downloadAttachment(svc, testcase.Attachments[0].ID)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.Equal("image/png", rec.Header().Get(echo.HeaderContentType))
+	s.Equal(`inline; filename=screenshot.png`, rec.Header().Get(echo.HeaderContentDisposition))
+	s.Equal(png, rec.Body.Bytes())
+
+	rec = s.downloadAttachment(svc, testcase.Attachments[1].ID)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.Equal("application/octet-stream", rec.Header().Get(echo.HeaderContentType))
+	s.Equal(`attachment; filename=report.html`, rec.Header().Get(echo.HeaderContentDisposition))
+
+	rec = s.downloadAttachment(svc, uuid.NewString())
+	s.Equal(http.StatusNotFound, rec.Code)
+
+	report, err := retention.NewPurger(s.db, retention.Policy{MaxAge: 72 * time.Hour}, retention.Stores{Attachments: store}).Purge(ctx, false)
+	s.Require().NoError(err)
+	s.Equal(int64(1), report.Sessions)
+	s.Equal(int64(3), report.Attachments)
+
+	entries, err := os.ReadDir(filepath.Join(storeDir, sessionIDStr))
+	s.Require().NoError(err)
+	s.Empty(entries)
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+var errUnknownRetriedTestcase = errors.New("unknown retried testcase")
+
+// previousAttempt finds the attempt that tc retries within the session.
+// An explicit retryOf is looked up directly; otherwise the latest attempt with the same
+// name, classname, testsuite and file is used. A request with attempt 1 starts a new testcase.
+// Returns nil if tc is a first attempt.
+func previousAttempt(
+	ctx context.Context,
+	db bun.IDB,
+	sessionID model_db.BinaryUUID,
+	tc TestcaseRequest,
+	retryOf *uuid.UUID,
+) (*model_db.Testcase, error) {
+	var prev model_db.Testcase
+	q := db.NewSelect().
+		Model(&prev).
+		Column("id", "status", "attempt").
+		Where("? = ?", bun.Ident("session_id"), sessionID)
+
+	if retryOf != nil {
+		err := q.Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(*retryOf)).Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errUnknownRetriedTestcase
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &prev, nil
+	}
+
+	if tc.Attempt != nil && *tc.Attempt == 1 {
+		return nil, nil
+	}
+
+	q = q.
+		Where("? = ?", bun.Ident("name"), tc.TestcaseName).
+		Where("? = ?", bun.Ident("superseded"), false)
+	q = whereNullableEq(q, "classname", tc.TestcaseClassname)
+	q = whereNullableEq(q, "testsuite", tc.Testsuite)
+	q = whereNullableEq(q, "file", tc.TestcaseFile)
+
+	err := q.OrderExpr("? DESC", bun.Ident("attempt")).Limit(1).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prev, nil
+}
+
+// applyAttempt links testcase to the attempt it retries and derives its final status:
+// a pass following a failed attempt is recorded as flaky.
+func applyAttempt(testcase *model_db.Testcase, prev *model_db.Testcase, attempt *int) {
+	testcase.Attempt = 1
+	if attempt != nil {
+		testcase.Attempt = *attempt
+	}
+
+	if prev == nil {
+		return
+	}
+
+	prevID := prev.ID
+	testcase.RetryOf = &prevID
+	if attempt == nil {
+		testcase.Attempt = prev.Attempt + 1
+	}
+
+	if testcase.Status == model_db.StatusPass {
+		switch prev.Status {
+		case model_db.StatusFail, model_db.StatusError, model_db.StatusFlaky:
+			testcase.Status = model_db.StatusFlaky
+		}
+	}
+}
+
+func whereNullableEq(q *bun.SelectQuery, column string, value *string) *bun.SelectQuery {
+	if value == nil {
+		return q.Where("? IS NULL", bun.Ident(column))
+	}
+	return q.Where("? = ?", bun.Ident(column), *value)
+}
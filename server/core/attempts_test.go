@@ -0,0 +1,115 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *BaseSuite) TestTestcaseAttempts() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"attempts": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	_, err := s.sendTestcases(testcasesRequest{}, `{"testcases": [
+		{"sessionId": "`+sid+`", "testcaseName": "test_flaky", "testcaseClassname": "TestRetry", "status": "fail"},
+		{"sessionId": "`+sid+`", "testcaseName": "test_flaky", "testcaseClassname": "TestRetry", "status": "error"},
+		{"sessionId": "`+sid+`", "testcaseName": "test_flaky", "testcaseClassname": "TestRetry", "status": "pass"},
+		{"sessionId": "`+sid+`", "testcaseName": "test_stable", "status": "pass"},
+		{"sessionId": "`+sid+`", "testcaseName": "test_stable", "status": "pass", "attempt": 1},
+		{"sessionId": "`+sid+`", "testcaseName": "test_broken", "status": "fail"},
+		{"sessionId": "`+sid+`", "testcaseName": "test_broken", "status": "fail"}
+	]}`)
+	s.Require().NoError(err)
+
+	result, err := svc.QueryTestcases(ctx, s.userID, core.QueryParams{
+		Query: `session_id = "` + sid + `" and name != "test_retention"`,
+	})
+	s.Require().NoError(err)
+	statuses := map[string][]string{}
+	for _, tc := range result.Results {
+		statuses[tc.Name] = append(statuses[tc.Name], tc.Status)
+	}
+	s.Equal(map[string][]string{
+		"test_flaky":  {"flaky"},
+		"test_stable": {"pass", "pass"},
+		"test_broken": {"fail"},
+	}, statuses)
+
+	result, err = svc.QueryTestcases(ctx, s.userID, core.QueryParams{
+		Query: `session_id = "` + sid + `" and status = "flaky"`,
+	})
+	s.Require().NoError(err)
+	s.Require().Len(result.Results, 1)
+
+	detail, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(result.Results[0].ID))
+	s.Require().NoError(err)
+	s.Equal(3, detail.Attempt)
+	s.Require().Len(detail.Attempts, 3)
+	s.Equal([]string{"fail", "error", "flaky"}, []string{
+		detail.Attempts[0].Status, detail.Attempts[1].Status, detail.Attempts[2].Status,
+	})
+	s.True(detail.Attempts[2].Current)
+
+	session, err := svc.GetSession(ctx, s.userID, uuid.UUID(sessionID))
+	s.Require().NoError(err)
+	s.Equal("fail", session.Status)
+
+	// an explicit retry of the last failure makes the whole session flaky
+	broken := mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_broken"`)
+	s.Require().Len(broken, 1)
+	_, err = s.sendTestcases(testcasesRequest{}, `{"testcases": [
+		{"sessionId": "`+sid+`", "testcaseName": "test_broken_rerun", "status": "pass", "retryOf": "`+broken[0]+`", "attempt": 5}
+	]}`)
+	s.Require().NoError(err)
+
+	session, err = svc.GetSession(ctx, s.userID, uuid.UUID(sessionID))
+	s.Require().NoError(err)
+	s.Equal("flaky", session.Status)
+
+	rerun := mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_broken_rerun"`)
+	s.Require().Len(rerun, 1)
+	detail, err = svc.GetTestcase(ctx, s.userID, uuid.MustParse(rerun[0]))
+	s.Require().NoError(err)
+	s.Equal("flaky", detail.Status)
+	s.Equal(5, detail.Attempt)
+}
+
+func (s *BaseSuite) TestTestcaseAttemptsInvalid() {
+	sid := s.session1Id.String()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"non-positive attempt", `{"sessionId": "` + sid + `", "testcaseName": "t", "status": "pass", "attempt": 0}`},
+		{"malformed retryOf", `{"sessionId": "` + sid + `", "testcaseName": "t", "status": "pass", "retryOf": "nope"}`},
+		{"unknown retryOf", `{"sessionId": "` + sid + `", "testcaseName": "t", "status": "pass", "retryOf": "` + uuid.NewString() + `"}`},
+		{"retryOf from another session", `{"sessionId": "` + sid + `", "testcaseName": "t", "status": "pass", "retryOf": "` + s.testcase6Id.String() + `"}`},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := s.sendTestcases(testcasesRequest{}, `{"testcases": [`+tt.body+`]}`)
+			s.Require().Error(err)
+			var httpErr *echo.HTTPError
+			s.Require().ErrorAs(err, &httpErr)
+			s.Equal(http.StatusBadRequest, httpErr.Code)
+		})
+	}
+}
+
+func mustQuery(s *BaseSuite, svc *core.QueryService, q string) []string {
+	result, err := svc.QueryTestcases(context.Background(), s.userID, core.QueryParams{Query: q})
+	s.Require().NoError(err)
+	ids := make([]string, 0, len(result.Results))
+	for _, tc := range result.Results {
+		ids = append(ids, tc.ID)
+	}
+	return ids
+}
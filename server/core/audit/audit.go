@@ -0,0 +1,136 @@
+// Package audit records administrative and data-changing actions, and lists and exports them.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin               = "login"
+	ActionLoginLockout        = "login.lockout"
+	ActionUserCreate          = "user.create"
+	ActionUserUpdate          = "user.update"
+	ActionUserDisable         = "user.disable"
+	ActionUserEnable          = "user.enable"
+	ActionUserPasswordReset   = "user.password_reset"
+	ActionUserPasswordChange  = "user.password_change"
+	ActionUserRevoke          = "user.revoke"
+	ActionUserUnlock          = "user.unlock"
+	ActionAPIKeyCreate        = "apikey.create"
+	ActionAPIKeyRotate        = "apikey.rotate"
+	ActionAPIKeyDelete        = "apikey.delete"
+	ActionOAuthClientRegister = "oauth.client.register"
+	ActionOAuthAuthorize      = "oauth.authorize"
+	ActionSessionUpdate       = "session.update"
+	ActionSessionPurge        = "session.purge"
+	ActionAlertRuleCreate     = "alert_rule.create"
+	ActionAlertRuleDelete     = "alert_rule.delete"
+)
+
+// Types of the targets of actions.
+const (
+	TargetUser        = "user"
+	TargetAPIKey      = "api_key"
+	TargetOAuthClient = "oauth_client"
+	TargetSession     = "session"
+	TargetAlertRule   = "alert_rule"
+)
+
+// Actor is who performed an action. Name is a username, "apikey:<id>" for API requests,
+// or the name of a non-user actor such as System.
+type Actor struct {
+	UserID   *model_db.BinaryUUID
+	Name     string
+	APIKeyID *model_db.BinaryUUID
+	IP       string
+}
+
+var (
+	// System performs the actions of the server itself, such as scheduled retention purges.
+	System = Actor{Name: "system"}
+	// Anonymous performs the actions of unauthenticated requests.
+	Anonymous = Actor{Name: "anonymous"}
+)
+
+// UserActor returns the actor for a user.
+func UserActor(userID model_db.BinaryUUID, username string) Actor {
+	return Actor{UserID: &userID, Name: username}
+}
+
+// APIKeyActor returns the actor for requests authenticated with API key keyID of a user.
+func APIKeyActor(userID, keyID model_db.BinaryUUID) Actor {
+	return Actor{UserID: &userID, Name: "apikey:" + keyID.String(), APIKeyID: &keyID}
+}
+
+// RequestActor returns the user logged in to the web session of c, or Anonymous,
+// with the client IP of c.
+func RequestActor(c echo.Context) Actor {
+	actor := Anonymous
+	if sess, err := session.Get("session", c); err == nil {
+		if auth, ok := sess.Values["authenticated"].(bool); ok && auth {
+			userIDStr, _ := sess.Values["user_id"].(string)
+			username, _ := sess.Values["username"].(string)
+			if userID, err := uuid.Parse(userIDStr); err == nil {
+				actor = UserActor(model_db.BinaryUUID(userID), username)
+			}
+		}
+	}
+	actor.IP = clientip.FromContext(c)
+	return actor
+}
+
+// Event is an action to record. Empty fields are stored as NULL.
+type Event struct {
+	Action     string
+	Failed     bool
+	TargetType string
+	TargetID   string
+	Details    string
+}
+
+// Record stores event performed by actor.
+func Record(ctx context.Context, db bun.IDB, actor Actor, event Event) error {
+	entry := &model_db.AuditEntry{
+		Action:        event.Action,
+		Outcome:       model_db.AuditSuccess,
+		ActorUserID:   actor.UserID,
+		ActorName:     actor.Name,
+		ActorAPIKeyID: actor.APIKeyID,
+		SourceIP:      optional(clientip.Parse(actor.IP)),
+		TargetType:    optional(event.TargetType),
+		TargetID:      optional(event.TargetID),
+		Details:       optional(event.Details),
+		CreatedAt:     time.Now().UTC(),
+	}
+	if event.Failed {
+		entry.Outcome = model_db.AuditFailure
+	}
+	_, err := db.NewInsert().Model(entry).Exec(ctx)
+	return err
+}
+
+// RecordRequest stores event performed by actor during request c. The action has already
+// happened, so a failure to record it is logged rather than failing the request.
+func RecordRequest(c echo.Context, db bun.IDB, actor Actor, event Event) {
+	// the entry is stored even if the client has gone away in the meantime
+	ctx := context.WithoutCancel(c.Request().Context())
+	if err := Record(ctx, db, actor, event); err != nil {
+		c.Logger().Errorf("Failed to record audit entry %s: %v", event.Action, err)
+	}
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
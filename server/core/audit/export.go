@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/uptrace/bun"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+const exportBatchSize = 1000
+
+// Filter selects audit entries. Zero fields do not restrict the selection.
+type Filter struct {
+	Since time.Time
+	Until time.Time
+	// Action matches the action itself and the actions under it, e.g. "apikey" matches "apikey.create".
+	Action string
+	Actor  string
+	// BeforeID pages through entries: only entries older than the entry with this ID are selected.
+	BeforeID int64
+	Limit    int
+}
+
+func (f Filter) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	if !f.Since.IsZero() {
+		q = q.Where("? >= ?", bun.Ident("created_at"), f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("? < ?", bun.Ident("created_at"), f.Until.UTC())
+	}
+	if f.Action != "" {
+		q = q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("? = ?", bun.Ident("action"), f.Action).
+				WhereOr("? LIKE ?", bun.Ident("action"), f.Action+".%")
+		})
+	}
+	if f.Actor != "" {
+		q = q.Where("? = ?", bun.Ident("actor_name"), f.Actor)
+	}
+	return q
+}
+
+// List returns the entries selected by filter, newest first.
+func List(ctx context.Context, db bun.IDB, filter Filter) ([]model_db.AuditEntry, error) {
+	var entries []model_db.AuditEntry
+	q := filter.apply(db.NewSelect().Model(&entries))
+	if filter.BeforeID > 0 {
+		q = q.Where("? < ?", bun.Ident("id"), filter.BeforeID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	if err := q.OrderExpr("? DESC", bun.Ident("id")).Scan(ctx); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ExportRecord is the exported form of an audit entry.
+type ExportRecord struct {
+	ID            int64     `json:"id"`
+	Time          time.Time `json:"time"`
+	Action        string    `json:"action"`
+	Outcome       string    `json:"outcome"`
+	Actor         string    `json:"actor"`
+	ActorUserID   string    `json:"actor_user_id,omitempty"`
+	ActorAPIKeyID string    `json:"actor_api_key_id,omitempty"`
+	SourceIP      string    `json:"source_ip,omitempty"`
+	TargetType    string    `json:"target_type,omitempty"`
+	TargetID      string    `json:"target_id,omitempty"`
+	Details       string    `json:"details,omitempty"`
+}
+
+var csvHeader = []string{
+	"id", "time", "action", "outcome", "actor", "actor_user_id", "actor_api_key_id",
+	"source_ip", "target_type", "target_id", "details",
+}
+
+func NewExportRecord(entry model_db.AuditEntry) ExportRecord {
+	record := ExportRecord{
+		ID:         entry.ID,
+		Time:       entry.CreatedAt.UTC(),
+		Action:     entry.Action,
+		Outcome:    string(entry.Outcome),
+		Actor:      entry.ActorName,
+		SourceIP:   deref(entry.SourceIP),
+		TargetType: deref(entry.TargetType),
+		TargetID:   deref(entry.TargetID),
+		Details:    deref(entry.Details),
+	}
+	if entry.ActorUserID != nil {
+		record.ActorUserID = entry.ActorUserID.String()
+	}
+	if entry.ActorAPIKeyID != nil {
+		record.ActorAPIKeyID = entry.ActorAPIKeyID.String()
+	}
+	return record
+}
+
+func (r ExportRecord) csv() []string {
+	return []string{
+		strconv.FormatInt(r.ID, 10), r.Time.Format(time.RFC3339), r.Action, r.Outcome, r.Actor,
+		r.ActorUserID, r.ActorAPIKeyID, r.SourceIP, r.TargetType, r.TargetID, r.Details,
+	}
+}
+
+// Export writes the entries selected by filter to w in format, oldest first.
+// filter.BeforeID and filter.Limit are ignored.
+func Export(ctx context.Context, db bun.IDB, w io.Writer, format string, filter Filter) (int, error) {
+	var write func(ExportRecord) error
+	var flush func() error
+	switch format {
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		write = func(r ExportRecord) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return 0, err
+		}
+		write = func(r ExportRecord) error { return cw.Write(r.csv()) }
+		flush = func() error { cw.Flush(); return cw.Error() }
+	default:
+		return 0, fmt.Errorf("unsupported format %q (must be %s or %s)", format, FormatJSONL, FormatCSV)
+	}
+
+	count := 0
+	var afterID int64
+	for {
+		var entries []model_db.AuditEntry
+		err := filter.apply(db.NewSelect().Model(&entries)).
+			Where("? > ?", bun.Ident("id"), afterID).
+			OrderExpr("? ASC", bun.Ident("id")).
+			Limit(exportBatchSize).
+			Scan(ctx)
+		if err != nil {
+			return count, err
+		}
+		for _, entry := range entries {
+			if err := write(NewExportRecord(entry)); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(entries) < exportBatchSize {
+			break
+		}
+		afterID = entries[len(entries)-1].ID
+	}
+	return count, flush()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+const auditPageSize = 100
+
+// recordAudit stores an audit entry of an action that already happened, so a failure
+// to store it is only logged.
+func recordAudit(ctx context.Context, db bun.IDB, logger echo.Logger, actor audit.Actor, event audit.Event) {
+	if err := audit.Record(context.WithoutCancel(ctx), db, actor, event); err != nil {
+		logger.Errorf("Failed to record audit entry %s: %v", event.Action, err)
+	}
+}
+
+// auditFilter reads the filter of the audit log from the "action", "actor", "since" and
+// "until" query parameters; dates are inclusive.
+func auditFilter(c echo.Context) (audit.Filter, error) {
+	filter := audit.Filter{
+		Action: strings.TrimSpace(c.QueryParam("action")),
+		Actor:  strings.TrimSpace(c.QueryParam("actor")),
+	}
+	if since := c.QueryParam("since"); since != "" {
+		date, err := time.Parse("2006-01-02", since)
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid since date")
+		}
+		filter.Since = date
+	}
+	if until := c.QueryParam("until"); until != "" {
+		date, err := time.Parse("2006-01-02", until)
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid until date")
+		}
+		filter.Until = date.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// AuditLogHandler shows the audit log to admins, newest entries first.
+func AuditLogHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return c.Redirect(http.StatusFound, "/login")
+	}
+	if !IsAdmin(c) {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Admin role is required to view the audit log</div>`)
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		return err
+	}
+	if before := c.QueryParam("before"); before != "" {
+		filter.BeforeID, err = strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before ID")
+		}
+	}
+	// one more entry tells whether there is a next page
+	filter.Limit = auditPageSize + 1
+
+	db := c.Get("db").(*bun.DB)
+	entries, err := audit.List(c.Request().Context(), db, filter)
+	if err != nil {
+		c.Logger().Errorf("Failed to load audit log: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load audit log")
+	}
+
+	var nextBefore int64
+	if len(entries) > auditPageSize {
+		entries = entries[:auditPageSize]
+		nextBefore = entries[auditPageSize-1].ID
+	}
+	records := make([]audit.ExportRecord, len(entries))
+	for i, entry := range entries {
+		records[i] = audit.NewExportRecord(entry)
+	}
+
+	return c.Render(http.StatusOK, "audit.html", map[string]any{
+		"Entries":         records,
+		"Action":          filter.Action,
+		"Actor":           filter.Actor,
+		"Since":           c.QueryParam("since"),
+		"Until":           c.QueryParam("until"),
+		"NextBefore":      nextBefore,
+		"ActivePage":      "audit",
+		"IsAuthenticated": true,
+		"IsAdmin":         true,
+	})
+}
+
+// AuditExportHandler downloads the audit log selected by the filter of AuditLogHandler
+// in the format given by the "format" query parameter (jsonl or csv).
+func AuditExportHandler(c echo.Context) error {
+	if !IsAdmin(c) {
+		return echo.NewHTTPError(http.StatusForbidden, "Admin role is required to export the audit log")
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		return err
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = audit.FormatJSONL
+	}
+	if format != audit.FormatJSONL && format != audit.FormatCSV {
+		return echo.NewHTTPError(http.StatusBadRequest, "Format must be jsonl or csv")
+	}
+
+	contentType := MIMEApplicationNDJSON
+	if format == audit.FormatCSV {
+		contentType = "text/csv"
+	}
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit.`+format+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	db := c.Get("db").(*bun.DB)
+	if _, err := audit.Export(c.Request().Context(), db, c.Response(), format, filter); err != nil {
+		// the response has started, so the export can only be cut short
+		c.Logger().Errorf("Failed to export audit log: %v", err)
+	}
+	return nil
+}
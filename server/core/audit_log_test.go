@@ -0,0 +1,157 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *BaseSuite) TestAuditLog() {
+	ctx := context.Background()
+
+	salt := []byte("audit-salt")
+	admin := &model_db.User{
+		ID:           model_db.BinaryUUID(uuid.New()),
+		Username:     "auditor",
+		PasswordSalt: salt,
+		PasswordHash: core.HashSecret("correct horse", salt),
+		Role:         model_db.RoleAdmin,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	_, err := s.db.NewInsert().Model(admin).Exec(ctx)
+	s.Require().NoError(err)
+
+	sessionID := uuid.New()
+	_, err = s.db.NewInsert().Model(&model_db.Session{
+		ID:        model_db.BinaryUUID(sessionID),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    s.userID,
+	}).Exec(ctx)
+	s.Require().NoError(err)
+	defer func() {
+		s.db.NewDelete().Model((*model_db.Session)(nil)).Where("id = ?", model_db.BinaryUUID(sessionID)).Exec(ctx)
+		s.db.NewDelete().Model((*model_db.User)(nil)).Where("id = ?", admin.ID).Exec(ctx)
+	}()
+
+	login := func(username, password string) int {
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username="+username+"&password="+password, false, "", "", s.db)
+		c.Request().RemoteAddr = "192.0.2.7:1234"
+		// forwarding headers of clients are not trusted, however long
+		c.Request().Header.Set(echo.HeaderXForwardedFor, "198.51.100.7, "+strings.Repeat("f", 100))
+		c.Echo().IPExtractor = echo.ExtractIPDirect()
+		s.Require().NoError(core.LoginHandler(c))
+		return rec.Code
+	}
+	s.Equal(http.StatusUnauthorized, login("auditor", "wrong"))
+	s.Equal(http.StatusOK, login("auditor", "correct+horse"))
+	s.Equal(http.StatusUnauthorized, login("nobody", "secret"))
+
+	entries, err := audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLogin, Actor: "auditor"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	s.Equal(model_db.AuditSuccess, entries[0].Outcome)
+	s.Equal(model_db.AuditFailure, entries[1].Outcome)
+	s.Require().NotNil(entries[1].Details)
+	s.Equal("invalid password", *entries[1].Details)
+	s.Require().NotNil(entries[0].ActorUserID)
+	s.Equal(admin.ID, *entries[0].ActorUserID)
+	s.Require().NotNil(entries[0].SourceIP)
+	s.Equal("192.0.2.7", *entries[0].SourceIP)
+	s.Require().NotNil(entries[0].TargetID)
+	s.Equal(admin.ID.String(), *entries[0].TargetID)
+
+	entries, err = audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLogin, Actor: "nobody"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(model_db.AuditFailure, entries[0].Outcome)
+	s.Nil(entries[0].ActorUserID)
+
+	// only valid addresses are stored, so that they fit the column
+	s.Require().NoError(audit.Record(ctx, s.db, audit.Actor{Name: "spoofer", IP: strings.Repeat("1", 100)}, audit.Event{Action: audit.ActionLogin, Failed: true}))
+	entries, err = audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLogin, Actor: "spoofer"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Nil(entries[0].SourceIP)
+
+	// the admin edits a session from the UI
+	c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/sessions/"+sessionID.String()+"/labels", "key=audited&value=yes", true, admin.ID.String(), string(admin.Role), s.db)
+	sess, _ := session.Get("session", c)
+	sess.Values["username"] = admin.Username
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	s.Require().NoError(core.SetSessionLabelHandler(c))
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	entries, err = audit.List(ctx, s.db, audit.Filter{Action: "session", Actor: "auditor"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(audit.ActionSessionUpdate, entries[0].Action)
+	s.Equal(sessionID.String(), *entries[0].TargetID)
+	s.Equal("set labels audited", *entries[0].Details)
+
+	// paging
+	entries, err = audit.List(ctx, s.db, audit.Filter{Actor: "auditor", Limit: 2})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	older, err := audit.List(ctx, s.db, audit.Filter{Actor: "auditor", BeforeID: entries[1].ID})
+	s.Require().NoError(err)
+	s.Require().Len(older, 1)
+	s.Equal("invalid password", *older[0].Details)
+
+	var buf bytes.Buffer
+	count, err := audit.Export(ctx, s.db, &buf, audit.FormatJSONL, audit.Filter{Actor: "auditor"})
+	s.Require().NoError(err)
+	s.Equal(3, count)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	s.Require().Len(lines, 3)
+	var record audit.ExportRecord
+	s.Require().NoError(json.Unmarshal([]byte(lines[0]), &record))
+	s.Equal(audit.ActionLogin, record.Action)
+	s.Equal("failure", record.Outcome)
+	s.Equal(admin.ID.String(), record.ActorUserID)
+
+	buf.Reset()
+	count, err = audit.Export(ctx, s.db, &buf, audit.FormatCSV, audit.Filter{Actor: "auditor"})
+	s.Require().NoError(err)
+	s.Equal(3, count)
+	rows, err := csv.NewReader(&buf).ReadAll()
+	s.Require().NoError(err)
+	s.Require().Len(rows, 4)
+	s.Equal("action", rows[0][2])
+	s.Equal(audit.ActionSessionUpdate, rows[3][2])
+
+	count, err = audit.Export(ctx, s.db, &buf, audit.FormatCSV, audit.Filter{Actor: "auditor", Since: time.Now().Add(time.Hour)})
+	s.Require().NoError(err)
+	s.Equal(0, count)
+
+	_, err = audit.Export(ctx, s.db, &buf, "xml", audit.Filter{})
+	s.Error(err)
+
+	// only admins see the audit log
+	c, rec = setupAPIKeyContext(s.T(), http.MethodGet, "/audit", "", true, s.userID.String(), string(model_db.RoleEditor), s.db)
+	s.Require().NoError(core.AuditLogHandler(c))
+	s.Equal(http.StatusForbidden, rec.Code)
+
+	c, rec = setupAPIKeyContext(s.T(), http.MethodGet, "/audit", "", true, admin.ID.String(), string(model_db.RoleAdmin), s.db)
+	s.Require().NoError(core.AuditLogHandler(c))
+	s.Equal(http.StatusOK, rec.Code)
+
+	c, rec = setupAPIKeyContext(s.T(), http.MethodGet, "/audit/export?format=csv&actor=auditor", "", true, admin.ID.String(), string(model_db.RoleAdmin), s.db)
+	s.Require().NoError(core.AuditExportHandler(c))
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("text/csv", rec.Header().Get("Content-Type"))
+	s.Len(strings.Split(strings.TrimSpace(rec.Body.String()), "\n"), 4)
+}
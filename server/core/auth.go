@@ -2,17 +2,26 @@ package core
 
 import (
 	"context"
-	"crypto/sha256"
 	"crypto/subtle"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/cephei8/greener/server/core/apikey"
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/lockout"
 	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/oauth"
 	"github.com/google/uuid"
+	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 	"github.com/uptrace/bun"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 type APIKeyData struct {
@@ -21,62 +30,278 @@ type APIKeyData struct {
 }
 
 const (
-	contextKeyAPIKey = "apikey"
-	contextKeyUserID = "user_id"
+	contextKeyAPIKey   = "apikey"
+	contextKeyUserID   = "user_id"
+	contextKeySecurity = "security"
 )
 
+// Security holds how a server protects credentials. Web UI handlers get it from the
+// "security" context value.
+type Security struct {
+	// loginGuard slows down password and API key guessing.
+	loginGuard *lockout.Guard
+	// passwordPolicy applies to new passwords.
+	passwordPolicy PasswordPolicy
+	// verifiedSecrets caches API key secrets verified with a slow hash.
+	verifiedSecrets *apikey.Cache
+}
+
+// NewSecurity protects logins, password changes and API keys against guessing with
+// loginPolicy, makes new passwords satisfy passwordPolicy and caches the verification of
+// up to apiKeyCacheSize API keys; 0 disables the cache.
+func NewSecurity(loginPolicy lockout.Policy, passwordPolicy PasswordPolicy, apiKeyCacheSize int) *Security {
+	return &Security{
+		loginGuard:      lockout.NewGuard(loginPolicy),
+		passwordPolicy:  passwordPolicy,
+		verifiedSecrets: apikey.NewCache(apiKeyCacheSize),
+	}
+}
+
+func getSecurity(c echo.Context) *Security {
+	return c.Get(contextKeySecurity).(*Security)
+}
+
+// HashSecret hashes an API key secret with the default algorithm, PBKDF2.
 func HashSecret(secret string, salt []byte) []byte {
-	return pbkdf2.Key([]byte(secret), salt, 100000, 32, sha256.New)
+	return apikey.Hash(model_db.SecretPBKDF2, secret, salt)
+}
+
+// lastUsedInterval is how often the last use of an API key is recorded at most, to avoid
+// a database write per request of a busy key.
+const lastUsedInterval = time.Minute
+
+// apiKeyContextKey is the context.Context key of the API key that authenticated a request.
+type apiKeyContextKey struct{}
+
+// WithAPIKey returns a copy of ctx that carries apiKey.
+func WithAPIKey(ctx context.Context, apiKey *model_db.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
 }
 
-func APIKeyAuth(db *bun.DB) echo.MiddlewareFunc {
+// APIKeyFromContext returns the API key that authenticated the request of ctx, or nil.
+func APIKeyFromContext(ctx context.Context) *model_db.APIKey {
+	apiKey, _ := ctx.Value(apiKeyContextKey{}).(*model_db.APIKey)
+	return apiKey
+}
+
+// APIKeyAuth authenticates requests with the X-API-Key header and requires keys with scope.
+// The key is also put into the request context, where the project restriction of the key is enforced.
+func APIKeyAuth(db *bun.DB, sec *Security, scope model_db.APIKeyScope) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			apiKeyHeader := c.Request().Header.Get("x-api-key")
-			if apiKeyHeader == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Missing X-API-Key header")
-			}
-
-			decodedData, err := base64.StdEncoding.DecodeString(apiKeyHeader)
+			req := c.Request()
+			apiKey, err := sec.authenticateAPIKey(req.Context(), db, c.Logger(), req.Header.Get("x-api-key"), scope, clientip.FromContext(c))
 			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key format")
+				setRetryAfter(c, err)
+				return err
 			}
 
-			var keyData APIKeyData
-			if err := json.Unmarshal(decodedData, &keyData); err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key format")
-			}
+			c.Set(contextKeyAPIKey, apiKey)
+			c.Set(contextKeyUserID, apiKey.UserID)
+			c.SetRequest(req.WithContext(WithAPIKey(req.Context(), apiKey)))
 
-			apiKeyID, err := uuid.Parse(keyData.APIKeyID)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key format")
+			return next(c)
+		}
+	}
+}
+
+// APIKeyOrBearerAuth authenticates requests with an X-API-Key header of a read key if they have one,
+// and with bearerAuth otherwise. API key requests get the OAuth context values bearerAuth would set.
+func APIKeyOrBearerAuth(db *bun.DB, sec *Security, bearerAuth echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		bearerNext := bearerAuth(next)
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get("x-api-key")
+			if header == "" {
+				return bearerNext(c)
 			}
 
-			var apiKey model_db.APIKey
-			err = db.NewSelect().
-				Model(&apiKey).
-				Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(apiKeyID)).
-				Scan(context.Background())
+			apiKey, err := sec.authenticateAPIKey(c.Request().Context(), db, c.Logger(), header, model_db.ScopeRead, clientip.FromContext(c))
 			if err != nil {
-				c.Logger().Errorf("Failed to find API key %s: %v", apiKeyID, err)
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+				setRetryAfter(c, err)
+				return err
 			}
-
-			c.Logger().Debugf("Found API key: %s", apiKey.ID)
-
-			secretHash := HashSecret(keyData.APIKeySecret, apiKey.SecretSalt)
-			if subtle.ConstantTimeCompare(secretHash, apiKey.SecretHash) != 1 {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+			if apiKey.Project != nil {
+				// queries are not limited to the sessions of a project
+				return echo.NewHTTPError(http.StatusForbidden, "API keys restricted to a project cannot query test results")
 			}
 
-			c.Set(contextKeyAPIKey, &apiKey)
-			c.Set(contextKeyUserID, apiKey.UserID)
+			c.Set(oauth.ContextKeyUserID, apiKey.UserID)
+			c.Set(oauth.ContextKeyClientID, "apikey:"+apiKey.ID.String())
+			c.Set(oauth.ContextKeyScope, string(apiKey.Scope))
 
 			return next(c)
 		}
 	}
 }
 
+// authenticateAPIKey verifies an X-API-Key header value and checks that the key is not expired
+// and has scope. Until the grace period of a rotation ends, the previous secret of a key is accepted too.
+// ip is recorded as the last address the key was used from; invalid keys count as failed attempts
+// of ip, which is blocked by the login guard after too many. Errors are returned as *echo.HTTPError.
+func (sec *Security) authenticateAPIKey(ctx context.Context, db *bun.DB, logger echo.Logger, apiKeyHeader string, scope model_db.APIKeyScope, ip string) (*model_db.APIKey, error) {
+	if apiKeyHeader == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Missing X-API-Key header")
+	}
+	if err := sec.loginGuard.Check(ctx, db, lockout.IP(ip)); err != nil {
+		return nil, apiKeyBlocked(logger, err)
+	}
+
+	apiKeyID, secret, err := parseAPIKey(apiKeyHeader)
+	if err != nil {
+		sec.failAPIKey(ctx, db, logger, ip)
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key format")
+	}
+
+	var apiKey model_db.APIKey
+	err = db.NewSelect().
+		Model(&apiKey).
+		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(apiKeyID)).
+		// keys of disabled users are rejected until the user is enabled again
+		Where("NOT EXISTS (SELECT 1 FROM ? AS u WHERE u.id = ?TableAlias.user_id AND u.disabled_at IS NOT NULL)", bun.Ident("users")).
+		Scan(ctx)
+	if err != nil {
+		logger.Errorf("Failed to find API key %s: %v", apiKeyID, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			sec.failAPIKey(ctx, db, logger, ip)
+		}
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+	}
+
+	logger.Debugf("Found API key: %s", apiKey.ID)
+
+	now := time.Now()
+	if !sec.verifySecret(&apiKey, secret, now) {
+		sec.failAPIKey(ctx, db, logger, ip)
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+	}
+	if apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt) {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "API key has expired")
+	}
+	if !apiKey.Scope.Allows(scope) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("API key does not have the %s scope", scope))
+	}
+
+	recordLastUse(ctx, db, logger, &apiKey, ip, now)
+
+	return &apiKey, nil
+}
+
+// apiKeyBlocked returns the error of a request with an API key from an address blocked by the login guard.
+func apiKeyBlocked(logger echo.Logger, err error) *echo.HTTPError {
+	var blocked *lockout.BlockedError
+	if !errors.As(err, &blocked) {
+		logger.Errorf("Failed to check failed API key attempts: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify API key")
+	}
+	return echo.NewHTTPError(http.StatusTooManyRequests, "Too many invalid API keys from your address, try again later").SetInternal(err)
+}
+
+// failAPIKey counts an invalid API key as a failed attempt of ip. Failures are only logged.
+func (sec *Security) failAPIKey(ctx context.Context, db *bun.DB, logger echo.Logger, ip string) {
+	locked, err := sec.loginGuard.Fail(ctx, db, lockout.IP(ip))
+	if err != nil {
+		logger.Errorf("Failed to record invalid API key: %v", err)
+	}
+	for _, subject := range locked {
+		actor := audit.Anonymous
+		actor.IP = ip
+		err := audit.Record(ctx, db, actor, audit.Event{
+			Action:  audit.ActionLoginLockout,
+			Details: fmt.Sprintf("%s locked out for %s after invalid API keys", subject, sec.loginGuard.Policy().Lockout),
+		})
+		if err != nil {
+			logger.Errorf("Failed to record audit entry %s: %v", audit.ActionLoginLockout, err)
+		}
+	}
+}
+
+// parseAPIKey parses an API key in its compact form or as base64-encoded APIKeyData.
+func parseAPIKey(header string) (uuid.UUID, string, error) {
+	if strings.HasPrefix(header, apikey.TokenPrefix) {
+		return apikey.ParseToken(header)
+	}
+
+	decodedData, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	var keyData APIKeyData
+	if err := json.Unmarshal(decodedData, &keyData); err != nil {
+		return uuid.Nil, "", err
+	}
+
+	apiKeyID, err := uuid.Parse(keyData.APIKeyID)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return apiKeyID, keyData.APIKeySecret, nil
+}
+
+// formatAPIKey returns the API key clients send for secret of apiKey: the compact form
+// for keys with fast hashes, which older clients never received, and APIKeyData otherwise.
+func formatAPIKey(apiKey *model_db.APIKey, secret string) string {
+	if apiKey.SecretAlgorithm == model_db.SecretSHA256 {
+		return apikey.FormatToken(uuid.UUID(apiKey.ID), secret)
+	}
+	keyJSON, _ := json.Marshal(APIKeyData{APIKeyID: apiKey.ID.String(), APIKeySecret: secret})
+	return base64.StdEncoding.EncodeToString(keyJSON)
+}
+
+// verifySecret reports whether secret is the current secret of apiKey, or its previous secret
+// while the grace period of the last rotation lasts.
+func (sec *Security) verifySecret(apiKey *model_db.APIKey, secret string, now time.Time) bool {
+	if sec.matchSecret(apiKey, secret, apiKey.SecretSalt, apiKey.SecretHash) {
+		return true
+	}
+	if apiKey.PreviousSecretHash == nil || apiKey.PreviousSecretExpiresAt == nil || !now.Before(*apiKey.PreviousSecretExpiresAt) {
+		return false
+	}
+	return sec.matchSecret(apiKey, secret, apiKey.PreviousSecretSalt, apiKey.PreviousSecretHash)
+}
+
+// matchSecret compares secret with a stored hash of apiKey, skipping the slow hash
+// of PBKDF2 keys for secrets that matched the same hash before.
+func (sec *Security) matchSecret(apiKey *model_db.APIKey, secret string, salt, hash []byte) bool {
+	if apiKey.SecretAlgorithm == model_db.SecretSHA256 {
+		return subtle.ConstantTimeCompare(apikey.Hash(apiKey.SecretAlgorithm, secret, salt), hash) == 1
+	}
+
+	id := uuid.UUID(apiKey.ID)
+	if sec.verifiedSecrets.Verified(id, secret, hash) {
+		return true
+	}
+	if subtle.ConstantTimeCompare(apikey.Hash(apiKey.SecretAlgorithm, secret, salt), hash) != 1 {
+		return false
+	}
+	sec.verifiedSecrets.Add(id, secret, hash)
+	return true
+}
+
+// recordLastUse stores when and from where apiKey was used, unless it was recorded less than
+// lastUsedInterval ago from the same address. Failures are only logged.
+func recordLastUse(ctx context.Context, db *bun.DB, logger echo.Logger, apiKey *model_db.APIKey, ip string, now time.Time) {
+	if apiKey.LastUsedAt != nil && now.Sub(*apiKey.LastUsedAt) < lastUsedInterval &&
+		apiKey.LastUsedIP != nil && *apiKey.LastUsedIP == ip {
+		return
+	}
+
+	apiKey.LastUsedAt = &now
+	apiKey.LastUsedIP = nil
+	if ip != "" {
+		apiKey.LastUsedIP = &ip
+	}
+	_, err := db.NewUpdate().
+		Model(apiKey).
+		Column("last_used_at", "last_used_ip").
+		Where("? = ?", bun.Ident("id"), apiKey.ID).
+		Exec(ctx)
+	if err != nil {
+		logger.Errorf("Failed to record use of API key %s: %v", apiKey.ID, err)
+	}
+}
+
 func GetAPIKey(c echo.Context) *model_db.APIKey {
 	if apiKey, ok := c.Get(contextKeyAPIKey).(*model_db.APIKey); ok {
 		return apiKey
@@ -97,3 +322,19 @@ func AllowUnauthenticatedViewers(c echo.Context) bool {
 	}
 	return false
 }
+
+// SSOName returns the display name of the single sign-on provider, or "" if none is configured.
+func SSOName(c echo.Context) string {
+	name, _ := c.Get("ssoName").(string)
+	return name
+}
+
+// IsAdmin reports whether the user logged in to the web session of c has the admin role.
+func IsAdmin(c echo.Context) bool {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return false
+	}
+	role, _ := sess.Values["role"].(string)
+	return role == string(model_db.RoleAdmin)
+}
@@ -0,0 +1,282 @@
+package core_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/apikey"
+	"github.com/cephei8/greener/server/core/clientip"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/oauth"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+)
+
+// createAPIKey stores an API key of the test user, adjusted by update, and returns its id
+// and its X-API-Key header value for secret.
+func (s *BaseSuite) createAPIKey(ctx context.Context, secret string, update func(*model_db.APIKey)) (model_db.BinaryUUID, string) {
+	salt := []byte("salt-" + secret)
+	apiKey := &model_db.APIKey{
+		ID:              model_db.BinaryUUID(uuid.New()),
+		SecretSalt:      salt,
+		SecretHash:      core.HashSecret(secret, salt),
+		SecretAlgorithm: model_db.SecretPBKDF2,
+		Scope:           model_db.ScopeIngest,
+		UserID:          s.userID,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	if update != nil {
+		update(apiKey)
+	}
+	_, err := s.db.NewInsert().Model(apiKey).Exec(ctx)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		_, err := s.db.NewDelete().Model((*model_db.APIKey)(nil)).Where("id = ?", apiKey.ID).Exec(ctx)
+		s.Require().NoError(err)
+	})
+	return apiKey.ID, apiKeyHeader(s, apiKey.ID, secret)
+}
+
+func apiKeyHeader(s *BaseSuite, id model_db.BinaryUUID, secret string) string {
+	key, err := json.Marshal(core.APIKeyData{APIKeyID: id.String(), APIKeySecret: secret})
+	s.Require().NoError(err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func (s *BaseSuite) TestAPIKeyAuth() {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	ipExtractor, err := clientip.Extractor(nil)
+	s.Require().NoError(err)
+
+	auth := func(scope model_db.APIKeyScope, header string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ingress/sessions", nil)
+		req.Header.Set("X-API-Key", header)
+		// not sent by a trusted proxy
+		req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.7")
+		rec := httptest.NewRecorder()
+		e := echo.New()
+		e.IPExtractor = ipExtractor
+		c := e.NewContext(req, rec)
+		err := core.APIKeyAuth(s.db, testSecurity, scope)(func(c echo.Context) error {
+			s.NotNil(core.APIKeyFromContext(c.Request().Context()))
+			return c.NoContent(http.StatusNoContent)
+		})(c)
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return httpErr.Code
+		}
+		s.Require().NoError(err)
+		return rec.Code
+	}
+
+	ingestID, ingest := s.createAPIKey(ctx, "ingest", nil)
+	_, read := s.createAPIKey(ctx, "read", func(k *model_db.APIKey) { k.Scope = model_db.ScopeRead })
+	_, admin := s.createAPIKey(ctx, "admin", func(k *model_db.APIKey) { k.Scope = model_db.ScopeAdmin })
+	_, expired := s.createAPIKey(ctx, "expired", func(k *model_db.APIKey) { k.ExpiresAt = &past })
+	_, expiring := s.createAPIKey(ctx, "expiring", func(k *model_db.APIKey) { k.ExpiresAt = &future })
+
+	s.Equal(http.StatusNoContent, auth(model_db.ScopeIngest, ingest))
+	s.Equal(http.StatusForbidden, auth(model_db.ScopeRead, ingest))
+	s.Equal(http.StatusForbidden, auth(model_db.ScopeIngest, read))
+	s.Equal(http.StatusNoContent, auth(model_db.ScopeRead, read))
+	s.Equal(http.StatusNoContent, auth(model_db.ScopeIngest, admin))
+	s.Equal(http.StatusNoContent, auth(model_db.ScopeRead, admin))
+	s.Equal(http.StatusUnauthorized, auth(model_db.ScopeIngest, expired))
+	s.Equal(http.StatusNoContent, auth(model_db.ScopeIngest, expiring))
+	s.Equal(http.StatusUnauthorized, auth(model_db.ScopeIngest, apiKeyHeader(s, ingestID, "wrong")))
+
+	// keys with fast hashes are sent in the compact form, but the other form works too
+	fastID, fast := s.createAPIKey(ctx, "fast", func(k *model_db.APIKey) {
+		k.SecretAlgorithm = model_db.SecretSHA256
+		k.SecretHash = apikey.Hash(model_db.SecretSHA256, "fast", k.SecretSalt)
+	})
+	s.Equal(http.StatusNoContent, auth(model_db.ScopeIngest, apikey.FormatToken(uuid.UUID(fastID), "fast")))
+	s.Equal(http.StatusNoContent, auth(model_db.ScopeIngest, fast))
+	s.Equal(http.StatusUnauthorized, auth(model_db.ScopeIngest, apikey.FormatToken(uuid.UUID(fastID), "slow")))
+	s.Equal(http.StatusUnauthorized, auth(model_db.ScopeIngest, apikey.FormatToken(uuid.UUID(ingestID), "wrong")))
+	s.Equal(http.StatusUnauthorized, auth(model_db.ScopeIngest, "grk_invalid"))
+
+	var apiKey model_db.APIKey
+	s.Require().NoError(s.db.NewSelect().Model(&apiKey).Where("id = ?", ingestID).Scan(ctx))
+	s.Require().NotNil(apiKey.LastUsedAt)
+	s.WithinDuration(time.Now(), *apiKey.LastUsedAt, time.Minute)
+	s.Require().NotNil(apiKey.LastUsedIP)
+	s.Equal("192.0.2.1", *apiKey.LastUsedIP)
+}
+
+func (s *BaseSuite) TestAPIKeyRotation() {
+	ctx := context.Background()
+	keyID, oldKey := s.createAPIKey(ctx, "before-rotation", func(k *model_db.APIKey) { k.Scope = model_db.ScopeAdmin })
+
+	rotate := func(graceHours string) string {
+		req := httptest.NewRequest(http.MethodPost, "/api-keys/"+keyID.String()+"/rotate", strings.NewReader("grace_hours="+graceHours))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(keyID.String())
+
+		store := sessions.NewCookieStore([]byte("test-secret"))
+		sess, _ := store.Get(req, "session")
+		sess.Values["authenticated"] = true
+		sess.Values["user_id"] = uuid.UUID(s.userID).String()
+		sess.Values["role"] = string(model_db.RoleEditor)
+		c.Set("_session_store", store)
+		c.Set("session", sess)
+		c.Set("db", s.db)
+		c.Set("security", testSecurity)
+
+		s.Require().NoError(core.RotateAPIKeyHandler(c))
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var keyData core.APIKeyData
+		for _, field := range strings.Fields(rec.Body.String()) {
+			if value, ok := strings.CutPrefix(field, `value="`); ok {
+				decoded, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(value, `"`))
+				s.Require().NoError(err)
+				s.Require().NoError(json.Unmarshal(decoded, &keyData))
+			}
+		}
+		s.Require().Equal(keyID.String(), keyData.APIKeyID)
+		return apiKeyHeader(s, keyID, keyData.APIKeySecret)
+	}
+	authenticates := func(header string) bool {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ingress/sessions", nil)
+		req.Header.Set("X-API-Key", header)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		return core.APIKeyAuth(s.db, testSecurity, model_db.ScopeIngest)(func(c echo.Context) error { return nil })(c) == nil
+	}
+
+	// with a grace period, both secrets are valid
+	newKey := rotate("24")
+	s.True(authenticates(newKey))
+	s.True(authenticates(oldKey))
+
+	// once the grace period ends, only the new one is
+	_, err := s.db.NewUpdate().Model((*model_db.APIKey)(nil)).
+		Set("previous_secret_expires_at = ?", time.Now().Add(-time.Minute)).
+		Where("id = ?", keyID).
+		Exec(ctx)
+	s.Require().NoError(err)
+	s.True(authenticates(newKey))
+	s.False(authenticates(oldKey))
+
+	// without one, the previous secret is revoked right away
+	newestKey := rotate("0")
+	s.True(authenticates(newestKey))
+	s.False(authenticates(newKey))
+}
+
+func (s *BaseSuite) TestAPIKeyProject() {
+	ctx := context.Background()
+	handler := core.NewIngressHandler(s.db, output.DefaultStorage())
+	projectKeyID, projectKey := s.createAPIKey(ctx, "project", func(k *model_db.APIKey) {
+		project := "web"
+		k.Project = &project
+	})
+
+	inProject := s.createScratchSession(map[string]string{core.ProjectLabel: "web"})
+	_, err := s.db.NewUpdate().Model((*model_db.Session)(nil)).
+		Set("api_key_id = ?", projectKeyID).
+		Where("id = ?", inProject).
+		Exec(ctx)
+	s.Require().NoError(err)
+	otherProject := s.createScratchSession(map[string]string{core.ProjectLabel: "api"})
+
+	send := func(method, path string, handle echo.HandlerFunc, body string) (*httptest.ResponseRecorder, int) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-API-Key", projectKey)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		if id, ok := strings.CutPrefix(path, "/api/v1/ingress/sessions/"); ok {
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+		}
+		err := core.APIKeyAuth(s.db, testSecurity, model_db.ScopeIngest)(handle)(c)
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return rec, httpErr.Code
+		}
+		s.Require().NoError(err)
+		return rec, rec.Code
+	}
+
+	// new sessions get the project label
+	rec, code := send(http.MethodPost, "/api/v1/ingress/sessions", handler.CreateSession, `{"labels": [{"key": "ci"}]}`)
+	s.Require().Equal(http.StatusCreated, code)
+	var session core.SessionResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &session))
+	s.purgeOnCleanup(model_db.BinaryUUID(uuid.MustParse(session.ID)), retention.Stores{})
+	stored, err := core.NewQueryService(s.db).GetSession(ctx, s.userID, uuid.MustParse(session.ID))
+	s.Require().NoError(err)
+	s.Equal("web", stored.Labels[core.ProjectLabel])
+
+	_, code = send(http.MethodPost, "/api/v1/ingress/sessions", handler.CreateSession, `{"labels": [{"key": "project", "value": "api"}]}`)
+	s.Equal(http.StatusForbidden, code)
+
+	// testcases can only be reported to sessions of the project
+	_, code = send(http.MethodPost, "/api/v1/ingress/testcases", handler.CreateTestcases,
+		`{"testcases": [{"sessionId": "`+uuid.UUID(inProject).String()+`", "testcaseName": "test_web", "status": "pass"}]}`)
+	s.Equal(http.StatusCreated, code)
+	rec, code = send(http.MethodPost, "/api/v1/ingress/testcases", handler.CreateTestcases,
+		`{"testcases": [{"sessionId": "`+uuid.UUID(otherProject).String()+`", "testcaseName": "test_api", "status": "pass"}]}`)
+	s.Equal(http.StatusBadRequest, code)
+
+	// sessions cannot be moved out of the project
+	_, code = send(http.MethodPatch, "/api/v1/ingress/sessions/"+uuid.UUID(inProject).String(), handler.PatchSession, `{"removeLabels": ["project"]}`)
+	s.Equal(http.StatusForbidden, code)
+	_, code = send(http.MethodPatch, "/api/v1/ingress/sessions/"+uuid.UUID(otherProject).String(), handler.PatchSession, `{"description": "moved"}`)
+	s.Equal(http.StatusForbidden, code)
+	_, code = send(http.MethodPatch, "/api/v1/ingress/sessions/"+uuid.UUID(inProject).String(), handler.PatchSession, `{"description": "kept"}`)
+	s.Equal(http.StatusNoContent, code)
+}
+
+func (s *BaseSuite) TestAPIKeyOrBearerAuth() {
+	ctx := context.Background()
+	_, read := s.createAPIKey(ctx, "mcp-read", func(k *model_db.APIKey) { k.Scope = model_db.ScopeRead })
+	_, ingest := s.createAPIKey(ctx, "mcp-ingest", nil)
+	_, project := s.createAPIKey(ctx, "mcp-project", func(k *model_db.APIKey) {
+		k.Scope = model_db.ScopeRead
+		project := "web"
+		k.Project = &project
+	})
+
+	bearer := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+	}
+	call := func(header string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/mcp", nil)
+		if header != "" {
+			req.Header.Set("X-API-Key", header)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		err := core.APIKeyOrBearerAuth(s.db, testSecurity, bearer)(func(c echo.Context) error {
+			s.Equal(s.userID, oauth.GetOAuthUserID(c))
+			return c.NoContent(http.StatusOK)
+		})(c)
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return httpErr.Code
+		}
+		s.Require().NoError(err)
+		return rec.Code
+	}
+
+	s.Equal(http.StatusOK, call(read))
+	s.Equal(http.StatusForbidden, call(ingest))
+	s.Equal(http.StatusForbidden, call(project))
+	s.Equal(http.StatusUnauthorized, call(""))
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrUnknownUser is returned by an Authenticator that does not know a user, so that the
+	// next one is tried.
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrInvalidPassword is returned by an Authenticator if the password of a user is wrong.
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
+// Authenticator verifies the username and password of a login to the web UI.
+type Authenticator interface {
+	// Authenticate returns the user logging in, provisioning it if needed. It returns
+	// ErrUnknownUser if it does not know username, and ErrInvalidPassword, along with the
+	// user if it exists, if password is wrong. Other errors may be *echo.HTTPError.
+	Authenticate(c echo.Context, username, password string) (*model_db.User, error)
+}
+
+// LocalAuthenticator verifies the passwords of local users, stored in the database.
+type LocalAuthenticator struct{}
+
+func (LocalAuthenticator) Authenticate(c echo.Context, username, password string) (*model_db.User, error) {
+	db := c.Get("db").(*bun.DB)
+
+	var user model_db.User
+	err := db.NewSelect().
+		Model(&user).
+		Where("? = ?", bun.Ident("username"), username).
+		Where("? IS NULL", bun.Ident("auth_source")).
+		Scan(c.Request().Context())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnknownUser
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !checkPassword(&user, password) {
+		return &user, ErrInvalidPassword
+	}
+	return &user, nil
+}
+
+// Authenticators returns the authenticators LoginHandler tries in order. Only local users
+// can log in unless others are configured.
+func Authenticators(c echo.Context) []Authenticator {
+	if authenticators, ok := c.Get("authenticators").([]Authenticator); ok {
+		return authenticators
+	}
+	return []Authenticator{LocalAuthenticator{}}
+}
+
+// RoleMapping maps the groups of external users to roles. Users in several groups get the
+// most privileged role; users in none get DefaultRole, or cannot log in if it is empty.
+type RoleMapping struct {
+	AdminGroups  []string
+	EditorGroups []string
+	ViewerGroups []string
+	DefaultRole  model_db.UserRole
+}
+
+// Managed reports whether roles are mapped from groups. If they are, the role of users is
+// updated at every login; otherwise new users get DefaultRole and admins manage roles.
+func (m RoleMapping) Managed() bool {
+	return len(m.AdminGroups) > 0 || len(m.EditorGroups) > 0 || len(m.ViewerGroups) > 0
+}
+
+// Role returns the role of a user in groups, or false if the user may not log in.
+func (m RoleMapping) Role(groups []string) (model_db.UserRole, bool) {
+	member := func(mapped []string) bool {
+		return slices.ContainsFunc(groups, func(group string) bool { return slices.Contains(mapped, group) })
+	}
+	switch {
+	case member(m.AdminGroups):
+		return model_db.RoleAdmin, true
+	case member(m.EditorGroups):
+		return model_db.RoleEditor, true
+	case member(m.ViewerGroups):
+		return model_db.RoleViewer, true
+	}
+	return m.DefaultRole, m.DefaultRole != ""
+}
+
+// Identity returns the external identity of a user in groups, with the role mapped from
+// them, or false if the user may not log in.
+func (m RoleMapping) Identity(source, subject, username string, groups []string) (ExternalIdentity, bool) {
+	role, ok := m.Role(groups)
+	if !ok {
+		return ExternalIdentity{}, false
+	}
+	identity := ExternalIdentity{
+		Source:      source,
+		Subject:     subject,
+		Username:    username,
+		DefaultRole: role,
+	}
+	if m.Managed() {
+		identity.Role = &role
+	}
+	return identity, true
+}
@@ -0,0 +1,151 @@
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/ldap"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleMapping(t *testing.T) {
+	m := core.RoleMapping{
+		AdminGroups:  []string{"ops"},
+		EditorGroups: []string{"qa", "dev"},
+		DefaultRole:  model_db.RoleViewer,
+	}
+	assert.True(t, m.Managed())
+
+	for _, tc := range []struct {
+		groups []string
+		role   model_db.UserRole
+	}{
+		{[]string{"dev"}, model_db.RoleEditor},
+		{[]string{"dev", "ops"}, model_db.RoleAdmin},
+		{[]string{"sales"}, model_db.RoleViewer},
+		{nil, model_db.RoleViewer},
+	} {
+		role, ok := m.Role(tc.groups)
+		assert.True(t, ok, tc.groups)
+		assert.Equal(t, tc.role, role, tc.groups)
+	}
+
+	m.DefaultRole = ""
+	_, ok := m.Role([]string{"sales"})
+	assert.False(t, ok)
+
+	identity, ok := m.Identity("ldap", "uid=dev", "dev", []string{"dev"})
+	assert.True(t, ok)
+	assert.Equal(t, model_db.RoleEditor, *identity.Role)
+
+	// without mapped groups, only new users get the default role
+	m = core.RoleMapping{DefaultRole: model_db.RoleViewer}
+	assert.False(t, m.Managed())
+	identity, ok = m.Identity("ldap", "uid=dev", "dev", []string{"dev"})
+	assert.True(t, ok)
+	assert.Nil(t, identity.Role)
+	assert.Equal(t, model_db.RoleViewer, identity.DefaultRole)
+}
+
+func (s *BaseSuite) TestLDAPLogin() {
+	ctx := context.Background()
+
+	d := testdirectory.Start(s.T(), testdirectory.WithNoTLS(s.T()), testdirectory.WithDefaults(s.T(), &testdirectory.Defaults{
+		UserDN:             testdirectory.DefaultUserDN,
+		GroupDN:            testdirectory.DefaultGroupDN,
+		AllowAnonymousBind: true,
+		Users: []*gldap.Entry{
+			gldap.NewEntry("uid=ldapuser,"+testdirectory.DefaultUserDN, map[string][]string{
+				"uid":      {"ldapuser"},
+				"password": {"ldap-password"},
+				"memberOf": {"cn=qa,ou=groups,dc=example,dc=org"},
+			}),
+			gldap.NewEntry("uid=outsider,"+testdirectory.DefaultUserDN, map[string][]string{
+				"uid":      {"outsider"},
+				"password": {"outsider-password"},
+			}),
+			gldap.NewEntry("uid=localadmin,"+testdirectory.DefaultUserDN, map[string][]string{
+				"uid":      {"localadmin"},
+				"password": {"ldap-password"},
+				"memberOf": {"cn=qa,ou=groups,dc=example,dc=org"},
+			}),
+		},
+	}))
+	ldapAuth, err := ldap.NewAuthenticator(s.db, ldap.Config{
+		URL:               fmt.Sprintf("ldap://%s:%d", d.Host(), d.Port()),
+		BaseDN:            testdirectory.DefaultUserDN,
+		UsernameAttribute: "uid",
+		Roles:             core.RoleMapping{EditorGroups: []string{"qa"}},
+	})
+	s.Require().NoError(err)
+
+	_, err = core.CreateUser(ctx, s.db, testSecurity, log.New("test"), audit.System, "localadmin", "local-password", model_db.RoleAdmin)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		_, err := s.db.NewDelete().Model((*model_db.User)(nil)).Where("username IN (?, ?)", "ldapuser", "localadmin").Exec(ctx)
+		s.Require().NoError(err)
+	})
+
+	login := func(username, password string) (int, string) {
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username="+username+"&password="+password, false, "", "", s.db)
+		c.Set("authenticators", []core.Authenticator{core.LocalAuthenticator{}, ldapAuth})
+		s.Require().NoError(core.LoginHandler(c))
+		sess, _ := session.Get("session", c)
+		role, _ := sess.Values["role"].(string)
+		return rec.Code, role
+	}
+
+	code, role := login("ldapuser", "ldap-password")
+	s.Equal(http.StatusOK, code)
+	s.Equal(string(model_db.RoleEditor), role)
+
+	var user model_db.User
+	s.Require().NoError(s.db.NewSelect().Model(&user).Where("username = ?", "ldapuser").Scan(ctx))
+	s.Require().NotNil(user.AuthSource)
+	s.Equal(ldap.Source, *user.AuthSource)
+	s.Require().NotNil(user.ExternalID)
+	s.Equal("uid=ldapuser,"+testdirectory.DefaultUserDN, *user.ExternalID)
+
+	code, _ = login("ldapuser", "wrong")
+	s.Equal(http.StatusUnauthorized, code)
+	code, _ = login("outsider", "outsider-password")
+	s.Equal(http.StatusForbidden, code)
+	code, _ = login("nobody", "secret")
+	s.Equal(http.StatusUnauthorized, code)
+
+	// local users are authenticated locally, even if the directory has the same name
+	code, role = login("localadmin", "local-password")
+	s.Equal(http.StatusOK, code)
+	s.Equal(string(model_db.RoleAdmin), role)
+	code, _ = login("localadmin", "ldap-password")
+	s.Equal(http.StatusUnauthorized, code)
+
+	// only local users log in without configured authenticators
+	c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username=ldapuser&password=ldap-password", false, "", "", s.db)
+	s.Require().NoError(core.LoginHandler(c))
+	s.Equal(http.StatusUnauthorized, rec.Code)
+
+	entries, err := audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLogin, Actor: "ldapuser"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 3)
+	s.Equal(model_db.AuditFailure, entries[0].Outcome)
+	s.Equal("unknown user", *entries[0].Details)
+	s.Equal(model_db.AuditFailure, entries[1].Outcome)
+	s.Equal("invalid password", *entries[1].Details)
+	s.Equal(model_db.AuditSuccess, entries[2].Outcome)
+	s.Equal(ldap.Source, *entries[2].Details)
+
+	entries, err = audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLogin, Actor: "outsider"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal("You are not allowed to use Greener", *entries[0].Details)
+}
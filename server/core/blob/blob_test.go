@@ -0,0 +1,28 @@
+package blob
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, "a/b", []byte("data")))
+	data, err := store.Get(ctx, "a/b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), data)
+
+	require.NoError(t, store.Delete(ctx, "a/b"))
+	require.NoError(t, store.Delete(ctx, "a/b"))
+	_, err = store.Get(ctx, "a/b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Error(t, store.Put(ctx, "../escape", []byte("data")))
+	assert.Error(t, store.Put(ctx, "", []byte("data")))
+}
@@ -0,0 +1,59 @@
+// Package clientip determines the IP addresses of clients. Forwarding headers such as
+// X-Forwarded-For are set by clients at will, so they are only trusted when they are
+// added by configured reverse proxies.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Extractor returns the echo.IPExtractor of a server behind trustedProxies, which are
+// IP addresses or CIDR ranges. Without trusted proxies, it returns the address of the
+// connection and ignores forwarding headers. Otherwise it returns the rightmost address
+// of X-Forwarded-For not belonging to a trusted proxy, if the connection comes from one.
+func Extractor(trustedProxies []string) (echo.IPExtractor, error) {
+	var options []echo.TrustOption
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			proxy = netip.PrefixFrom(addr, addr.BitLen()).String()
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	if len(options) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options = append(options, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// Parse returns addr in canonical form, or "" if it is not an IP address.
+func Parse(addr string) string {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	return ip.WithZone("").String()
+}
+
+// FromContext returns the IP address of the client of c in canonical form, or "" if it is
+// unknown. It is at most 45 characters long.
+func FromContext(c echo.Context) string {
+	return Parse(c.RealIP())
+}
@@ -0,0 +1,60 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func extract(t *testing.T, trustedProxies []string, remoteAddr string, headers map[string]string) string {
+	extractor, err := Extractor(trustedProxies)
+	require.NoError(t, err)
+	e := echo.New()
+	e.IPExtractor = extractor
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return FromContext(e.NewContext(req, httptest.NewRecorder()))
+}
+
+func TestExtractorDirect(t *testing.T) {
+	spoofed := map[string]string{
+		echo.HeaderXForwardedFor: "198.51.100.7, " + strings.Repeat("a", 100),
+		echo.HeaderXRealIP:       "198.51.100.8",
+	}
+	assert.Equal(t, "192.0.2.1", extract(t, nil, "192.0.2.1:1234", spoofed))
+	assert.Equal(t, "127.0.0.1", extract(t, nil, "127.0.0.1:1234", spoofed))
+	assert.Equal(t, "2001:db8::1", extract(t, nil, "[2001:db8::1%eth0]:1234", nil))
+}
+
+func TestExtractorTrustedProxies(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", " 192.0.2.10 "}
+	xff := map[string]string{echo.HeaderXForwardedFor: "198.51.100.66, 198.51.100.7, 10.1.2.3"}
+
+	// the proxies append the client address
+	assert.Equal(t, "198.51.100.7", extract(t, proxies, "192.0.2.10:1234", xff))
+	assert.Equal(t, "198.51.100.7", extract(t, proxies, "10.0.0.1:1234", xff))
+	// clients bypassing the proxies cannot choose their address
+	assert.Equal(t, "192.0.2.11", extract(t, proxies, "192.0.2.11:1234", xff))
+	assert.Equal(t, "127.0.0.1", extract(t, proxies, "127.0.0.1:1234", xff))
+	// invalid forwarded addresses are ignored
+	assert.Equal(t, "192.0.2.10", extract(t, proxies, "192.0.2.10:1234", map[string]string{echo.HeaderXForwardedFor: "garbage"}))
+
+	_, err := Extractor([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = Extractor([]string{"proxy.example.com"})
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, "192.0.2.1", Parse("192.0.2.1"))
+	assert.Equal(t, "2001:db8::1", Parse("2001:DB8:0::1"))
+	assert.Equal(t, "", Parse("192.0.2.1:1234"))
+	assert.Equal(t, "", Parse(strings.Repeat("1", 100)))
+}
@@ -2,11 +2,12 @@ package dbutil
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/cephei8/greener/server/core/model/db"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/mysqldialect"
 	"github.com/uptrace/bun/dialect/pgdialect"
@@ -72,6 +73,42 @@ func Init(dbURL string) (*bun.DB, error) {
 	return bunDB, nil
 }
 
+const (
+	mysqlDuplicateEntry        = 1062
+	postgresUniqueViolation    = "23505"
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// IsUniqueViolation reports whether err is a primary key or unique constraint violation
+// raised by any of the supported database drivers.
+func IsUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C') == postgresUniqueViolation
+	}
+
+	// lib/pq, used by the tests, reports the SQLSTATE through SQLState
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == postgresUniqueViolation
+	}
+
+	// sqlite drivers report the extended result code through Code
+	var codeErr interface{ Code() int }
+	if errors.As(err, &codeErr) {
+		code := codeErr.Code()
+		return code == sqliteConstraintPrimaryKey || code == sqliteConstraintUnique
+	}
+
+	return false
+}
+
 func convertMySQLURL(sqlalchemyURL string) (string, error) {
 	rest := strings.TrimPrefix(sqlalchemyURL, "mysql://")
 
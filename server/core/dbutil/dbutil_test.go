@@ -0,0 +1,41 @@
+package dbutil
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type sqliteError int
+
+func (e sqliteError) Error() string { return fmt.Sprintf("sqlite error %d", int(e)) }
+func (e sqliteError) Code() int     { return int(e) }
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pq: " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'PRIMARY'"}, true},
+		{&mysql.MySQLError{Number: 1452}, false},
+		{sqlStateError("23505"), true},
+		{sqlStateError("23503"), false},
+		{sqliteError(1555), true},
+		{sqliteError(2067), true},
+		{sqliteError(787), false},
+		{fmt.Errorf("failed to insert session: %w", &mysql.MySQLError{Number: 1062}), true},
+		{errors.New("UNIQUE constraint failed"), false},
+	}
+	for _, tt := range tests {
+		if got := IsUniqueViolation(tt.err); got != tt.want {
+			t.Errorf("IsUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/query"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *BaseSuite) TestTestcaseFailureFields() {
+	ctx := context.Background()
+
+	sessionID := s.createScratchSession(map[string]string{"failure": "test"})
+	sessionIDStr := uuid.UUID(sessionID).String()
+
+	body := `{"testcases": [
+		{"sessionId": "` + sessionIDStr + `", "testcaseName": "test_assert", "status": "fail",
+		 "failureMessage": "expected 1, got 2", "failureType": "AssertionError",
+		 "stackTrace": "test_math.py:12: in test_assert", "stdout": "computing", "stderr": "warning: slow"},
+		{"sessionId": "` + sessionIDStr + `", "testcaseName": "test_timeout", "status": "error",
+		 "failureMessage": "timed out", "failureType": "TimeoutError"}
+	]}`
+
+	rec, err := s.sendTestcases(testcasesRequest{}, body)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusCreated, rec.Code)
+
+	q, err := query.NewParser(`failure_type = "AssertionError"`).Parse()
+	s.Require().NoError(err)
+	selectQuery, err := core.BuildTestcasesQuery(s.db, s.userID, q)
+	s.Require().NoError(err)
+
+	var results []struct {
+		ID   model_db.BinaryUUID `bun:"id"`
+		Name string              `bun:"name"`
+	}
+	s.Require().NoError(s.db.NewSelect().TableExpr("(?) AS t", selectQuery).Column("id", "name").Scan(ctx, &results))
+	s.Require().Len(results, 1)
+	s.Equal("test_assert", results[0].Name)
+
+	detail, err := core.NewQueryService(s.db).GetTestcase(ctx, s.userID, uuid.UUID(results[0].ID))
+	s.Require().NoError(err)
+	s.Equal("expected 1, got 2", detail.FailureMessage)
+	s.Equal("AssertionError", detail.FailureType)
+	s.Equal("test_math.py:12: in test_assert", detail.StackTrace)
+	s.Equal("computing", detail.Stdout)
+	s.Equal("warning: slow", detail.Stderr)
+	s.Empty(detail.Output)
+
+	q, err = query.NewParser(`failure_type != "AssertionError" and session_id = "` + sessionIDStr + `"`).Parse()
+	s.Require().NoError(err)
+	selectQuery, err = core.BuildTestcasesQuery(s.db, s.userID, q)
+	s.Require().NoError(err)
+	results = nil
+	s.Require().NoError(s.db.NewSelect().TableExpr("(?) AS t", selectQuery).Column("id", "name").Scan(ctx, &results))
+	s.Require().Len(results, 1)
+	s.Equal("test_timeout", results[0].Name)
+}
+
+func (s *BaseSuite) TestTestcaseFailureTypeTooLong() {
+	body := `{"testcases": [{"sessionId": "` + s.session1Id.String() + `", "testcaseName": "t", "status": "fail",
+		"failureType": "` + strings.Repeat("x", 256) + `"}]}`
+
+	_, err := s.sendTestcases(testcasesRequest{}, body)
+	s.Require().Error(err)
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusBadRequest, httpErr.Code)
+}
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 
@@ -21,7 +20,7 @@ func GroupsHandler(c echo.Context) error {
 	}
 
 	svc := c.Get("queryService").(QueryServiceInterface)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
 	queryStr := c.FormValue("query")
 	if queryStr == "" {
@@ -42,6 +41,7 @@ func GroupsHandler(c echo.Context) error {
 			"Query":           "",
 			"ActivePage":      "groups",
 			"IsAuthenticated": auth,
+			"IsAdmin":         IsAdmin(c),
 		})
 	}
 
@@ -60,5 +60,6 @@ func GroupsHandler(c echo.Context) error {
 		"Query":           queryStr,
 		"ActivePage":      "groups",
 		"IsAuthenticated": auth,
+		"IsAdmin":         IsAdmin(c),
 	})
 }
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// maxTestcaseDepth bounds the walk up the parent chain when rolling up statuses.
+const maxTestcaseDepth = 64
+
+var errUnknownParentTestcase = errors.New("unknown parent testcase")
+
+// TestcaseNode is a testcase with its subtests or steps, as shown on the session page.
+type TestcaseNode struct {
+	ID       string
+	Name     string
+	Status   string
+	Children []*TestcaseNode
+}
+
+// checkParent verifies that the parent testcase exists in the session.
+func checkParent(ctx context.Context, db bun.IDB, sessionID model_db.BinaryUUID, parentID uuid.UUID) error {
+	exists, err := db.NewSelect().
+		Model((*model_db.Testcase)(nil)).
+		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(parentID)).
+		Where("? = ?", bun.Ident("session_id"), sessionID).
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errUnknownParentTestcase
+	}
+	return nil
+}
+
+// rollUpStatus recomputes the statuses of the ancestors of a changed testcase: a parent ends
+// up with the worst of its reported status and the statuses of its current attempts of
+// children, so that a child that passes on retry no longer fails its parent. Skipped
+// children do not affect their parents.
+func rollUpStatus(ctx context.Context, db bun.IDB, parentID *model_db.BinaryUUID) error {
+	for depth := 0; parentID != nil && depth < maxTestcaseDepth; depth++ {
+		var parent model_db.Testcase
+		err := db.NewSelect().
+			Model(&parent).
+			Column("status", "reported_status", "parent_id").
+			Where("? = ?", bun.Ident("id"), *parentID).
+			Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		var worst sql.NullInt64
+		err = db.NewSelect().
+			Model((*model_db.Testcase)(nil)).
+			ColumnExpr("MIN(?)", bun.Ident("status")).
+			Where("? = ?", bun.Ident("parent_id"), *parentID).
+			Where("? = ?", bun.Ident("superseded"), false).
+			Where("? <> ?", bun.Ident("status"), model_db.StatusSkip).
+			Scan(ctx, &worst)
+		if err != nil {
+			return err
+		}
+
+		// testcases not stored through ingress may lack a reported status
+		status := parent.Status
+		if parent.ReportedStatus != nil {
+			status = *parent.ReportedStatus
+		}
+		if worst.Valid && model_db.TestcaseStatus(worst.Int64) < status {
+			status = model_db.TestcaseStatus(worst.Int64)
+		}
+		if status == parent.Status {
+			return nil
+		}
+
+		_, err = db.NewUpdate().
+			Model((*model_db.Testcase)(nil)).
+			Set("? = ?", bun.Ident("status"), status).
+			Where("? = ?", bun.Ident("id"), *parentID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+// buildTestcaseTree nests testcases under their parents, keeping the input order.
+// Testcases whose parent is not in the list become roots.
+func buildTestcaseTree(testcases []model_db.Testcase) []*TestcaseNode {
+	nodes := make(map[model_db.BinaryUUID]*TestcaseNode, len(testcases))
+	for _, tc := range testcases {
+		nodes[tc.ID] = &TestcaseNode{
+			ID:     tc.ID.String(),
+			Name:   tc.Name,
+			Status: TestcaseStatusToString(tc.Status),
+		}
+	}
+
+	var roots []*TestcaseNode
+	for _, tc := range testcases {
+		node := nodes[tc.ID]
+		if tc.ParentID != nil {
+			if parent, ok := nodes[*tc.ParentID]; ok && parent != node {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
@@ -0,0 +1,128 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *BaseSuite) TestTestcaseHierarchy() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"hierarchy": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	parentID := uuid.NewString()
+	groupID := uuid.NewString()
+	_, err := s.sendTestcases(testcasesRequest{}, `{"testcases": [
+		{"id": "`+parentID+`", "sessionId": "`+sid+`", "testcaseName": "TestFoo", "status": "pass"},
+		{"id": "`+groupID+`", "sessionId": "`+sid+`", "parentId": "`+parentID+`", "testcaseName": "TestFoo/group", "status": "pass"},
+		{"sessionId": "`+sid+`", "parentId": "`+groupID+`", "testcaseName": "TestFoo/group/case_1", "status": "pass"},
+		{"sessionId": "`+sid+`", "parentId": "`+groupID+`", "testcaseName": "TestFoo/group/case_2", "status": "skip"},
+		{"sessionId": "`+sid+`", "parentId": "`+parentID+`", "testcaseName": "TestFoo/other", "status": "fail"}
+	]}`)
+	s.Require().NoError(err)
+
+	parent, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(parentID))
+	s.Require().NoError(err)
+	s.Equal("fail", parent.Status)
+
+	group, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(groupID))
+	s.Require().NoError(err)
+	s.Equal("pass", group.Status)
+	s.Equal(parentID, group.ParentID)
+
+	session, err := svc.GetSession(ctx, s.userID, uuid.UUID(sessionID))
+	s.Require().NoError(err)
+	s.False(session.TestcasesTruncated)
+	s.Require().Len(session.Testcases, 2)
+	s.Equal("test_retention", session.Testcases[0].Name)
+
+	root := session.Testcases[1]
+	s.Equal(parentID, root.ID)
+	s.Require().Len(root.Children, 2)
+	s.Equal("TestFoo/group", root.Children[0].Name)
+	s.Equal("TestFoo/other", root.Children[1].Name)
+	s.Require().Len(root.Children[0].Children, 2)
+	s.Equal("skip", root.Children[0].Children[1].Status)
+}
+
+func (s *BaseSuite) TestTestcaseHierarchyRetry() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"hierarchy": "retry"})
+	sid := uuid.UUID(sessionID).String()
+
+	parentID := uuid.NewString()
+	groupID := uuid.NewString()
+	caseID := uuid.NewString()
+	_, err := s.sendTestcases(testcasesRequest{}, `{"testcases": [
+		{"id": "`+parentID+`", "sessionId": "`+sid+`", "testcaseName": "TestBar", "status": "pass"},
+		{"id": "`+groupID+`", "sessionId": "`+sid+`", "parentId": "`+parentID+`", "testcaseName": "TestBar/group", "status": "pass"},
+		{"id": "`+caseID+`", "sessionId": "`+sid+`", "parentId": "`+groupID+`", "testcaseName": "TestBar/group/case", "status": "fail"},
+		{"sessionId": "`+sid+`", "parentId": "`+groupID+`", "testcaseName": "TestBar/group/other", "status": "pass"}
+	]}`)
+	s.Require().NoError(err)
+
+	status := func(id string) string {
+		tc, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(id))
+		s.Require().NoError(err)
+		return tc.Status
+	}
+	s.Equal("fail", status(parentID))
+	s.Equal("fail", status(groupID))
+
+	// the failed case passes on retry, so its ancestors are only flaky
+	_, err = s.sendTestcases(testcasesRequest{}, `{"testcases": [
+		{"sessionId": "`+sid+`", "parentId": "`+groupID+`", "testcaseName": "TestBar/group/case", "status": "pass", "retryOf": "`+caseID+`"}
+	]}`)
+	s.Require().NoError(err)
+	s.Equal("flaky", status(parentID))
+	s.Equal("flaky", status(groupID))
+
+	// a parent that failed on its own keeps failing
+	ownID := uuid.NewString()
+	ownCaseID := uuid.NewString()
+	_, err = s.sendTestcases(testcasesRequest{}, `{"testcases": [
+		{"id": "`+ownID+`", "sessionId": "`+sid+`", "testcaseName": "TestBaz", "status": "fail"},
+		{"id": "`+ownCaseID+`", "sessionId": "`+sid+`", "parentId": "`+ownID+`", "testcaseName": "TestBaz/case", "status": "error"}
+	]}`)
+	s.Require().NoError(err)
+	s.Equal("error", status(ownID))
+	_, err = s.sendTestcases(testcasesRequest{}, `{"testcases": [
+		{"sessionId": "`+sid+`", "parentId": "`+ownID+`", "testcaseName": "TestBaz/case", "status": "pass", "retryOf": "`+ownCaseID+`"}
+	]}`)
+	s.Require().NoError(err)
+	s.Equal("fail", status(ownID))
+}
+
+func (s *BaseSuite) TestTestcaseHierarchyInvalid() {
+	sid := s.session1Id.String()
+	existing := s.testcase1Id.String()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed id", `{"id": "nope", "sessionId": "` + sid + `", "testcaseName": "t", "status": "pass"}`},
+		{"duplicate id", `{"id": "` + existing + `", "sessionId": "` + sid + `", "testcaseName": "t", "status": "pass"}`},
+		{"malformed parentId", `{"parentId": "nope", "sessionId": "` + sid + `", "testcaseName": "t", "status": "pass"}`},
+		{"unknown parentId", `{"parentId": "` + uuid.NewString() + `", "sessionId": "` + sid + `", "testcaseName": "t", "status": "pass"}`},
+		{"parentId from another session", `{"parentId": "` + s.testcase6Id.String() + `", "sessionId": "` + sid + `", "testcaseName": "t", "status": "pass"}`},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := s.sendTestcases(testcasesRequest{}, `{"testcases": [`+tt.body+`]}`)
+			s.Require().Error(err)
+			var httpErr *echo.HTTPError
+			s.Require().ErrorAs(err, &httpErr)
+			s.Equal(http.StatusBadRequest, httpErr.Code)
+		})
+	}
+}
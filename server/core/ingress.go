@@ -1,25 +1,40 @@
 package core
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
-	"strings"
+	"slices"
 	"time"
 
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/dbutil"
+	"github.com/cephei8/greener/server/core/metrics"
 	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/queue"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/uptrace/bun"
 )
 
 type IngressHandler struct {
-	db *bun.DB
+	db      *bun.DB
+	outputs *output.Storage
+	// queue is set by OpenQueue; sessions and JSON testcase batches are then journaled
+	// and stored by its workers
+	queue *queue.Queue
 }
 
-func NewIngressHandler(db *bun.DB) *IngressHandler {
-	return &IngressHandler{db: db}
+func NewIngressHandler(db *bun.DB, outputs *output.Storage) *IngressHandler {
+	return &IngressHandler{db: db, outputs: outputs}
 }
 
+var errSessionExists = errors.New("session exists")
+
 type LabelRequest struct {
 	Key   string  `json:"key"`
 	Value *string `json:"value,omitempty"`
@@ -36,7 +51,16 @@ type SessionResponse struct {
 	ID string `json:"id"`
 }
 
+type SessionPatchRequest struct {
+	Description  *string        `json:"description,omitempty"`
+	Baggage      map[string]any `json:"baggage,omitempty"`
+	Labels       []LabelRequest `json:"labels,omitempty"`
+	RemoveLabels []string       `json:"removeLabels,omitempty"`
+}
+
 type TestcaseRequest struct {
+	ID                *string        `json:"id,omitempty"`
+	ParentID          *string        `json:"parentId,omitempty"`
 	SessionID         string         `json:"sessionId"`
 	TestcaseName      string         `json:"testcaseName"`
 	TestcaseClassname *string        `json:"testcaseClassname,omitempty"`
@@ -44,9 +68,19 @@ type TestcaseRequest struct {
 	Testsuite         *string        `json:"testsuite,omitempty"`
 	Status            string         `json:"status"`
 	Output            *string        `json:"output,omitempty"`
+	FailureMessage    *string        `json:"failureMessage,omitempty"`
+	FailureType       *string        `json:"failureType,omitempty"`
+	StackTrace        *string        `json:"stackTrace,omitempty"`
+	Stdout            *string        `json:"stdout,omitempty"`
+	Stderr            *string        `json:"stderr,omitempty"`
+	Attempt           *int           `json:"attempt,omitempty"`
+	RetryOf           *string        `json:"retryOf,omitempty"`
 	Baggage           map[string]any `json:"baggage,omitempty"`
 }
 
+// maxFailureTypeLength matches the width of the indexed failure_type column.
+const maxFailureTypeLength = 255
+
 type TestcasesRequest struct {
 	Testcases []TestcaseRequest `json:"testcases"`
 }
@@ -59,26 +93,33 @@ func (h *IngressHandler) CreateSession(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
 
-	var sessionID uuid.UUID
-	var err error
-	if req.ID != nil && *req.ID != "" {
-		sessionID, err = uuid.Parse(*req.ID)
+	if h.queue != nil {
+		sessionID, err := h.enqueueSession(c.Request().Context(), c.Logger(), userID, req)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Cannot parse session ID")
+			return err
 		}
-	} else {
-		sessionID = uuid.New()
+		return c.JSON(http.StatusAccepted, SessionResponse{ID: sessionID.String()})
 	}
 
-	var baggageJSON []byte
-	if req.Baggage != nil {
-		baggageJSON, err = json.Marshal(req.Baggage)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid baggage format")
-		}
+	sessionID, err := h.createSession(c.Request().Context(), c.Logger(), userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, SessionResponse{ID: sessionID.String()})
+}
+
+// createSession stores a session with its labels. Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) createSession(ctx context.Context, logger echo.Logger, userID model_db.BinaryUUID, req SessionRequest) (uuid.UUID, error) {
+	if err := applyKeyProject(ctx, &req); err != nil {
+		return uuid.Nil, err
+	}
+
+	sessionID, baggageJSON, err := validateSession(req)
+	if err != nil {
+		return uuid.Nil, err
 	}
 
-	ctx := c.Request().Context()
 	now := time.Now()
 
 	session := &model_db.Session{
@@ -89,38 +130,149 @@ func (h *IngressHandler) CreateSession(c echo.Context) error {
 		UpdatedAt:   now,
 		UserID:      userID,
 	}
+	if apiKey := APIKeyFromContext(ctx); apiKey != nil && apiKey.ID != model_db.BinaryUUID(uuid.Nil) {
+		session.APIKeyID = &apiKey.ID
+	}
 
-	_, err = h.db.NewInsert().Model(session).Exec(ctx)
-	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE") || strings.Contains(err.Error(), "duplicate") {
-			return echo.NewHTTPError(http.StatusBadRequest, "Session with this ID already exists")
-		}
-		c.Logger().Errorf("Failed to insert session: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create session")
-	}
-
-	if len(req.Labels) > 0 {
-		for _, labelReq := range req.Labels {
-			label := &model_db.Label{
-				SessionID: model_db.BinaryUUID(sessionID),
-				Key:       labelReq.Key,
-				Value:     labelReq.Value,
-				UserID:    userID,
-				CreatedAt: now,
-				UpdatedAt: now,
+	labels := make([]model_db.Label, 0, len(req.Labels))
+	for _, labelReq := range req.Labels {
+		labels = append(labels, model_db.Label{
+			SessionID: model_db.BinaryUUID(sessionID),
+			Key:       labelReq.Key,
+			Value:     labelReq.Value,
+			UserID:    userID,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	// the session and its labels are stored together, so that a queued session whose labels
+	// failed is not taken for stored when it is retried
+	err = h.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(session).Exec(ctx); err != nil {
+			if dbutil.IsUniqueViolation(err) {
+				return errSessionExists
 			}
-			_, err = h.db.NewInsert().Model(label).Exec(ctx)
-			if err != nil {
-				c.Logger().Errorf("Failed to insert label: %v", err)
-				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create label")
+			return fmt.Errorf("failed to insert session: %w", err)
+		}
+		if len(labels) > 0 {
+			if _, err := tx.NewInsert().Model(&labels).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to insert labels: %w", err)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errSessionExists) {
+			return uuid.Nil, invalidField("id", msgSessionExists)
+		}
+		logger.Errorf("Failed to create session: %v", err)
+		return uuid.Nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create session")
 	}
 
-	return c.JSON(http.StatusCreated, SessionResponse{ID: sessionID.String()})
+	metrics.IngressSessionsTotal.Inc()
+
+	return sessionID, nil
 }
 
+func (h *IngressHandler) PatchSession(c echo.Context) error {
+	userID := GetUserId(c)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Cannot parse session ID")
+	}
+
+	var req SessionPatchRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	actor := SessionActor{UserID: userID}
+	if apiKey := GetAPIKey(c); apiKey != nil {
+		actor.APIKeyID = &apiKey.ID
+	}
+
+	patch := SessionPatch{
+		Description:  req.Description,
+		Baggage:      req.Baggage,
+		SetLabels:    req.Labels,
+		RemoveLabels: req.RemoveLabels,
+	}
+	if err := h.patchSession(c.Request().Context(), c.Logger(), sessionID, patch, actor, clientip.FromContext(c)); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// patchSession applies patch to a session owned by the actor, who connects from ip.
+// Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) patchSession(ctx context.Context, logger echo.Logger, sessionID uuid.UUID, patch SessionPatch, actor SessionActor, ip string) error {
+	h.AwaitSession(ctx, sessionID)
+
+	var session model_db.Session
+	err := h.db.NewSelect().
+		Model(&session).
+		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(sessionID)).
+		Scan(ctx)
+	if err != nil || session.UserID != actor.UserID {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	}
+	if !mayPatchSession(ctx, &session, actor) {
+		return echo.NewHTTPError(http.StatusForbidden, "Session was created by another API key")
+	}
+
+	if err := patch.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := CheckSessionProject(ctx, h.db, logger, session.ID); err != nil {
+		return err
+	}
+	if err := checkPatchProject(ctx, patch); err != nil {
+		return err
+	}
+
+	if err := PatchSession(ctx, h.db, model_db.BinaryUUID(sessionID), patch, actor); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		if errors.Is(err, ErrSessionLimit) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		logger.Errorf("Failed to update session: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update session")
+	}
+
+	auditActor := audit.Actor{UserID: &actor.UserID, Name: "user:" + actor.UserID.String()}
+	if actor.APIKeyID != nil {
+		auditActor = audit.APIKeyActor(actor.UserID, *actor.APIKeyID)
+	}
+	auditActor.IP = ip
+	recordAudit(ctx, h.db, logger, auditActor, audit.Event{
+		Action:     audit.ActionSessionUpdate,
+		TargetType: audit.TargetSession,
+		TargetID:   sessionID.String(),
+		Details:    patch.summary(),
+	})
+	return nil
+}
+
+// mayPatchSession reports whether the actor may patch a session of its user. Sessions can only be
+// patched by the API key that created them, or by admin keys, which act as editors.
+func mayPatchSession(ctx context.Context, session *model_db.Session, actor SessionActor) bool {
+	if apiKey := APIKeyFromContext(ctx); apiKey != nil && apiKey.Scope == model_db.ScopeAdmin {
+		return true
+	}
+	return session.APIKeyID != nil && actor.APIKeyID != nil && *session.APIKeyID == *actor.APIKeyID
+}
+
+// CreateTestcases stores a batch of testcases, or a stream of them with an application/x-ndjson body.
 func (h *IngressHandler) CreateTestcases(c echo.Context) error {
+	if mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType)); mediaType == MIMEApplicationNDJSON {
+		return h.createTestcasesNDJSON(c)
+	}
+
 	userID := GetUserId(c)
 
 	var req TestcasesRequest
@@ -134,64 +286,285 @@ func (h *IngressHandler) CreateTestcases(c echo.Context) error {
 
 	ctx := c.Request().Context()
 	now := time.Now()
+	partial := c.QueryParam("partial") == "true"
 
-	for _, tc := range req.Testcases {
-		sessionID, err := uuid.Parse(tc.SessionID)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Cannot parse session ID")
+	// validate every testcase before storing any, so that invalid requests are rejected
+	// without touching the database
+	sessionIDs := make([]uuid.UUID, len(req.Testcases))
+	sessions := make(map[string]uuid.UUID)
+	var rejected []TestcaseError
+	for i, tc := range req.Testcases {
+		sessionID, ok := sessions[tc.SessionID]
+		if !ok {
+			var err error
+			if h.queue != nil {
+				// the session may still be queued, its workers check it
+				sessionID, err = parseSessionID(tc)
+			} else {
+				sessionID, err = h.testcaseSession(ctx, c.Logger(), userID, tc)
+			}
+			if err != nil {
+				if !rejectable(err) {
+					return err
+				}
+				rejected = append(rejected, testcaseError(i, err))
+				continue
+			}
+			sessions[tc.SessionID] = sessionID
 		}
 
-		var session model_db.Session
-		err = h.db.NewSelect().
-			Model(&session).
-			Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(sessionID)).
-			Scan(ctx)
-		if err != nil {
-			if err.Error() == "sql: no rows in result set" {
-				return echo.NewHTTPError(http.StatusBadRequest, "Unknown session ID")
-			}
-			c.Logger().Errorf("Failed to find session: %v", err)
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify session")
+		if _, err := validateTestcase(tc); err != nil {
+			rejected = append(rejected, testcaseError(i, err))
+			continue
 		}
+		sessionIDs[i] = sessionID
+	}
+
+	if len(rejected) > 0 && !partial {
+		return echo.NewHTTPError(http.StatusBadRequest, ValidationErrorResponse{Message: "Invalid testcases", Errors: rejected})
+	}
 
-		if session.UserID != userID {
-			return echo.NewHTTPError(http.StatusBadRequest, "Session not found")
+	var count, outputSize int64
+	for i, tc := range req.Testcases {
+		if sessionIDs[i] != uuid.Nil {
+			count++
+			outputSize += testcaseOutputSize(tc)
 		}
+	}
+	if err := reserveQuota(ctx, c.Logger(), count, outputSize); err != nil {
+		return err
+	}
 
-		status, err := TestcaseStatusFromString(tc.Status)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	if h.queue != nil {
+		if err := h.enqueueTestcases(ctx, c.Logger(), userID, req.Testcases, sessionIDs, now); err != nil {
+			releaseQuota(ctx, c.Logger(), count, outputSize)
+			return err
+		}
+		if !partial {
+			return c.NoContent(http.StatusAccepted)
 		}
+		return c.JSON(http.StatusAccepted, TestcasesResponse{Accepted: int(count), Rejected: len(rejected), Errors: rejected})
+	}
 
-		var baggageJSON []byte
-		if tc.Baggage != nil {
-			baggageJSON, err = json.Marshal(tc.Baggage)
+	if !partial {
+		if err := h.createTestcasesAtomically(c, userID, req.Testcases, sessionIDs, now); err != nil {
+			releaseQuota(ctx, c.Logger(), count, outputSize)
+			return err
+		}
+		metrics.IngressTestcasesTotal.Add(float64(count))
+		return c.NoContent(http.StatusCreated)
+	}
+
+	// the quota reserved for testcases that are not stored is released
+	resp := TestcasesResponse{Errors: rejected, Rejected: len(rejected)}
+	var storedSize int64
+	for i, tc := range req.Testcases {
+		if sessionIDs[i] == uuid.Nil {
+			continue
+		}
+		if _, err := h.storeTestcase(ctx, h.db, c.Logger(), userID, sessionIDs[i], tc, now); err != nil {
+			if !rejectable(err) {
+				releaseQuota(ctx, c.Logger(), count-int64(resp.Accepted), outputSize-storedSize)
+				return err
+			}
+			resp.Rejected++
+			resp.Errors = append(resp.Errors, testcaseError(i, err))
+			continue
+		}
+		metrics.IngressTestcasesTotal.Inc()
+		resp.Accepted++
+		storedSize += testcaseOutputSize(tc)
+	}
+	releaseQuota(ctx, c.Logger(), count-int64(resp.Accepted), outputSize-storedSize)
+
+	if resp.Rejected > 0 {
+		slices.SortFunc(resp.Errors, func(a, b TestcaseError) int { return a.Index - b.Index })
+		return c.JSON(http.StatusOK, resp)
+	}
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// createTestcasesAtomically stores the validated testcases of a strict request in one
+// transaction, so that the request stores all of them or none, even if one fails when stored.
+// Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) createTestcasesAtomically(c echo.Context, userID model_db.BinaryUUID, testcases []TestcaseRequest, sessionIDs []uuid.UUID, now time.Time) error {
+	ctx := c.Request().Context()
+	var stored []*model_db.Testcase
+	err := h.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for i, tc := range testcases {
+			testcase, err := h.storeTestcase(ctx, tx, c.Logger(), userID, sessionIDs[i], tc, now)
 			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "Invalid baggage format")
+				if rejectable(err) {
+					return echo.NewHTTPError(http.StatusBadRequest, ValidationErrorResponse{Message: "Invalid testcases", Errors: []TestcaseError{testcaseError(i, err)}})
+				}
+				return err
 			}
+			stored = append(stored, testcase)
+		}
+		return nil
+	})
+	if err != nil {
+		h.discardOutputs(ctx, c.Logger(), stored...)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return err
 		}
+		c.Logger().Errorf("Failed to store testcases: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create testcases")
+	}
+	return nil
+}
 
-		testcase := &model_db.Testcase{
-			ID:        model_db.BinaryUUID(uuid.New()),
-			SessionID: model_db.BinaryUUID(sessionID),
-			Name:      tc.TestcaseName,
-			Classname: tc.TestcaseClassname,
-			File:      tc.TestcaseFile,
-			Testsuite: tc.Testsuite,
-			Status:    status,
-			Output:    tc.Output,
-			Baggage:   baggageJSON,
-			CreatedAt: now,
-			UpdatedAt: now,
-			UserID:    userID,
+// discardOutputs deletes the offloaded output of testcases that were not stored.
+func (h *IngressHandler) discardOutputs(ctx context.Context, logger echo.Logger, testcases ...*model_db.Testcase) {
+	var refs []string
+	for _, testcase := range testcases {
+		if testcase.OutputRef != nil {
+			refs = append(refs, *testcase.OutputRef)
 		}
+	}
+	if err := h.outputs.Delete(context.WithoutCancel(ctx), refs); err != nil {
+		logger.Errorf("Failed to delete output of testcases that were not stored: %v", err)
+	}
+}
 
-		_, err = h.db.NewInsert().Model(testcase).Exec(ctx)
-		if err != nil {
-			c.Logger().Errorf("Failed to insert testcase: %v", err)
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create testcase")
+func parseSessionID(tc TestcaseRequest) (uuid.UUID, error) {
+	sessionID, err := uuid.Parse(tc.SessionID)
+	if err != nil {
+		return uuid.Nil, invalidField("sessionId", "Cannot parse session ID")
+	}
+	return sessionID, nil
+}
+
+// testcaseSession parses the session ID of a testcase and checks that the session belongs to userID
+// and to the project of the API key of ctx.
+// Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) testcaseSession(ctx context.Context, logger echo.Logger, userID model_db.BinaryUUID, tc TestcaseRequest) (uuid.UUID, error) {
+	sessionID, err := parseSessionID(tc)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var session model_db.Session
+	err = h.db.NewSelect().
+		Model(&session).
+		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(sessionID)).
+		Scan(ctx)
+	if err != nil {
+		if err.Error() == "sql: no rows in result set" {
+			return uuid.Nil, invalidField("sessionId", "Unknown session ID")
+		}
+		logger.Errorf("Failed to find session: %v", err)
+		return uuid.Nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify session")
+	}
+
+	if session.UserID != userID {
+		return uuid.Nil, invalidField("sessionId", "Session not found")
+	}
+	if err := CheckSessionProject(ctx, h.db, logger, session.ID); err != nil {
+		return uuid.Nil, err
+	}
+
+	return sessionID, nil
+}
+
+// createTestcase validates, stores and counts a single testcase of a session owned by userID.
+// Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) createTestcase(ctx context.Context, logger echo.Logger, userID model_db.BinaryUUID, sessionID uuid.UUID, tc TestcaseRequest, now time.Time) error {
+	outputSize := testcaseOutputSize(tc)
+	if err := reserveQuota(ctx, logger, 1, outputSize); err != nil {
+		return err
+	}
+	if _, err := h.storeTestcase(ctx, h.db, logger, userID, sessionID, tc, now); err != nil {
+		releaseQuota(ctx, logger, 1, outputSize)
+		return err
+	}
+	metrics.IngressTestcasesTotal.Inc()
+	return nil
+}
+
+// storeTestcase validates and stores a single testcase of a session owned by userID with db,
+// which may be a transaction storing several testcases. A transaction that is rolled back must
+// discard the output of the returned testcase. The caller counts the testcase against the
+// quotas of the request. Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) storeTestcase(ctx context.Context, db bun.IDB, logger echo.Logger, userID model_db.BinaryUUID, sessionID uuid.UUID, tc TestcaseRequest, now time.Time) (*model_db.Testcase, error) {
+	valid, err := validateTestcase(tc)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentID *model_db.BinaryUUID
+	if valid.parentID != nil {
+		if err := checkParent(ctx, db, model_db.BinaryUUID(sessionID), *valid.parentID); err != nil {
+			if errors.Is(err, errUnknownParentTestcase) {
+				return nil, invalidField("parentId", "Unknown parent testcase")
+			}
+			logger.Errorf("Failed to find parent testcase: %v", err)
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create testcase")
+		}
+		parentID = (*model_db.BinaryUUID)(valid.parentID)
+	}
+
+	testcase := &model_db.Testcase{
+		ID:             model_db.BinaryUUID(valid.id),
+		SessionID:      model_db.BinaryUUID(sessionID),
+		ParentID:       parentID,
+		Name:           tc.TestcaseName,
+		Classname:      tc.TestcaseClassname,
+		File:           tc.TestcaseFile,
+		Testsuite:      tc.Testsuite,
+		Status:         valid.status,
+		FailureMessage: h.outputs.Limit(tc.FailureMessage),
+		FailureType:    tc.FailureType,
+		StackTrace:     h.outputs.Limit(tc.StackTrace),
+		Stdout:         h.outputs.Limit(tc.Stdout),
+		Stderr:         h.outputs.Limit(tc.Stderr),
+		Baggage:        valid.baggage,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		UserID:         userID,
+	}
+
+	prev, err := previousAttempt(ctx, db, testcase.SessionID, tc, valid.retryOf)
+	if err != nil {
+		if errors.Is(err, errUnknownRetriedTestcase) {
+			return nil, invalidField("retryOf", "Unknown retried testcase")
 		}
+		logger.Errorf("Failed to find previous attempt: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create testcase")
 	}
+	applyAttempt(testcase, prev, tc.Attempt)
+	reportedStatus := testcase.Status
+	testcase.ReportedStatus = &reportedStatus
 
-	return c.NoContent(http.StatusCreated)
+	if err := h.outputs.Encode(ctx, testcase, tc.Output); err != nil {
+		logger.Errorf("Failed to store testcase output: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to store testcase output")
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if prev != nil {
+			_, err := tx.NewUpdate().
+				Model((*model_db.Testcase)(nil)).
+				Set("? = ?", bun.Ident("superseded"), true).
+				Where("? = ?", bun.Ident("id"), prev.ID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewInsert().Model(testcase).Exec(ctx); err != nil {
+			return err
+		}
+		return rollUpStatus(ctx, tx, testcase.ParentID)
+	})
+	if err != nil {
+		h.discardOutputs(ctx, logger, testcase)
+		if dbutil.IsUniqueViolation(err) {
+			return nil, invalidField("id", msgTestcaseExists)
+		}
+		logger.Errorf("Failed to insert testcase: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create testcase")
+	}
+	return testcase, nil
 }
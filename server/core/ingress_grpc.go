@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/metrics"
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/quota"
+	ingressv1 "github.com/cephei8/greener/server/proto/ingress/v1"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ingressServer implements the gRPC ingress service with the validation and persistence of IngressHandler.
+// Its methods return *echo.HTTPError, which the interceptors of NewGRPCServer translate to gRPC status codes.
+type ingressServer struct {
+	ingressv1.UnimplementedIngressServiceServer
+
+	handler *IngressHandler
+	sec     *Security
+	limiter *quota.Limiter
+	logger  echo.Logger
+}
+
+// NewGRPCServer returns a gRPC server with the ingress service. Calls are authenticated with
+// the same ingest API keys as the HTTP ingress API, passed in the x-api-key metadata and verified with sec,
+// and limited by limiter unless it is nil.
+func NewGRPCServer(handler *IngressHandler, sec *Security, limiter *quota.Limiter, logger echo.Logger, opts ...grpc.ServerOption) *grpc.Server {
+	s := &ingressServer{handler: handler, sec: sec, limiter: limiter, logger: logger}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	server := grpc.NewServer(opts...)
+	ingressv1.RegisterIngressServiceServer(server, s)
+	return server
+}
+
+// GRPCHandler serves HTTP/2 gRPC requests with server and passes everything else on to Echo.
+// It lets gRPC share the HTTP port when the server accepts unencrypted HTTP/2.
+func GRPCHandler(server *grpc.Server) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.ProtoMajor == 2 && strings.HasPrefix(req.Header.Get(echo.HeaderContentType), "application/grpc") {
+				server.ServeHTTP(c.Response().Writer, req)
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+func (s *ingressServer) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, usage, err := s.admit(ctx)
+	if err != nil {
+		grpc.SetTrailer(ctx, retryAfterTrailer(err))
+		return nil, s.grpcError(info.FullMethod, err)
+	}
+	defer s.flush(ctx, usage)
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		grpc.SetTrailer(ctx, retryAfterTrailer(err))
+		return nil, s.grpcError(info.FullMethod, err)
+	}
+	return resp, nil
+}
+
+func (s *ingressServer) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, usage, err := s.admit(stream.Context())
+	if err != nil {
+		stream.SetTrailer(retryAfterTrailer(err))
+		return s.grpcError(info.FullMethod, err)
+	}
+	defer s.flush(ctx, usage)
+
+	if err := handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx}); err != nil {
+		stream.SetTrailer(retryAfterTrailer(err))
+		return s.grpcError(info.FullMethod, err)
+	}
+	return nil
+}
+
+// admit authenticates a call and applies the rate limits and quotas of its API key.
+// The returned context carries the API key and the usage of the call.
+func (s *ingressServer) admit(ctx context.Context) (context.Context, *quota.Usage, error) {
+	authCtx, err := s.authenticate(ctx)
+	if err != nil || s.limiter == nil {
+		return authCtx, nil, err
+	}
+	usage, err := beginUsage(authCtx, s.limiter, s.logger, APIKeyFromContext(authCtx))
+	if err != nil {
+		return authCtx, nil, err
+	}
+	return quota.NewContext(authCtx, usage), usage, nil
+}
+
+func (s *ingressServer) flush(ctx context.Context, usage *quota.Usage) {
+	if err := usage.Flush(context.WithoutCancel(ctx)); err != nil {
+		s.logger.Errorf("Failed to record API key usage: %v", err)
+	}
+}
+
+// retryAfterTrailer returns the retry-after trailer of a call rejected by a rate limit, quota or lockout.
+func retryAfterTrailer(err error) metadata.MD {
+	if seconds, ok := retryAfter(err); ok {
+		return metadata.Pairs("retry-after", strconv.Itoa(seconds))
+	}
+	return nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *ingressServer) authenticate(ctx context.Context) (context.Context, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+	apiKey, err := s.sec.authenticateAPIKey(ctx, s.handler.db, s.logger, header, model_db.ScopeIngest, peerIP(ctx))
+	if err != nil {
+		return ctx, err
+	}
+	return WithAPIKey(ctx, apiKey), nil
+}
+
+// peerIP returns the IP address of the client of a gRPC call, or "" if unknown.
+func peerIP(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return clientip.Parse(host)
+		}
+	}
+	return ""
+}
+
+func (s *ingressServer) actor(ctx context.Context) SessionActor {
+	apiKey := APIKeyFromContext(ctx)
+	return SessionActor{UserID: apiKey.UserID, APIKeyID: &apiKey.ID}
+}
+
+// grpcError translates an *echo.HTTPError to a gRPC status and counts it as an ingress error.
+func (s *ingressServer) grpcError(method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		s.logger.Errorf("gRPC ingress call %s failed: %v", method, err)
+		httpErr = echo.NewHTTPError(http.StatusInternalServerError, "Internal error")
+	}
+	metrics.IngressErrorsTotal.WithLabelValues(method, strconv.Itoa(httpErr.Code)).Inc()
+
+	code := codes.Internal
+	switch httpErr.Code {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, fmt.Sprint(httpErr.Message))
+}
+
+func (s *ingressServer) CreateSession(ctx context.Context, req *ingressv1.CreateSessionRequest) (*ingressv1.CreateSessionResponse, error) {
+	sessionID, err := s.handler.createSession(ctx, s.logger, s.actor(ctx).UserID, SessionRequest{
+		ID:          req.Id,
+		Description: req.Description,
+		Baggage:     grpcBaggage(req.Baggage),
+		Labels:      grpcLabels(req.Labels),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ingressv1.CreateSessionResponse{Id: sessionID.String()}, nil
+}
+
+func (s *ingressServer) ReportTestcases(stream grpc.ClientStreamingServer[ingressv1.ReportTestcasesRequest, ingressv1.ReportTestcasesResponse]) error {
+	ctx := stream.Context()
+	userID := s.actor(ctx).UserID
+
+	// sessions caches the session IDs already verified on this stream
+	sessions := map[string]uuid.UUID{}
+	var stored int64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&ingressv1.ReportTestcasesResponse{Testcases: stored})
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, t := range req.Testcases {
+			tc := grpcTestcaseRequest(t)
+
+			sessionID, ok := sessions[tc.SessionID]
+			if !ok {
+				sessionID, err = s.handler.requestSession(ctx, s.logger, userID, tc)
+				if err != nil {
+					return err
+				}
+				sessions[tc.SessionID] = sessionID
+			}
+
+			if err := s.handler.createTestcase(ctx, s.logger, userID, sessionID, tc, now); err != nil {
+				return err
+			}
+			stored++
+		}
+	}
+}
+
+func (s *ingressServer) FinalizeSession(ctx context.Context, req *ingressv1.FinalizeSessionRequest) (*ingressv1.FinalizeSessionResponse, error) {
+	sessionID, err := uuid.Parse(req.SessionId)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Cannot parse session ID")
+	}
+
+	patch := SessionPatch{
+		Description:  req.Description,
+		Baggage:      grpcBaggage(req.Baggage),
+		SetLabels:    grpcLabels(req.Labels),
+		RemoveLabels: req.RemoveLabels,
+	}
+	if err := s.handler.patchSession(ctx, s.logger, sessionID, patch, s.actor(ctx), peerIP(ctx)); err != nil {
+		return nil, err
+	}
+
+	var summary struct {
+		Status    *int64 `bun:"status"`
+		Testcases int64  `bun:"testcases"`
+	}
+	err = s.handler.db.NewSelect().
+		TableExpr("?", bun.Ident("testcases")).
+		ColumnExpr("MIN(?) AS ?", bun.Ident("status"), bun.Ident("status")).
+		ColumnExpr("COUNT(*) AS ?", bun.Ident("testcases")).
+		Where("? = ?", bun.Ident("session_id"), model_db.BinaryUUID(sessionID)).
+		Where("? = ?", bun.Ident("superseded"), false).
+		Scan(ctx, &summary)
+	if err != nil {
+		s.logger.Errorf("Failed to summarize session: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to summarize session")
+	}
+
+	resp := &ingressv1.FinalizeSessionResponse{Status: "pass", Testcases: summary.Testcases}
+	if summary.Status != nil {
+		resp.Status = TestcaseStatusToString(model_db.TestcaseStatus(*summary.Status))
+	}
+	return resp, nil
+}
+
+func grpcTestcaseRequest(t *ingressv1.Testcase) TestcaseRequest {
+	tc := TestcaseRequest{
+		ID:                t.Id,
+		ParentID:          t.ParentId,
+		SessionID:         t.SessionId,
+		TestcaseName:      t.Name,
+		TestcaseClassname: t.Classname,
+		TestcaseFile:      t.File,
+		Testsuite:         t.Testsuite,
+		Status:            t.Status,
+		Output:            t.Output,
+		FailureMessage:    t.FailureMessage,
+		FailureType:       t.FailureType,
+		StackTrace:        t.StackTrace,
+		Stdout:            t.Stdout,
+		Stderr:            t.Stderr,
+		RetryOf:           t.RetryOf,
+		Baggage:           grpcBaggage(t.Baggage),
+	}
+	if t.Attempt != nil {
+		attempt := int(*t.Attempt)
+		tc.Attempt = &attempt
+	}
+	return tc
+}
+
+func grpcLabels(labels []*ingressv1.Label) []LabelRequest {
+	var result []LabelRequest
+	for _, label := range labels {
+		result = append(result, LabelRequest{Key: label.Key, Value: label.Value})
+	}
+	return result
+}
+
+func grpcBaggage(baggage *structpb.Struct) map[string]any {
+	if baggage == nil {
+		return nil
+	}
+	return baggage.AsMap()
+}
@@ -0,0 +1,142 @@
+package core_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/retention"
+	ingressv1 "github.com/cephei8/greener/server/proto/ingress/v1"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// grpcIngressClient starts the gRPC ingress service in memory and returns a client
+// with the metadata of a new API key of the test user.
+func (s *BaseSuite) grpcIngressClient(ctx context.Context) (ingressv1.IngressServiceClient, context.Context) {
+	secret := "grpc-secret"
+	salt := []byte("grpc-salt")
+	apiKeyID := uuid.New()
+	_, err := s.db.NewInsert().Model(&model_db.APIKey{
+		ID:         model_db.BinaryUUID(apiKeyID),
+		SecretSalt: salt,
+		SecretHash: core.HashSecret(secret, salt),
+		Scope:      model_db.ScopeIngest,
+		UserID:     s.userID,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}).Exec(ctx)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		_, err := s.db.NewDelete().Model((*model_db.APIKey)(nil)).Where("id = ?", model_db.BinaryUUID(apiKeyID)).Exec(ctx)
+		s.Require().NoError(err)
+	})
+
+	listener := bufconn.Listen(1 << 20)
+	server := core.NewGRPCServer(core.NewIngressHandler(s.db, output.DefaultStorage()), testSecurity, nil, echo.New().Logger)
+	go server.Serve(listener)
+	s.T().Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { conn.Close() })
+
+	key, err := json.Marshal(core.APIKeyData{APIKeyID: apiKeyID.String(), APIKeySecret: secret})
+	s.Require().NoError(err)
+	return ingressv1.NewIngressServiceClient(conn), metadata.AppendToOutgoingContext(ctx, "x-api-key", base64.StdEncoding.EncodeToString(key))
+}
+
+func (s *BaseSuite) TestGRPCIngress() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+	client, authCtx := s.grpcIngressClient(ctx)
+
+	_, err := client.CreateSession(ctx, &ingressv1.CreateSessionRequest{})
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	baggage, err := structpb.NewStruct(map[string]any{"ci": "grpc"})
+	s.Require().NoError(err)
+	created, err := client.CreateSession(authCtx, &ingressv1.CreateSessionRequest{
+		Description: proto.String("streamed"),
+		Baggage:     baggage,
+		Labels:      []*ingressv1.Label{{Key: "transport", Value: proto.String("grpc")}},
+	})
+	s.Require().NoError(err)
+	sid := created.Id
+	s.purgeOnCleanup(model_db.BinaryUUID(uuid.MustParse(sid)), retention.Stores{})
+
+	stream, err := client.ReportTestcases(authCtx)
+	s.Require().NoError(err)
+	s.Require().NoError(stream.Send(&ingressv1.ReportTestcasesRequest{Testcases: []*ingressv1.Testcase{
+		{SessionId: sid, Name: "test_a", Status: "pass"},
+		{SessionId: sid, Name: "test_b", Status: "pass", Testsuite: proto.String("suite")},
+	}}))
+	s.Require().NoError(stream.Send(&ingressv1.ReportTestcasesRequest{Testcases: []*ingressv1.Testcase{
+		{SessionId: sid, Name: "test_c", Status: "fail", FailureMessage: proto.String("boom"), Attempt: proto.Int32(1)},
+	}}))
+	reported, err := stream.CloseAndRecv()
+	s.Require().NoError(err)
+	s.Equal(int64(3), reported.Testcases)
+
+	failed := mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_c"`)
+	s.Require().Len(failed, 1)
+	detail, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(failed[0]))
+	s.Require().NoError(err)
+	s.Equal("fail", detail.Status)
+	s.Equal("boom", detail.FailureMessage)
+
+	// an invalid testcase aborts the stream, earlier testcases are kept
+	stream, err = client.ReportTestcases(authCtx)
+	s.Require().NoError(err)
+	s.Require().NoError(stream.Send(&ingressv1.ReportTestcasesRequest{Testcases: []*ingressv1.Testcase{
+		{SessionId: sid, Name: "test_d", Status: "pass"},
+		{SessionId: sid, Name: "test_e", Status: "broken"},
+	}}))
+	_, err = stream.CloseAndRecv()
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_d"`), 1)
+
+	stream, err = client.ReportTestcases(authCtx)
+	s.Require().NoError(err)
+	s.Require().NoError(stream.Send(&ingressv1.ReportTestcasesRequest{Testcases: []*ingressv1.Testcase{
+		{SessionId: uuid.NewString(), Name: "test_f", Status: "pass"},
+	}}))
+	_, err = stream.CloseAndRecv()
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	finalized, err := client.FinalizeSession(authCtx, &ingressv1.FinalizeSessionRequest{
+		SessionId: sid,
+		Labels:    []*ingressv1.Label{{Key: "result", Value: proto.String("done")}},
+	})
+	s.Require().NoError(err)
+	s.Equal("fail", finalized.Status)
+	s.Equal(int64(4), finalized.Testcases)
+
+	session, err := svc.GetSession(ctx, s.userID, uuid.MustParse(sid))
+	s.Require().NoError(err)
+	s.Equal("streamed", session.Description)
+	s.Equal(map[string]string{"transport": "grpc", "result": "done"}, session.Labels)
+	s.Equal(map[string]any{"ci": "grpc"}, session.Baggage)
+
+	_, err = client.FinalizeSession(authCtx, &ingressv1.FinalizeSessionRequest{SessionId: uuid.NewString()})
+	s.Equal(codes.NotFound, status.Code(err))
+
+	_, err = client.FinalizeSession(authCtx, &ingressv1.FinalizeSessionRequest{SessionId: "nope"})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
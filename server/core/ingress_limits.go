@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cephei8/greener/server/core/lockout"
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/quota"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// IngressLimits applies the rate limits and quotas of limiter to requests authenticated by APIKeyAuth
+// and counts their usage. Limited requests are rejected with 429 and a Retry-After header.
+func IngressLimits(limiter *quota.Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiKey := GetAPIKey(c)
+			if apiKey == nil {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			usage, err := beginUsage(ctx, limiter, c.Logger(), apiKey)
+			if err != nil {
+				setRetryAfter(c, err)
+				return err
+			}
+			c.SetRequest(c.Request().WithContext(quota.NewContext(ctx, usage)))
+
+			err = next(c)
+			if err != nil {
+				setRetryAfter(c, err)
+			}
+			if err := usage.Flush(context.WithoutCancel(ctx)); err != nil {
+				c.Logger().Errorf("Failed to record API key usage: %v", err)
+			}
+			return err
+		}
+	}
+}
+
+// beginUsage admits a request of apiKey. Errors are returned as *echo.HTTPError.
+func beginUsage(ctx context.Context, limiter *quota.Limiter, logger echo.Logger, apiKey *model_db.APIKey) (*quota.Usage, error) {
+	var project string
+	if apiKey.Project != nil {
+		project = *apiKey.Project
+	}
+	usage, err := limiter.Begin(ctx, uuid.UUID(apiKey.ID), uuid.UUID(apiKey.UserID), project)
+	if err != nil {
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			return nil, limitExceeded(exceeded)
+		}
+		logger.Errorf("Failed to check API key quotas: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to check quotas")
+	}
+	return usage, nil
+}
+
+// reserveQuota counts testcases with outputBytes of output against the quotas of the request
+// of ctx before they are stored. Errors are returned as *echo.HTTPError.
+func reserveQuota(ctx context.Context, logger echo.Logger, testcases, outputBytes int64) error {
+	err := quota.FromContext(ctx).Reserve(ctx, testcases, outputBytes)
+	if err == nil {
+		return nil
+	}
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return limitExceeded(exceeded)
+	}
+	logger.Errorf("Failed to reserve quota: %v", err)
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check quotas")
+}
+
+// releaseQuota returns testcases with outputBytes of output reserved by reserveQuota
+// that were not stored.
+func releaseQuota(ctx context.Context, logger echo.Logger, testcases, outputBytes int64) {
+	if err := quota.FromContext(ctx).Release(context.WithoutCancel(ctx), testcases, outputBytes); err != nil {
+		logger.Errorf("Failed to release quota: %v", err)
+	}
+}
+
+func limitExceeded(err *quota.ExceededError) *echo.HTTPError {
+	message := err.Error()
+	return echo.NewHTTPError(http.StatusTooManyRequests, strings.ToUpper(message[:1])+message[1:]).SetInternal(err)
+}
+
+func setRetryAfter(c echo.Context, err error) {
+	if seconds, ok := retryAfter(err); ok {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
+// retryAfter returns in how many seconds a request rejected by a rate limit, quota or
+// lockout with err may be retried.
+func retryAfter(err error) (int, bool) {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return exceeded.RetryAfterSeconds(), true
+	}
+	var blocked *lockout.BlockedError
+	if errors.As(err, &blocked) {
+		return blocked.RetryAfterSeconds(), true
+	}
+	return 0, false
+}
+
+// testcaseOutputSize is the size of the output of a testcase counted against the daily output quota.
+func testcaseOutputSize(tc TestcaseRequest) int64 {
+	var size int
+	for _, output := range []*string{tc.Output, tc.Stdout, tc.Stderr} {
+		if output != nil {
+			size += len(*output)
+		}
+	}
+	return int64(size)
+}
@@ -0,0 +1,158 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/quota"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// deleteUsage deletes the API key and quota usage of the test user.
+func (s *BaseSuite) deleteUsage(ctx context.Context) {
+	_, err := s.db.NewDelete().Model((*model_db.APIKeyUsage)(nil)).Where("user_id = ?", s.userID).Exec(ctx)
+	s.Require().NoError(err)
+	_, err = s.db.NewDelete().Model((*model_db.QuotaUsage)(nil)).Where("user_id = ?", s.userID).Exec(ctx)
+	s.Require().NoError(err)
+}
+
+func (s *BaseSuite) TestIngressLimits() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"limits": "test"})
+	sid := uuid.UUID(sessionID).String()
+	apiKey := &model_db.APIKey{ID: model_db.BinaryUUID(uuid.New()), UserID: s.userID}
+	defer s.deleteUsage(ctx)
+
+	testcases := func(names ...string) string {
+		var items []string
+		for _, name := range names {
+			items = append(items, `{"sessionId": "`+sid+`", "testcaseName": "`+name+`", "status": "pass", "stdout": "hello"}`)
+		}
+		return `{"testcases": [` + strings.Join(items, ",") + `]}`
+	}
+
+	limiter := quota.NewLimiter(s.db, quota.Limits{DailyTestcases: 3})
+
+	rec, err := s.sendTestcases(testcasesRequest{APIKey: apiKey, Limiter: limiter}, testcases("test_a", "test_b"))
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+
+	usage, err := quota.KeyUsage(ctx, s.db, uuid.UUID(s.userID), quota.Day(time.Now()))
+	s.Require().NoError(err)
+	s.Equal(quota.Counters{Requests: 1, Testcases: 2, OutputBytes: 10}, usage[uuid.UUID(apiKey.ID)])
+
+	// a request exceeding the quota stores nothing
+	rec, err = s.sendTestcases(testcasesRequest{APIKey: apiKey, Limiter: limiter}, testcases("test_c", "test_d"))
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusTooManyRequests, httpErr.Code)
+	s.Equal("Daily testcase quota exceeded", httpErr.Message)
+	s.NotEmpty(rec.Header().Get("Retry-After"))
+	s.Empty(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_c"`))
+
+	rec, err = s.sendTestcases(testcasesRequest{APIKey: apiKey, Limiter: limiter}, testcases("test_c"))
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+
+	// the quota is shared by all API keys of the user
+	otherKey := &model_db.APIKey{ID: model_db.BinaryUUID(uuid.New()), UserID: s.userID}
+	_, err = s.sendTestcases(testcasesRequest{APIKey: otherKey, Limiter: limiter}, testcases("test_e"))
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusTooManyRequests, httpErr.Code)
+
+	usage, err = quota.KeyUsage(ctx, s.db, uuid.UUID(s.userID), quota.Day(time.Now()))
+	s.Require().NoError(err)
+	s.Equal(quota.Counters{Requests: 3, Testcases: 3, OutputBytes: 15}, usage[uuid.UUID(apiKey.ID)])
+
+	// rate limits
+	limiter = quota.NewLimiter(s.db, quota.Limits{KeyRate: 0.5, KeyBurst: 1})
+	rec, err = s.sendTestcases(testcasesRequest{APIKey: otherKey, Limiter: limiter}, testcases("test_f"))
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+
+	rec, err = s.sendTestcases(testcasesRequest{APIKey: otherKey, Limiter: limiter}, testcases("test_g"))
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusTooManyRequests, httpErr.Code)
+	s.Equal("API key rate limit exceeded", httpErr.Message)
+	s.Equal("2", rec.Header().Get("Retry-After"))
+}
+
+func (s *BaseSuite) TestIngressProjectQuotas() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{core.ProjectLabel: "web"})
+	sid := uuid.UUID(sessionID).String()
+	defer s.deleteUsage(ctx)
+
+	testcase := func(name string) string {
+		return `{"testcases": [{"sessionId": "` + sid + `", "testcaseName": "` + name + `", "status": "pass", "stdout": "hello"}]}`
+	}
+
+	project := "web"
+	webKey := &model_db.APIKey{ID: model_db.BinaryUUID(uuid.New()), UserID: s.userID, Project: &project}
+	otherWebKey := &model_db.APIKey{ID: model_db.BinaryUUID(uuid.New()), UserID: s.userID, Project: &project}
+	unrestrictedKey := &model_db.APIKey{ID: model_db.BinaryUUID(uuid.New()), UserID: s.userID}
+	limiter := quota.NewLimiter(s.db, quota.Limits{DailyTestcases: 3, ProjectDailyOutputBytes: 10})
+
+	_, err := s.sendTestcases(testcasesRequest{APIKey: webKey, Limiter: limiter}, testcase("test_a"))
+	s.Require().NoError(err)
+	_, err = s.sendTestcases(testcasesRequest{APIKey: otherWebKey, Limiter: limiter}, testcase("test_b"))
+	s.Require().NoError(err)
+
+	// the project quota is shared by the keys restricted to the project
+	_, err = s.sendTestcases(testcasesRequest{APIKey: webKey, Limiter: limiter}, testcase("test_c"))
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusTooManyRequests, httpErr.Code)
+	s.Equal("Project daily output quota exceeded", httpErr.Message)
+	s.Empty(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_c"`))
+
+	// keys that are not restricted to the project only have the user quota
+	rec, err := s.sendTestcases(testcasesRequest{APIKey: unrestrictedKey, Limiter: limiter}, testcase("test_d"))
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+	_, err = s.sendTestcases(testcasesRequest{APIKey: unrestrictedKey, Limiter: limiter}, testcase("test_e"))
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal("Daily testcase quota exceeded", httpErr.Message)
+}
+
+func (s *BaseSuite) TestQuotaReservations() {
+	ctx := context.Background()
+	defer s.deleteUsage(ctx)
+
+	// both requests are admitted before either stores its testcases
+	limiter := quota.NewLimiter(s.db, quota.Limits{DailyTestcases: 3, ProjectDailyTestcases: 2})
+	userID := uuid.UUID(s.userID)
+	first, err := limiter.Begin(ctx, uuid.New(), userID, "web")
+	s.Require().NoError(err)
+	second, err := limiter.Begin(ctx, uuid.New(), userID, "web")
+	s.Require().NoError(err)
+
+	s.Require().NoError(first.Reserve(ctx, 2, 0))
+	err = second.Reserve(ctx, 1, 0)
+	var exceeded *quota.ExceededError
+	s.Require().ErrorAs(err, &exceeded)
+	s.Equal("project daily testcase quota", exceeded.Limit)
+
+	// a rejected reservation counts nothing, and released testcases may be reserved again
+	unrestricted, err := limiter.Begin(ctx, uuid.New(), userID, "")
+	s.Require().NoError(err)
+	s.Require().NoError(unrestricted.Reserve(ctx, 1, 0))
+	s.Require().NoError(first.Release(ctx, 1, 0))
+	s.Require().NoError(second.Reserve(ctx, 1, 0))
+	s.Require().ErrorAs(unrestricted.Reserve(ctx, 1, 0), &exceeded)
+	s.Equal("daily testcase quota", exceeded.Limit)
+
+	// requests that could not store a single testcase are rejected when admitted
+	_, err = limiter.Begin(ctx, uuid.New(), userID, "")
+	s.Require().ErrorAs(err, &exceeded)
+	s.Equal("daily testcase quota", exceeded.Limit)
+}
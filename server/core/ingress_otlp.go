@@ -0,0 +1,414 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxOTLPRequestSize caps both the request body and the decompressed OTLP export.
+const maxOTLPRequestSize = 16 << 20
+
+// Attributes of the OpenTelemetry test semantic conventions and the Greener session ID attribute.
+const (
+	otlpTestCaseName   = "test.case.name"
+	otlpTestSuiteName  = "test.suite.name"
+	otlpTestCaseStatus = "test.case.result.status"
+	otlpSessionID      = "greener.session.id"
+)
+
+const (
+	otlpContentTypeProtobuf = "application/x-protobuf"
+	otlpContentTypeJSON     = "application/json"
+)
+
+// otlpTestcase is a testcase taken from a span or log record, before its session is resolved.
+type otlpTestcase struct {
+	sessionID string
+	labels    []LabelRequest
+	testcase  TestcaseRequest
+}
+
+// ExportTraces is an OTLP/HTTP trace receiver. Spans with a test.case.name attribute are stored
+// as testcases, other spans are ignored. Testcases are added to the session given by the
+// greener.session.id span or resource attribute, or else to a session with the span's trace ID;
+// a missing session is created with the resource attributes as labels.
+func (h *IngressHandler) ExportTraces(c echo.Context) error {
+	var req coltracepb.ExportTraceServiceRequest
+	contentType, err := readOTLPRequest(c, &req)
+	if err != nil {
+		return err
+	}
+
+	var testcases []otlpTestcase
+	for _, rs := range req.GetResourceSpans() {
+		resource := rs.GetResource().GetAttributes()
+		fallbackSessionID := uuid.NewString()
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				tc, ok, err := spanTestcase(resource, span, fallbackSessionID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+				}
+				if ok {
+					testcases = append(testcases, tc)
+				}
+			}
+		}
+	}
+
+	if err := h.storeOTLPTestcases(c, testcases); err != nil {
+		return err
+	}
+	return writeOTLPResponse(c, contentType, &coltracepb.ExportTraceServiceResponse{})
+}
+
+// ExportLogs is an OTLP/HTTP log receiver. Log records with a test.case.name attribute are
+// stored like spans in ExportTraces, with the record body as output. Records without a session
+// attribute or trace ID share a new session per resource.
+func (h *IngressHandler) ExportLogs(c echo.Context) error {
+	var req collogspb.ExportLogsServiceRequest
+	contentType, err := readOTLPRequest(c, &req)
+	if err != nil {
+		return err
+	}
+
+	var testcases []otlpTestcase
+	for _, rl := range req.GetResourceLogs() {
+		resource := rl.GetResource().GetAttributes()
+		fallbackSessionID := uuid.NewString()
+		for _, sl := range rl.GetScopeLogs() {
+			for _, record := range sl.GetLogRecords() {
+				tc, ok, err := logTestcase(resource, record, fallbackSessionID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+				}
+				if ok {
+					testcases = append(testcases, tc)
+				}
+			}
+		}
+	}
+
+	if err := h.storeOTLPTestcases(c, testcases); err != nil {
+		return err
+	}
+	return writeOTLPResponse(c, contentType, &collogspb.ExportLogsServiceResponse{})
+}
+
+// storeOTLPTestcases stores testcases in their sessions, creating missing sessions first.
+func (h *IngressHandler) storeOTLPTestcases(c echo.Context, testcases []otlpTestcase) error {
+	userID := GetUserId(c)
+	ctx := c.Request().Context()
+	now := time.Now()
+
+	sessions := map[string]uuid.UUID{}
+	for _, tc := range testcases {
+		sessionID, ok := sessions[tc.sessionID]
+		if !ok {
+			var err error
+			sessionID, err = h.reportSession(ctx, c.Logger(), userID, SessionRequest{ID: &tc.sessionID, Labels: tc.labels})
+			if err != nil {
+				return err
+			}
+			sessions[tc.sessionID] = sessionID
+		}
+
+		tc.testcase.SessionID = sessionID.String()
+		if err := h.createTestcase(ctx, c.Logger(), userID, sessionID, tc.testcase, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func spanTestcase(resource []*commonpb.KeyValue, span *tracepb.Span, fallbackSessionID string) (otlpTestcase, bool, error) {
+	attrs := otlpAttributes(span.GetAttributes())
+	name, ok := attrs[otlpTestCaseName]
+	if !ok || name == "" {
+		return otlpTestcase{}, false, nil
+	}
+
+	status, err := otlpStatus(attrs[otlpTestCaseStatus], span.GetStatus().GetCode() == tracepb.Status_STATUS_CODE_ERROR)
+	if err != nil {
+		return otlpTestcase{}, false, err
+	}
+
+	tc := otlpTestcase{
+		sessionID: otlpSessionKey(attrs, resource, span.GetTraceId(), fallbackSessionID),
+		labels:    otlpLabels(resource),
+		testcase:  otlpTestcaseRequest(name, status, attrs),
+	}
+
+	for _, event := range span.GetEvents() {
+		if event.GetName() == "exception" {
+			setOTLPException(&tc.testcase, otlpAttributes(event.GetAttributes()))
+			break
+		}
+	}
+	if tc.testcase.FailureMessage == nil && status != "pass" && span.GetStatus().GetMessage() != "" {
+		message := span.GetStatus().GetMessage()
+		tc.testcase.FailureMessage = &message
+	}
+
+	baggage := otlpBaggage(span.GetTraceId(), span.GetSpanId())
+	if start, end := span.GetStartTimeUnixNano(), span.GetEndTimeUnixNano(); start > 0 && end >= start {
+		baggage["time"] = time.Duration(end - start).Seconds()
+	}
+	tc.testcase.Baggage = baggage
+
+	return tc, true, nil
+}
+
+func logTestcase(resource []*commonpb.KeyValue, record *logspb.LogRecord, fallbackSessionID string) (otlpTestcase, bool, error) {
+	attrs := otlpAttributes(record.GetAttributes())
+	name, ok := attrs[otlpTestCaseName]
+	if !ok || name == "" {
+		return otlpTestcase{}, false, nil
+	}
+
+	status, err := otlpStatus(attrs[otlpTestCaseStatus], record.GetSeverityNumber() >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR)
+	if err != nil {
+		return otlpTestcase{}, false, err
+	}
+
+	tc := otlpTestcase{
+		sessionID: otlpSessionKey(attrs, resource, record.GetTraceId(), fallbackSessionID),
+		labels:    otlpLabels(resource),
+		testcase:  otlpTestcaseRequest(name, status, attrs),
+	}
+	setOTLPException(&tc.testcase, attrs)
+
+	if body := otlpValueString(record.GetBody()); body != "" {
+		tc.testcase.Output = &body
+	}
+
+	baggage := otlpBaggage(record.GetTraceId(), record.GetSpanId())
+	if len(baggage) > 0 {
+		tc.testcase.Baggage = baggage
+	}
+
+	return tc, true, nil
+}
+
+func otlpTestcaseRequest(name, status string, attrs map[string]string) TestcaseRequest {
+	optional := func(keys ...string) *string {
+		for _, key := range keys {
+			if v := attrs[key]; v != "" {
+				return &v
+			}
+		}
+		return nil
+	}
+
+	return TestcaseRequest{
+		TestcaseName:      name,
+		TestcaseClassname: optional("code.namespace"),
+		TestcaseFile:      optional("code.file.path", "code.filepath"),
+		Testsuite:         optional(otlpTestSuiteName),
+		Status:            status,
+	}
+}
+
+// otlpStatus maps test.case.result.status (pass or fail; error and skip are accepted as well).
+// Without it, an errored span or record counts as error and anything else as pass.
+func otlpStatus(status string, errored bool) (string, error) {
+	switch status {
+	case "":
+		if errored {
+			return "error", nil
+		}
+		return "pass", nil
+	case "pass", "fail", "error", "skip":
+		return status, nil
+	case "skipped":
+		return "skip", nil
+	default:
+		return "", fmt.Errorf("invalid %s: %s", otlpTestCaseStatus, status)
+	}
+}
+
+func setOTLPException(tc *TestcaseRequest, attrs map[string]string) {
+	set := func(field **string, key string) {
+		if v := attrs[key]; v != "" {
+			*field = &v
+		}
+	}
+	set(&tc.FailureMessage, "exception.message")
+	set(&tc.FailureType, "exception.type")
+	set(&tc.StackTrace, "exception.stacktrace")
+}
+
+// otlpSessionKey returns the session ID of a testcase: the greener.session.id attribute of the
+// span or record, then of the resource, then the trace ID, then fallback.
+func otlpSessionKey(attrs map[string]string, resource []*commonpb.KeyValue, traceID []byte, fallback string) string {
+	if id := attrs[otlpSessionID]; id != "" {
+		return id
+	}
+	if id := otlpAttributes(resource)[otlpSessionID]; id != "" {
+		return id
+	}
+	if id, err := uuid.FromBytes(traceID); err == nil && id != uuid.Nil {
+		return id.String()
+	}
+	return fallback
+}
+
+// otlpLabels turns resource attributes (e.g. service.name) into session labels.
+func otlpLabels(resource []*commonpb.KeyValue) []LabelRequest {
+	var labels []LabelRequest
+	for _, kv := range resource {
+		if kv.GetKey() == otlpSessionID {
+			continue
+		}
+		value := otlpValueString(kv.GetValue())
+		labels = append(labels, LabelRequest{Key: kv.GetKey(), Value: &value})
+	}
+	return labels
+}
+
+func otlpBaggage(traceID, spanID []byte) map[string]any {
+	baggage := map[string]any{}
+	if len(traceID) > 0 {
+		baggage["traceId"] = hex.EncodeToString(traceID)
+	}
+	if len(spanID) > 0 {
+		baggage["spanId"] = hex.EncodeToString(spanID)
+	}
+	return baggage
+}
+
+func otlpAttributes(kvs []*commonpb.KeyValue) map[string]string {
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.GetKey()] = otlpValueString(kv.GetValue())
+	}
+	return attrs
+}
+
+// otlpValueString formats scalar values and arrays of scalars; other values are dropped.
+func otlpValueString(v *commonpb.AnyValue) string {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]string, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, otlpValueString(item))
+		}
+		return strings.Join(values, ",")
+	default:
+		return ""
+	}
+}
+
+// readOTLPRequest decodes a protobuf or JSON OTLP export, optionally gzip-compressed,
+// and returns its content type.
+func readOTLPRequest(c echo.Context, msg proto.Message) (string, error) {
+	req := c.Request()
+
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if contentType != otlpContentTypeProtobuf && contentType != otlpContentTypeJSON {
+		return "", echo.NewHTTPError(http.StatusUnsupportedMediaType, "Unsupported content type, expected "+otlpContentTypeProtobuf+" or "+otlpContentTypeJSON)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxOTLPRequestSize))
+	if err == nil {
+		body, err = decompressBody(body, req.Header.Get(echo.HeaderContentEncoding) == "gzip", maxOTLPRequestSize)
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) || errors.Is(err, errBodyTooLarge) {
+			return "", echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Export exceeds the size limit of %d bytes", maxOTLPRequestSize))
+		}
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if contentType == otlpContentTypeProtobuf {
+		err = proto.Unmarshal(body, msg)
+	} else if body, err = otlpJSONIDsToBase64(body); err == nil {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, msg)
+	}
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid OTLP export: "+err.Error())
+	}
+	return contentType, nil
+}
+
+func writeOTLPResponse(c echo.Context, contentType string, msg proto.Message) error {
+	var body []byte
+	var err error
+	if contentType == otlpContentTypeProtobuf {
+		body, err = proto.Marshal(msg)
+	} else {
+		body, err = protojson.Marshal(msg)
+	}
+	if err != nil {
+		c.Logger().Errorf("Failed to encode OTLP response: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to encode response")
+	}
+	return c.Blob(http.StatusOK, contentType, body)
+}
+
+// otlpJSONIDsToBase64 rewrites the hex-encoded trace and span IDs of OTLP/JSON
+// into the base64 encoding that protojson expects for bytes fields.
+func otlpJSONIDsToBase64(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				switch key {
+				case "traceId", "spanId", "parentSpanId":
+					if s, ok := value.(string); ok {
+						if id, err := hex.DecodeString(s); err == nil {
+							v[key] = base64.StdEncoding.EncodeToString(id)
+						}
+					}
+				default:
+					walk(value)
+				}
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+
+	return json.Marshal(v)
+}
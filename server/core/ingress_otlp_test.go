@@ -0,0 +1,221 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// exportOTLP calls the handler of the "traces" or "logs" OTLP endpoint.
+func (s *BaseSuite) exportOTLP(signal, contentType string, body []byte) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/otlp/v1/"+signal, bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", s.userID)
+
+	handler := core.NewIngressHandler(s.db, output.DefaultStorage())
+	export := handler.ExportTraces
+	if signal == "logs" {
+		export = handler.ExportLogs
+	}
+	return rec, export(c)
+}
+
+func otlpString(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func (s *BaseSuite) TestExportOTLPTraces() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	traceID := uuid.New()
+	s.purgeOnCleanup(model_db.BinaryUUID(traceID), retention.Stores{})
+	start := uint64(time.Now().UnixNano())
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{otlpString("service.name", "shop")}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Spans: []*tracepb.Span{
+					{
+						TraceId:           traceID[:],
+						SpanId:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+						Name:              "test_cart",
+						StartTimeUnixNano: start,
+						EndTimeUnixNano:   start + uint64(2*time.Second),
+						Attributes: []*commonpb.KeyValue{
+							otlpString("test.case.name", "test_cart"),
+							otlpString("test.suite.name", "shop"),
+							otlpString("code.namespace", "tests.cart"),
+							otlpString("test.case.result.status", "pass"),
+						},
+					},
+					{
+						TraceId:    traceID[:],
+						SpanId:     []byte{2, 2, 3, 4, 5, 6, 7, 8},
+						Name:       "test_checkout",
+						Attributes: []*commonpb.KeyValue{otlpString("test.case.name", "test_checkout")},
+						Status:     &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR},
+						Events: []*tracepb.Span_Event{{
+							Name: "exception",
+							Attributes: []*commonpb.KeyValue{
+								otlpString("exception.type", "AssertionError"),
+								otlpString("exception.message", "total mismatch"),
+								otlpString("exception.stacktrace", "trace"),
+							},
+						}},
+					},
+					{
+						TraceId: traceID[:],
+						SpanId:  []byte{3, 2, 3, 4, 5, 6, 7, 8},
+						Name:    "http GET /cart",
+					},
+				},
+			}},
+		}},
+	}
+	body, err := proto.Marshal(req)
+	s.Require().NoError(err)
+
+	// protobuf export creating a session from the trace ID
+	rec, err := s.exportOTLP("traces", "application/x-protobuf", body)
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("application/x-protobuf", rec.Header().Get(echo.HeaderContentType))
+
+	session, err := svc.GetSession(ctx, s.userID, traceID)
+	s.Require().NoError(err)
+	s.Equal("error", session.Status)
+	s.Equal(map[string]string{"service.name": "shop"}, session.Labels)
+	s.Len(mustQuery(s, svc, `session_id = "`+traceID.String()+`"`), 2)
+
+	passed := mustQuery(s, svc, `session_id = "`+traceID.String()+`" and name = "test_cart"`)
+	s.Require().Len(passed, 1)
+	detail, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(passed[0]))
+	s.Require().NoError(err)
+	s.Equal("pass", detail.Status)
+	s.Equal("shop", detail.Testsuite)
+	s.Equal("tests.cart", detail.Classname)
+	s.Equal(map[string]any{
+		"traceId": hex.EncodeToString(traceID[:]),
+		"spanId":  "0102030405060708",
+		"time":    2.0,
+	}, detail.Baggage)
+
+	failed := mustQuery(s, svc, `session_id = "`+traceID.String()+`" and name = "test_checkout"`)
+	s.Require().Len(failed, 1)
+	detail, err = svc.GetTestcase(ctx, s.userID, uuid.MustParse(failed[0]))
+	s.Require().NoError(err)
+	s.Equal("error", detail.Status)
+	s.Equal("total mismatch", detail.FailureMessage)
+	s.Equal("AssertionError", detail.FailureType)
+	s.Equal("trace", detail.StackTrace)
+
+	// JSON export with hex IDs attached to an existing session by attribute
+	existing := s.createScratchSession(map[string]string{"otlp": "test"})
+	sid := uuid.UUID(existing).String()
+	json := `{"resourceSpans": [{
+		"resource": {"attributes": [{"key": "greener.session.id", "value": {"stringValue": "` + sid + `"}}]},
+		"scopeSpans": [{"spans": [{
+			"traceId": "5b8efff798038103d269b633813fc60c",
+			"spanId": "eee19b7ec3c1b174",
+			"name": "test_json",
+			"attributes": [
+				{"key": "test.case.name", "value": {"stringValue": "test_json"}},
+				{"key": "test.case.result.status", "value": {"stringValue": "fail"}}
+			],
+			"status": {"code": 2, "message": "expected 1"}
+		}]}]
+	}]}`
+	rec, err = s.exportOTLP("traces", "application/json", []byte(json))
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, rec.Code)
+	s.JSONEq(`{}`, rec.Body.String())
+
+	failed = mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_json"`)
+	s.Require().Len(failed, 1)
+	detail, err = svc.GetTestcase(ctx, s.userID, uuid.MustParse(failed[0]))
+	s.Require().NoError(err)
+	s.Equal("fail", detail.Status)
+	s.Equal("expected 1", detail.FailureMessage)
+	s.Equal("5b8efff798038103d269b633813fc60c", detail.Baggage.(map[string]any)["traceId"])
+}
+
+func (s *BaseSuite) TestExportOTLPLogs() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	existing := s.createScratchSession(map[string]string{"otlp": "logs"})
+	sid := uuid.UUID(existing).String()
+
+	json := `{"resourceLogs": [{"scopeLogs": [{"logRecords": [
+		{
+			"severityNumber": 17,
+			"body": {"stringValue": "connection refused"},
+			"attributes": [
+				{"key": "greener.session.id", "value": {"stringValue": "` + sid + `"}},
+				{"key": "test.case.name", "value": {"stringValue": "test_db"}},
+				{"key": "exception.type", "value": {"stringValue": "ConnectionError"}}
+			]
+		},
+		{"body": {"stringValue": "not a test"}}
+	]}]}]}`
+	rec, err := s.exportOTLP("logs", "application/json", []byte(json))
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, rec.Code)
+
+	ids := mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_db"`)
+	s.Require().Len(ids, 1)
+	detail, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(ids[0]))
+	s.Require().NoError(err)
+	s.Equal("error", detail.Status)
+	s.Equal("ConnectionError", detail.FailureType)
+	s.Equal("connection refused", detail.Output)
+}
+
+func (s *BaseSuite) TestExportOTLPInvalid() {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+	}{
+		{"unsupported content type", "text/plain", "{}", http.StatusUnsupportedMediaType},
+		{"malformed json", "application/json", "{", http.StatusBadRequest},
+		{"malformed protobuf", "application/x-protobuf", "\xff", http.StatusBadRequest},
+		{"invalid status", "application/json", `{"resourceSpans": [{"scopeSpans": [{"spans": [{
+			"traceId": "5b8efff798038103d269b633813fc60c",
+			"attributes": [
+				{"key": "test.case.name", "value": {"stringValue": "t"}},
+				{"key": "test.case.result.status", "value": {"stringValue": "broken"}}
+			]
+		}]}]}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := s.exportOTLP("traces", tt.contentType, []byte(tt.body))
+			s.Require().Error(err)
+			var httpErr *echo.HTTPError
+			s.Require().ErrorAs(err, &httpErr)
+			s.Equal(tt.code, httpErr.Code)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+// ProjectLabel is the session label that assigns a session to a project. API keys restricted
+// to a project label the sessions they create with it, and can only report to sessions with it.
+const ProjectLabel = "project"
+
+// keyProject returns the project the API key of ctx is restricted to, if any.
+func keyProject(ctx context.Context) (string, bool) {
+	apiKey := APIKeyFromContext(ctx)
+	if apiKey == nil || apiKey.Project == nil {
+		return "", false
+	}
+	return *apiKey.Project, true
+}
+
+func projectForbidden(project string) error {
+	return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("API key is restricted to project %q", project))
+}
+
+// applyKeyProject adds the project label of the API key of ctx to a new session.
+// Errors are returned as *echo.HTTPError.
+func applyKeyProject(ctx context.Context, req *SessionRequest) error {
+	project, ok := keyProject(ctx)
+	if !ok {
+		return nil
+	}
+	for _, label := range req.Labels {
+		if label.Key == ProjectLabel {
+			if label.Value == nil || *label.Value != project {
+				return projectForbidden(project)
+			}
+			return nil
+		}
+	}
+	req.Labels = append(req.Labels, LabelRequest{Key: ProjectLabel, Value: &project})
+	return nil
+}
+
+// CheckSessionProject checks that a session has the project label of the API key of ctx.
+// Errors are returned as *echo.HTTPError.
+func CheckSessionProject(ctx context.Context, db bun.IDB, logger echo.Logger, sessionID model_db.BinaryUUID) error {
+	project, ok := keyProject(ctx)
+	if !ok {
+		return nil
+	}
+	exists, err := db.NewSelect().
+		Model((*model_db.Label)(nil)).
+		Where("? = ?", bun.Ident("session_id"), sessionID).
+		Where("? = ?", bun.Ident("key"), ProjectLabel).
+		Where("? = ?", bun.Ident("value"), project).
+		Exists(ctx)
+	if err != nil {
+		logger.Errorf("Failed to check session project: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify session")
+	}
+	if !exists {
+		return projectForbidden(project)
+	}
+	return nil
+}
+
+// checkPatchProject rejects patches that would move a session out of the project of the API key of ctx.
+func checkPatchProject(ctx context.Context, patch SessionPatch) error {
+	project, ok := keyProject(ctx)
+	if !ok {
+		return nil
+	}
+	for _, label := range patch.SetLabels {
+		if label.Key == ProjectLabel && (label.Value == nil || *label.Value != project) {
+			return projectForbidden(project)
+		}
+	}
+	for _, key := range patch.RemoveLabels {
+		if key == ProjectLabel {
+			return projectForbidden(project)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/queue"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	msgSessionExists  = "Session with this ID already exists"
+	msgTestcaseExists = "Testcase with this ID already exists"
+)
+
+// queuedRequest is the journaled form of a session or of the testcases of one session.
+type queuedRequest struct {
+	UserID     uuid.UUID `json:"userId"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	// APIKeyID is the API key of the request, which becomes the creator of a queued session
+	APIKeyID *uuid.UUID `json:"apiKeyId,omitempty"`
+	// Project is the project the API key of the request is restricted to
+	Project   *string           `json:"project,omitempty"`
+	Session   *SessionRequest   `json:"session,omitempty"`
+	Testcases []TestcaseRequest `json:"testcases,omitempty"`
+}
+
+// OpenQueue opens a durable queue and makes the handler journal sessions and JSON testcase
+// batches instead of storing them during the request. Their requests are then answered with 202
+// once journaled, and the queue workers store them, retrying while the database is unavailable.
+// Entries are keyed by session ID, so the requests of a session are stored in order, and other
+// requests of a session wait for them with AwaitSession.
+func (h *IngressHandler) OpenQueue(opts queue.Options, logger echo.Logger) (*queue.Queue, error) {
+	q, err := queue.Open(opts, func(ctx context.Context, entries []queue.Entry) error {
+		return h.applyQueued(ctx, logger, entries)
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.queue = q
+	return q, nil
+}
+
+// queueWaitTimeout bounds how long requests wait for the queued requests of their session.
+const queueWaitTimeout = 30 * time.Second
+
+// AwaitSession waits until the queued requests of a session are stored, so that requests
+// that are not queued, such as session updates, NDJSON streams, reports and attachments,
+// find the session and its testcases. If the queue does not catch up in time, the request
+// goes ahead with what is stored so far.
+func (h *IngressHandler) AwaitSession(ctx context.Context, sessionID uuid.UUID) {
+	if h.queue == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, queueWaitTimeout)
+	defer cancel()
+	h.queue.Wait(ctx, sessionID.String())
+}
+
+// requestSession is testcaseSession for requests that are not queued, which wait for the
+// queued requests of the session first.
+func (h *IngressHandler) requestSession(ctx context.Context, logger echo.Logger, userID model_db.BinaryUUID, tc TestcaseRequest) (uuid.UUID, error) {
+	if sessionID, err := parseSessionID(tc); err == nil {
+		h.AwaitSession(ctx, sessionID)
+	}
+	return h.testcaseSession(ctx, logger, userID, tc)
+}
+
+// enqueueSession validates and journals a session request. Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) enqueueSession(ctx context.Context, logger echo.Logger, userID model_db.BinaryUUID, req SessionRequest) (uuid.UUID, error) {
+	if err := applyKeyProject(ctx, &req); err != nil {
+		return uuid.Nil, err
+	}
+	sessionID, _, err := validateSession(req)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id := sessionID.String()
+	req.ID = &id
+	queued := newQueuedRequest(ctx, userID, time.Now())
+	queued.Session = &req
+	if err := h.enqueue(sessionID, queued); err != nil {
+		logger.Errorf("Failed to queue session: %v", err)
+		return uuid.Nil, echo.NewHTTPError(http.StatusServiceUnavailable, "Failed to queue session")
+	}
+	return sessionID, nil
+}
+
+// enqueueTestcases journals validated testcases, one entry per session; testcases with
+// a zero session ID are skipped. Testcases get their IDs here, so that storing an entry again
+// after a failure does not store its testcases twice. Errors are returned as *echo.HTTPError.
+func (h *IngressHandler) enqueueTestcases(ctx context.Context, logger echo.Logger, userID model_db.BinaryUUID, testcases []TestcaseRequest, sessionIDs []uuid.UUID, now time.Time) error {
+	var order []uuid.UUID
+	bySession := make(map[uuid.UUID][]TestcaseRequest)
+	for i, tc := range testcases {
+		sessionID := sessionIDs[i]
+		if sessionID == uuid.Nil {
+			continue
+		}
+		if tc.ID == nil || *tc.ID == "" {
+			id := uuid.NewString()
+			tc.ID = &id
+		}
+		if _, ok := bySession[sessionID]; !ok {
+			order = append(order, sessionID)
+		}
+		bySession[sessionID] = append(bySession[sessionID], tc)
+	}
+
+	for _, sessionID := range order {
+		req := newQueuedRequest(ctx, userID, now)
+		req.Testcases = bySession[sessionID]
+		if err := h.enqueue(sessionID, req); err != nil {
+			logger.Errorf("Failed to queue testcases: %v", err)
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "Failed to queue testcases")
+		}
+	}
+	return nil
+}
+
+// newQueuedRequest returns a queue entry of userID carrying the API key of ctx.
+func newQueuedRequest(ctx context.Context, userID model_db.BinaryUUID, receivedAt time.Time) queuedRequest {
+	req := queuedRequest{UserID: uuid.UUID(userID), ReceivedAt: receivedAt}
+	if apiKey := APIKeyFromContext(ctx); apiKey != nil {
+		req.Project = apiKey.Project
+		if apiKey.ID != model_db.BinaryUUID(uuid.Nil) {
+			apiKeyID := uuid.UUID(apiKey.ID)
+			req.APIKeyID = &apiKeyID
+		}
+	}
+	return req
+}
+
+func (h *IngressHandler) enqueue(sessionID uuid.UUID, req queuedRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return h.queue.Enqueue(sessionID.String(), data)
+}
+
+// applyQueued stores queued requests. Requests the database rejects are logged and dropped,
+// since their clients cannot be told anymore; other errors fail the batch to have it retried.
+func (h *IngressHandler) applyQueued(ctx context.Context, logger echo.Logger, entries []queue.Entry) error {
+	for _, entry := range entries {
+		var req queuedRequest
+		if err := json.Unmarshal(entry.Data, &req); err != nil {
+			logger.Errorf("Dropping invalid queue entry %d: %v", entry.Seq, err)
+			continue
+		}
+		userID := model_db.BinaryUUID(req.UserID)
+		ctx := ctx
+		if req.APIKeyID != nil || req.Project != nil {
+			// the project restriction of the API key applies to its queued requests too
+			apiKey := &model_db.APIKey{UserID: userID, Project: req.Project}
+			if req.APIKeyID != nil {
+				apiKey.ID = model_db.BinaryUUID(*req.APIKeyID)
+			}
+			ctx = WithAPIKey(ctx, apiKey)
+		}
+
+		if req.Session != nil {
+			if _, err := h.createSession(ctx, logger, userID, *req.Session); err != nil {
+				if !rejectable(err) {
+					return err
+				}
+				// a session that exists was stored by an earlier attempt
+				if errorMessage(err) != msgSessionExists {
+					logger.Warnf("Dropping queued session %s: %s", entry.Key, errorMessage(err))
+				}
+			}
+			continue
+		}
+
+		if len(req.Testcases) == 0 {
+			continue
+		}
+		sessionID, err := h.testcaseSession(ctx, logger, userID, req.Testcases[0])
+		if err != nil {
+			if !rejectable(err) {
+				return err
+			}
+			logger.Warnf("Dropping %d queued testcases of session %s: %s", len(req.Testcases), entry.Key, errorMessage(err))
+			continue
+		}
+		for _, tc := range req.Testcases {
+			if err := h.createTestcase(ctx, logger, userID, sessionID, tc, req.ReceivedAt); err != nil {
+				if !rejectable(err) {
+					return err
+				}
+				if errorMessage(err) != msgTestcaseExists {
+					logger.Warnf("Dropping queued testcase %q of session %s: %s", tc.TestcaseName, entry.Key, errorMessage(err))
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/queue"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+func (s *BaseSuite) TestIngressQueue() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+	dir := s.T().TempDir()
+
+	handler := core.NewIngressHandler(s.db, output.DefaultStorage())
+	q, err := handler.OpenQueue(queue.Options{Dir: dir, Workers: 2}, echo.New().Logger)
+	s.Require().NoError(err)
+
+	send := func(handle echo.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.Set("user_id", s.userID)
+		s.Require().NoError(handle(c))
+		return rec
+	}
+
+	rec := send(handler.CreateSession, "/api/v1/ingress/sessions", `{"labels": [{"key": "queue", "value": "test"}]}`)
+	s.Equal(http.StatusAccepted, rec.Code)
+	var session core.SessionResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &session))
+	sid := session.ID
+	s.purgeOnCleanup(model_db.BinaryUUID(uuid.MustParse(sid)), retention.Stores{})
+
+	// the session may not be stored yet, its testcases are queued after it
+	rec = send(handler.CreateTestcases, "/api/v1/ingress/testcases", `{"testcases": [
+		{"sessionId": "`+sid+`", "testcaseName": "test_queued", "status": "pass"},
+		{"sessionId": "`+sid+`", "testcaseName": "test_queued_fail", "status": "fail"}
+	]}`)
+	s.Equal(http.StatusAccepted, rec.Code)
+
+	// invalid testcases are still rejected during the request
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingress/testcases", strings.NewReader(`{"testcases": [{"sessionId": "`+sid+`", "testcaseName": "test_invalid", "status": "broken"}]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	c.Set("user_id", s.userID)
+	err = handler.CreateTestcases(c)
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusBadRequest, httpErr.Code)
+
+	// testcases of sessions of other users are dropped by the workers
+	send(handler.CreateTestcases, "/api/v1/ingress/testcases", `{"testcases": [{"sessionId": "`+uuid.NewString()+`", "testcaseName": "test_unknown", "status": "pass"}]}`)
+
+	s.Require().NoError(q.Drain(ctx))
+	s.Equal(0, q.Depth())
+
+	stored, err := svc.GetSession(ctx, s.userID, uuid.MustParse(sid))
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"queue": "test"}, stored.Labels)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_queued"`), 1)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_queued_fail"`), 1)
+	s.Empty(mustQuery(s, svc, `name = "test_unknown"`))
+}
+
+func (s *BaseSuite) TestIngressQueueReplay() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+	dir := s.T().TempDir()
+
+	sid := uuid.NewString()
+	s.purgeOnCleanup(model_db.BinaryUUID(uuid.MustParse(sid)), retention.Stores{})
+
+	// every entry twice, as left by a server that stopped after storing it but before removing it
+	request := `{"userId": "` + uuid.UUID(s.userID).String() + `", "receivedAt": "2026-10-18T12:00:00Z", `
+	sessionEntry := sid + "\n" + request + `"session": {"id": "` + sid + `", "labels": [{"key": "queue", "value": "replay"}]}}`
+	testcaseEntry := sid + "\n" + request + `"testcases": [
+		{"id": "` + uuid.NewString() + `", "sessionId": "` + sid + `", "testcaseName": "test_replayed", "status": "pass"}
+	]}`
+	for i, entry := range []string{sessionEntry, sessionEntry, testcaseEntry, testcaseEntry} {
+		name := fmt.Sprintf("%020d.entry", i+1)
+		s.Require().NoError(os.WriteFile(filepath.Join(dir, name), []byte(entry), 0o644))
+	}
+
+	handler := core.NewIngressHandler(s.db, output.DefaultStorage())
+	q, err := handler.OpenQueue(queue.Options{Dir: dir}, echo.New().Logger)
+	s.Require().NoError(err)
+	s.Require().NoError(q.Drain(ctx))
+	s.Equal(0, q.Depth())
+
+	stored, err := svc.GetSession(ctx, s.userID, uuid.MustParse(sid))
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"queue": "replay"}, stored.Labels)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_replayed"`), 1)
+}
+
+// failOnceHook cancels the first query starting with prefix after skipping skip of them,
+// making it fail.
+type failOnceHook struct {
+	prefix string
+	skip   int32
+	seen   atomic.Int32
+	failed atomic.Bool
+}
+
+func (h *failOnceHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if strings.HasPrefix(event.Query, h.prefix) && h.seen.Add(1) > h.skip && h.failed.CompareAndSwap(false, true) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		return ctx
+	}
+	return ctx
+}
+
+func (h *failOnceHook) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+func (s *BaseSuite) TestIngressQueueRetriesSessionLabels() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	// the labels of the first attempt to store the session fail
+	hook := &failOnceHook{prefix: "INSERT INTO " + string(s.db.Dialect().IdentQuote()) + "labels"}
+	db := bun.NewDB(s.db.DB, s.db.Dialect())
+	db.AddQueryHook(hook)
+
+	handler := core.NewIngressHandler(db, output.DefaultStorage())
+	q, err := handler.OpenQueue(queue.Options{Dir: s.T().TempDir(), MinRetryInterval: 10 * time.Millisecond}, echo.New().Logger)
+	s.Require().NoError(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingress/sessions", strings.NewReader(`{"labels": [{"key": "queue", "value": "retried"}]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user_id", s.userID)
+	s.Require().NoError(handler.CreateSession(c))
+	s.Equal(http.StatusAccepted, rec.Code)
+	var session core.SessionResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &session))
+	sessionID := uuid.MustParse(session.ID)
+	defer func() {
+		_, err := s.db.NewDelete().Model((*model_db.Label)(nil)).Where("session_id = ?", model_db.BinaryUUID(sessionID)).Exec(ctx)
+		s.Require().NoError(err)
+		_, err = s.db.NewDelete().Model((*model_db.Session)(nil)).Where("id = ?", model_db.BinaryUUID(sessionID)).Exec(ctx)
+		s.Require().NoError(err)
+	}()
+
+	s.Require().NoError(q.Drain(ctx))
+	s.True(hook.failed.Load())
+
+	stored, err := svc.GetSession(ctx, s.userID, sessionID)
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"queue": "retried"}, stored.Labels)
+}
+
+func (s *BaseSuite) TestIngressQueueFollowUpRequests() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	// the session is stored on the second attempt, after the follow-up requests are sent
+	hook := &failOnceHook{prefix: "INSERT INTO " + string(s.db.Dialect().IdentQuote()) + "sessions"}
+	db := bun.NewDB(s.db.DB, s.db.Dialect())
+	db.AddQueryHook(hook)
+
+	handler := core.NewIngressHandler(db, output.DefaultStorage())
+	q, err := handler.OpenQueue(queue.Options{Dir: s.T().TempDir(), MinRetryInterval: 50 * time.Millisecond}, echo.New().Logger)
+	s.Require().NoError(err)
+	apiKeyID, _ := s.createAPIKey(ctx, "queue", nil)
+	apiKey := &model_db.APIKey{ID: apiKeyID, UserID: s.userID, Scope: model_db.ScopeIngest}
+
+	send := func(handle echo.HandlerFunc, method, path, contentType, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req.WithContext(core.WithAPIKey(ctx, apiKey)), rec)
+		c.SetParamNames("id")
+		c.SetParamValues(path[strings.LastIndex(path, "/")+1:])
+		c.Set("user_id", s.userID)
+		c.Set("apikey", apiKey)
+		s.Require().NoError(handle(c))
+		return rec.Code
+	}
+
+	sid := uuid.NewString()
+	s.Equal(http.StatusAccepted, send(handler.CreateSession, http.MethodPost, "/api/v1/ingress/sessions", echo.MIMEApplicationJSON, `{"id": "`+sid+`"}`))
+	defer func() {
+		_, err := s.db.NewDelete().Model((*model_db.Testcase)(nil)).Where("session_id = ?", model_db.BinaryUUID(uuid.MustParse(sid))).Exec(ctx)
+		s.Require().NoError(err)
+		_, err = s.db.NewDelete().Model((*model_db.Label)(nil)).Where("session_id = ?", model_db.BinaryUUID(uuid.MustParse(sid))).Exec(ctx)
+		s.Require().NoError(err)
+		_, err = s.db.NewDelete().Model((*model_db.Session)(nil)).Where("id = ?", model_db.BinaryUUID(uuid.MustParse(sid))).Exec(ctx)
+		s.Require().NoError(err)
+	}()
+
+	// requests that are not queued wait for the queued session
+	s.Equal(http.StatusCreated, send(handler.CreateTestcases, http.MethodPost, "/api/v1/ingress/testcases", core.MIMEApplicationNDJSON,
+		`{"sessionId": "`+sid+`", "testcaseName": "test_streamed", "status": "pass"}`))
+	s.True(hook.failed.Load())
+	s.Equal(http.StatusNoContent, send(handler.PatchSession, http.MethodPatch, "/api/v1/ingress/sessions/"+sid, echo.MIMEApplicationJSON,
+		`{"labels": [{"key": "followed", "value": "up"}]}`))
+
+	s.Require().NoError(q.Drain(ctx))
+	stored, err := svc.GetSession(ctx, s.userID, uuid.MustParse(sid))
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"followed": "up"}, stored.Labels)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_streamed"`), 1)
+}
@@ -0,0 +1,293 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/report"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+// maxReportUploadSize caps both the request body and each decompressed report.
+const maxReportUploadSize = 64 << 20
+
+var errBodyTooLarge = errors.New("decompressed request body is too large")
+
+type ReportUploadResponse struct {
+	SessionID string `json:"sessionId"`
+	Testcases int    `json:"testcases"`
+}
+
+// UploadJUnit stores the testcases of one or more JUnit XML reports, see UploadReport.
+func (h *IngressHandler) UploadJUnit(c echo.Context) error {
+	return h.uploadReports(c, "junit")
+}
+
+// UploadReport stores the testcases of one or more test reports in a format of the report
+// package. The format is taken from the "format" query parameter or the X-Greener-Report-Format
+// header and detected from the content if neither is given.
+// The body is either a single report or a multipart form with a report in each file part;
+// reports may be gzip-compressed. The session is selected with the "sessionId" query parameter
+// or the X-Greener-Session-Id header: an existing session is extended, otherwise a session is
+// created with the description, labels and baggage given in the query or headers.
+func (h *IngressHandler) UploadReport(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = c.Request().Header.Get("X-Greener-Report-Format")
+	}
+	if _, ok := report.Lookup(format); format != "" && !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"Unknown report format %q, expected one of: %s", format, strings.Join(report.Formats(), ", ")))
+	}
+	return h.uploadReports(c, format)
+}
+
+func (h *IngressHandler) uploadReports(c echo.Context, format string) error {
+	userID := GetUserId(c)
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxReportUploadSize)
+
+	documents, err := readReports(c)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) || errors.Is(err, errBodyTooLarge) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Report exceeds the size limit of %d bytes", maxReportUploadSize))
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if len(documents) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "No reports in request")
+	}
+
+	var results []report.Result
+	for _, doc := range documents {
+		parsed, err := report.Parse(format, doc)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid report: "+err.Error())
+		}
+		results = append(results, parsed...)
+	}
+
+	sessionReq, err := reportSessionRequest(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	sessionID, err := h.reportSession(ctx, c.Logger(), userID, sessionReq)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, result := range results {
+		if err := h.createTestcase(ctx, c.Logger(), userID, sessionID, reportTestcaseRequest(sessionID, result), now); err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusCreated, ReportUploadResponse{
+		SessionID: sessionID.String(),
+		Testcases: len(results),
+	})
+}
+
+// readReports returns the decompressed reports of a raw or multipart request body.
+func readReports(c echo.Context) ([][]byte, error) {
+	req := c.Request()
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEMultipartForm {
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, nil
+		}
+		doc, err := decompressBody(data, req.Header.Get(echo.HeaderContentEncoding) == "gzip", maxReportUploadSize)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{doc}, nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	defer form.RemoveAll()
+
+	var documents [][]byte
+	for _, field := range slices.Sorted(maps.Keys(form.File)) {
+		for _, header := range form.File[field] {
+			f, err := header.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			doc, err := decompressBody(data, false, maxReportUploadSize)
+			if err != nil {
+				return nil, err
+			}
+			documents = append(documents, doc)
+		}
+	}
+	return documents, nil
+}
+
+// decompressBody gunzips data if it is marked as gzip or starts with the gzip magic number.
+// The decompressed data may not exceed limit bytes.
+func decompressBody(data []byte, gzipped bool, limit int64) ([]byte, error) {
+	if !gzipped && !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return data, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	doc, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(doc)) > limit {
+		return nil, errBodyTooLarge
+	}
+	return doc, nil
+}
+
+// reportSessionRequest reads the session parameters of a report upload.
+// Each parameter is taken from the query string, falling back to its X-Greener-Session-* header.
+func reportSessionRequest(c echo.Context) (SessionRequest, error) {
+	param := func(name, header string) *string {
+		if v := c.QueryParam(name); v != "" {
+			return &v
+		}
+		if v := c.Request().Header.Get(header); v != "" {
+			return &v
+		}
+		return nil
+	}
+
+	req := SessionRequest{
+		ID:          param("sessionId", "X-Greener-Session-Id"),
+		Description: param("description", "X-Greener-Session-Description"),
+	}
+
+	labels := c.QueryParams()["label"]
+	if header := c.Request().Header.Get("X-Greener-Session-Labels"); header != "" {
+		labels = append(labels, strings.Split(header, ",")...)
+	}
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		if key, value, ok := strings.Cut(label, "="); ok {
+			req.Labels = append(req.Labels, LabelRequest{Key: key, Value: &value})
+		} else {
+			req.Labels = append(req.Labels, LabelRequest{Key: label})
+		}
+	}
+
+	if baggage := param("baggage", "X-Greener-Session-Baggage"); baggage != nil {
+		if err := json.Unmarshal([]byte(*baggage), &req.Baggage); err != nil {
+			return SessionRequest{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid baggage format")
+		}
+	}
+
+	return req, nil
+}
+
+// reportSession returns the session to attach the uploaded testcases to,
+// creating it unless req names an existing session of the user.
+func (h *IngressHandler) reportSession(ctx context.Context, logger echo.Logger, userID model_db.BinaryUUID, req SessionRequest) (uuid.UUID, error) {
+	if req.ID == nil {
+		return h.createSession(ctx, logger, userID, req)
+	}
+
+	sessionID, err := uuid.Parse(*req.ID)
+	if err != nil {
+		return uuid.Nil, echo.NewHTTPError(http.StatusBadRequest, "Cannot parse session ID")
+	}
+	h.AwaitSession(ctx, sessionID)
+
+	var session model_db.Session
+	err = h.db.NewSelect().
+		Model(&session).
+		Column("id", "user_id").
+		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(sessionID)).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return h.createSession(ctx, logger, userID, req)
+	}
+	if err != nil {
+		logger.Errorf("Failed to find session: %v", err)
+		return uuid.Nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify session")
+	}
+	if session.UserID != userID {
+		return uuid.Nil, echo.NewHTTPError(http.StatusBadRequest, "Session not found")
+	}
+	if err := CheckSessionProject(ctx, h.db, logger, session.ID); err != nil {
+		return uuid.Nil, err
+	}
+	return sessionID, nil
+}
+
+// reportTestcaseRequest maps a report result to a testcase; its time and properties go to the baggage.
+func reportTestcaseRequest(sessionID uuid.UUID, result report.Result) TestcaseRequest {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+
+	req := TestcaseRequest{
+		SessionID:         sessionID.String(),
+		TestcaseName:      result.Name,
+		TestcaseClassname: optional(result.Classname),
+		TestcaseFile:      optional(result.File),
+		Testsuite:         optional(result.Testsuite),
+		Status:            result.Status,
+		Output:            optional(result.Output),
+		FailureMessage:    optional(result.FailureMessage),
+		FailureType:       optional(result.FailureType),
+		StackTrace:        optional(result.StackTrace),
+		Stdout:            optional(result.Stdout),
+		Stderr:            optional(result.Stderr),
+	}
+
+	if result.Time != nil || len(result.Properties) > 0 {
+		req.Baggage = map[string]any{}
+		if result.Time != nil {
+			req.Baggage["time"] = *result.Time
+		}
+		if len(result.Properties) > 0 {
+			req.Baggage["properties"] = result.Properties
+		}
+	}
+	return req
+}
@@ -0,0 +1,200 @@
+package core_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const junitReport = `<testsuites>
+  <testsuite name="math">
+    <properties><property name="python" value="3.12"/></properties>
+    <testcase name="test_add" classname="tests.test_math" time="0.5"/>
+    <testcase name="test_div" classname="tests.test_math">
+      <failure message="division by zero" type="ZeroDivisionError">trace</failure>
+    </testcase>
+  </testsuite>
+</testsuites>`
+
+// uploadReport calls the handler of the "junit" or "reports" ingress endpoint.
+func (s *BaseSuite) uploadReport(endpoint string, query url.Values, header http.Header, body io.Reader) (core.ReportUploadResponse, error) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingress/"+endpoint+"?"+query.Encode(), body)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", s.userID)
+
+	handler := core.NewIngressHandler(s.db, output.DefaultStorage())
+	upload := handler.UploadReport
+	if endpoint == "junit" {
+		upload = handler.UploadJUnit
+	}
+
+	var resp core.ReportUploadResponse
+	if err := upload(c); err != nil {
+		return resp, err
+	}
+	s.Require().Equal(http.StatusCreated, rec.Code)
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp, nil
+}
+
+func (s *BaseSuite) TestUploadJUnit() {
+	ctx := context.Background()
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"junit": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	// raw body attached to an existing session
+	resp, err := s.uploadReport("junit", url.Values{"sessionId": {sid}}, http.Header{
+		echo.HeaderContentType: {echo.MIMEApplicationXML},
+	}, strings.NewReader(junitReport))
+	s.Require().NoError(err)
+	s.Equal(sid, resp.SessionID)
+	s.Equal(2, resp.Testcases)
+
+	failed := mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_div"`)
+	s.Require().Len(failed, 1)
+	detail, err := svc.GetTestcase(ctx, s.userID, uuid.MustParse(failed[0]))
+	s.Require().NoError(err)
+	s.Equal("fail", detail.Status)
+	s.Equal("tests.test_math", detail.Classname)
+	s.Equal("math", detail.Testsuite)
+	s.Equal("division by zero", detail.FailureMessage)
+	s.Equal("ZeroDivisionError", detail.FailureType)
+
+	var passed model_db.Testcase
+	s.Require().NoError(s.db.NewSelect().Model(&passed).
+		Where("session_id = ?", sessionID).
+		Where("name = ?", "test_add").
+		Scan(ctx))
+	var baggage map[string]any
+	s.Require().NoError(json.Unmarshal(passed.Baggage, &baggage))
+	s.Equal(map[string]any{"time": 0.5, "properties": map[string]any{"python": "3.12"}}, baggage)
+
+	// gzip body creating a new session with labels from the query and headers
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err = w.Write([]byte(junitReport))
+	s.Require().NoError(err)
+	s.Require().NoError(w.Close())
+
+	newID := uuid.New()
+	s.purgeOnCleanup(model_db.BinaryUUID(newID), retention.Stores{})
+	resp, err = s.uploadReport("junit", url.Values{"label": {"ci"}, "description": {"nightly"}}, http.Header{
+		echo.HeaderContentEncoding: {"gzip"},
+		"X-Greener-Session-Id":     {newID.String()},
+		"X-Greener-Session-Labels": {"branch=main, junit=upload"},
+	}, &gz)
+	s.Require().NoError(err)
+	s.Equal(newID.String(), resp.SessionID)
+
+	session, err := svc.GetSession(ctx, s.userID, newID)
+	s.Require().NoError(err)
+	s.Equal("fail", session.Status)
+	s.Equal("nightly", session.Description)
+	s.Len(session.Labels, 3)
+	s.Len(mustQuery(s, svc, `session_id = "`+newID.String()+`"`), 2)
+
+	// multipart body with several reports
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, name := range []string{"a.xml", "b.xml"} {
+		part, err := mw.CreateFormFile("file", name)
+		s.Require().NoError(err)
+		_, err = part.Write([]byte(`<testsuite name="` + name + `"><testcase name="test_` + name + `"/></testsuite>`))
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(mw.Close())
+
+	resp, err = s.uploadReport("junit", url.Values{"sessionId": {sid}}, http.Header{
+		echo.HeaderContentType: {mw.FormDataContentType()},
+	}, &body)
+	s.Require().NoError(err)
+	s.Equal(2, resp.Testcases)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and testsuite = "b.xml"`), 1)
+}
+
+func (s *BaseSuite) TestUploadJUnitInvalid() {
+	tests := []struct {
+		name  string
+		query url.Values
+		body  string
+	}{
+		{"empty body", nil, ""},
+		{"malformed xml", nil, "<testsuites>"},
+		{"not junit", nil, "<html/>"},
+		{"malformed session id", url.Values{"sessionId": {"nope"}}, junitReport},
+		{"invalid baggage", url.Values{"baggage": {"[1]"}}, junitReport},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := s.uploadReport("junit", tt.query, nil, strings.NewReader(tt.body))
+			s.Require().Error(err)
+			var httpErr *echo.HTTPError
+			s.Require().ErrorAs(err, &httpErr)
+			s.Equal(http.StatusBadRequest, httpErr.Code)
+		})
+	}
+}
+
+func (s *BaseSuite) TestUploadReport() {
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"report": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	// detected format
+	tap := "TAP version 14\n1..2\nok 1 - tap_pass\nnot ok 2 - tap_fail\n"
+	resp, err := s.uploadReport("reports", url.Values{"sessionId": {sid}}, nil, strings.NewReader(tap))
+	s.Require().NoError(err)
+	s.Equal(2, resp.Testcases)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "tap_fail" and status = "fail"`), 1)
+
+	// explicit format
+	ctrf := `{"results": {"tests": [{"name": "ctrf_skip", "status": "skipped"}]}}`
+	resp, err = s.uploadReport("reports", url.Values{"sessionId": {sid}}, http.Header{
+		"X-Greener-Report-Format": {"ctrf"},
+	}, strings.NewReader(ctrf))
+	s.Require().NoError(err)
+	s.Equal(1, resp.Testcases)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "ctrf_skip" and status = "skip"`), 1)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		body  string
+	}{
+		{"unknown format", url.Values{"format": {"allure"}}, tap},
+		{"undetected format", nil, "plain text"},
+		{"format mismatch", url.Values{"format": {"trx"}}, tap},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := s.uploadReport("reports", tt.query, nil, strings.NewReader(tt.body))
+			s.Require().Error(err)
+			var httpErr *echo.HTTPError
+			s.Require().ErrorAs(err, &httpErr)
+			s.Equal(http.StatusBadRequest, httpErr.Code)
+		})
+	}
+}
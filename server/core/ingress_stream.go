@@ -0,0 +1,247 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+)
+
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+const (
+	// maxNDJSONLineSize caps a single testcase line of an NDJSON stream.
+	maxNDJSONLineSize = 16 << 20
+	// maxNDJSONLineErrors caps the errors listed in an NDJSON response; further errors are only counted.
+	maxNDJSONLineErrors = 100
+	// maxZstdWindowSize bounds the decoder memory a zstd-compressed request can claim.
+	maxZstdWindowSize = 8 << 20
+	// DefaultMaxDecompressedSize caps the decompressed body of a request by default.
+	DefaultMaxDecompressedSize = 256 << 20
+)
+
+var errDecompressedTooLarge = errors.New("decompressed request body too large")
+
+// NDJSONLineError describes a rejected line of an NDJSON stream, like TestcaseError does for a JSON request.
+type NDJSONLineError struct {
+	Line   int    `json:"line"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type NDJSONResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Errors   []NDJSONLineError `json:"errors,omitempty"`
+}
+
+// DecompressRequest decodes request bodies with a gzip or zstd Content-Encoding as they are read,
+// so handlers see the uncompressed body. Other encodings are rejected with 415, and bodies
+// decompressing to more than maxSize bytes (DefaultMaxDecompressedSize if not positive) with 413,
+// so that a small compressed request cannot make handlers read an unbounded body.
+func DecompressRequest(maxSize int64) echo.MiddlewareFunc {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			var body io.ReadCloser
+			switch encoding := strings.ToLower(strings.TrimSpace(req.Header.Get(echo.HeaderContentEncoding))); encoding {
+			case "", output.EncodingIdentity:
+				return next(c)
+			case output.EncodingGzip:
+				r, err := gzip.NewReader(req.Body)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, "Invalid gzip body")
+				}
+				body = r
+			case output.EncodingZstd:
+				r, err := zstd.NewReader(req.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindowSize))
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, "Invalid zstd body")
+				}
+				body = r.IOReadCloser()
+			default:
+				return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Unsupported content encoding "+encoding)
+			}
+			defer body.Close()
+
+			limited := &limitedBody{ReadCloser: body, remaining: maxSize}
+			req.Body = limited
+			req.ContentLength = -1
+			req.Header.Del(echo.HeaderContentEncoding)
+			req.Header.Del(echo.HeaderContentLength)
+			err := next(c)
+			if limited.exceeded && !c.Response().Committed {
+				// handlers report read errors as invalid bodies
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Decompressed request body exceeds %d bytes", maxSize))
+			}
+			return err
+		}
+	}
+}
+
+// limitedBody fails reads beyond remaining bytes with errDecompressedTooLarge.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errDecompressedTooLarge
+	}
+	// read a byte more than remaining to tell a body of exactly the limit from a longer one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.exceeded = true
+		return n, errDecompressedTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// createTestcasesNDJSON stores a stream of testcases, one JSON object per line, as the lines
+// are read. Invalid lines are reported in the response and do not stop the stream;
+// internal errors do. The response is 201 if every testcase was stored and 200 otherwise.
+func (h *IngressHandler) createTestcasesNDJSON(c echo.Context) error {
+	userID := GetUserId(c)
+	ctx := c.Request().Context()
+	r := bufio.NewReader(c.Request().Body)
+
+	var resp NDJSONResponse
+	reject := func(line int, err error) {
+		resp.Rejected++
+		if len(resp.Errors) < maxNDJSONLineErrors {
+			tcErr := testcaseError(0, err)
+			resp.Errors = append(resp.Errors, NDJSONLineError{Line: line, Field: tcErr.Field, Reason: tcErr.Reason})
+		}
+	}
+
+	// the session of the previous line, which is usually the session of the next one
+	var lastSessionKey string
+	var lastSessionID uuid.UUID
+
+	for line := 1; ; line++ {
+		data, err := readNDJSONLine(r, maxNDJSONLineSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, errLineTooLong) {
+			reject(line, echo.NewHTTPError(http.StatusBadRequest, "Line is too long"))
+			continue
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		var tc TestcaseRequest
+		if err := json.Unmarshal(data, &tc); err != nil {
+			reject(line, echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON"))
+			continue
+		}
+
+		sessionID := lastSessionID
+		if tc.SessionID != lastSessionKey || lastSessionID == uuid.Nil {
+			sessionID, err = h.requestSession(ctx, c.Logger(), userID, tc)
+			if err != nil {
+				if rejectable(err) {
+					reject(line, err)
+					continue
+				}
+				return err
+			}
+			lastSessionKey, lastSessionID = tc.SessionID, sessionID
+		}
+
+		if err := h.createTestcase(ctx, c.Logger(), userID, sessionID, tc, time.Now()); err != nil {
+			if rejectable(err) {
+				reject(line, err)
+				continue
+			}
+			return err
+		}
+		resp.Accepted++
+	}
+
+	if resp.Rejected > 0 {
+		return c.JSON(http.StatusOK, resp)
+	}
+	return c.JSON(http.StatusCreated, resp)
+}
+
+var errLineTooLong = errors.New("line is too long")
+
+// readNDJSONLine returns the next line without its line ending. A line longer than limit
+// is skipped and reported as errLineTooLong; a final line without line ending is returned as is.
+func readNDJSONLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > limit+2 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && (len(line) > 0 || tooLong):
+			// last line without line ending
+		case err != nil:
+			return nil, err
+		}
+
+		if tooLong {
+			return nil, errLineTooLong
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if len(line) > limit {
+			return nil, errLineTooLong
+		}
+		return line, nil
+	}
+}
+
+// rejectable reports whether err rejects a single testcase rather than the whole request.
+// Exceeded quotas stop the request, since every further testcase would be rejected as well.
+func rejectable(err error) bool {
+	var httpErr *echo.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Code < http.StatusInternalServerError && httpErr.Code != http.StatusTooManyRequests
+}
+
+func errorMessage(err error) string {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		if message, ok := httpErr.Message.(string); ok {
+			return message
+		}
+	}
+	return err.Error()
+}
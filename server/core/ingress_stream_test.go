@@ -0,0 +1,140 @@
+package core_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *BaseSuite) TestCreateTestcasesCompressed() {
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"compressed": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	body := func(name string) []byte {
+		return []byte(`{"testcases": [{"sessionId": "` + sid + `", "testcaseName": "` + name + `", "status": "pass"}]}`)
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write(body("test_gzip"))
+	s.Require().NoError(err)
+	s.Require().NoError(gw.Close())
+
+	rec, err := s.sendTestcases(testcasesRequest{ContentEncoding: "gzip"}, gz.String())
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_gzip"`), 1)
+
+	zw, err := zstd.NewWriter(nil)
+	s.Require().NoError(err)
+	rec, err = s.sendTestcases(testcasesRequest{ContentEncoding: "zstd"}, string(zw.EncodeAll(body("test_zstd"), nil)))
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_zstd"`), 1)
+
+	tests := []struct {
+		name     string
+		encoding string
+		code     int
+	}{
+		{"unsupported encoding", "br", http.StatusUnsupportedMediaType},
+		{"not gzip", "gzip", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := s.sendTestcases(testcasesRequest{ContentEncoding: tt.encoding}, string(body("test_invalid")))
+			s.Require().Error(err)
+			var httpErr *echo.HTTPError
+			s.Require().ErrorAs(err, &httpErr)
+			s.Equal(tt.code, httpErr.Code)
+		})
+	}
+}
+
+func (s *BaseSuite) TestCreateTestcasesNDJSON() {
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"ndjson": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	lines := strings.Join([]string{
+		`{"sessionId": "` + sid + `", "testcaseName": "test_one", "status": "pass"}`,
+		`{"sessionId": "` + sid + `", "testcaseName": "test_two", "status": "fail"}`,
+		``,
+		`{"sessionId": "` + sid + `", "testcaseName": "test_bad_status", "status": "broken"}`,
+		`not json`,
+		`{"sessionId": "` + uuid.NewString() + `", "testcaseName": "test_unknown", "status": "pass"}`,
+		`{"sessionId": "` + sid + `", "testcaseName": "test_three", "status": "skip"}`,
+	}, "\r\n")
+
+	// all lines valid
+	rec, err := s.sendTestcases(testcasesRequest{ContentType: core.MIMEApplicationNDJSON}, strings.SplitN(lines, "\r\n", 2)[0])
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+	s.JSONEq(`{"accepted": 1, "rejected": 0}`, rec.Body.String())
+
+	// invalid lines are reported, the others stored
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err = gw.Write([]byte(lines))
+	s.Require().NoError(err)
+	s.Require().NoError(gw.Close())
+
+	rec, err = s.sendTestcases(testcasesRequest{ContentType: core.MIMEApplicationNDJSON + "; charset=utf-8", ContentEncoding: "gzip"}, gz.String())
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, rec.Code)
+
+	var resp core.NDJSONResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal(3, resp.Accepted)
+	s.Equal(3, resp.Rejected)
+	s.Equal([]core.NDJSONLineError{
+		{Line: 4, Field: "status", Reason: "invalid status: broken"},
+		{Line: 5, Reason: "Invalid JSON"},
+		{Line: 6, Field: "sessionId", Reason: "Unknown session ID"},
+	}, resp.Errors)
+
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_two"`), 1)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_three"`), 1)
+	s.Empty(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_bad_status"`))
+}
+
+func (s *BaseSuite) TestCreateTestcasesDecompressionBomb() {
+	// a few KiB expanding to 64 MiB of whitespace in a JSON body or an NDJSON stream
+	bomb := func(prefix string) []byte {
+		var gz bytes.Buffer
+		gw, err := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+		s.Require().NoError(err)
+		_, err = gw.Write([]byte(prefix))
+		s.Require().NoError(err)
+		chunk := bytes.Repeat([]byte(" "), 1<<20)
+		for range 64 {
+			_, err = gw.Write(chunk)
+			s.Require().NoError(err)
+		}
+		s.Require().NoError(gw.Close())
+		s.Less(gz.Len(), 1<<20)
+		return gz.Bytes()
+	}
+
+	send := func(contentType string, body []byte) error {
+		_, err := s.sendTestcases(testcasesRequest{ContentType: contentType, ContentEncoding: "gzip", MaxDecompressedSize: 1 << 20}, string(body))
+		return err
+	}
+
+	for _, contentType := range []string{echo.MIMEApplicationJSON, core.MIMEApplicationNDJSON} {
+		err := send(contentType, bomb(`{"testcases": [`))
+		var httpErr *echo.HTTPError
+		s.Require().ErrorAs(err, &httpErr, contentType)
+		s.Equal(http.StatusRequestEntityTooLarge, httpErr.Code, contentType)
+	}
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Limits of ingested data. Names and label keys match the width of their VARCHAR(255) columns.
+const (
+	maxTestcaseNameLength = 255
+	maxLabelKeyLength     = 255
+	maxSessionLabels      = 100
+	maxBaggageSize        = 64 << 10
+)
+
+// TestcaseError describes a rejected testcase of a CreateTestcases request.
+// Field is the JSON name of the offending field, if the error is about a single field.
+type TestcaseError struct {
+	Index  int    `json:"index"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ValidationErrorResponse is the 400 response body of a CreateTestcases request with invalid testcases.
+type ValidationErrorResponse struct {
+	Message string          `json:"message"`
+	Errors  []TestcaseError `json:"errors"`
+}
+
+// TestcasesResponse is the response body of a CreateTestcases request in partial mode.
+type TestcasesResponse struct {
+	Accepted int             `json:"accepted"`
+	Rejected int             `json:"rejected"`
+	Errors   []TestcaseError `json:"errors,omitempty"`
+}
+
+// fieldError is the internal error of a 400 response caused by a single request field.
+type fieldError struct {
+	field string
+}
+
+func (e *fieldError) Error() string {
+	return "invalid field " + e.field
+}
+
+// invalidField returns a 400 error about a single request field.
+func invalidField(field, message string) *echo.HTTPError {
+	return echo.NewHTTPError(http.StatusBadRequest, message).SetInternal(&fieldError{field: field})
+}
+
+// testcaseError describes err, a rejection of the testcase at index.
+func testcaseError(index int, err error) TestcaseError {
+	result := TestcaseError{Index: index, Reason: errorMessage(err)}
+	var fieldErr *fieldError
+	if errors.As(err, &fieldErr) {
+		result.Field = fieldErr.field
+	}
+	return result
+}
+
+// validTestcase holds the parsed fields of a validated TestcaseRequest.
+type validTestcase struct {
+	id       uuid.UUID
+	status   model_db.TestcaseStatus
+	parentID *uuid.UUID
+	retryOf  *uuid.UUID
+	baggage  []byte
+}
+
+// validateTestcase checks the fields of a testcase that do not depend on stored data.
+// Errors are returned as *echo.HTTPError.
+func validateTestcase(tc TestcaseRequest) (validTestcase, error) {
+	var valid validTestcase
+	var err error
+
+	if utf8.RuneCountInString(tc.TestcaseName) > maxTestcaseNameLength {
+		return valid, invalidField("testcaseName", fmt.Sprintf("Name is longer than %d characters", maxTestcaseNameLength))
+	}
+
+	valid.status, err = TestcaseStatusFromString(tc.Status)
+	if err != nil {
+		return valid, invalidField("status", err.Error())
+	}
+
+	if tc.FailureType != nil && len(*tc.FailureType) > maxFailureTypeLength {
+		return valid, invalidField("failureType", "Failure type is too long")
+	}
+
+	if tc.Attempt != nil && *tc.Attempt < 1 {
+		return valid, invalidField("attempt", "Attempt must be positive")
+	}
+
+	valid.id = uuid.New()
+	if tc.ID != nil && *tc.ID != "" {
+		valid.id, err = uuid.Parse(*tc.ID)
+		if err != nil {
+			return valid, invalidField("id", "Cannot parse testcase ID")
+		}
+	}
+
+	if tc.ParentID != nil {
+		id, err := uuid.Parse(*tc.ParentID)
+		if err != nil {
+			return valid, invalidField("parentId", "Cannot parse parent testcase ID")
+		}
+		valid.parentID = &id
+	}
+
+	if tc.RetryOf != nil {
+		id, err := uuid.Parse(*tc.RetryOf)
+		if err != nil {
+			return valid, invalidField("retryOf", "Cannot parse retried testcase ID")
+		}
+		valid.retryOf = &id
+	}
+
+	valid.baggage, err = marshalBaggage(tc.Baggage)
+	if err != nil {
+		return valid, err
+	}
+
+	return valid, nil
+}
+
+// validateSession checks a session request and returns the ID of the session,
+// which is generated unless the request sets it, and its encoded baggage.
+func validateSession(req SessionRequest) (uuid.UUID, []byte, error) {
+	sessionID := uuid.New()
+	if req.ID != nil && *req.ID != "" {
+		var err error
+		sessionID, err = uuid.Parse(*req.ID)
+		if err != nil {
+			return uuid.Nil, nil, invalidField("id", "Cannot parse session ID")
+		}
+	}
+
+	if err := validateLabels(req.Labels); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	baggage, err := marshalBaggage(req.Baggage)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	return sessionID, baggage, nil
+}
+
+// validateLabels checks the labels set by a session request.
+func validateLabels(labels []LabelRequest) error {
+	if len(labels) > maxSessionLabels {
+		return invalidField("labels", fmt.Sprintf("More than %d labels", maxSessionLabels))
+	}
+	for _, label := range labels {
+		if label.Key == "" {
+			return invalidField("labels", "Label key is required")
+		}
+		if utf8.RuneCountInString(label.Key) > maxLabelKeyLength {
+			return invalidField("labels", fmt.Sprintf("Label key is longer than %d characters", maxLabelKeyLength))
+		}
+	}
+	return nil
+}
+
+// marshalBaggage encodes baggage, which must not exceed maxBaggageSize once encoded.
+func marshalBaggage(baggage map[string]any) ([]byte, error) {
+	if baggage == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(baggage)
+	if err != nil {
+		return nil, invalidField("baggage", "Invalid baggage format")
+	}
+	if len(data) > maxBaggageSize {
+		return nil, invalidField("baggage", fmt.Sprintf("Baggage is larger than %d bytes", maxBaggageSize))
+	}
+	return data, nil
+}
@@ -0,0 +1,110 @@
+package core_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+func (s *BaseSuite) TestCreateTestcasesValidation() {
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"validation": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	body := `{"testcases": [
+		{"sessionId": "` + sid + `", "testcaseName": "test_valid", "status": "pass"},
+		{"sessionId": "` + sid + `", "testcaseName": "` + strings.Repeat("x", 256) + `", "status": "pass"},
+		{"sessionId": "` + sid + `", "testcaseName": "test_bad_status", "status": "broken"},
+		{"sessionId": "` + uuid.NewString() + `", "testcaseName": "test_unknown_session", "status": "pass"},
+		{"sessionId": "` + sid + `", "testcaseName": "test_big_baggage", "status": "pass", "baggage": {"blob": "` + strings.Repeat("x", 64<<10) + `"}},
+		{"sessionId": "` + sid + `", "testcaseName": "test_valid_too", "status": "fail"}
+	]}`
+	expected := []core.TestcaseError{
+		{Index: 1, Field: "testcaseName", Reason: "Name is longer than 255 characters"},
+		{Index: 2, Field: "status", Reason: "invalid status: broken"},
+		{Index: 3, Field: "sessionId", Reason: "Unknown session ID"},
+		{Index: 4, Field: "baggage", Reason: "Baggage is larger than 65536 bytes"},
+	}
+
+	// strict mode rejects the whole request
+	_, err := s.sendTestcases(testcasesRequest{}, body)
+	s.Require().Error(err)
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusBadRequest, httpErr.Code)
+	s.Equal(core.ValidationErrorResponse{Message: "Invalid testcases", Errors: expected}, httpErr.Message)
+	s.Empty(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_valid"`))
+
+	// partial mode stores the valid testcases
+	rec, err := s.sendTestcases(testcasesRequest{Query: "partial=true"}, body)
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, rec.Code)
+
+	var resp core.TestcasesResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal(2, resp.Accepted)
+	s.Equal(4, resp.Rejected)
+	s.Equal(expected, resp.Errors)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_valid"`), 1)
+	s.Len(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_valid_too"`), 1)
+	s.Empty(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_bad_status"`))
+
+	rec, err = s.sendTestcases(testcasesRequest{Query: "partial=true"}, `{"testcases": [{"sessionId": "`+sid+`", "testcaseName": "test_all_valid", "status": "skip"}]}`)
+	s.Require().NoError(err)
+	s.Equal(http.StatusCreated, rec.Code)
+	s.JSONEq(`{"accepted": 1, "rejected": 0}`, rec.Body.String())
+}
+
+func (s *BaseSuite) TestCreateTestcasesAtomic() {
+	svc := core.NewQueryService(s.db)
+
+	sessionID := s.createScratchSession(map[string]string{"atomic": "test"})
+	sid := uuid.UUID(sessionID).String()
+
+	// the second testcase passes validation but fails when stored
+	hook := &failOnceHook{prefix: "INSERT INTO " + string(s.db.Dialect().IdentQuote()) + "testcases", skip: 1}
+	db := bun.NewDB(s.db.DB, s.db.Dialect())
+	db.AddQueryHook(hook)
+
+	_, err := s.sendTestcases(testcasesRequest{Handler: core.NewIngressHandler(db, output.DefaultStorage())}, `{"testcases": [
+		{"sessionId": "`+sid+`", "testcaseName": "test_stored_first", "status": "pass"},
+		{"sessionId": "`+sid+`", "testcaseName": "test_failing", "status": "pass"}
+	]}`)
+	s.Require().Error(err)
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusInternalServerError, httpErr.Code)
+	s.True(hook.failed.Load())
+
+	// strict mode stores nothing, not even the testcases before the failing one
+	s.Empty(mustQuery(s, svc, `session_id = "`+sid+`" and name = "test_stored_first"`))
+}
+
+func (s *BaseSuite) TestSessionLimits() {
+	labels := make([]core.LabelRequest, 101)
+	for i := range labels {
+		labels[i] = core.LabelRequest{Key: uuid.NewString()}
+	}
+
+	tests := []struct {
+		name  string
+		patch core.SessionPatch
+		err   string
+	}{
+		{"too many labels", core.SessionPatch{SetLabels: labels}, "more than 100 labels"},
+		{"label key too long", core.SessionPatch{SetLabels: []core.LabelRequest{{Key: strings.Repeat("k", 256)}}}, "label key is longer than 255 characters"},
+		{"baggage too large", core.SessionPatch{Baggage: map[string]any{"blob": strings.Repeat("x", 64<<10)}}, "baggage is larger than 65536 bytes"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.EqualError(tt.patch.Validate(), tt.err)
+		})
+	}
+}
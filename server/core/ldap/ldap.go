@@ -0,0 +1,247 @@
+// Package ldap authenticates web UI logins against an LDAP directory: it finds the user
+// with a search, verifies the password with a bind as the user, and maps the groups of the
+// user to a role.
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/clientip"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+// Source is the auth source of users provisioned from LDAP.
+const Source = "ldap"
+
+const (
+	DefaultUserFilter  = "(uid={username})"
+	DefaultGroupFilter = "(|(member={dn})(uniqueMember={dn})(memberUid={username}))"
+	defaultTimeout     = 10 * time.Second
+)
+
+type Config struct {
+	// URL of the directory: ldap://host:389 or ldaps://host:636.
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS before binding.
+	StartTLS bool
+	// TLSConfig verifies the certificate of the directory; nil uses the system roots.
+	TLSConfig *tls.Config
+	// BindDN and BindPassword are the credentials of the account searching for users;
+	// if BindDN is empty, searches are anonymous.
+	BindDN       string
+	BindPassword string
+	// BaseDN is where users are searched.
+	BaseDN string
+	// UserFilter finds the user logging in; {username} is replaced with the escaped username.
+	UserFilter string
+	// UsernameAttribute names the attribute users are named after; if it is empty or
+	// missing, the name given at login is used.
+	UsernameAttribute string
+	// GroupBaseDN is where groups are searched with GroupFilter, in which {dn} and
+	// {username} are replaced. If it is empty, the memberOf attribute of users is used.
+	GroupBaseDN string
+	GroupFilter string
+	// Roles maps groups, by their DN or their name (the value of the first RDN), to roles.
+	Roles core.RoleMapping
+	// Timeout limits connecting and every request; zero means 10 seconds.
+	Timeout time.Duration
+}
+
+// Authenticator is a core.Authenticator verifying passwords against an LDAP directory.
+type Authenticator struct {
+	db     *bun.DB
+	config Config
+}
+
+func NewAuthenticator(db *bun.DB, cfg Config) (*Authenticator, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		if cfg.StartTLS {
+			return nil, errors.New("StartTLS cannot be used with ldaps://")
+		}
+	default:
+		return nil, fmt.Errorf("invalid URL scheme %q, expected ldap or ldaps", u.Scheme)
+	}
+	if cfg.BaseDN == "" {
+		return nil, errors.New("base DN is required")
+	}
+	if cfg.Roles.DefaultRole != "" {
+		if _, err := model_db.ParseUserRole(string(cfg.Roles.DefaultRole)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = DefaultUserFilter
+	}
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = DefaultGroupFilter
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{}
+	}
+	if cfg.TLSConfig.ServerName == "" {
+		cfg.TLSConfig = cfg.TLSConfig.Clone()
+		cfg.TLSConfig.ServerName = u.Hostname()
+	}
+	return &Authenticator{db: db, config: cfg}, nil
+}
+
+// entry is a user found in the directory.
+type entry struct {
+	DN       string
+	Username string
+	Groups   []string
+}
+
+func (a *Authenticator) Authenticate(c echo.Context, username, password string) (*model_db.User, error) {
+	user, err := a.verify(username, password)
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := a.config.Roles.Identity(Source, strings.ToLower(user.DN), user.Username, user.Groups)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "You are not allowed to use Greener")
+	}
+	return core.ProvisionUser(c.Request().Context(), a.db, c.Logger(), clientip.FromContext(c), identity)
+}
+
+// verify finds the user logging in as username and checks their password with a bind.
+func (a *Authenticator) verify(username, password string) (*entry, error) {
+	// directories treat binds without a password as anonymous, which succeed
+	if username == "" || password == "" {
+		return nil, core.ErrUnknownUser
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	user, err := a.find(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(user.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, core.ErrInvalidPassword
+		}
+		return nil, fmt.Errorf("failed to bind as %s: %w", user.DN, err)
+	}
+	return user, nil
+}
+
+func (a *Authenticator) dial() (*goldap.Conn, error) {
+	conn, err := goldap.DialURL(a.config.URL,
+		goldap.DialWithDialer(&net.Dialer{Timeout: a.config.Timeout}),
+		goldap.DialWithTLSConfig(a.config.TLSConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", a.config.URL, err)
+	}
+	conn.SetTimeout(a.config.Timeout)
+
+	if a.config.StartTLS {
+		if err := conn.StartTLS(a.config.TLSConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if a.config.BindDN != "" {
+		err = conn.Bind(a.config.BindDN, a.config.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to bind as %s: %w", a.config.BindDN, err)
+	}
+	return conn, nil
+}
+
+// find searches the user logging in as username and their groups.
+func (a *Authenticator) find(conn *goldap.Conn, username string) (*entry, error) {
+	attributes := []string{"memberOf"}
+	if a.config.UsernameAttribute != "" {
+		attributes = append(attributes, a.config.UsernameAttribute)
+	}
+	res, err := conn.Search(goldap.NewSearchRequest(
+		a.config.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 2, 0, false,
+		strings.ReplaceAll(a.config.UserFilter, "{username}", goldap.EscapeFilter(username)),
+		attributes, nil,
+	))
+	// usernames must identify users unambiguously
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) || goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+		return nil, core.ErrUnknownUser
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search user %s: %w", username, err)
+	}
+	if len(res.Entries) != 1 {
+		return nil, core.ErrUnknownUser
+	}
+
+	found := res.Entries[0]
+	user := &entry{DN: found.DN, Username: username}
+	if a.config.UsernameAttribute != "" {
+		if name := found.GetAttributeValue(a.config.UsernameAttribute); name != "" {
+			user.Username = name
+		}
+	}
+
+	groupDNs := found.GetAttributeValues("memberOf")
+	if a.config.GroupBaseDN != "" {
+		filter := strings.NewReplacer(
+			"{dn}", goldap.EscapeFilter(found.DN),
+			"{username}", goldap.EscapeFilter(username),
+		).Replace(a.config.GroupFilter)
+		res, err := conn.Search(goldap.NewSearchRequest(
+			a.config.GroupBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, 0, false,
+			filter, []string{"1.1"}, nil,
+		))
+		if err != nil && !goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) {
+			return nil, fmt.Errorf("failed to search groups of %s: %w", username, err)
+		}
+		groupDNs = nil
+		if res != nil {
+			for _, group := range res.Entries {
+				groupDNs = append(groupDNs, group.DN)
+			}
+		}
+	}
+	for _, groupDN := range groupDNs {
+		user.Groups = append(user.Groups, groupDN)
+		if name := groupName(groupDN); name != "" {
+			user.Groups = append(user.Groups, name)
+		}
+	}
+	return user, nil
+}
+
+// groupName returns the value of the first RDN of a group DN, e.g. "qa" of
+// "cn=qa,ou=groups,dc=example,dc=org".
+func groupName(dn string) string {
+	parsed, err := goldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return ""
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
@@ -0,0 +1,140 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"testing"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	serviceDN = "cn=greener,ou=people,dc=example,dc=org"
+	qaDN      = "cn=qa,ou=groups,dc=example,dc=org"
+	adminsDN  = "cn=admins,ou=groups,dc=example,dc=org"
+)
+
+// startDirectory starts a directory with a service account and the users alice, in the qa
+// group by memberOf, and bob, a member of the admins group.
+func startDirectory(t *testing.T, opt ...testdirectory.Option) *testdirectory.Directory {
+	d := testdirectory.Start(t, append(opt, testdirectory.WithDefaults(t, &testdirectory.Defaults{
+		UserDN:  testdirectory.DefaultUserDN,
+		GroupDN: testdirectory.DefaultGroupDN,
+		Users: []*gldap.Entry{
+			gldap.NewEntry(serviceDN, map[string][]string{"password": {"service-password"}}),
+			gldap.NewEntry("uid=alice,"+testdirectory.DefaultUserDN, map[string][]string{
+				"uid":      {"alice"},
+				"password": {"alice-password"},
+				"memberOf": {qaDN},
+			}),
+			gldap.NewEntry("uid=bob,"+testdirectory.DefaultUserDN, map[string][]string{
+				"uid":      {"Bob"},
+				"password": {"bob-password"},
+			}),
+		},
+		Groups: []*gldap.Entry{
+			gldap.NewEntry(qaDN, map[string][]string{"member": {"uid=alice," + testdirectory.DefaultUserDN}}),
+			gldap.NewEntry(adminsDN, map[string][]string{"member": {"uid=bob," + testdirectory.DefaultUserDN}}),
+		},
+	}))...)
+	return d
+}
+
+func tlsConfig(t *testing.T, d *testdirectory.Directory) *tls.Config {
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM([]byte(d.Cert())))
+	return &tls.Config{RootCAs: roots}
+}
+
+func newAuthenticator(t *testing.T, cfg Config) *Authenticator {
+	cfg.BindDN = serviceDN
+	cfg.BindPassword = "service-password"
+	cfg.BaseDN = testdirectory.DefaultUserDN
+	cfg.UsernameAttribute = "uid"
+	a, err := NewAuthenticator(nil, cfg)
+	require.NoError(t, err)
+	return a
+}
+
+func TestVerify(t *testing.T) {
+	d := startDirectory(t)
+	a := newAuthenticator(t, Config{
+		URL:       fmt.Sprintf("ldaps://%s:%d", d.Host(), d.Port()),
+		TLSConfig: tlsConfig(t, d),
+	})
+
+	user, err := a.verify("alice", "alice-password")
+	require.NoError(t, err)
+	assert.Equal(t, &entry{
+		DN:       "uid=alice," + testdirectory.DefaultUserDN,
+		Username: "alice",
+		Groups:   []string{qaDN, "qa"},
+	}, user)
+
+	// users are named after the username attribute
+	user, err = a.verify("bob", "bob-password")
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", user.Username)
+	assert.Empty(t, user.Groups)
+
+	_, err = a.verify("alice", "bob-password")
+	assert.ErrorIs(t, err, core.ErrInvalidPassword)
+	_, err = a.verify("carol", "carol-password")
+	assert.ErrorIs(t, err, core.ErrUnknownUser)
+	_, err = a.verify("alice", "")
+	assert.ErrorIs(t, err, core.ErrUnknownUser)
+
+	// groups can be searched instead of read from memberOf
+	a = newAuthenticator(t, Config{
+		URL:         fmt.Sprintf("ldaps://%s:%d", d.Host(), d.Port()),
+		TLSConfig:   tlsConfig(t, d),
+		GroupBaseDN: testdirectory.DefaultGroupDN,
+		GroupFilter: "(member={dn})",
+	})
+	user, err = a.verify("bob", "bob-password")
+	require.NoError(t, err)
+	assert.Equal(t, []string{adminsDN, "admins"}, user.Groups)
+
+	// the certificate of the directory is verified
+	a = newAuthenticator(t, Config{URL: fmt.Sprintf("ldaps://%s:%d", d.Host(), d.Port())})
+	_, err = a.verify("alice", "alice-password")
+	assert.ErrorContains(t, err, "failed to connect")
+}
+
+func TestVerifyStartTLS(t *testing.T) {
+	d := startDirectory(t, testdirectory.WithNoTLS(t))
+	url := fmt.Sprintf("ldap://%s:%d", d.Host(), d.Port())
+
+	a := newAuthenticator(t, Config{URL: url, StartTLS: true, TLSConfig: tlsConfig(t, d)})
+	user, err := a.verify("alice", "alice-password")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	a = newAuthenticator(t, Config{URL: url, StartTLS: true})
+	_, err = a.verify("alice", "alice-password")
+	assert.ErrorContains(t, err, "failed to start TLS")
+
+	a = newAuthenticator(t, Config{URL: url, BindDN: serviceDN})
+	a.config.BindPassword = "wrong"
+	_, err = a.verify("alice", "alice-password")
+	assert.ErrorContains(t, err, "failed to bind as "+serviceDN)
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	_, err := NewAuthenticator(nil, Config{URL: "ldaps://ldap.example.com", StartTLS: true, BaseDN: "dc=example,dc=org"})
+	assert.Error(t, err)
+	_, err = NewAuthenticator(nil, Config{URL: "http://ldap.example.com", BaseDN: "dc=example,dc=org"})
+	assert.Error(t, err)
+	_, err = NewAuthenticator(nil, Config{URL: "ldap://ldap.example.com"})
+	assert.Error(t, err)
+
+	a, err := NewAuthenticator(nil, Config{URL: "ldap://ldap.example.com", BaseDN: "dc=example,dc=org"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultUserFilter, a.config.UserFilter)
+	assert.Equal(t, "ldap.example.com", a.config.TLSConfig.ServerName)
+}
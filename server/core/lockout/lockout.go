@@ -0,0 +1,358 @@
+// Package lockout slows down password guessing. It counts failed attempts per username and
+// per client IP address in the database, so that all servers sharing it see them, makes
+// clients wait exponentially longer after every failure, and locks usernames and addresses
+// out for a while after too many failures.
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/uptrace/bun"
+)
+
+// Kinds of subjects whose failed attempts are counted.
+const (
+	KindUser = "user"
+	KindIP   = "ip"
+)
+
+// maxNameLength matches the width of the name column.
+const maxNameLength = 255
+
+// Subject is a username or client IP address that failed attempts are counted for.
+type Subject struct {
+	Kind string
+	Name string
+}
+
+// User returns the subject of a username. Usernames are compared case-insensitively, so
+// that directories ignoring case cannot be guessed at under several spellings.
+func User(username string) Subject {
+	name := strings.ToLower(strings.ToValidUTF8(username, "\uFFFD"))
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = string([]rune(name)[:maxNameLength])
+	}
+	return Subject{Kind: KindUser, Name: name}
+}
+
+// IP returns the subject of a client IP address, in canonical form if it is valid.
+func IP(addr string) Subject {
+	if ip, err := netip.ParseAddr(addr); err == nil {
+		addr = ip.WithZone("").String()
+	} else if len(addr) > maxNameLength {
+		addr = addr[:maxNameLength]
+	}
+	return Subject{Kind: KindIP, Name: strings.ToValidUTF8(addr, "\uFFFD")}
+}
+
+func (s Subject) String() string {
+	return s.Kind + " " + s.Name
+}
+
+// Limit is how many failed attempts of a kind of subject are tolerated.
+type Limit struct {
+	// FreeAttempts failed attempts are allowed without waiting.
+	FreeAttempts int
+	// MaxAttempts failed attempts lock the subject out; zero disables lockouts.
+	MaxAttempts int
+}
+
+// Policy configures a Guard. The zero value disables it.
+type Policy struct {
+	Users Limit
+	IPs   Limit
+	// BaseDelay is the wait after the first failed attempt beyond the free ones. It doubles
+	// with every further failure, up to MaxDelay. Zero disables waiting.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Lockout is how long subjects are locked out, and how long failed attempts are counted.
+	Lockout time.Duration
+}
+
+// DefaultPolicy tolerates a few typos per username, and more per address, which may be
+// shared by many users.
+var DefaultPolicy = Policy{
+	Users:     Limit{FreeAttempts: 3, MaxAttempts: 10},
+	IPs:       Limit{FreeAttempts: 20, MaxAttempts: 100},
+	BaseDelay: time.Second,
+	MaxDelay:  time.Minute,
+	Lockout:   15 * time.Minute,
+}
+
+func (p Policy) Validate() error {
+	if p.Users.FreeAttempts < 0 || p.Users.MaxAttempts < 0 || p.IPs.FreeAttempts < 0 || p.IPs.MaxAttempts < 0 {
+		return fmt.Errorf("attempt limits must be non-negative")
+	}
+	if p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("delays must be non-negative")
+	}
+	if p.enabled() && p.Lockout <= 0 {
+		return fmt.Errorf("lockout duration must be positive")
+	}
+	return nil
+}
+
+func (p Policy) enabled() bool {
+	return p.BaseDelay > 0 || p.Users.MaxAttempts > 0 || p.IPs.MaxAttempts > 0
+}
+
+func (p Policy) limit(kind string) Limit {
+	if kind == KindIP {
+		return p.IPs
+	}
+	return p.Users
+}
+
+// delay returns the wait after failures failed attempts within limit.
+func (p Policy) delay(limit Limit, failures int) time.Duration {
+	excess := failures - limit.FreeAttempts
+	if p.BaseDelay <= 0 || excess <= 0 {
+		return 0
+	}
+	if excess > 32 {
+		return p.MaxDelay
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(excess-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// BlockedError is returned for attempts of a subject that is locked out, or has to wait
+// after its last failed attempt, until Until.
+type BlockedError struct {
+	Subject Subject
+	Locked  bool
+	Until   time.Time
+	now     time.Time
+}
+
+func (e *BlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("%s is locked out until %s", e.Subject, e.Until.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s has to wait until %s", e.Subject, e.Until.Format(time.RFC3339))
+}
+
+// RetryAfterSeconds returns when the attempt may be retried in whole seconds for a
+// Retry-After header, rounded up.
+func (e *BlockedError) RetryAfterSeconds() int {
+	return max(1, int(math.Ceil(e.Until.Sub(e.now).Seconds())))
+}
+
+// Guard enforces a Policy.
+type Guard struct {
+	policy Policy
+	now    func() time.Time
+}
+
+func NewGuard(policy Policy) *Guard {
+	return &Guard{policy: policy, now: time.Now}
+}
+
+func (g *Guard) Policy() Policy {
+	return g.policy
+}
+
+// Check returns a *BlockedError if one of subjects is locked out or has to wait.
+func (g *Guard) Check(ctx context.Context, db bun.IDB, subjects ...Subject) error {
+	if !g.policy.enabled() {
+		return nil
+	}
+	now := g.now().UTC()
+	for _, subject := range subjects {
+		if subject.Name == "" {
+			continue
+		}
+		var failure model_db.LoginFailure
+		err := db.NewSelect().
+			Model(&failure).
+			Where("? = ?", bun.Ident("kind"), subject.Kind).
+			Where("? = ?", bun.Ident("name"), subject.Name).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load failed attempts of %s: %w", subject, err)
+		}
+
+		if failure.LockedUntil != nil && now.Before(*failure.LockedUntil) {
+			return &BlockedError{Subject: subject, Locked: true, Until: *failure.LockedUntil, now: now}
+		}
+		if g.stale(&failure, now) {
+			continue
+		}
+		until := failure.LastFailedAt.Add(g.policy.delay(g.policy.limit(subject.Kind), failure.Failures))
+		if now.Before(until) {
+			return &BlockedError{Subject: subject, Until: until, now: now}
+		}
+	}
+	return nil
+}
+
+// stale reports whether the failed attempts of failure are too old to count.
+func (g *Guard) stale(failure *model_db.LoginFailure, now time.Time) bool {
+	return !failure.LastFailedAt.After(now.Add(-g.policy.Lockout))
+}
+
+// Fail records a failed attempt of subjects and returns those it locked out.
+func (g *Guard) Fail(ctx context.Context, db bun.IDB, subjects ...Subject) ([]Subject, error) {
+	if !g.policy.enabled() {
+		return nil, nil
+	}
+	now := g.now().UTC()
+	var locked []Subject
+	for _, subject := range subjects {
+		if subject.Name == "" {
+			continue
+		}
+		failures, err := g.increment(ctx, db, subject, now)
+		if err != nil {
+			return locked, fmt.Errorf("failed to record failed attempt of %s: %w", subject, err)
+		}
+
+		limit := g.policy.limit(subject.Kind)
+		if limit.MaxAttempts == 0 || failures < limit.MaxAttempts {
+			continue
+		}
+		res, err := db.NewUpdate().
+			Model((*model_db.LoginFailure)(nil)).
+			Set("? = ?", bun.Ident("locked_until"), now.Add(g.policy.Lockout)).
+			Where("? = ?", bun.Ident("kind"), subject.Kind).
+			Where("? = ?", bun.Ident("name"), subject.Name).
+			WhereGroup(" AND ", func(q *bun.UpdateQuery) *bun.UpdateQuery {
+				return q.Where("? IS NULL", bun.Ident("locked_until")).
+					WhereOr("? <= ?", bun.Ident("locked_until"), now)
+			}).
+			Exec(ctx)
+		if err != nil {
+			return locked, fmt.Errorf("failed to lock out %s: %w", subject, err)
+		}
+		// concurrent attempts lock a subject out once
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			locked = append(locked, subject)
+		}
+	}
+	return locked, nil
+}
+
+// increment counts a failed attempt of subject and returns its recent failed attempts.
+// Failed attempts that are stale are forgotten, along with an expired lockout.
+func (g *Guard) increment(ctx context.Context, db bun.IDB, subject Subject, now time.Time) (int, error) {
+	staleBefore := now.Add(-g.policy.Lockout)
+	update := func() (int64, error) {
+		// MySQL assigns columns from left to right, so last_failed_at is set after the
+		// columns depending on its previous value
+		res, err := db.NewUpdate().
+			Model((*model_db.LoginFailure)(nil)).
+			Set("? = CASE WHEN ? <= ? THEN 1 ELSE ? + 1 END", bun.Ident("failures"), bun.Ident("last_failed_at"), staleBefore, bun.Ident("failures")).
+			Set("? = CASE WHEN ? <= ? THEN NULL ELSE ? END", bun.Ident("locked_until"), bun.Ident("last_failed_at"), staleBefore, bun.Ident("locked_until")).
+			Set("? = ?", bun.Ident("last_failed_at"), now).
+			Where("? = ?", bun.Ident("kind"), subject.Kind).
+			Where("? = ?", bun.Ident("name"), subject.Name).
+			Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	updated, err := update()
+	if err != nil {
+		return 0, err
+	}
+	if updated == 0 {
+		// stale entries are deleted as new ones are added
+		_, err := db.NewDelete().
+			Model((*model_db.LoginFailure)(nil)).
+			Where("? <= ?", bun.Ident("last_failed_at"), staleBefore).
+			WhereGroup(" AND ", func(q *bun.DeleteQuery) *bun.DeleteQuery {
+				return q.Where("? IS NULL", bun.Ident("locked_until")).
+					WhereOr("? <= ?", bun.Ident("locked_until"), now)
+			}).
+			Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+		_, err = db.NewInsert().Model(&model_db.LoginFailure{
+			Kind:         subject.Kind,
+			Name:         subject.Name,
+			Failures:     1,
+			LastFailedAt: now,
+		}).Exec(ctx)
+		if err == nil {
+			return 1, nil
+		}
+		// another attempt inserted the row first
+		if updated, updateErr := update(); updateErr != nil || updated == 0 {
+			return 0, err
+		}
+	}
+
+	var failures int
+	err = db.NewSelect().
+		Model((*model_db.LoginFailure)(nil)).
+		Column("failures").
+		Where("? = ?", bun.Ident("kind"), subject.Kind).
+		Where("? = ?", bun.Ident("name"), subject.Name).
+		Scan(ctx, &failures)
+	return failures, err
+}
+
+// Reset forgets the failed attempts of subject, which ends its lockout. It reports whether
+// subject was locked out.
+func (g *Guard) Reset(ctx context.Context, db bun.IDB, subject Subject) (bool, error) {
+	if subject.Name == "" {
+		return false, nil
+	}
+	var failure model_db.LoginFailure
+	err := db.NewSelect().
+		Model(&failure).
+		Where("? = ?", bun.Ident("kind"), subject.Kind).
+		Where("? = ?", bun.Ident("name"), subject.Name).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err == nil {
+		_, err = db.NewDelete().
+			Model((*model_db.LoginFailure)(nil)).
+			Where("? = ?", bun.Ident("kind"), subject.Kind).
+			Where("? = ?", bun.Ident("name"), subject.Name).
+			Exec(ctx)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to reset failed attempts of %s: %w", subject, err)
+	}
+	return failure.LockedUntil != nil && g.now().Before(*failure.LockedUntil), nil
+}
+
+// Locked returns the names of the subjects of kind that are locked out, with the end of
+// their lockouts.
+func (g *Guard) Locked(ctx context.Context, db bun.IDB, kind string) (map[string]time.Time, error) {
+	var failures []model_db.LoginFailure
+	err := db.NewSelect().
+		Model(&failures).
+		Where("? = ?", bun.Ident("kind"), kind).
+		Where("? > ?", bun.Ident("locked_until"), g.now().UTC()).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lockouts: %w", err)
+	}
+	locked := make(map[string]time.Time, len(failures))
+	for _, failure := range failures {
+		locked[failure.Name] = *failure.LockedUntil
+	}
+	return locked, nil
+}
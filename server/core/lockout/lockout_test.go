@@ -0,0 +1,54 @@
+package lockout
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 10 * time.Second, Lockout: time.Hour}
+	limit := Limit{FreeAttempts: 2}
+
+	for failures, delay := range []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		assert.Equal(t, delay, p.delay(limit, failures), failures)
+	}
+	assert.Equal(t, 10*time.Second, p.delay(limit, 1000))
+
+	// without a maximum, delays keep doubling
+	p.MaxDelay = 0
+	assert.Equal(t, 16*time.Second, p.delay(limit, 7))
+
+	p.BaseDelay = 0
+	assert.Zero(t, p.delay(limit, 7))
+}
+
+func TestPolicyValidate(t *testing.T) {
+	assert.NoError(t, Policy{}.Validate())
+	assert.NoError(t, DefaultPolicy.Validate())
+	assert.Error(t, Policy{Users: Limit{MaxAttempts: -1}}.Validate())
+	assert.Error(t, Policy{BaseDelay: -time.Second}.Validate())
+	assert.Error(t, Policy{Users: Limit{MaxAttempts: 5}}.Validate())
+}
+
+func TestSubjects(t *testing.T) {
+	assert.Equal(t, Subject{Kind: KindUser, Name: "alice"}, User("Alice"))
+	assert.Equal(t, Subject{Kind: KindIP, Name: "192.0.2.1"}, IP("192.0.2.1"))
+	assert.Equal(t, "2001:db8::1", IP("2001:DB8::1%eth0").Name)
+	assert.Len(t, IP(strings.Repeat("1", 300)).Name, maxNameLength)
+	assert.Equal(t, strings.Repeat("ä", maxNameLength), User(strings.Repeat("Ä", 300)).Name)
+	assert.Equal(t, "a�b", User("a\xffb").Name)
+}
+
+func TestBlockedError(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	err := &BlockedError{Subject: User("alice"), Until: now.Add(1500 * time.Millisecond), now: now}
+	assert.Equal(t, 2, err.RetryAfterSeconds())
+	assert.Equal(t, "user alice has to wait until 2026-10-19T12:00:01Z", err.Error())
+
+	err = &BlockedError{Subject: IP("192.0.2.1"), Locked: true, Until: now, now: now}
+	assert.Equal(t, 1, err.RetryAfterSeconds())
+	assert.Equal(t, "ip 192.0.2.1 is locked out until 2026-10-19T12:00:00Z", err.Error())
+}
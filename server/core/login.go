@@ -1,16 +1,23 @@
 package core
 
 import (
-	"context"
-	"crypto/sha256"
-	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/lockout"
 	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 	"github.com/uptrace/bun"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 func IndexHandler(c echo.Context) error {
@@ -34,43 +41,167 @@ func LoginPageHandler(c echo.Context) error {
 
 	return c.Render(http.StatusOK, "login.html", map[string]any{
 		"ShowSidebar": false,
+		"SSOName":     SSOName(c),
 	})
 }
 
+// LoginHandler logs in with a username and password, verified by the configured
+// authenticators in order (see Authenticators).
 func LoginHandler(c echo.Context) error {
 	username := c.FormValue("username")
 	password := c.FormValue("password")
 
 	db := c.Get("db").(*bun.DB)
-	ctx := context.Background()
+	sec := getSecurity(c)
+	ctx := c.Request().Context()
 
-	var user model_db.User
-	err := db.NewSelect().
-		Model(&user).
-		Where("? = ?", bun.Ident("username"), username).
-		Scan(ctx)
+	subjects := []lockout.Subject{lockout.User(username), lockout.IP(clientip.FromContext(c))}
+	if err := sec.loginGuard.Check(ctx, db, subjects...); err != nil {
+		return loginBlocked(c, db, username, err)
+	}
+
+	user, err := authenticate(c, username, password)
 	if err != nil {
-		c.Logger().Errorf("Failed to find user %s: %v", username, err)
+		code, message, details := http.StatusUnauthorized, "Invalid username or password", err.Error()
+		var he *echo.HTTPError
+		switch {
+		case errors.Is(err, ErrUnknownUser), errors.Is(err, ErrInvalidPassword):
+			failLogin(c, db, user, username, subjects)
+		case errors.As(err, &he):
+			code, message = he.Code, fmt.Sprint(he.Message)
+			details = message
+		default:
+			c.Logger().Errorf("Failed to authenticate user %s: %v", username, err)
+			code, message = http.StatusInternalServerError, "Login failed"
+		}
+
+		event := audit.Event{
+			Action:     audit.ActionLogin,
+			Failed:     true,
+			TargetType: audit.TargetUser,
+			Details:    details,
+		}
+		if user != nil {
+			event.TargetID = user.ID.String()
+		}
+		audit.RecordRequest(c, db, loginActor(c, user, username), event)
+		c.Response().Header().Set("Content-Type", "text/html")
+		return c.HTML(code, fmt.Sprintf(`<span>%s</span>`, html.EscapeString(message)))
+	}
+
+	if user.DisabledAt != nil {
+		audit.RecordRequest(c, db, loginActor(c, user, username), audit.Event{
+			Action:     audit.ActionLogin,
+			Failed:     true,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID.String(),
+			Details:    "user disabled",
+		})
 		c.Response().Header().Set("Content-Type", "text/html")
-		return c.HTML(http.StatusUnauthorized, `<span>Invalid username or password</span>`)
+		return c.HTML(http.StatusForbidden, `<span>Your account is disabled</span>`)
+	}
+
+	if _, err := sec.loginGuard.Reset(ctx, db, lockout.User(username)); err != nil {
+		c.Logger().Errorf("Failed to reset failed logins of %s: %v", username, err)
 	}
 
-	passwordHash := pbkdf2.Key([]byte(password), user.PasswordSalt, 100000, 32, sha256.New)
+	StartWebSession(c, user)
+
+	audit.RecordRequest(c, db, loginActor(c, user, username), audit.Event{
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.String(),
+		Details:    externalSource(user),
+	})
+
+	c.Response().Header().Set("HX-Redirect", "/sessions")
+	return c.NoContent(http.StatusOK)
+}
 
-	if subtle.ConstantTimeCompare(passwordHash, user.PasswordHash) != 1 {
+// loginBlocked rejects a login attempt blocked by the login guard with err.
+func loginBlocked(c echo.Context, db *bun.DB, username string, err error) error {
+	var blocked *lockout.BlockedError
+	if !errors.As(err, &blocked) {
+		c.Logger().Errorf("Failed to check failed logins of %s: %v", username, err)
 		c.Response().Header().Set("Content-Type", "text/html")
-		return c.HTML(http.StatusUnauthorized, `<span>Invalid username or password</span>`)
+		return c.HTML(http.StatusInternalServerError, `<span>Login failed</span>`)
+	}
+
+	audit.RecordRequest(c, db, loginActor(c, nil, username), audit.Event{
+		Action:     audit.ActionLogin,
+		Failed:     true,
+		TargetType: audit.TargetUser,
+		Details:    blocked.Error(),
+	})
+
+	message := fmt.Sprintf("Too many failed attempts, try again in %d seconds", blocked.RetryAfterSeconds())
+	switch {
+	case blocked.Locked && blocked.Subject.Kind == lockout.KindUser:
+		message = "Too many failed attempts, the account is locked. Try again later or ask an admin to unlock it"
+	case blocked.Locked:
+		message = "Too many failed attempts from your address, try again later"
 	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(blocked.RetryAfterSeconds()))
+	c.Response().Header().Set("Content-Type", "text/html")
+	return c.HTML(http.StatusTooManyRequests, fmt.Sprintf(`<span>%s</span>`, html.EscapeString(message)))
+}
 
+// failLogin counts a failed login attempt of username, the user if it exists, against subjects
+// and records the lockouts it causes. Failures are only logged.
+func failLogin(c echo.Context, db *bun.DB, user *model_db.User, username string, subjects []lockout.Subject) {
+	guard := getSecurity(c).loginGuard
+	locked, err := guard.Fail(c.Request().Context(), db, subjects...)
+	if err != nil {
+		c.Logger().Errorf("Failed to record failed login of %s: %v", username, err)
+	}
+	for _, subject := range locked {
+		event := audit.Event{
+			Action:  audit.ActionLoginLockout,
+			Details: fmt.Sprintf("%s locked out for %s", subject, guard.Policy().Lockout),
+		}
+		if subject.Kind == lockout.KindUser {
+			event.TargetType = audit.TargetUser
+			if user != nil {
+				event.TargetID = user.ID.String()
+			}
+		}
+		audit.RecordRequest(c, db, loginActor(c, user, username), event)
+	}
+}
+
+// authenticate returns the user of the first authenticator that knows username.
+func authenticate(c echo.Context, username, password string) (*model_db.User, error) {
+	for _, authenticator := range Authenticators(c) {
+		user, err := authenticator.Authenticate(c, username, password)
+		if !errors.Is(err, ErrUnknownUser) {
+			return user, err
+		}
+	}
+	return nil, ErrUnknownUser
+}
+
+// StartWebSession logs user in to the web session of c.
+func StartWebSession(c echo.Context, user *model_db.User) {
 	sess, _ := session.Get("session", c)
 	sess.Values["authenticated"] = true
-	sess.Values["username"] = username
+	sess.Values["username"] = user.Username
 	sess.Values["user_id"] = user.ID.String()
 	sess.Values["role"] = string(user.Role)
+	sess.Values["login_at"] = time.Now().Unix()
 	sess.Save(c.Request(), c.Response())
+}
 
-	c.Response().Header().Set("HX-Redirect", "/sessions")
-	return c.NoContent(http.StatusOK)
+// loginActor returns the actor of a login attempt as username, the user if it exists.
+func loginActor(c echo.Context, user *model_db.User, username string) audit.Actor {
+	actor := audit.Actor{Name: username}
+	if user != nil {
+		actor = audit.UserActor(user.ID, user.Username)
+	}
+	if len(actor.Name) > 255 {
+		actor.Name = actor.Name[:255]
+	}
+	actor.IP = clientip.FromContext(c)
+	return actor
 }
 
 func LogoutHandler(c echo.Context) error {
@@ -79,8 +210,63 @@ func LogoutHandler(c echo.Context) error {
 	delete(sess.Values, "username")
 	delete(sess.Values, "user_id")
 	delete(sess.Values, "role")
+	delete(sess.Values, "login_at")
 	sess.Save(c.Request(), c.Response())
 
 	c.Response().Header().Set("HX-Redirect", "/login")
 	return c.NoContent(http.StatusOK)
 }
+
+// CheckUserSession signs out web sessions of users that were deleted or disabled, or whose
+// sessions were revoked after they logged in, and keeps the role of sessions up to date.
+func CheckUserSession(db *bun.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if strings.HasPrefix(c.Request().URL.Path, "/static/") {
+				return next(c)
+			}
+			sess, err := session.Get("session", c)
+			if err != nil {
+				return next(c)
+			}
+			if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+				return next(c)
+			}
+
+			userIDStr, _ := sess.Values["user_id"].(string)
+			userID, err := uuid.Parse(userIDStr)
+			if err != nil {
+				return next(c)
+			}
+
+			var user model_db.User
+			err = db.NewSelect().
+				Model(&user).
+				Column("role", "disabled_at", "sessions_revoked_at").
+				Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(userID)).
+				Scan(c.Request().Context())
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				c.Logger().Errorf("Failed to load user %s: %v", userID, err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
+			}
+
+			loginAt, _ := sess.Values["login_at"].(int64)
+			revoked := user.SessionsRevokedAt != nil && loginAt < user.SessionsRevokedAt.Unix()
+			if err != nil || user.DisabledAt != nil || revoked {
+				sess.Values["authenticated"] = false
+				delete(sess.Values, "username")
+				delete(sess.Values, "user_id")
+				delete(sess.Values, "role")
+				delete(sess.Values, "login_at")
+				sess.Save(c.Request(), c.Response())
+				return next(c)
+			}
+
+			if role, _ := sess.Values["role"].(string); role != string(user.Role) {
+				sess.Values["role"] = string(user.Role)
+				sess.Save(c.Request(), c.Response())
+			}
+			return next(c)
+		}
+	}
+}
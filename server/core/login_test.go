@@ -0,0 +1,195 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/lockout"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// loginSecurity returns a Security guarding logins with policy. All failed attempts are
+// forgotten now and at the end of the test.
+func (s *BaseSuite) loginSecurity(policy lockout.Policy) *core.Security {
+	ctx := context.Background()
+	reset := func() {
+		_, err := s.db.NewDelete().Model((*model_db.LoginFailure)(nil)).Where("1 = 1").Exec(ctx)
+		s.Require().NoError(err)
+	}
+	reset()
+	s.T().Cleanup(reset)
+	return core.NewSecurity(policy, core.PasswordPolicy{}, 0)
+}
+
+func (s *BaseSuite) TestLoginLockout() {
+	ctx := context.Background()
+	logger := log.New("test")
+
+	user, err := core.CreateUser(ctx, s.db, testSecurity, logger, audit.System, "lockme", "lockme-password", model_db.RoleViewer)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		_, err := s.db.NewDelete().Model((*model_db.User)(nil)).Where("username = ?", "lockme").Exec(ctx)
+		s.Require().NoError(err)
+	})
+
+	var sec *core.Security
+	login := func(username, password string) *httptest.ResponseRecorder {
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username="+username+"&password="+password, false, "", "", s.db)
+		c.Set("security", sec)
+		s.Require().NoError(core.LoginHandler(c))
+		return rec
+	}
+
+	// usernames wait exponentially longer after the free attempts
+	sec = s.loginSecurity(lockout.Policy{
+		Users:     lockout.Limit{FreeAttempts: 2},
+		IPs:       lockout.Limit{FreeAttempts: 100},
+		BaseDelay: time.Hour,
+		MaxDelay:  2 * time.Hour,
+		Lockout:   24 * time.Hour,
+	})
+	s.Equal(http.StatusUnauthorized, login("lockme", "wrong").Code)
+	s.Equal(http.StatusUnauthorized, login("lockme", "wrong").Code)
+	s.Equal(http.StatusUnauthorized, login("LockMe", "wrong").Code)
+	rec := login("lockme", "lockme-password")
+	s.Equal(http.StatusTooManyRequests, rec.Code)
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	s.Require().NoError(err)
+	s.InDelta(3600, retryAfter, 5)
+	s.Contains(rec.Body.String(), "try again in")
+	// other usernames are not affected
+	s.Equal(http.StatusUnauthorized, login("someone", "wrong").Code)
+
+	// a successful login forgets the failed attempts
+	sec = s.loginSecurity(lockout.Policy{
+		Users:     lockout.Limit{FreeAttempts: 2},
+		IPs:       lockout.Limit{FreeAttempts: 100},
+		BaseDelay: time.Hour,
+		Lockout:   24 * time.Hour,
+	})
+	s.Equal(http.StatusUnauthorized, login("lockme", "wrong").Code)
+	s.Equal(http.StatusOK, login("lockme", "lockme-password").Code)
+	s.Equal(http.StatusUnauthorized, login("lockme", "wrong").Code)
+	s.Equal(http.StatusUnauthorized, login("lockme", "wrong").Code)
+
+	// too many failed attempts lock usernames out, whether users exist or not
+	sec = s.loginSecurity(lockout.Policy{Users: lockout.Limit{MaxAttempts: 3}, Lockout: time.Hour})
+	for _, username := range []string{"lockme", "nobody"} {
+		for range 3 {
+			s.Equal(http.StatusUnauthorized, login(username, "wrong").Code)
+		}
+		rec := login(username, "lockme-password")
+		s.Equal(http.StatusTooManyRequests, rec.Code)
+		s.Contains(rec.Body.String(), "the account is locked")
+	}
+
+	c, rec := setupAPIKeyContext(s.T(), http.MethodGet, "/api/v1/users", "", false, "", "", s.db)
+	s.Require().NoError(core.NewUsersAPI(s.db, sec).List(c))
+	s.Equal(http.StatusOK, rec.Code)
+	s.Contains(rec.Body.String(), `"username":"lockme","role":"viewer","disabled":false,"locked_until"`)
+
+	actor := audit.UserActor(s.userID, "testuser")
+	_, unlocked, err := core.UnlockUser(ctx, s.db, sec, logger, actor, user.ID)
+	s.Require().NoError(err)
+	s.True(unlocked)
+	s.Equal(http.StatusOK, login("lockme", "lockme-password").Code)
+	_, unlocked, err = core.UnlockUser(ctx, s.db, sec, logger, actor, user.ID)
+	s.Require().NoError(err)
+	s.False(unlocked)
+
+	entries, err := audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLoginLockout, Actor: "lockme"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(user.ID.String(), *entries[0].TargetID)
+	s.Equal("user lockme locked out for 1h0m0s", *entries[0].Details)
+
+	entries, err = audit.List(ctx, s.db, audit.Filter{Action: audit.ActionUserUnlock, Actor: "testuser"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	s.Equal("was not locked out", *entries[0].Details)
+	s.Equal("unlocked", *entries[1].Details)
+
+	// addresses are locked out too
+	sec = s.loginSecurity(lockout.Policy{IPs: lockout.Limit{MaxAttempts: 2}, Lockout: time.Hour})
+	s.Equal(http.StatusUnauthorized, login("first", "wrong").Code)
+	s.Equal(http.StatusUnauthorized, login("second", "wrong").Code)
+	rec = login("lockme", "lockme-password")
+	s.Equal(http.StatusTooManyRequests, rec.Code)
+	s.Contains(rec.Body.String(), "from your address")
+}
+
+func (s *BaseSuite) TestLoginLockoutIgnoresSpoofedAddresses() {
+	ipExtractor, err := clientip.Extractor(nil)
+	s.Require().NoError(err)
+	sec := s.loginSecurity(lockout.Policy{IPs: lockout.Limit{MaxAttempts: 3}, Lockout: time.Hour})
+
+	login := func(forwardedFor string) int {
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username=nobody&password=wrong", false, "", "", s.db)
+		c.Set("security", sec)
+		c.Echo().IPExtractor = ipExtractor
+		c.Request().Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		c.Request().Header.Set(echo.HeaderXRealIP, forwardedFor)
+		s.Require().NoError(core.LoginHandler(c))
+		return rec.Code
+	}
+
+	// a new forwarded address on every attempt does not escape the lockout of the connection's address
+	s.Equal(http.StatusUnauthorized, login("198.51.100.1"))
+	s.Equal(http.StatusUnauthorized, login("198.51.100.2"))
+	s.Equal(http.StatusUnauthorized, login(strings.Repeat("1", 300)))
+	s.Equal(http.StatusTooManyRequests, login("198.51.100.4"))
+
+	locked, err := lockout.NewGuard(lockout.Policy{}).Locked(context.Background(), s.db, lockout.KindIP)
+	s.Require().NoError(err)
+	s.Len(locked, 1)
+	s.Contains(locked, "192.0.2.1")
+}
+
+func (s *BaseSuite) TestAPIKeyLockout() {
+	ctx := context.Background()
+	sec := s.loginSecurity(lockout.Policy{IPs: lockout.Limit{MaxAttempts: 3}, Lockout: time.Hour})
+
+	auth := func(header string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ingress/sessions", nil)
+		req.Header.Set("X-API-Key", header)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		err := core.APIKeyAuth(s.db, sec, model_db.ScopeIngest)(func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		})(c)
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return httpErr.Code, rec.Header().Get("Retry-After")
+		}
+		s.Require().NoError(err)
+		return rec.Code, rec.Header().Get("Retry-After")
+	}
+
+	keyID, key := s.createAPIKey(ctx, "lockout", nil)
+
+	code, _ := auth(key)
+	s.Equal(http.StatusNoContent, code)
+	for _, header := range []string{"invalid", apiKeyHeader(s, keyID, "wrong"), apiKeyHeader(s, model_db.BinaryUUID{1}, "wrong")} {
+		code, _ := auth(header)
+		s.Equal(http.StatusUnauthorized, code)
+	}
+
+	// once the address is locked out, valid keys are rejected as well
+	code, retryAfter := auth(key)
+	s.Equal(http.StatusTooManyRequests, code)
+	s.NotEmpty(retryAfter)
+
+	entries, err := audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLoginLockout, Actor: audit.Anonymous.Name})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(entries)
+	s.Equal("ip 192.0.2.1 locked out for 1h0m0s after invalid API keys", *entries[0].Details)
+	s.Equal("192.0.2.1", *entries[0].SourceIP)
+}
@@ -1,12 +1,15 @@
 package mcp
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/metrics"
 	"github.com/cephei8/greener/server/core/oauth"
 	"github.com/cephei8/greener/server/core/sse"
 	"github.com/labstack/echo/v4"
+	"github.com/mark3labs/mcp-go/mcp"
 	mcpserver "github.com/mark3labs/mcp-go/server"
 	"github.com/uptrace/bun"
 )
@@ -27,6 +30,7 @@ func NewMCPServerWithQueryService(db *bun.DB, sseHub *sse.Hub, queryService core
 		"Greener",
 		"1.0.0",
 		mcpserver.WithToolCapabilities(false),
+		mcpserver.WithToolHandlerMiddleware(toolMetricsMiddleware),
 	)
 
 	s := &MCPServer{
@@ -41,6 +45,20 @@ func NewMCPServerWithQueryService(db *bun.DB, sseHub *sse.Hub, queryService core
 	return s
 }
 
+func toolMetricsMiddleware(next mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+
+		status := "success"
+		if err != nil || (result != nil && result.IsError) {
+			status = "error"
+		}
+		metrics.MCPToolCallsTotal.WithLabelValues(request.Params.Name, status).Inc()
+
+		return result, err
+	}
+}
+
 func (s *MCPServer) EchoHandler() echo.HandlerFunc {
 	httpServer := mcpserver.NewStreamableHTTPServer(s.server)
 
@@ -10,12 +10,13 @@ GREENER QUERY LANGUAGE SYNTAX:
 IMPORTANT: All string values MUST be enclosed in double quotes.
 
 FIELD FILTERS:
-- status = "pass"              Filter by status (values: "pass", "fail", "error", "skip")
+- status = "pass"              Filter by status (values: "pass", "fail", "error", "skip", "flaky")
 - status != "fail"             Negated status filter
 - name = "test_login"          Filter by test name (exact match)
 - classname = "TestAuth"       Filter by class name
 - testsuite = "api_tests"      Filter by test suite name
 - file = "tests/test_api.py"   Filter by file path
+- failure_type = "AssertionError" Filter by failure type (e.g. exception class)
 - session_id = "uuid-here"     Filter by session UUID
 - id = "uuid-here"             Filter by testcase UUID
 
@@ -139,7 +140,7 @@ func (s *MCPServer) RegisterTools() {
 
 	s.server.AddTool(
 		mcp.NewTool("get_testcase",
-			mcp.WithDescription("Get detailed information about a specific test case including full output, error messages, and metadata."),
+			mcp.WithDescription("Get detailed information about a specific test case including full output, error messages, metadata, and attachments (screenshots, logs, artifacts) with their download URLs."),
 			mcp.WithString("id",
 				mcp.Required(),
 				mcp.Description("UUID of the test case (e.g., \"550e8400-e29b-41d4-a716-446655440000\")"),
@@ -153,7 +154,7 @@ func (s *MCPServer) RegisterTools() {
 
 	s.server.AddTool(
 		mcp.NewTool("get_session",
-			mcp.WithDescription("Get detailed information about a specific test session including summary statistics and metadata."),
+			mcp.WithDescription("Get detailed information about a specific test session including summary statistics, metadata, and session-level attachments with their download URLs."),
 			mcp.WithString("id",
 				mcp.Required(),
 				mcp.Description("UUID of the session (e.g., \"550e8400-e29b-41d4-a716-446655440000\")"),
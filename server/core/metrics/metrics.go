@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "greener"
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "route", "status"},
+	)
+
+	IngressSessionsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingress_sessions_total",
+			Help:      "Number of sessions created via ingress.",
+		},
+	)
+
+	IngressTestcasesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingress_testcases_total",
+			Help:      "Number of testcase rows stored via ingress.",
+		},
+	)
+
+	IngressErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingress_errors_total",
+			Help:      "Number of failed ingress requests.",
+		},
+		[]string{"route", "status"},
+	)
+
+	IngressQueueAppliedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingress_queue_applied_total",
+			Help:      "Number of queued ingress requests applied to the database.",
+		},
+	)
+
+	IngressQueueRetriesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingress_queue_retries_total",
+			Help:      "Number of failed attempts to apply a batch of queued ingress requests.",
+		},
+	)
+
+	APIKeyCacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_key_cache_lookups_total",
+			Help:      "Number of API key verification cache lookups by result.",
+		},
+		[]string{"result"},
+	)
+
+	QueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_duration_seconds",
+			Help:      "Query service latency by query type.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"type"},
+	)
+
+	MCPToolCallsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mcp_tool_calls_total",
+			Help:      "Number of MCP tool calls by tool.",
+		},
+		[]string{"tool", "result"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		IngressSessionsTotal,
+		IngressTestcasesTotal,
+		IngressErrorsTotal,
+		IngressQueueAppliedTotal,
+		IngressQueueRetriesTotal,
+		APIKeyCacheLookupsTotal,
+		QueryDuration,
+		MCPToolCallsTotal,
+	)
+}
+
+func ObserveQuery(queryType string, start time.Time) {
+	QueryDuration.WithLabelValues(queryType).Observe(time.Since(start).Seconds())
+}
+
+func RegisterSSEClients(count func() int) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sse_connected_clients",
+			Help:      "Number of connected SSE clients.",
+		},
+		func() float64 { return float64(count()) },
+	))
+}
+
+func RegisterIngressQueueDepth(depth func() int) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ingress_queue_depth",
+			Help:      "Number of queued ingress requests not yet applied to the database.",
+		},
+		func() float64 { return float64(depth()) },
+	))
+}
+
+func RegisterDBStats(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			route := c.Path()
+			if route == "" {
+				route = "unknown"
+			}
+			HTTPRequestDuration.
+				WithLabelValues(c.Request().Method, route, strconv.Itoa(responseStatus(c, err))).
+				Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+func IngressErrors() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+
+			if status := responseStatus(c, err); status >= 400 {
+				IngressErrorsTotal.WithLabelValues(c.Path(), strconv.Itoa(status)).Inc()
+			}
+
+			return err
+		}
+	}
+}
+
+func Handler(bearerToken string) echo.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+
+	return func(c echo.Context) error {
+		if bearerToken != "" {
+			expected := "Bearer " + bearerToken
+			actual := c.Request().Header.Get("Authorization")
+			if subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid metrics token")
+			}
+		}
+
+		h.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
+
+func responseStatus(c echo.Context, err error) int {
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr.Code
+		}
+		return http.StatusInternalServerError
+	}
+	return c.Response().Status
+}
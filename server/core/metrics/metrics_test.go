@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware_ObservesRoute(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/sessions/:id/details", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/missing/:id", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "not found")
+	})
+
+	before := testutil.CollectAndCount(HTTPRequestDuration)
+
+	for _, path := range []string{"/sessions/1/details", "/sessions/2/details", "/missing/1"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, before+2, testutil.CollectAndCount(HTTPRequestDuration))
+}
+
+func TestIngressErrors_CountsFailures(t *testing.T) {
+	e := echo.New()
+	g := e.Group("/ingress", IngressErrors())
+	g.POST("/testcases", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad")
+	})
+
+	before := testutil.ToFloat64(IngressErrorsTotal.WithLabelValues("/ingress/testcases", "400"))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingress/testcases", nil))
+
+	assert.Equal(t, before+1, testutil.ToFloat64(IngressErrorsTotal.WithLabelValues("/ingress/testcases", "400")))
+}
+
+func TestHandler_BearerToken(t *testing.T) {
+	e := echo.New()
+	e.GET("/metrics", Handler("secret"))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), "greener_ingress_testcases_total"))
+}
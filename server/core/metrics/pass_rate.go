@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uptrace/bun"
+)
+
+// PassRateCollector exports the pass rate of the latest session for each
+// value of the configured labels. Values are computed on scrape.
+type PassRateCollector struct {
+	db     *bun.DB
+	labels []string
+	desc   *prometheus.Desc
+}
+
+func NewPassRateCollector(db *bun.DB, labels []string) *PassRateCollector {
+	return &PassRateCollector{
+		db:     db,
+		labels: labels,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "latest_pass_rate"),
+			"Pass rate (0-1) of the latest session with the given label value. Skipped testcases are ignored.",
+			[]string{"label", "value"},
+			nil,
+		),
+	}
+}
+
+func (p *PassRateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.desc
+}
+
+func (p *PassRateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, label := range p.labels {
+		rates, err := p.passRates(ctx, label)
+		if err != nil {
+			log.Printf("metrics: failed to compute pass rate for label %q: %v", label, err)
+			continue
+		}
+		for value, rate := range rates {
+			ch <- prometheus.MustNewConstMetric(p.desc, prometheus.GaugeValue, rate, label, value)
+		}
+	}
+}
+
+func (p *PassRateCollector) passRates(ctx context.Context, label string) (map[string]float64, error) {
+	latestQuery := p.db.NewSelect().
+		ColumnExpr("COALESCE(?, '') AS ?", bun.Ident("labels.value"), bun.Ident("label_value")).
+		ColumnExpr("? AS ?", bun.Ident("labels.session_id"), bun.Ident("session_id")).
+		ColumnExpr(
+			"ROW_NUMBER() OVER (PARTITION BY ? ORDER BY ? DESC) AS ?",
+			bun.Ident("labels.value"),
+			bun.Ident("sessions.created_at"),
+			bun.Ident("rn"),
+		).
+		Table("labels").
+		Join("JOIN ? ON ? = ?", bun.Ident("sessions"), bun.Ident("sessions.id"), bun.Ident("labels.session_id")).
+		Where("? = ?", bun.Ident("labels.key"), label)
+
+	type row struct {
+		LabelValue    string `bun:"label_value"`
+		PassCount     int64  `bun:"pass_count"`
+		ExecutedCount int64  `bun:"executed_count"`
+	}
+
+	var rows []row
+	err := p.db.NewSelect().
+		With("latest", latestQuery).
+		ColumnExpr("? AS ?", bun.Ident("latest.label_value"), bun.Ident("label_value")).
+		ColumnExpr(
+			"COALESCE(SUM(CASE WHEN ? IN (?, ?) THEN 1 ELSE 0 END), 0) AS ?",
+			bun.Ident("testcases.status"), model_db.StatusPass, model_db.StatusFlaky, bun.Ident("pass_count"),
+		).
+		ColumnExpr(
+			"COALESCE(SUM(CASE WHEN ? != ? THEN 1 ELSE 0 END), 0) AS ?",
+			bun.Ident("testcases.status"), model_db.StatusSkip, bun.Ident("executed_count"),
+		).
+		Table("latest").
+		Join("JOIN ? ON ? = ?", bun.Ident("testcases"), bun.Ident("testcases.session_id"), bun.Ident("latest.session_id")).
+		Where("? = 1", bun.Ident("latest.rn")).
+		Where("? = ?", bun.Ident("testcases.superseded"), false).
+		Group("latest.label_value").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]float64, len(rows))
+	for _, r := range rows {
+		if r.ExecutedCount == 0 {
+			continue
+		}
+		rates[r.LabelValue] = float64(r.PassCount) / float64(r.ExecutedCount)
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,25 @@
+package core_test
+
+import (
+	"strings"
+
+	"github.com/cephei8/greener/server/core/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func (s *BaseSuite) TestPassRateCollector() {
+	collector := metrics.NewPassRateCollector(s.db, []string{"branch", "env"})
+
+	// latest main session is session3 (all pass), develop is session2 (pass + error),
+	// latest production session is session3, staging is session2
+	expected := `
+# HELP greener_latest_pass_rate Pass rate (0-1) of the latest session with the given label value. Skipped testcases are ignored.
+# TYPE greener_latest_pass_rate gauge
+greener_latest_pass_rate{label="branch",value="develop"} 0.5
+greener_latest_pass_rate{label="branch",value="main"} 1
+greener_latest_pass_rate{label="env",value="production"} 1
+greener_latest_pass_rate{label="env",value="staging"} 0.5
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected))
+	s.Require().NoError(err)
+}
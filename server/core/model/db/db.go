@@ -87,28 +87,108 @@ func (u BinaryUUID) UUID() uuid.UUID {
 	return uuid.UUID(u)
 }
 
+// User is a person logging in to the web UI. Disabled users cannot log in and their API keys
+// are rejected. Web sessions started before SessionsRevokedAt are no longer valid.
+// Users provisioned by an external identity provider have AuthSource set and are identified
+// there by ExternalID; they cannot log in with a local password.
 type User struct {
 	bun.BaseModel `bun:"table:users"`
 
-	ID           BinaryUUID `bun:"id,notnull"`
-	Username     string     `bun:"username,notnull"`
-	PasswordSalt []byte     `bun:"password_salt,notnull"`
-	PasswordHash []byte     `bun:"password_hash,notnull"`
-	Role         UserRole   `bun:"role,notnull"`
-	CreatedAt    time.Time  `bun:"created_at,nullzero,notnull"`
-	UpdatedAt    time.Time  `bun:"updated_at,nullzero,notnull"`
+	ID                BinaryUUID `bun:"id,notnull"`
+	Username          string     `bun:"username,notnull"`
+	PasswordSalt      []byte     `bun:"password_salt,notnull"`
+	PasswordHash      []byte     `bun:"password_hash,notnull"`
+	Role              UserRole   `bun:"role,notnull"`
+	DisabledAt        *time.Time `bun:"disabled_at"`
+	SessionsRevokedAt *time.Time `bun:"sessions_revoked_at"`
+	AuthSource        *string    `bun:"auth_source"`
+	ExternalID        *string    `bun:"external_id"`
+	CreatedAt         time.Time  `bun:"created_at,nullzero,notnull"`
+	UpdatedAt         time.Time  `bun:"updated_at,nullzero,notnull"`
 }
 
+// APIKey authenticates API requests of a user. Scope limits what the key may do, and Project,
+// if set, limits it to the sessions with that "project" label. After a rotation the previous
+// secret remains valid until PreviousSecretExpiresAt.
 type APIKey struct {
 	bun.BaseModel `bun:"table:apikeys"`
 
-	ID          BinaryUUID `bun:"id,notnull"`
-	Description *string    `bun:"description"`
-	SecretSalt  []byte     `bun:"secret_salt,notnull"`
-	SecretHash  []byte     `bun:"secret_hash,notnull"`
-	CreatedAt   time.Time  `bun:"created_at,nullzero,notnull"`
-	UpdatedAt   time.Time  `bun:"updated_at,nullzero,notnull"`
+	ID                      BinaryUUID      `bun:"id,notnull"`
+	Description             *string         `bun:"description"`
+	SecretSalt              []byte          `bun:"secret_salt,notnull"`
+	SecretHash              []byte          `bun:"secret_hash,notnull"`
+	SecretAlgorithm         SecretAlgorithm `bun:"secret_algorithm,notnull"`
+	Scope                   APIKeyScope     `bun:"scope,notnull"`
+	Project                 *string         `bun:"project"`
+	ExpiresAt               *time.Time      `bun:"expires_at"`
+	LastUsedAt              *time.Time      `bun:"last_used_at"`
+	LastUsedIP              *string         `bun:"last_used_ip"`
+	PreviousSecretSalt      []byte          `bun:"previous_secret_salt"`
+	PreviousSecretHash      []byte          `bun:"previous_secret_hash"`
+	PreviousSecretExpiresAt *time.Time      `bun:"previous_secret_expires_at"`
+	CreatedAt               time.Time       `bun:"created_at,nullzero,notnull"`
+	UpdatedAt               time.Time       `bun:"updated_at,nullzero,notnull"`
+	UserID                  BinaryUUID      `bun:"user_id,notnull"`
+}
+
+// SecretAlgorithm is how the secrets of an API key are hashed. Rotated secrets keep the algorithm of their key.
+type SecretAlgorithm string
+
+const (
+	// SecretPBKDF2 secrets are hashed with PBKDF2-SHA256.
+	SecretPBKDF2 SecretAlgorithm = "pbkdf2"
+	// SecretSHA256 secrets are hashed with HMAC-SHA256, keyed with the salt.
+	SecretSHA256 SecretAlgorithm = "sha256"
+)
+
+type APIKeyScope string
+
+const (
+	// ScopeIngest allows reporting test results.
+	ScopeIngest APIKeyScope = "ingest"
+	// ScopeRead allows querying test results.
+	ScopeRead APIKeyScope = "read"
+	// ScopeAdmin allows everything the other scopes allow.
+	ScopeAdmin APIKeyScope = "admin"
+)
+
+func ParseAPIKeyScope(s string) (APIKeyScope, error) {
+	switch scope := APIKeyScope(s); scope {
+	case ScopeIngest, ScopeRead, ScopeAdmin:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("invalid API key scope: %s", s)
+	}
+}
+
+// Allows reports whether a key with scope s may do what requires scope required.
+func (s APIKeyScope) Allows(required APIKeyScope) bool {
+	return s == ScopeAdmin || s == required
+}
+
+// APIKeyUsage counts what an API key sent to the ingress API on a UTC day (formatted as 2006-01-02).
+type APIKeyUsage struct {
+	bun.BaseModel `bun:"table:api_key_usage"`
+
+	APIKeyID    BinaryUUID `bun:"api_key_id,notnull"`
+	Day         string     `bun:"day,notnull"`
 	UserID      BinaryUUID `bun:"user_id,notnull"`
+	Requests    int64      `bun:"requests,notnull"`
+	Testcases   int64      `bun:"testcases,notnull"`
+	OutputBytes int64      `bun:"output_bytes,notnull"`
+}
+
+// QuotaUsage counts what the API keys of a user stored on a UTC day against the daily quotas.
+// Project is empty for the usage of all keys of the user, and otherwise counts the keys restricted
+// to that project. Rows are kept when keys are deleted, so that the quotas still see them.
+type QuotaUsage struct {
+	bun.BaseModel `bun:"table:quota_usage"`
+
+	UserID      BinaryUUID `bun:"user_id,notnull"`
+	Project     string     `bun:"project,notnull"`
+	Day         string     `bun:"day,notnull"`
+	Testcases   int64      `bun:"testcases,notnull"`
+	OutputBytes int64      `bun:"output_bytes,notnull"`
 }
 
 type Session struct {
@@ -120,6 +200,8 @@ type Session struct {
 	CreatedAt   time.Time       `bun:"created_at,nullzero,notnull"`
 	UpdatedAt   time.Time       `bun:"updated_at,nullzero,notnull"`
 	UserID      BinaryUUID      `bun:"user_id,notnull"`
+	// APIKeyID is the API key that created the session, if any.
+	APIKeyID *BinaryUUID `bun:"api_key_id"`
 }
 
 type Label struct {
@@ -134,18 +216,56 @@ type Label struct {
 	UpdatedAt time.Time  `bun:"updated_at,nullzero,notnull"`
 }
 
+type SessionChangeField string
+
+const (
+	SessionChangeLabel       SessionChangeField = "label"
+	SessionChangeDescription SessionChangeField = "description"
+	SessionChangeBaggage     SessionChangeField = "baggage"
+)
+
+// SessionChange records a modification of a session after it was created.
+// UserID is the user who made the change; APIKeyID is set for changes made via ingress.
+type SessionChange struct {
+	bun.BaseModel `bun:"table:session_changes"`
+
+	ID        int64              `bun:"id,notnull,autoincrement"`
+	SessionID BinaryUUID         `bun:"session_id,notnull"`
+	Field     SessionChangeField `bun:"field,notnull"`
+	Key       *string            `bun:"key"`
+	OldValue  *string            `bun:"old_value"`
+	NewValue  *string            `bun:"new_value"`
+	UserID    BinaryUUID         `bun:"user_id,notnull"`
+	APIKeyID  *BinaryUUID        `bun:"api_key_id"`
+	CreatedAt time.Time          `bun:"created_at,nullzero,notnull"`
+}
+
 type UserRole string
 
 const (
 	RoleEditor UserRole = "editor"
 	RoleViewer UserRole = "viewer"
+	// RoleAdmin can do everything an editor can and also administer the instance.
+	RoleAdmin UserRole = "admin"
 )
 
+func ParseUserRole(s string) (UserRole, error) {
+	switch role := UserRole(s); role {
+	case RoleAdmin, RoleEditor, RoleViewer:
+		return role, nil
+	default:
+		return "", fmt.Errorf("invalid user role: %s", s)
+	}
+}
+
 type TestcaseStatus int
 
+// Statuses are ordered from worst to best; MIN(status) aggregates to the worst outcome.
 const (
 	StatusError TestcaseStatus = iota
 	StatusFail
+	// StatusFlaky marks a final attempt that passed after an earlier attempt failed.
+	StatusFlaky
 	StatusPass
 	StatusSkip
 )
@@ -153,16 +273,135 @@ const (
 type Testcase struct {
 	bun.BaseModel `bun:"table:testcases"`
 
-	ID        BinaryUUID      `bun:"id,notnull"`
-	SessionID BinaryUUID      `bun:"session_id,notnull"`
-	Name      string          `bun:"name,notnull"`
-	Classname *string         `bun:"classname"`
-	File      *string         `bun:"file"`
-	Testsuite *string         `bun:"testsuite"`
-	Output    *string         `bun:"output"`
-	Status    TestcaseStatus  `bun:"status,notnull"`
-	Baggage   json.RawMessage `bun:"baggage"`
-	CreatedAt time.Time       `bun:"created_at,nullzero,notnull"`
-	UpdatedAt time.Time       `bun:"updated_at,nullzero,notnull"`
-	UserID    BinaryUUID      `bun:"user_id,notnull"`
+	ID             BinaryUUID      `bun:"id,notnull"`
+	SessionID      BinaryUUID      `bun:"session_id,notnull"`
+	Name           string          `bun:"name,notnull"`
+	Classname      *string         `bun:"classname"`
+	File           *string         `bun:"file"`
+	Testsuite      *string         `bun:"testsuite"`
+	Output         *string         `bun:"output"`
+	OutputData     []byte          `bun:"output_data"`
+	OutputEncoding *string         `bun:"output_encoding"`
+	OutputRef      *string         `bun:"output_ref"`
+	FailureMessage *string         `bun:"failure_message"`
+	FailureType    *string         `bun:"failure_type"`
+	StackTrace     *string         `bun:"stack_trace"`
+	Stdout         *string         `bun:"stdout"`
+	Stderr         *string         `bun:"stderr"`
+	Status         TestcaseStatus  `bun:"status,notnull"`
+	ReportedStatus *TestcaseStatus `bun:"reported_status"`
+	Attempt        int             `bun:"attempt,nullzero,notnull,default:1"`
+	RetryOf        *BinaryUUID     `bun:"retry_of"`
+	Superseded     bool            `bun:"superseded,notnull"`
+	ParentID       *BinaryUUID     `bun:"parent_id"`
+	Baggage        json.RawMessage `bun:"baggage"`
+	CreatedAt      time.Time       `bun:"created_at,nullzero,notnull"`
+	UpdatedAt      time.Time       `bun:"updated_at,nullzero,notnull"`
+	UserID         BinaryUUID      `bun:"user_id,notnull"`
+}
+
+type Attachment struct {
+	bun.BaseModel `bun:"table:attachments"`
+
+	ID          BinaryUUID  `bun:"id,notnull"`
+	SessionID   BinaryUUID  `bun:"session_id,notnull"`
+	TestcaseID  *BinaryUUID `bun:"testcase_id"`
+	Name        string      `bun:"name,notnull"`
+	ContentType string      `bun:"content_type,notnull"`
+	Size        int64       `bun:"size,notnull"`
+	StorageRef  string      `bun:"storage_ref,notnull"`
+	CreatedAt   time.Time   `bun:"created_at,nullzero,notnull"`
+	UserID      BinaryUUID  `bun:"user_id,notnull"`
+}
+
+type AlertAggregate string
+
+const (
+	AggregateFailureCount  AlertAggregate = "failure_count"
+	AggregateFlakyCount    AlertAggregate = "flaky_count"
+	AggregatePassRate      AlertAggregate = "pass_rate"
+	AggregateTestcaseCount AlertAggregate = "testcase_count"
+)
+
+type AlertOperator string
+
+const (
+	OperatorLess         AlertOperator = "<"
+	OperatorLessEqual    AlertOperator = "<="
+	OperatorGreater      AlertOperator = ">"
+	OperatorGreaterEqual AlertOperator = ">="
+)
+
+type AlertState string
+
+const (
+	AlertStateOK       AlertState = "ok"
+	AlertStateFiring   AlertState = "firing"
+	AlertStateResolved AlertState = "resolved"
+)
+
+type AlertRule struct {
+	bun.BaseModel `bun:"table:alert_rules"`
+
+	ID              BinaryUUID     `bun:"id,notnull"`
+	Name            string         `bun:"name,notnull"`
+	Query           string         `bun:"query,notnull"`
+	Aggregate       AlertAggregate `bun:"aggregate,notnull"`
+	Operator        AlertOperator  `bun:"operator,notnull"`
+	Threshold       float64        `bun:"threshold,notnull"`
+	WindowSeconds   int64          `bun:"window_seconds,notnull"`
+	State           AlertState     `bun:"state,notnull"`
+	LastValue       *float64       `bun:"last_value"`
+	LastEvaluatedAt *time.Time     `bun:"last_evaluated_at"`
+	StateChangedAt  *time.Time     `bun:"state_changed_at"`
+	CreatedAt       time.Time      `bun:"created_at,nullzero,notnull"`
+	UpdatedAt       time.Time      `bun:"updated_at,nullzero,notnull"`
+	UserID          BinaryUUID     `bun:"user_id,notnull"`
+}
+
+type AlertEvent struct {
+	bun.BaseModel `bun:"table:alert_events"`
+
+	ID        int64      `bun:"id,notnull,autoincrement"`
+	RuleID    BinaryUUID `bun:"rule_id,notnull"`
+	State     AlertState `bun:"state,notnull"`
+	Value     float64    `bun:"value,notnull"`
+	CreatedAt time.Time  `bun:"created_at,nullzero,notnull"`
+}
+
+type AuditOutcome string
+
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditEntry records an administrative or data-changing action. ActorName is kept even if
+// the acting user is deleted later; actors that are not users (e.g. "system") have no ActorUserID.
+type AuditEntry struct {
+	bun.BaseModel `bun:"table:audit_log"`
+
+	ID            int64        `bun:"id,notnull,autoincrement"`
+	Action        string       `bun:"action,notnull"`
+	Outcome       AuditOutcome `bun:"outcome,notnull"`
+	ActorUserID   *BinaryUUID  `bun:"actor_user_id"`
+	ActorName     string       `bun:"actor_name,notnull"`
+	ActorAPIKeyID *BinaryUUID  `bun:"actor_api_key_id"`
+	SourceIP      *string      `bun:"source_ip"`
+	TargetType    *string      `bun:"target_type"`
+	TargetID      *string      `bun:"target_id"`
+	Details       *string      `bun:"details"`
+	CreatedAt     time.Time    `bun:"created_at,nullzero,notnull"`
+}
+
+// LoginFailure counts the recent failed login attempts of a username or client IP address.
+// Kind tells which; a username or address is locked out until LockedUntil.
+type LoginFailure struct {
+	bun.BaseModel `bun:"table:login_failures"`
+
+	Kind         string     `bun:"kind,notnull"`
+	Name         string     `bun:"name,notnull"`
+	Failures     int        `bun:"failures,notnull"`
+	LastFailedAt time.Time  `bun:"last_failed_at,notnull"`
+	LockedUntil  *time.Time `bun:"locked_until"`
 }
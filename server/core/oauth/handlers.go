@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cephei8/greener/server/core/audit"
 	"github.com/go-oauth2/oauth2/v4/models"
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
@@ -108,6 +109,13 @@ func (s *Server) AuthorizeHandler(c echo.Context) error {
 
 	decision := c.FormValue("decision")
 	if decision == "deny" {
+		audit.RecordRequest(c, s.db, audit.RequestActor(c), audit.Event{
+			Action:     audit.ActionOAuthAuthorize,
+			Failed:     true,
+			TargetType: audit.TargetOAuthClient,
+			TargetID:   c.FormValue("client_id"),
+			Details:    "denied by user",
+		})
 		redirectURI := c.FormValue("redirect_uri")
 		state := c.FormValue("state")
 		errorRedirect := redirectURI + "?error=access_denied"
@@ -148,6 +156,13 @@ func (s *Server) AuthorizeHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create authorization")
 	}
 
+	audit.RecordRequest(c, s.db, audit.RequestActor(c), audit.Event{
+		Action:     audit.ActionOAuthAuthorize,
+		TargetType: audit.TargetOAuthClient,
+		TargetID:   clientID,
+		Details:    "scope=" + scope,
+	})
+
 	callbackURL := redirectURI + "?code=" + code
 	if state != "" {
 		callbackURL += "&state=" + state
@@ -358,6 +373,13 @@ func (s *Server) RegisterHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to register client")
 	}
 
+	audit.RecordRequest(c, s.db, audit.RequestActor(c), audit.Event{
+		Action:     audit.ActionOAuthClientRegister,
+		TargetType: audit.TargetOAuthClient,
+		TargetID:   resp.ClientID,
+		Details:    "client_name=" + req.ClientName,
+	})
+
 	return c.JSON(http.StatusCreated, resp)
 }
 
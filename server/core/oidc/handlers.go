@@ -0,0 +1,134 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+// LoginHandler starts a login by redirecting to the identity provider. The state, nonce and
+// PKCE verifier of the request are kept in the web session until the callback.
+func (p *Provider) LoginHandler(c echo.Context) error {
+	state, err := randomString()
+	if err != nil {
+		c.Logger().Errorf("Failed to generate OIDC state: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+	nonce, err := randomString()
+	if err != nil {
+		c.Logger().Errorf("Failed to generate OIDC nonce: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	sess, _ := session.Get("session", c)
+	sess.Values["oidc_state"] = state
+	sess.Values["oidc_nonce"] = nonce
+	sess.Values["oidc_verifier"] = verifier
+	sess.Save(c.Request(), c.Response())
+
+	return c.Redirect(http.StatusFound, p.authCodeURL(state, nonce, verifier))
+}
+
+// CallbackHandler completes a login: it redeems the authorization code, provisions the user
+// and starts their web session.
+func (p *Provider) CallbackHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	state, _ := sess.Values["oidc_state"].(string)
+	nonce, _ := sess.Values["oidc_nonce"].(string)
+	verifier, _ := sess.Values["oidc_verifier"].(string)
+	delete(sess.Values, "oidc_state")
+	delete(sess.Values, "oidc_nonce")
+	delete(sess.Values, "oidc_verifier")
+	sess.Save(c.Request(), c.Response())
+
+	if state == "" || c.QueryParam("state") != state {
+		return p.loginError(c, http.StatusBadRequest, "Login expired, please try again")
+	}
+	if idpError := c.QueryParam("error"); idpError != "" {
+		p.recordFailure(c, audit.Anonymous.Name, "identity provider error: "+idpError)
+		return p.loginError(c, http.StatusUnauthorized, "Login was denied by the identity provider")
+	}
+
+	ctx := c.Request().Context()
+	identity, err := p.exchange(ctx, c.QueryParam("code"), verifier, nonce)
+	if err != nil {
+		c.Logger().Errorf("OIDC login failed: %v", err)
+		p.recordFailure(c, audit.Anonymous.Name, err.Error())
+		return p.loginError(c, http.StatusUnauthorized, "Login failed")
+	}
+
+	external, ok := p.config.Roles.Identity(Source, identity.Subject, identity.Username, identity.Groups)
+	if !ok {
+		p.recordFailure(c, identity.Username, "no role for groups")
+		return p.loginError(c, http.StatusForbidden, "You are not allowed to use Greener")
+	}
+
+	user, err := core.ProvisionUser(ctx, p.db, c.Logger(), clientip.FromContext(c), external)
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Login failed"
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			code, message = he.Code, fmt.Sprint(he.Message)
+		}
+		p.recordFailure(c, identity.Username, message)
+		return p.loginError(c, code, message)
+	}
+
+	actor := audit.UserActor(user.ID, user.Username)
+	actor.IP = clientip.FromContext(c)
+	if user.DisabledAt != nil {
+		audit.RecordRequest(c, p.db, actor, audit.Event{
+			Action:     audit.ActionLogin,
+			Failed:     true,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID.String(),
+			Details:    "user disabled",
+		})
+		return p.loginError(c, http.StatusForbidden, "Your account is disabled")
+	}
+
+	core.StartWebSession(c, user)
+	audit.RecordRequest(c, p.db, actor, audit.Event{
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.String(),
+		Details:    Source,
+	})
+	return c.Redirect(http.StatusFound, "/sessions")
+}
+
+func (p *Provider) recordFailure(c echo.Context, username, details string) {
+	actor := audit.Actor{Name: username, IP: clientip.FromContext(c)}
+	audit.RecordRequest(c, p.db, actor, audit.Event{
+		Action:     audit.ActionLogin,
+		Failed:     true,
+		TargetType: audit.TargetUser,
+		Details:    Source + ": " + details,
+	})
+}
+
+func (p *Provider) loginError(c echo.Context, code int, message string) error {
+	return c.Render(code, "login.html", map[string]any{
+		"ShowSidebar": false,
+		"SSOName":     p.name,
+		"Error":       message,
+	})
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
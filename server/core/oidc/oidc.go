@@ -0,0 +1,164 @@
+// Package oidc implements OpenID Connect single sign-on for the web UI: the authorization
+// code flow with PKCE against a configurable issuer, just-in-time provisioning of users
+// and mapping of group claims to roles.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/uptrace/bun"
+	"golang.org/x/oauth2"
+)
+
+// Source is the auth source of users provisioned by OpenID Connect.
+const Source = "oidc"
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered at the identity provider.
+	RedirectURL string
+	// Scopes are requested in addition to "openid".
+	Scopes []string
+	// UsernameClaim names the claim users are named after; if it is missing, the email
+	// claim and then the subject are used.
+	UsernameClaim string
+	// GroupsClaim names the claim listing the groups of users.
+	GroupsClaim string
+	Roles       core.RoleMapping
+}
+
+// Identity is a user authenticated by the identity provider.
+type Identity struct {
+	Subject  string
+	Username string
+	Groups   []string
+}
+
+// Provider logs users in with an OpenID Connect identity provider.
+type Provider struct {
+	db       *bun.DB
+	name     string
+	config   Config
+	oauth2   oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewProvider discovers the endpoints of the issuer. name is shown on the login page.
+func NewProvider(ctx context.Context, db *bun.DB, name string, cfg Config) (*Provider, error) {
+	if cfg.ClientID == "" {
+		return nil, errors.New("client ID is required")
+	}
+	if cfg.RedirectURL == "" {
+		return nil, errors.New("redirect URL is required")
+	}
+	if cfg.Roles.DefaultRole != "" {
+		if _, err := model_db.ParseUserRole(string(cfg.Roles.DefaultRole)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	provider, err := gooidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover issuer %s: %w", cfg.Issuer, err)
+	}
+
+	scopes := []string{gooidc.ScopeOpenID}
+	for _, scope := range cfg.Scopes {
+		if scope = strings.TrimSpace(scope); scope != "" && scope != gooidc.ScopeOpenID {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &Provider{
+		db:     db,
+		name:   name,
+		config: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// authCodeURL returns the URL of the authorization request for state and nonce, with the
+// PKCE challenge of verifier.
+func (p *Provider) authCodeURL(state, nonce, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// exchange redeems an authorization code and verifies the returned ID token.
+func (p *Provider) exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no ID token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	identity := &Identity{
+		Subject: idToken.Subject,
+		Groups:  stringList(claims[p.config.GroupsClaim]),
+	}
+	for _, claim := range []string{p.config.UsernameClaim, "email"} {
+		if username, ok := claims[claim].(string); ok && username != "" {
+			identity.Username = username
+			break
+		}
+	}
+	if identity.Username == "" {
+		identity.Username = idToken.Subject
+	}
+	return identity, nil
+}
+
+// stringList returns a claim holding a list of strings, or a single one.
+func stringList(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
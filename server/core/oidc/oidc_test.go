@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/cephei8/greener/server/core/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// authorize runs an authorization request against idp and returns the callback query.
+func authorize(t *testing.T, p *Provider, state, nonce, verifier string) url.Values {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(p.authCodeURL(state, nonce, verifier))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/login/oidc/callback", location.Path)
+	assert.Equal(t, state, location.Query().Get("state"))
+	return location.Query()
+}
+
+func TestExchange(t *testing.T) {
+	idp := oidctest.NewIdP("greener", "client-secret")
+	defer idp.Close()
+
+	ctx := context.Background()
+	p, err := NewProvider(ctx, nil, "Test", Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "greener",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://greener.test/login/oidc/callback",
+		GroupsClaim:  "roles",
+	})
+	require.NoError(t, err)
+
+	idp.SetUser(&oidctest.User{Subject: "u-1", Claims: map[string]any{
+		"preferred_username": "alice",
+		"roles":              []string{"qa", "dev"},
+	}})
+	verifier := oauth2.GenerateVerifier()
+	code := authorize(t, p, "state", "nonce", verifier).Get("code")
+	require.NotEmpty(t, code)
+
+	identity, err := p.exchange(ctx, code, verifier, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "u-1", Username: "alice", Groups: []string{"qa", "dev"}}, identity)
+
+	// codes can be redeemed once
+	_, err = p.exchange(ctx, code, verifier, "nonce")
+	assert.Error(t, err)
+
+	// the username falls back to the email, and a single group may be a string
+	idp.SetUser(&oidctest.User{Subject: "u-2", Claims: map[string]any{"email": "bob@example.com", "roles": "qa"}})
+	code = authorize(t, p, "state", "nonce", verifier).Get("code")
+	identity, err = p.exchange(ctx, code, verifier, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "u-2", Username: "bob@example.com", Groups: []string{"qa"}}, identity)
+
+	code = authorize(t, p, "state", "nonce", verifier).Get("code")
+	_, err = p.exchange(ctx, code, oauth2.GenerateVerifier(), "nonce")
+	assert.ErrorContains(t, err, "failed to redeem authorization code")
+
+	code = authorize(t, p, "state", "nonce", verifier).Get("code")
+	_, err = p.exchange(ctx, code, verifier, "other")
+	assert.ErrorContains(t, err, "nonce does not match")
+
+	idp.SetUser(nil)
+	assert.Equal(t, "access_denied", authorize(t, p, "state", "nonce", verifier).Get("error"))
+}
@@ -0,0 +1,227 @@
+// Package oidctest provides an in-process OpenID Connect identity provider for tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cephei8/greener/server/core/oauth"
+	"github.com/go-jose/go-jose/v4"
+)
+
+const keyID = "oidctest"
+
+// User is the user the identity provider authenticates.
+type User struct {
+	Subject string
+	// Claims are added to the ID token, e.g. "preferred_username" and "groups".
+	Claims map[string]any
+}
+
+type authorization struct {
+	user          User
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// IdP is an identity provider that supports the authorization code flow with PKCE. Its
+// authorization endpoint logs in the current user (see SetUser) without any interaction,
+// or denies the request if there is none.
+type IdP struct {
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	user  *User
+	codes map[string]authorization
+}
+
+// NewIdP starts an identity provider with one registered client. Close it when done.
+func NewIdP(clientID, clientSecret string) *IdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	p := &IdP{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /keys", p.keys)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+// Issuer returns the issuer URL of the identity provider.
+func (p *IdP) Issuer() string {
+	return p.server.URL
+}
+
+func (p *IdP) Close() {
+	p.server.Close()
+}
+
+// SetUser sets the user logged in by the next authorization requests; nil denies them.
+func (p *IdP) SetUser(user *User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = user
+}
+
+func (p *IdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer(),
+		"authorization_endpoint":                p.Issuer() + "/authorize",
+		"token_endpoint":                        p.Issuer() + "/token",
+		"jwks_uri":                              p.Issuer() + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *IdP) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &p.key.PublicKey,
+		KeyID:     keyID,
+		Algorithm: string(jose.RS256),
+		Use:       "sig",
+	}}})
+}
+
+func (p *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != p.ClientID {
+		http.Error(w, "invalid client", http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{"state": {q.Get("state")}}
+	p.mu.Lock()
+	switch {
+	case q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		params.Set("error", "invalid_request")
+	case p.user == nil:
+		params.Set("error", "access_denied")
+	default:
+		code := randomString()
+		p.codes[code] = authorization{
+			user:          *p.user,
+			redirectURI:   redirectURI.String(),
+			nonce:         q.Get("nonce"),
+			codeChallenge: q.Get("code_challenge"),
+		}
+		params.Set("code", code)
+	}
+	p.mu.Unlock()
+
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	code := r.PostFormValue("code")
+	auth, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	switch {
+	case r.PostFormValue("grant_type") != "authorization_code" || !ok:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case r.PostFormValue("redirect_uri") != auth.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "redirect_uri mismatch"})
+		return
+	case !oauth.ValidatePKCE(r.PostFormValue("code_verifier"), auth.codeChallenge, "S256"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	idToken, err := p.signIDToken(auth)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (p *IdP) signIDToken(auth authorization) (string, error) {
+	now := time.Now()
+	claims := map[string]any{}
+	for k, v := range auth.user.Claims {
+		claims[k] = v
+	}
+	claims["iss"] = p.Issuer()
+	claims["sub"] = auth.user.Subject
+	claims["aud"] = p.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(time.Hour).Unix()
+	if auth.nonce != "" {
+		claims["nonce"] = auth.nonce
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID),
+	)
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return signed.CompactSerialize()
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,158 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/oidc"
+	"github.com/cephei8/greener/server/core/oidc/oidctest"
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/gommon/log"
+)
+
+// oidcLogin logs in with p, carrying the web session from the login request to the callback.
+// tamper, if set, may change the callback query.
+func (s *BaseSuite) oidcLogin(p *oidc.Provider, tamper func(url.Values)) (*httptest.ResponseRecorder, *sessions.Session) {
+	c, rec := setupAPIKeyContext(s.T(), http.MethodGet, "/login/oidc", "", false, "", "", s.db)
+	s.Require().NoError(p.LoginHandler(c))
+	s.Require().Equal(http.StatusFound, rec.Code)
+	loginSess, _ := session.Get("session", c)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Require().Equal(http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	s.Require().NoError(err)
+	query := callback.Query()
+	if tamper != nil {
+		tamper(query)
+	}
+
+	c, rec = setupAPIKeyContext(s.T(), http.MethodGet, callback.Path+"?"+query.Encode(), "", false, "", "", s.db)
+	sess, _ := session.Get("session", c)
+	for _, key := range []string{"oidc_state", "oidc_nonce", "oidc_verifier"} {
+		sess.Values[key] = loginSess.Values[key]
+	}
+	s.Require().NoError(p.CallbackHandler(c))
+	return rec, sess
+}
+
+func (s *BaseSuite) TestOIDCLogin() {
+	ctx := context.Background()
+	logger := log.New("test")
+
+	idp := oidctest.NewIdP("greener", "client-secret")
+	defer idp.Close()
+
+	p, err := oidc.NewProvider(ctx, s.db, "Test IdP", oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "greener",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://greener.test/login/oidc/callback",
+		Scopes:       []string{"profile", "groups"},
+		Roles: core.RoleMapping{
+			AdminGroups:  []string{"greener-admins"},
+			EditorGroups: []string{"qa"},
+		},
+	})
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		_, err := s.db.NewDelete().Model((*model_db.User)(nil)).Where("auth_source = ?", oidc.Source).Exec(ctx)
+		s.Require().NoError(err)
+	})
+
+	setUser := func(subject, username string, groups ...string) {
+		idp.SetUser(&oidctest.User{Subject: subject, Claims: map[string]any{
+			"preferred_username": username,
+			"groups":             groups,
+		}})
+	}
+	loadUser := func() model_db.User {
+		var user model_db.User
+		s.Require().NoError(s.db.NewSelect().Model(&user).Where("auth_source = ?", oidc.Source).Where("external_id = ?", "sso-1").Scan(ctx))
+		return user
+	}
+
+	// the first login provisions the user
+	setUser("sso-1", "ssouser", "qa")
+	rec, sess := s.oidcLogin(p, nil)
+	s.Equal(http.StatusFound, rec.Code)
+	s.Equal("/sessions", rec.Header().Get("Location"))
+	s.Equal(true, sess.Values["authenticated"])
+	s.Equal("ssouser", sess.Values["username"])
+	s.Equal(string(model_db.RoleEditor), sess.Values["role"])
+	s.Nil(sess.Values["oidc_state"])
+
+	user := loadUser()
+	s.Equal("ssouser", user.Username)
+	s.Equal(model_db.RoleEditor, user.Role)
+	s.Equal(user.ID.String(), sess.Values["user_id"])
+
+	// later logins update the role from the groups
+	setUser("sso-1", "ssouser", "qa", "greener-admins")
+	rec, sess = s.oidcLogin(p, nil)
+	s.Equal(http.StatusFound, rec.Code)
+	s.Equal(string(model_db.RoleAdmin), sess.Values["role"])
+	s.Equal(model_db.RoleAdmin, loadUser().Role)
+
+	setUser("sso-1", "ssouser", "sales")
+	rec, sess = s.oidcLogin(p, nil)
+	s.Equal(http.StatusForbidden, rec.Code)
+	s.NotEqual(true, sess.Values["authenticated"])
+	s.Equal(model_db.RoleAdmin, loadUser().Role)
+
+	// local users are not taken over
+	setUser("sso-2", "testuser", "qa")
+	rec, _ = s.oidcLogin(p, nil)
+	s.Equal(http.StatusConflict, rec.Code)
+
+	setUser("sso-1", "ssouser", "qa")
+	rec, _ = s.oidcLogin(p, func(q url.Values) { q.Set("state", "forged") })
+	s.Equal(http.StatusBadRequest, rec.Code)
+	rec, _ = s.oidcLogin(p, func(q url.Values) { q.Set("code", "forged") })
+	s.Equal(http.StatusUnauthorized, rec.Code)
+	idp.SetUser(nil)
+	rec, _ = s.oidcLogin(p, nil)
+	s.Equal(http.StatusUnauthorized, rec.Code)
+
+	// provisioned users have no local password
+	c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username=ssouser&password=", false, "", "", s.db)
+	s.Require().NoError(core.LoginHandler(c))
+	s.Equal(http.StatusUnauthorized, rec.Code)
+	user = loadUser()
+	_, err = core.SetUserPassword(ctx, s.db, testSecurity, logger, audit.System, user.ID, "secret")
+	s.Equal(http.StatusBadRequest, httpErrorCode(err))
+
+	disabled := true
+	_, err = core.UpdateUser(ctx, s.db, logger, audit.System, user.ID, core.UserUpdate{Disabled: &disabled})
+	s.Require().NoError(err)
+	setUser("sso-1", "ssouser", "qa")
+	rec, _ = s.oidcLogin(p, nil)
+	s.Equal(http.StatusForbidden, rec.Code)
+
+	entries, err := audit.List(ctx, s.db, audit.Filter{Action: audit.ActionLogin, Actor: "ssouser"})
+	s.Require().NoError(err)
+	var succeeded int
+	for _, entry := range entries {
+		if entry.Outcome == model_db.AuditSuccess {
+			succeeded++
+			s.Require().NotNil(entry.Details)
+			s.Equal(oidc.Source, *entry.Details)
+		}
+	}
+	s.Equal(2, succeeded)
+
+	entries, err = audit.List(ctx, s.db, audit.Filter{Action: audit.ActionUserCreate, Actor: "ssouser"})
+	s.Require().NoError(err)
+	s.Len(entries, 1)
+}
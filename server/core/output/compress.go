@@ -0,0 +1,89 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
+
+func ValidateEncoding(encoding string) error {
+	switch encoding {
+	case EncodingIdentity, EncodingGzip, EncodingZstd:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output encoding: %s", encoding)
+	}
+}
+
+func Compress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingIdentity:
+		return data, nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported output encoding: %s", encoding)
+	}
+}
+
+func Decompress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingIdentity:
+		return data, nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case EncodingZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unsupported output encoding: %s", encoding)
+	}
+}
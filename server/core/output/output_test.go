@@ -0,0 +1,124 @@
+package output
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cephei8/greener/server/core/blob"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("panic: runtime error\n", 100))
+
+	for _, encoding := range []string{EncodingIdentity, EncodingGzip, EncodingZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := Compress(encoding, data)
+			require.NoError(t, err)
+			if encoding != EncodingIdentity {
+				assert.Less(t, len(compressed), len(data))
+			}
+
+			decompressed, err := Decompress(encoding, compressed)
+			require.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+		})
+	}
+
+	_, err := Compress("brotli", data)
+	assert.Error(t, err)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", Truncate("short", 10))
+	assert.Equal(t, "unlimited", Truncate("unlimited", 0))
+
+	s := strings.Repeat("a", 50) + strings.Repeat("b", 50)
+	truncated := Truncate(s, 20)
+	assert.True(t, strings.HasPrefix(truncated, strings.Repeat("a", 10)+"\n\n[... truncated 80 bytes ...]"))
+	assert.True(t, strings.HasSuffix(truncated, "\n\n"+strings.Repeat("b", 10)))
+
+	// multi-byte characters are never split
+	truncated = Truncate(strings.Repeat("é", 20), 5)
+	assert.True(t, strings.HasPrefix(truncated, "é\n\n"))
+	assert.True(t, strings.HasSuffix(truncated, "]\n\né"))
+}
+
+func TestStorageEncode(t *testing.T) {
+	ctx := context.Background()
+	store, err := blob.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	storage, err := NewStorage(Options{
+		Encoding:         EncodingZstd,
+		MaxSize:          1000,
+		Store:            store,
+		OffloadThreshold: 64,
+	})
+	require.NoError(t, err)
+
+	newTestcase := func() *model_db.Testcase {
+		return &model_db.Testcase{
+			ID:        model_db.BinaryUUID(uuid.New()),
+			SessionID: model_db.BinaryUUID(uuid.New()),
+		}
+	}
+
+	small := newTestcase()
+	require.NoError(t, storage.Encode(ctx, small, stringPtr("ok")))
+	assert.NotNil(t, small.OutputData)
+	assert.Nil(t, small.OutputRef)
+	assert.Equal(t, EncodingZstd, *small.OutputEncoding)
+
+	text, err := storage.Load(ctx, small)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", text)
+
+	large := newTestcase()
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		sb.WriteString(uuid.NewString())
+	}
+	require.NoError(t, storage.Encode(ctx, large, stringPtr(sb.String())))
+	assert.Nil(t, large.OutputData)
+	require.NotNil(t, large.OutputRef)
+	assert.True(t, strings.HasPrefix(*large.OutputRef, uuid.UUID(large.SessionID).String()+"/"))
+
+	// output of the same testcase encoded again gets a new blob
+	again := &model_db.Testcase{ID: large.ID, SessionID: large.SessionID}
+	require.NoError(t, storage.Encode(ctx, again, stringPtr(sb.String())))
+	require.NotNil(t, again.OutputRef)
+	assert.NotEqual(t, *large.OutputRef, *again.OutputRef)
+
+	text, err = storage.Load(ctx, large)
+	require.NoError(t, err)
+	assert.Len(t, text, 1000+len("\n\n[... truncated 17000 bytes ...]\n\n"))
+	assert.Contains(t, text, "[... truncated 17000 bytes ...]")
+
+	require.NoError(t, storage.Delete(ctx, []string{*large.OutputRef}))
+	_, err = storage.Load(ctx, large)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+
+	none := newTestcase()
+	require.NoError(t, storage.Encode(ctx, none, nil))
+	assert.Nil(t, none.OutputEncoding)
+	text, err = storage.Load(ctx, none)
+	require.NoError(t, err)
+	assert.Empty(t, text)
+
+	legacy := &model_db.Testcase{Output: stringPtr("plain")}
+	text, err = storage.Load(ctx, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "plain", text)
+
+	_, err = NewStorage(Options{Encoding: "brotli"})
+	assert.Error(t, err)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/cephei8/greener/server/core/blob"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxSize          = 1 << 20
+	DefaultOffloadThreshold = 4 << 10
+)
+
+type Options struct {
+	// Encoding is the compression applied to stored output.
+	Encoding string
+	// MaxSize caps the output size in bytes; longer output is truncated. 0 disables the cap.
+	MaxSize int
+	// Store receives compressed output larger than OffloadThreshold.
+	// Without a store, output is kept in the database.
+	Store            blob.Store
+	OffloadThreshold int
+}
+
+// Storage encodes testcase output for persistence and loads it back.
+type Storage struct {
+	opts Options
+}
+
+func NewStorage(opts Options) (*Storage, error) {
+	if opts.Encoding == "" {
+		opts.Encoding = EncodingGzip
+	}
+	if err := ValidateEncoding(opts.Encoding); err != nil {
+		return nil, err
+	}
+	if opts.MaxSize < 0 {
+		return nil, fmt.Errorf("output max size must be non-negative")
+	}
+	if opts.OffloadThreshold < 0 {
+		return nil, fmt.Errorf("output offload threshold must be non-negative")
+	}
+	return &Storage{opts: opts}, nil
+}
+
+// DefaultStorage keeps gzip-compressed output in the database.
+func DefaultStorage() *Storage {
+	return &Storage{opts: Options{Encoding: EncodingGzip, MaxSize: DefaultMaxSize}}
+}
+
+// blobKey returns a new key for offloaded output of testcase. Keys are generated rather than
+// derived from the testcase ID chosen by the client, so that a request cannot overwrite the
+// output of a stored testcase.
+func blobKey(testcase *model_db.Testcase) string {
+	return uuid.UUID(testcase.SessionID).String() + "/" + uuid.NewString()
+}
+
+// Encode truncates, compresses and stores output, setting the output fields of testcase.
+// The session ID must already be set. Offloaded output is written before the testcase is
+// stored, so it must be deleted with Delete if the testcase is not stored.
+func (s *Storage) Encode(ctx context.Context, testcase *model_db.Testcase, output *string) error {
+	testcase.Output = nil
+	testcase.OutputData = nil
+	testcase.OutputEncoding = nil
+	testcase.OutputRef = nil
+
+	if output == nil {
+		return nil
+	}
+
+	text := Truncate(*output, s.opts.MaxSize)
+	data, err := Compress(s.opts.Encoding, []byte(text))
+	if err != nil {
+		return fmt.Errorf("failed to compress output: %w", err)
+	}
+
+	encoding := s.opts.Encoding
+	testcase.OutputEncoding = &encoding
+
+	if s.opts.Store != nil && len(data) > s.opts.OffloadThreshold {
+		key := blobKey(testcase)
+		if err := s.opts.Store.Put(ctx, key, data); err != nil {
+			return fmt.Errorf("failed to store output: %w", err)
+		}
+		testcase.OutputRef = &key
+		return nil
+	}
+
+	testcase.OutputData = data
+	return nil
+}
+
+// Limit applies the output size cap to a plain-text testcase field such as a stack trace.
+func (s *Storage) Limit(text *string) *string {
+	if text == nil {
+		return nil
+	}
+	limited := Truncate(*text, s.opts.MaxSize)
+	return &limited
+}
+
+// Load returns the output of testcase, fetching it from the blob store if it was offloaded.
+func (s *Storage) Load(ctx context.Context, testcase *model_db.Testcase) (string, error) {
+	if testcase.OutputEncoding == nil {
+		if testcase.Output != nil {
+			return *testcase.Output, nil
+		}
+		return "", nil
+	}
+
+	data := testcase.OutputData
+	if testcase.OutputRef != nil {
+		if s.opts.Store == nil {
+			return "", fmt.Errorf("output is offloaded but no blob store is configured")
+		}
+		var err error
+		data, err = s.opts.Store.Get(ctx, *testcase.OutputRef)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch output: %w", err)
+		}
+	}
+
+	text, err := Decompress(*testcase.OutputEncoding, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress output: %w", err)
+	}
+	return string(text), nil
+}
+
+// Delete removes offloaded blobs. Missing blobs are ignored.
+func (s *Storage) Delete(ctx context.Context, refs []string) error {
+	if s.opts.Store == nil {
+		return nil
+	}
+	for _, ref := range refs {
+		if err := s.opts.Store.Delete(ctx, ref); err != nil {
+			return fmt.Errorf("failed to delete output %s: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+// Truncate shortens s to at most maxSize bytes (plus a marker), keeping its head and tail.
+func Truncate(s string, maxSize int) string {
+	if maxSize <= 0 || len(s) <= maxSize {
+		return s
+	}
+
+	head := maxSize / 2
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	tail := len(s) - (maxSize - head)
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+
+	marker := fmt.Sprintf("\n\n[... truncated %d bytes ...]\n\n", tail-head)
+	return s[:head] + marker + s[tail:]
+}
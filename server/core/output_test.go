@@ -0,0 +1,146 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/blob"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+func (s *BaseSuite) TestTestcaseOutputStorage() {
+	ctx := context.Background()
+
+	storeDir := s.T().TempDir()
+	store, err := blob.NewFSStore(storeDir)
+	s.Require().NoError(err)
+	outputs, err := output.NewStorage(output.Options{
+		Encoding:         output.EncodingZstd,
+		MaxSize:          4096,
+		Store:            store,
+		OffloadThreshold: 256,
+	})
+	s.Require().NoError(err)
+
+	createdAt := time.Now().Add(-96 * time.Hour)
+	sessionID := s.createRetentionSession(ctx, createdAt, map[string]string{"output": "test"})
+	s.purgeOnCleanup(sessionID, retention.Stores{Outputs: outputs})
+
+	var large strings.Builder
+	for i := 0; i < 200; i++ {
+		large.WriteString(uuid.NewString() + "\n")
+	}
+
+	body := `{"testcases": [
+		{"sessionId": "` + uuid.UUID(sessionID).String() + `", "testcaseName": "small", "status": "fail", "output": "assertion failed"},
+		{"sessionId": "` + uuid.UUID(sessionID).String() + `", "testcaseName": "large", "status": "fail", "output": "` + strings.ReplaceAll(large.String(), "\n", `\n`) + `"}
+	]}`
+
+	rec, err := s.sendTestcases(testcasesRequest{Handler: core.NewIngressHandler(s.db, outputs)}, body)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusCreated, rec.Code)
+
+	var testcases []model_db.Testcase
+	err = s.db.NewSelect().
+		Model(&testcases).
+		Where("? = ?", bun.Ident("session_id"), sessionID).
+		Where("? IN (?)", bun.Ident("name"), bun.In([]string{"small", "large"})).
+		OrderExpr("? DESC", bun.Ident("name")).
+		Scan(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(testcases, 2)
+
+	small, offloaded := testcases[0], testcases[1]
+	s.Nil(small.Output)
+	s.NotEmpty(small.OutputData)
+	s.Nil(small.OutputRef)
+	s.Nil(offloaded.OutputData)
+	s.Require().NotNil(offloaded.OutputRef)
+	s.Equal(output.EncodingZstd, *offloaded.OutputEncoding)
+	s.FileExists(filepath.Join(storeDir, *offloaded.OutputRef))
+
+	svc := core.NewQueryServiceWithOutputStorage(s.db, outputs)
+
+	detail, err := svc.GetTestcase(ctx, s.userID, uuid.UUID(small.ID))
+	s.Require().NoError(err)
+	s.Equal("assertion failed", detail.Output)
+
+	detail, err = svc.GetTestcase(ctx, s.userID, uuid.UUID(offloaded.ID))
+	s.Require().NoError(err)
+	s.Contains(detail.Output, "[... truncated ")
+	s.True(strings.HasPrefix(large.String(), detail.Output[:2048]))
+
+	// fixture testcases store plain output
+	_, err = svc.GetTestcase(ctx, s.userID, s.testcase1Id)
+	s.Require().NoError(err)
+
+	report, err := retention.NewPurger(s.db, retention.Policy{MaxAge: 72 * time.Hour}, retention.Stores{Outputs: outputs}).Purge(ctx, false)
+	s.Require().NoError(err)
+	s.Equal(int64(1), report.Sessions)
+	s.Equal(int64(3), report.Testcases)
+
+	_, err = os.Stat(filepath.Join(storeDir, *offloaded.OutputRef))
+	s.True(os.IsNotExist(err))
+}
+
+func (s *BaseSuite) TestTestcaseOutputNotOverwritten() {
+	ctx := context.Background()
+
+	storeDir := s.T().TempDir()
+	store, err := blob.NewFSStore(storeDir)
+	s.Require().NoError(err)
+	outputs, err := output.NewStorage(output.Options{Store: store, OffloadThreshold: 16})
+	s.Require().NoError(err)
+	handler := core.NewIngressHandler(s.db, outputs)
+
+	sessionID := s.createRetentionSession(ctx, time.Now().Add(-96*time.Hour), map[string]string{"output": "overwrite"})
+	sid := uuid.UUID(sessionID).String()
+	s.purgeOnCleanup(sessionID, retention.Stores{Outputs: outputs})
+
+	send := func(body string) error {
+		_, err := s.sendTestcases(testcasesRequest{Handler: handler}, body)
+		return err
+	}
+	testcase := func(id, name, output string) string {
+		return `{"id": "` + id + `", "sessionId": "` + sid + `", "testcaseName": "` + name + `", "status": "fail", "output": "` + output + `"}`
+	}
+	blobs := func() int {
+		var n int
+		err := filepath.WalkDir(storeDir, func(_ string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				n++
+			}
+			return err
+		})
+		s.Require().NoError(err)
+		return n
+	}
+
+	original := strings.Repeat(uuid.NewString(), 20)
+	testcaseID := uuid.New()
+	s.Require().NoError(send(`{"testcases": [` + testcase(testcaseID.String(), "original", original) + `]}`))
+	s.Equal(1, blobs())
+
+	// a request reusing the testcase ID is rejected as a whole and leaves no blobs behind
+	err = send(`{"testcases": [` +
+		testcase(uuid.NewString(), "new", strings.Repeat(uuid.NewString(), 20)) + `,` +
+		testcase(testcaseID.String(), "duplicate", strings.Repeat(uuid.NewString(), 20)) + `]}`)
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusBadRequest, httpErr.Code)
+	s.Equal(1, blobs())
+
+	detail, err := core.NewQueryServiceWithOutputStorage(s.db, outputs).GetTestcase(ctx, s.userID, testcaseID)
+	s.Require().NoError(err)
+	s.Equal(original, detail.Output)
+}
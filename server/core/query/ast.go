@@ -105,6 +105,15 @@ func (FileSelectQuery) isSelectQuery() {}
 
 ////////////////////////////////////////////////////////////
 
+type FailureTypeSelectQuery struct {
+	FailureType string
+	Operator    EqualityOperator
+}
+
+func (FailureTypeSelectQuery) isSelectQuery() {}
+
+////////////////////////////////////////////////////////////
+
 type TestcaseStatus string
 
 const (
@@ -112,6 +121,7 @@ const (
 	StatusFail  TestcaseStatus = "fail"
 	StatusError TestcaseStatus = "error"
 	StatusSkip  TestcaseStatus = "skip"
+	StatusFlaky TestcaseStatus = "flaky"
 )
 
 ////////////////////////////////////////////////////////////
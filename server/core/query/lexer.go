@@ -143,6 +143,8 @@ func (l *queryLexer) Lex(lval *yySymType) int {
 				return TESTSUITE
 			case "file":
 				return FILE
+			case "failure_type":
+				return FAILURE_TYPE
 			case "status":
 				return STATUS
 			case "group_by":
@@ -55,6 +55,7 @@ func TestLexer_Keywords(t *testing.T) {
 		{"classname", "classname", CLASSNAME},
 		{"testsuite", "testsuite", TESTSUITE},
 		{"file", "file", FILE},
+		{"failure_type", "failure_type", FAILURE_TYPE},
 		{"status", "status", STATUS},
 		{"group_by", "group_by", GROUP_BY},
 		{"group", "group", GROUP},
@@ -50,13 +50,14 @@ const NAME = 57360
 const CLASSNAME = 57361
 const TESTSUITE = 57362
 const FILE = 57363
-const STATUS = 57364
-const GROUP_BY = 57365
-const GROUP = 57366
-const OFFSET = 57367
-const LIMIT = 57368
-const START_DATE = 57369
-const END_DATE = 57370
+const FAILURE_TYPE = 57364
+const STATUS = 57365
+const GROUP_BY = 57366
+const GROUP = 57367
+const OFFSET = 57368
+const LIMIT = 57369
+const START_DATE = 57370
+const END_DATE = 57371
 
 var yyToknames = [...]string{
 	"$end",
@@ -80,6 +81,7 @@ var yyToknames = [...]string{
 	"CLASSNAME",
 	"TESTSUITE",
 	"FILE",
+	"FAILURE_TYPE",
 	"STATUS",
 	"GROUP_BY",
 	"GROUP",
@@ -95,7 +97,7 @@ const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line query.y:358
+//line query.y:365
 
 //line yacctab:1
 var yyExca = [...]int8{
@@ -106,69 +108,69 @@ var yyExca = [...]int8{
 
 const yyPrivate = 57344
 
-const yyLast = 74
+const yyLast = 77
 
 var yyAct = [...]int8{
-	62, 21, 38, 39, 32, 33, 34, 35, 65, 54,
-	4, 24, 25, 26, 27, 28, 29, 15, 16, 31,
-	19, 20, 8, 9, 10, 11, 12, 13, 14, 40,
-	64, 73, 48, 72, 67, 63, 66, 22, 23, 55,
-	53, 52, 51, 50, 58, 57, 74, 70, 68, 60,
-	59, 56, 49, 47, 46, 45, 44, 43, 42, 41,
-	30, 17, 2, 1, 69, 61, 37, 36, 71, 18,
-	3, 7, 6, 5,
+	65, 22, 40, 41, 34, 35, 36, 37, 76, 33,
+	75, 25, 26, 27, 28, 29, 30, 31, 16, 17,
+	4, 58, 68, 8, 9, 10, 11, 12, 13, 14,
+	15, 67, 57, 56, 51, 70, 66, 69, 20, 21,
+	42, 23, 24, 55, 54, 53, 61, 60, 77, 73,
+	71, 63, 62, 59, 52, 50, 49, 48, 47, 46,
+	45, 44, 43, 32, 18, 2, 1, 72, 64, 39,
+	38, 74, 19, 3, 7, 6, 5,
 }
 
 var yyPact = [...]int16{
-	6, -1000, -1000, 11, -1000, -1000, -1000, -1000, 30, 30,
-	30, 30, 30, 30, 30, 56, 8, -21, 6, -1000,
-	-1000, 55, -1000, -1000, 54, 53, 52, 51, 50, 49,
-	30, 48, 36, 35, 34, 33, -1000, -1000, -5, 32,
-	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, 47, -1000,
-	39, 38, 46, 45, 19, -6, -1000, -1000, -1000, -1000,
-	-1000, 21, -1000, -1000, 44, 43, -1000, 19, -1000, 18,
-	-1000, -1000, -1000, 42, -1000,
+	7, -1000, -1000, 29, -1000, -1000, -1000, -1000, 34, 34,
+	34, 34, 34, 34, 34, 34, 59, -2, -22, 7,
+	-1000, -1000, 58, -1000, -1000, 57, 56, 55, 54, 53,
+	52, 51, 34, 50, 38, 37, 36, 26, -1000, -1000,
+	18, 14, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, 49, -1000, 41, 40, 48, 47, 20, 8, -1000,
+	-1000, -1000, -1000, -1000, 22, -1000, -1000, 46, 45, -1000,
+	20, -1000, -5, -1000, -1000, -1000, 44, -1000,
 }
 
 var yyPgo = [...]int8{
-	0, 10, 73, 72, 71, 70, 69, 1, 67, 66,
-	0, 65, 64, 63, 62, 61,
+	0, 20, 76, 75, 74, 73, 72, 1, 70, 69,
+	0, 68, 67, 66, 65, 64,
 }
 
 var yyR1 = [...]int8{
 	0, 13, 14, 14, 15, 15, 15, 15, 15, 15,
 	15, 5, 5, 6, 6, 1, 1, 1, 2, 2,
-	2, 2, 2, 2, 2, 3, 3, 3, 4, 7,
-	7, 8, 9, 11, 11, 10, 10, 12, 12,
+	2, 2, 2, 2, 2, 2, 3, 3, 3, 4,
+	7, 7, 8, 9, 11, 11, 10, 10, 12, 12,
 }
 
 var yyR2 = [...]int8{
 	0, 2, 0, 1, 0, 4, 4, 4, 4, 2,
 	2, 1, 3, 1, 1, 1, 1, 1, 3, 3,
-	3, 3, 3, 3, 3, 4, 3, 2, 3, 1,
-	1, 4, 5, 1, 3, 1, 2, 1, 3,
+	3, 3, 3, 3, 3, 3, 4, 3, 2, 3,
+	1, 1, 4, 5, 1, 3, 1, 2, 1, 3,
 }
 
 var yyChk = [...]int16{
 	-1000, -13, -14, -5, -1, -2, -3, -4, 16, 17,
-	18, 19, 20, 21, 22, 11, 12, -15, -6, 9,
-	10, -7, 7, 8, -7, -7, -7, -7, -7, -7,
-	4, 11, 25, 26, 27, 28, -8, -9, 23, 24,
-	-1, 4, 4, 4, 4, 4, 4, 4, -7, 4,
-	7, 7, 7, 7, 14, 7, 4, 6, 6, 4,
-	4, -11, -10, 16, 11, 14, 15, 13, 4, -12,
-	4, -10, 15, 13, 4,
+	18, 19, 20, 21, 22, 23, 11, 12, -15, -6,
+	9, 10, -7, 7, 8, -7, -7, -7, -7, -7,
+	-7, -7, 4, 11, 26, 27, 28, 29, -8, -9,
+	24, 25, -1, 4, 4, 4, 4, 4, 4, 4,
+	4, -7, 4, 7, 7, 7, 7, 14, 7, 4,
+	6, 6, 4, 4, -11, -10, 16, 11, 14, 15,
+	13, 4, -12, 4, -10, 15, 13, 4,
 }
 
 var yyDef = [...]int8{
 	2, -2, 4, 3, 11, 15, 16, 17, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 1, 0, 13,
-	14, 0, 29, 30, 0, 0, 0, 0, 0, 0,
-	27, 0, 0, 0, 0, 0, 9, 10, 0, 0,
-	12, 18, 19, 20, 21, 22, 23, 24, 26, 28,
-	0, 0, 0, 0, 0, 0, 25, 5, 6, 7,
-	8, 0, 33, 35, 0, 0, 31, 0, 36, 0,
-	37, 34, 32, 0, 38,
+	0, 0, 0, 0, 0, 0, 0, 0, 1, 0,
+	13, 14, 0, 30, 31, 0, 0, 0, 0, 0,
+	0, 0, 28, 0, 0, 0, 0, 0, 9, 10,
+	0, 0, 12, 18, 19, 20, 21, 22, 23, 24,
+	25, 27, 29, 0, 0, 0, 0, 0, 0, 26,
+	5, 6, 7, 8, 0, 34, 36, 0, 0, 32,
+	0, 37, 0, 38, 35, 33, 0, 39,
 }
 
 var yyTok1 = [...]int8{
@@ -178,7 +180,7 @@ var yyTok1 = [...]int8{
 var yyTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
-	22, 23, 24, 25, 26, 27, 28,
+	22, 23, 24, 25, 26, 27, 28, 29,
 }
 
 var yyTok3 = [...]int8{
@@ -746,7 +748,16 @@ yydefault:
 		yyDollar = yyS[yypt-3 : yypt+1]
 //line query.y:243
 		{
-			validStatuses := []TestcaseStatus{StatusPass, StatusFail, StatusError, StatusSkip}
+			yyVAL.SelectQuery = FailureTypeSelectQuery{
+				FailureType: yyDollar[3].String,
+				Operator:    yyDollar[2].EqualityOperator,
+			}
+		}
+	case 25:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line query.y:250
+		{
+			validStatuses := []TestcaseStatus{StatusPass, StatusFail, StatusError, StatusSkip, StatusFlaky}
 			var status TestcaseStatus
 			isValid := false
 			for _, s := range validStatuses {
@@ -757,7 +768,7 @@ yydefault:
 				}
 			}
 			if !isValid {
-				yylex.Error(fmt.Sprintf("invalid status: %s (expected: pass, fail, error, skip)", yyDollar[3].String))
+				yylex.Error(fmt.Sprintf("invalid status: %s (expected: pass, fail, error, skip, flaky)", yyDollar[3].String))
 				return 1
 			}
 			yyVAL.SelectQuery = StatusSelectQuery{
@@ -765,9 +776,9 @@ yydefault:
 				Operator: yyDollar[2].EqualityOperator,
 			}
 		}
-	case 25:
+	case 26:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line query.y:267
+//line query.y:274
 		{
 			yyVAL.SelectQuery = TagValueSelectQuery{
 				Tag:      yyDollar[2].String,
@@ -775,90 +786,90 @@ yydefault:
 				Operator: yyDollar[3].EqualityOperator,
 			}
 		}
-	case 26:
+	case 27:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line query.y:275
+//line query.y:282
 		{
 			yylex.Error(fmt.Sprintf("expected value after equality operator for tag %s", yyDollar[2].String))
 			return 1
 		}
-	case 27:
+	case 28:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line query.y:280
+//line query.y:287
 		{
 			yyVAL.SelectQuery = TagSelectQuery{
 				Tag:      yyDollar[2].String,
 				Operator: OpEq,
 			}
 		}
-	case 28:
+	case 29:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line query.y:290
+//line query.y:297
 		{
 			yyVAL.SelectQuery = TagSelectQuery{
 				Tag:      yyDollar[3].String,
 				Operator: OpNEq,
 			}
 		}
-	case 29:
+	case 30:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line query.y:300
+//line query.y:307
 		{
 			yyVAL.EqualityOperator = OpEq
 		}
-	case 30:
+	case 31:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line query.y:304
+//line query.y:311
 		{
 			yyVAL.EqualityOperator = OpNEq
 		}
-	case 31:
+	case 32:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line query.y:311
+//line query.y:318
 		{
 			yyVAL.GroupQuery = GroupQuery{
 				Tokens: yyDollar[3].GroupTokens,
 			}
 		}
-	case 32:
+	case 33:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line query.y:320
+//line query.y:327
 		{
 			yyVAL.GroupSelector = yyDollar[4].Strings
 		}
-	case 33:
+	case 34:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line query.y:327
+//line query.y:334
 		{
 			yyVAL.GroupTokens = []GroupToken{yyDollar[1].GroupToken}
 		}
-	case 34:
+	case 35:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line query.y:331
+//line query.y:338
 		{
 			yyVAL.GroupTokens = append(yyDollar[1].GroupTokens, yyDollar[3].GroupToken)
 		}
-	case 35:
+	case 36:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line query.y:338
+//line query.y:345
 		{
 			yyVAL.GroupToken = SessionGroupToken{}
 		}
-	case 36:
+	case 37:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line query.y:342
+//line query.y:349
 		{
 			yyVAL.GroupToken = TagGroupToken{Tag: yyDollar[2].String}
 		}
-	case 37:
+	case 38:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line query.y:349
+//line query.y:356
 		{
 			yyVAL.Strings = []string{yyDollar[1].String}
 		}
-	case 38:
+	case 39:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line query.y:353
+//line query.y:360
 		{
 			yyVAL.Strings = append(yyDollar[1].Strings, yyDollar[3].String)
 		}
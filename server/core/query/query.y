@@ -31,7 +31,7 @@ const dateFormat = "2006/01/02 15:04:05"
 %token EQUALS NOTEQUALS
 %token AND OR
 %token HASH BANG COMMA LPAREN RPAREN
-%token SESSION_ID ID NAME CLASSNAME TESTSUITE FILE STATUS GROUP_BY GROUP OFFSET LIMIT START_DATE END_DATE
+%token SESSION_ID ID NAME CLASSNAME TESTSUITE FILE FAILURE_TYPE STATUS GROUP_BY GROUP OFFSET LIMIT START_DATE END_DATE
 
 %type <SelectQuery> atomic_query field_query tag_query not_tag_query
 %type <CompoundSelectQuery> compound_query
@@ -239,9 +239,16 @@ field_query:
 			Operator: $2,
 		}
 	}
+	| FAILURE_TYPE equality_op STRING
+	{
+		$$ = FailureTypeSelectQuery{
+			FailureType: $3,
+			Operator:    $2,
+		}
+	}
 	| STATUS equality_op STRING
 	{
-		validStatuses := []TestcaseStatus{StatusPass, StatusFail, StatusError, StatusSkip}
+		validStatuses := []TestcaseStatus{StatusPass, StatusFail, StatusError, StatusSkip, StatusFlaky}
 		var status TestcaseStatus
 		isValid := false
 		for _, s := range validStatuses {
@@ -252,7 +259,7 @@ field_query:
 			}
 		}
 		if !isValid {
-			yylex.Error(fmt.Sprintf("invalid status: %s (expected: pass, fail, error, skip)", $3))
+			yylex.Error(fmt.Sprintf("invalid status: %s (expected: pass, fail, error, skip, flaky)", $3))
 			return 1
 		}
 		$$ = StatusSelectQuery{
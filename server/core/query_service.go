@@ -5,12 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/cephei8/greener/server/core/metrics"
 	model_api "github.com/cephei8/greener/server/core/model/api"
 	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
 	"github.com/cephei8/greener/server/core/query"
+	"github.com/cephei8/greener/server/core/tracing"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type QueryServiceInterface interface {
@@ -22,11 +28,16 @@ type QueryServiceInterface interface {
 }
 
 type QueryService struct {
-	db *bun.DB
+	db      *bun.DB
+	outputs *output.Storage
 }
 
 func NewQueryService(db *bun.DB) *QueryService {
-	return &QueryService{db: db}
+	return NewQueryServiceWithOutputStorage(db, output.DefaultStorage())
+}
+
+func NewQueryServiceWithOutputStorage(db *bun.DB, outputs *output.Storage) *QueryService {
+	return &QueryService{db: db, outputs: outputs}
 }
 
 type QueryParams struct {
@@ -41,17 +52,26 @@ type QueryResult[T any] struct {
 }
 
 type TestcaseDetail struct {
-	ID        string
-	SessionID string
-	Name      string
-	Status    string
-	Classname string
-	File      string
-	Testsuite string
-	Output    string
-	Baggage   any
-	Labels    map[string]string
-	CreatedAt string
+	ID             string
+	SessionID      string
+	Name           string
+	Status         string
+	Classname      string
+	File           string
+	Testsuite      string
+	Output         string
+	FailureMessage string
+	FailureType    string
+	StackTrace     string
+	Stdout         string
+	Stderr         string
+	ParentID       string
+	Attempt        int
+	Attempts       []TestcaseAttempt
+	Baggage        any
+	Labels         map[string]string
+	Attachments    []AttachmentInfo
+	CreatedAt      string
 }
 
 type SessionDetail struct {
@@ -60,10 +80,62 @@ type SessionDetail struct {
 	Status      string
 	Baggage     any
 	Labels      map[string]string
-	CreatedAt   string
+	Attachments []AttachmentInfo
+	Changes     []SessionChangeInfo
+	// Testcases holds the session's testcases nested by parent, capped at sessionTestcaseLimit.
+	Testcases          []*TestcaseNode
+	TestcasesTruncated bool
+	CreatedAt          string
+}
+
+const sessionTestcaseLimit = 1000
+
+type SessionChangeInfo struct {
+	Field     string
+	Key       string
+	OldValue  *string
+	NewValue  *string
+	ChangedBy string
+	CreatedAt string
+}
+
+// TestcaseAttempt is one run of a retried testcase.
+type TestcaseAttempt struct {
+	ID      string
+	Attempt int
+	Status  string
+	Current bool
+}
+
+type AttachmentInfo struct {
+	ID          string
+	Name        string
+	ContentType string
+	Size        int64
+	URL         string
+}
+
+// IsImage reports whether the attachment can be previewed inline.
+func (a AttachmentInfo) IsImage() bool {
+	return IsInlineImage(a.ContentType)
+}
+
+// IsInlineImage reports whether content of the given type is safe to display inline.
+func IsInlineImage(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	default:
+		return false
+	}
 }
 
 func (s *QueryService) QueryTestcases(ctx context.Context, userID model_db.BinaryUUID, params QueryParams) (*QueryResult[model_api.Testcase], error) {
+	defer metrics.ObserveQuery("testcases", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "QueryService.QueryTestcases", trace.WithAttributes(attribute.String("greener.query", params.Query)))
+	defer span.End()
+
 	var queryAST query.Query
 
 	if params.Query != "" {
@@ -87,8 +159,11 @@ func (s *QueryService) QueryTestcases(ctx context.Context, userID model_db.Binar
 		queryAST.Limit = params.Limit
 	}
 
-	q, err := BuildTestcasesQuery(s.db, userID, queryAST)
+	q, err := traceBuild(ctx, "BuildTestcasesQuery", func() (*bun.SelectQuery, error) {
+		return BuildTestcasesQuery(s.db, userID, queryAST)
+	})
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
@@ -100,8 +175,10 @@ func (s *QueryService) QueryTestcases(ctx context.Context, userID model_db.Binar
 
 	var results []dbResult
 	if err := q.Scan(ctx, &results); err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
+	span.SetAttributes(attribute.Int("greener.rows", len(results)))
 
 	testcases := []model_api.Testcase{}
 	totalCount := 0
@@ -130,6 +207,11 @@ func (s *QueryService) QueryTestcases(ctx context.Context, userID model_db.Binar
 }
 
 func (s *QueryService) QuerySessions(ctx context.Context, userID model_db.BinaryUUID, params QueryParams) (*QueryResult[model_api.Session], error) {
+	defer metrics.ObserveQuery("sessions", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "QueryService.QuerySessions", trace.WithAttributes(attribute.String("greener.query", params.Query)))
+	defer span.End()
+
 	var queryAST query.Query
 
 	if params.Query != "" {
@@ -153,8 +235,11 @@ func (s *QueryService) QuerySessions(ctx context.Context, userID model_db.Binary
 		queryAST.Limit = params.Limit
 	}
 
-	q, err := BuildSessionsQuery(s.db, userID, queryAST)
+	q, err := traceBuild(ctx, "BuildSessionsQuery", func() (*bun.SelectQuery, error) {
+		return BuildSessionsQuery(s.db, userID, queryAST)
+	})
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
@@ -166,8 +251,10 @@ func (s *QueryService) QuerySessions(ctx context.Context, userID model_db.Binary
 
 	var results []dbResult
 	if err := q.Scan(ctx, &results); err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
+	span.SetAttributes(attribute.Int("greener.rows", len(results)))
 
 	sessions := []model_api.Session{}
 	totalCount := 0
@@ -199,6 +286,11 @@ func (s *QueryService) QuerySessions(ctx context.Context, userID model_db.Binary
 }
 
 func (s *QueryService) QueryGroups(ctx context.Context, userID model_db.BinaryUUID, params QueryParams) (*QueryResult[model_api.Group], error) {
+	defer metrics.ObserveQuery("groups", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "QueryService.QueryGroups", trace.WithAttributes(attribute.String("greener.query", params.Query)))
+	defer span.End()
+
 	if params.Query == "" {
 		return nil, fmt.Errorf("query is required for group queries")
 	}
@@ -248,13 +340,17 @@ func (s *QueryService) QueryGroups(ctx context.Context, userID model_db.BinaryUU
 		}
 	}
 
-	q, err := BuildGroupsQuery(s.db, userID, queryAST, queryAST.GroupQuery)
+	q, err := traceBuild(ctx, "BuildGroupsQuery", func() (*bun.SelectQuery, error) {
+		return BuildGroupsQuery(s.db, userID, queryAST, queryAST.GroupQuery)
+	})
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
 	rows, err := q.Rows(ctx)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
 	defer rows.Close()
@@ -306,6 +402,7 @@ func (s *QueryService) QueryGroups(ctx context.Context, userID model_db.BinaryUU
 	if totalCount == 0 {
 		totalCount = len(groups)
 	}
+	span.SetAttributes(attribute.Int("greener.rows", len(groups)))
 
 	return &QueryResult[model_api.Group]{
 		Results:    groups,
@@ -313,10 +410,30 @@ func (s *QueryService) QueryGroups(ctx context.Context, userID model_db.BinaryUU
 	}, nil
 }
 
+func traceBuild(ctx context.Context, name string, build func() (*bun.SelectQuery, error)) (*bun.SelectQuery, error) {
+	_, span := tracing.Tracer().Start(ctx, name)
+	defer span.End()
+
+	q, err := build()
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(tracing.StatementAttribute(q))
+
+	return q, nil
+}
+
 func (s *QueryService) GetTestcase(ctx context.Context, userID model_db.BinaryUUID, testcaseID uuid.UUID) (*TestcaseDetail, error) {
+	defer metrics.ObserveQuery("testcase", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "QueryService.GetTestcase", trace.WithAttributes(attribute.String("greener.testcase_id", testcaseID.String())))
+	defer span.End()
+
 	var testcase model_db.Testcase
 	err := s.db.NewSelect().
 		Model(&testcase).
+		ExcludeColumn("output", "output_data").
 		Where("? = ?", bun.Ident("id"), model_db.BinaryUUID(testcaseID)).
 		Scan(ctx)
 
@@ -332,6 +449,7 @@ func (s *QueryService) GetTestcase(ctx context.Context, userID model_db.BinaryUU
 		SessionID: sessionIDStr.String(),
 		Name:      testcase.Name,
 		Status:    TestcaseStatusToString(testcase.Status),
+		Attempt:   testcase.Attempt,
 		CreatedAt: testcase.CreatedAt.Format("2006-01-02 15:04:05"),
 	}
 
@@ -344,9 +462,42 @@ func (s *QueryService) GetTestcase(ctx context.Context, userID model_db.BinaryUU
 	if testcase.Testsuite != nil {
 		result.Testsuite = *testcase.Testsuite
 	}
-	if testcase.Output != nil {
-		result.Output = *testcase.Output
+	if testcase.ParentID != nil {
+		result.ParentID = testcase.ParentID.String()
+	}
+	if testcase.FailureMessage != nil {
+		result.FailureMessage = *testcase.FailureMessage
+	}
+	if testcase.FailureType != nil {
+		result.FailureType = *testcase.FailureType
 	}
+	if testcase.StackTrace != nil {
+		result.StackTrace = *testcase.StackTrace
+	}
+	if testcase.Stdout != nil {
+		result.Stdout = *testcase.Stdout
+	}
+	if testcase.Stderr != nil {
+		result.Stderr = *testcase.Stderr
+	}
+
+	if testcase.OutputRef == nil {
+		err = s.db.NewSelect().
+			Model(&testcase).
+			Column("output", "output_data").
+			Where("? = ?", bun.Ident("id"), testcase.ID).
+			Scan(ctx)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, fmt.Errorf("failed to fetch output: %w", err)
+		}
+	}
+	result.Output, err = s.outputs.Load(ctx, &testcase)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
 	if testcase.Baggage != nil {
 		var baggage any
 		if err := json.Unmarshal(testcase.Baggage, &baggage); err == nil {
@@ -372,10 +523,31 @@ func (s *QueryService) GetTestcase(ctx context.Context, userID model_db.BinaryUU
 		}
 	}
 
+	result.Attachments, err = s.listAttachments(ctx, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("? = ?", bun.Ident("testcase_id"), testcase.ID)
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	if testcase.Attempt > 1 || testcase.Superseded {
+		result.Attempts, err = s.listAttempts(ctx, &testcase)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+	}
+
 	return result, nil
 }
 
 func (s *QueryService) GetSession(ctx context.Context, userID model_db.BinaryUUID, sessionID uuid.UUID) (*SessionDetail, error) {
+	defer metrics.ObserveQuery("session", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "QueryService.GetSession", trace.WithAttributes(attribute.String("greener.session_id", sessionID.String())))
+	defer span.End()
+
 	type SessionWithStatus struct {
 		model_db.Session
 		AggregatedStatus *int64 `bun:"aggregated_status"`
@@ -386,7 +558,7 @@ func (s *QueryService) GetSession(ctx context.Context, userID model_db.BinaryUUI
 		TableExpr("?", bun.Ident("sessions")).
 		ColumnExpr("?.*", bun.Ident("sessions")).
 		ColumnExpr("MIN(?) AS ?", bun.Ident("testcases.status"), bun.Ident("aggregated_status")).
-		Join("LEFT JOIN ? ON ? = ?", bun.Ident("testcases"), bun.Ident("sessions.id"), bun.Ident("testcases.session_id")).
+		Join("LEFT JOIN ? ON ? = ? AND ? = ?", bun.Ident("testcases"), bun.Ident("sessions.id"), bun.Ident("testcases.session_id"), bun.Ident("testcases.superseded"), false).
 		Where("? = ?", bun.Ident("sessions.id"), model_db.BinaryUUID(sessionID)).
 		Group("sessions.id").
 		Scan(ctx, &sessionData)
@@ -437,5 +609,151 @@ func (s *QueryService) GetSession(ctx context.Context, userID model_db.BinaryUUI
 		}
 	}
 
+	result.Attachments, err = s.listAttachments(ctx, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Where("? = ?", bun.Ident("session_id"), model_db.BinaryUUID(sessionID)).
+			Where("? IS NULL", bun.Ident("testcase_id"))
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	result.Changes, err = s.listSessionChanges(ctx, model_db.BinaryUUID(sessionID))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	result.Testcases, result.TestcasesTruncated, err = s.sessionTestcaseTree(ctx, model_db.BinaryUUID(sessionID))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *QueryService) listAttachments(ctx context.Context, filter func(*bun.SelectQuery) *bun.SelectQuery) ([]AttachmentInfo, error) {
+	var attachments []model_db.Attachment
+	err := filter(s.db.NewSelect().Model(&attachments)).
+		OrderBy("created_at", bun.OrderAsc).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments: %w", err)
+	}
+
+	result := make([]AttachmentInfo, 0, len(attachments))
+	for _, a := range attachments {
+		id := a.ID.String()
+		result = append(result, AttachmentInfo{
+			ID:          id,
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			URL:         "/attachments/" + id,
+		})
+	}
+	return result, nil
+}
+
+func (s *QueryService) sessionTestcaseTree(ctx context.Context, sessionID model_db.BinaryUUID) ([]*TestcaseNode, bool, error) {
+	var testcases []model_db.Testcase
+	err := s.db.NewSelect().
+		Model(&testcases).
+		Column("id", "parent_id", "name", "status").
+		Where("? = ?", bun.Ident("session_id"), sessionID).
+		Where("? = ?", bun.Ident("superseded"), false).
+		OrderExpr("? ASC, ? ASC", bun.Ident("created_at"), bun.Ident("name")).
+		Limit(sessionTestcaseLimit + 1).
+		Scan(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch testcases: %w", err)
+	}
+
+	truncated := len(testcases) > sessionTestcaseLimit
+	if truncated {
+		testcases = testcases[:sessionTestcaseLimit]
+	}
+	return buildTestcaseTree(testcases), truncated, nil
+}
+
+// listAttempts returns all attempts of the testcase within its session, oldest first.
+func (s *QueryService) listAttempts(ctx context.Context, testcase *model_db.Testcase) ([]TestcaseAttempt, error) {
+	var attempts []model_db.Testcase
+	q := s.db.NewSelect().
+		Model(&attempts).
+		Column("id", "status", "attempt").
+		Where("? = ?", bun.Ident("session_id"), testcase.SessionID).
+		Where("? = ?", bun.Ident("name"), testcase.Name)
+	q = whereNullableEq(q, "classname", testcase.Classname)
+	q = whereNullableEq(q, "testsuite", testcase.Testsuite)
+	q = whereNullableEq(q, "file", testcase.File)
+
+	err := q.OrderExpr("? ASC, ? ASC", bun.Ident("attempt"), bun.Ident("created_at")).Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attempts: %w", err)
+	}
+
+	result := make([]TestcaseAttempt, 0, len(attempts))
+	for _, a := range attempts {
+		result = append(result, TestcaseAttempt{
+			ID:      a.ID.String(),
+			Attempt: a.Attempt,
+			Status:  TestcaseStatusToString(a.Status),
+			Current: a.ID == testcase.ID,
+		})
+	}
+	return result, nil
+}
+
+func (s *QueryService) listSessionChanges(ctx context.Context, sessionID model_db.BinaryUUID) ([]SessionChangeInfo, error) {
+	type changeRow struct {
+		model_db.SessionChange
+		Username          *string `bun:"username"`
+		APIKeyDescription *string `bun:"api_key_description"`
+	}
+
+	var rows []changeRow
+	err := s.db.NewSelect().
+		TableExpr("? AS ?", bun.Ident("session_changes"), bun.Ident("session_change")).
+		ColumnExpr("?.*", bun.Ident("session_change")).
+		ColumnExpr("? AS ?", bun.Ident("users.username"), bun.Ident("username")).
+		ColumnExpr("? AS ?", bun.Ident("apikeys.description"), bun.Ident("api_key_description")).
+		Join("LEFT JOIN ? ON ? = ?", bun.Ident("users"), bun.Ident("users.id"), bun.Ident("session_change.user_id")).
+		Join("LEFT JOIN ? ON ? = ?", bun.Ident("apikeys"), bun.Ident("apikeys.id"), bun.Ident("session_change.api_key_id")).
+		Where("? = ?", bun.Ident("session_change.session_id"), sessionID).
+		OrderExpr("? DESC", bun.Ident("session_change.id")).
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch session changes: %w", err)
+	}
+
+	result := make([]SessionChangeInfo, 0, len(rows))
+	for _, row := range rows {
+		changedBy := row.UserID.String()
+		if row.Username != nil {
+			changedBy = *row.Username
+		}
+		if row.APIKeyID != nil {
+			apiKey := row.APIKeyID.String()
+			if row.APIKeyDescription != nil && *row.APIKeyDescription != "" {
+				apiKey = *row.APIKeyDescription
+			}
+			changedBy += " (API key: " + apiKey + ")"
+		}
+
+		info := SessionChangeInfo{
+			Field:     string(row.Field),
+			OldValue:  row.OldValue,
+			NewValue:  row.NewValue,
+			ChangedBy: changedBy,
+			CreatedAt: row.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if row.Key != nil {
+			info.Key = *row.Key
+		}
+		result = append(result, info)
+	}
 	return result, nil
 }
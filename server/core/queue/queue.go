@@ -0,0 +1,417 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cephei8/greener/server/core/metrics"
+)
+
+const (
+	DefaultWorkers          = 4
+	DefaultBatchSize        = 100
+	DefaultMinRetryInterval = time.Second
+	DefaultMaxRetryInterval = time.Minute
+
+	entrySuffix  = ".entry"
+	tmpSuffix    = ".tmp"
+	brokenSuffix = ".broken"
+)
+
+var ErrClosed = errors.New("queue is closed")
+
+// Entry is a journaled request. Entries with the same Key are applied in the order they were enqueued.
+type Entry struct {
+	Seq  uint64
+	Key  string
+	Data []byte
+}
+
+// ApplyFunc applies a batch of entries. An error means the batch could not be applied for now
+// and is retried later in full, so applying an entry must be idempotent.
+type ApplyFunc func(ctx context.Context, entries []Entry) error
+
+type Options struct {
+	Dir              string
+	Workers          int
+	BatchSize        int
+	MinRetryInterval time.Duration
+	MaxRetryInterval time.Duration
+}
+
+// Queue is a durable queue of entries applied by worker goroutines. Every entry is written to
+// its own file in Dir and synced before Enqueue returns, and the file is removed once the entry
+// is applied, so entries that were not applied when the server stopped are applied after a restart.
+type Queue struct {
+	opts  Options
+	apply ApplyFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// enqueueMu serializes Enqueue per worker, so that entries are pending in seq order
+	enqueueMu []sync.Mutex
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	seq     uint64
+	pending [][]pendingEntry
+	depth   int
+	// keys counts the pending entries per key
+	keys   map[string]int
+	closed bool
+}
+
+type pendingEntry struct {
+	seq uint64
+	key string
+}
+
+// Open opens the queue in opts.Dir, creating the directory if needed, and starts its workers.
+// Entries left in the directory are applied first.
+func Open(opts Options, apply ApplyFunc) (*Queue, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MinRetryInterval <= 0 {
+		opts.MinRetryInterval = DefaultMinRetryInterval
+	}
+	if opts.MaxRetryInterval < opts.MinRetryInterval {
+		opts.MaxRetryInterval = max(DefaultMaxRetryInterval, opts.MinRetryInterval)
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &Queue{
+		opts:      opts,
+		apply:     apply,
+		pending:   make([][]pendingEntry, opts.Workers),
+		keys:      make(map[string]int),
+		enqueueMu: make([]sync.Mutex, opts.Workers),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	for i := range opts.Workers {
+		q.wg.Add(1)
+		go q.work(i)
+	}
+	return q, nil
+}
+
+// recover loads the entries left in the queue directory by a previous run. Broken entries are
+// set aside, like they are when workers read them.
+func (q *Queue) recover() error {
+	files, err := os.ReadDir(q.opts.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read queue directory: %w", err)
+	}
+
+	var entries []pendingEntry
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasSuffix(name, tmpSuffix) {
+			// an entry that was never acknowledged
+			if err := os.Remove(filepath.Join(q.opts.Dir, name)); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+			continue
+		}
+		seqStr, ok := strings.CutSuffix(name, entrySuffix)
+		if !ok {
+			continue
+		}
+		seq, err := strconv.ParseUint(seqStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		q.seq = max(q.seq, seq)
+		entry, err := q.read(seq)
+		if err != nil {
+			q.setAside(seq, err)
+			continue
+		}
+		entries = append(entries, pendingEntry{seq: seq, key: entry.Key})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	for _, entry := range entries {
+		q.push(entry)
+	}
+	if len(entries) > 0 {
+		log.Printf("queue: recovered %d entries", len(entries))
+	}
+	return nil
+}
+
+// Enqueue journals an entry. Once it returns without error, the entry is applied eventually,
+// even if the server stops before.
+func (q *Queue) Enqueue(key string, data []byte) error {
+	// entries with the same key belong to the same worker; as long as one of its entries is
+	// written, the next one cannot get a seq, so it cannot be pushed before
+	worker := q.worker(key)
+	q.enqueueMu[worker].Lock()
+	defer q.enqueueMu[worker].Unlock()
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	q.seq++
+	seq := q.seq
+	q.mu.Unlock()
+
+	if err := q.write(seq, key, data); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.push(pendingEntry{seq: seq, key: key})
+	q.mu.Unlock()
+	return nil
+}
+
+// worker returns the worker applying the entries with key.
+func (q *Queue) worker(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(q.pending)))
+}
+
+// push adds an entry to the pending entries of its worker. The caller holds q.mu.
+func (q *Queue) push(entry pendingEntry) {
+	worker := q.worker(entry.key)
+	q.pending[worker] = append(q.pending[worker], entry)
+	q.depth++
+	q.keys[entry.key]++
+	q.cond.Broadcast()
+}
+
+// Wait waits until no entries with key are pending, or ctx is done. It returns ErrClosed if
+// the workers stop before the entries are applied.
+func (q *Queue) Wait(ctx context.Context, key string) error {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	defer stop()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.keys[key] > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if q.ctx.Err() != nil {
+			return ErrClosed
+		}
+		q.cond.Wait()
+	}
+	return nil
+}
+
+// Depth returns the number of entries that are not applied yet.
+func (q *Queue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depth
+}
+
+// Drain stops accepting entries and waits until the pending entries are applied.
+// If ctx is done first, the workers are stopped and the remaining entries are left
+// on disk for the next start.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+		<-done
+		return fmt.Errorf("%d entries left: %w", q.Depth(), ctx.Err())
+	}
+}
+
+func (q *Queue) work(worker int) {
+	defer q.wg.Done()
+	for {
+		batch, n, ok := q.next(worker)
+		if !ok {
+			return
+		}
+		if len(batch) > 0 && !q.applyBatch(batch) {
+			return
+		}
+
+		for _, entry := range batch {
+			if err := os.Remove(q.path(entry.Seq)); err != nil {
+				log.Printf("queue: failed to remove applied entry %d: %v", entry.Seq, err)
+			}
+		}
+
+		q.mu.Lock()
+		for _, entry := range q.pending[worker][:n] {
+			if q.keys[entry.key]--; q.keys[entry.key] == 0 {
+				delete(q.keys, entry.key)
+			}
+		}
+		q.pending[worker] = q.pending[worker][n:]
+		q.depth -= n
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// next waits for pending entries of worker and reads up to a batch of them, along with the
+// number of pending entries it took, including broken ones it set aside.
+// It returns false when the queue is drained or stopped.
+func (q *Queue) next(worker int) ([]Entry, int, bool) {
+	q.mu.Lock()
+	for len(q.pending[worker]) == 0 && !q.closed && q.ctx.Err() == nil {
+		q.cond.Wait()
+	}
+	if len(q.pending[worker]) == 0 || q.ctx.Err() != nil {
+		q.mu.Unlock()
+		return nil, 0, false
+	}
+	pending := q.pending[worker][:min(len(q.pending[worker]), q.opts.BatchSize)]
+	pending = append([]pendingEntry(nil), pending...)
+	q.mu.Unlock()
+
+	batch := make([]Entry, 0, len(pending))
+	for _, p := range pending {
+		entry, err := q.read(p.seq)
+		if err != nil {
+			q.setAside(p.seq, err)
+			continue
+		}
+		batch = append(batch, entry)
+	}
+	return batch, len(pending), true
+}
+
+// setAside renames the file of a broken entry, which cannot be applied, so that it is kept
+// for inspection but not read again.
+func (q *Queue) setAside(seq uint64, err error) {
+	log.Printf("queue: skipping broken entry: %v", err)
+	path := q.path(seq)
+	if err := os.Rename(path, path+brokenSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("queue: failed to set aside broken entry %d: %v", seq, err)
+	}
+}
+
+// applyBatch applies batch, retrying with exponential backoff until it succeeds.
+// It returns false if the queue was stopped before.
+func (q *Queue) applyBatch(batch []Entry) bool {
+	interval := q.opts.MinRetryInterval
+	for {
+		err := q.apply(q.ctx, batch)
+		if err == nil {
+			metrics.IngressQueueAppliedTotal.Add(float64(len(batch)))
+			return true
+		}
+		if q.ctx.Err() != nil {
+			return false
+		}
+
+		metrics.IngressQueueRetriesTotal.Inc()
+		log.Printf("queue: failed to apply %d entries, retrying in %s: %v", len(batch), interval, err)
+		select {
+		case <-time.After(interval):
+		case <-q.ctx.Done():
+			return false
+		}
+		interval = min(interval*2, q.opts.MaxRetryInterval)
+	}
+}
+
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.opts.Dir, fmt.Sprintf("%020d%s", seq, entrySuffix))
+}
+
+// write stores an entry as the key, a newline and the data. The file is written under
+// a temporary name and renamed when synced, so a crash never leaves a partial entry.
+func (q *Queue) write(seq uint64, key string, data []byte) error {
+	path := q.path(seq)
+	tmp := path + tmpSuffix
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create queue entry: %w", err)
+	}
+	_, err = f.Write(append(append([]byte(key), '\n'), data...))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err == nil {
+		err = syncDir(q.opts.Dir)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) read(seq uint64) (Entry, error) {
+	content, err := os.ReadFile(q.path(seq))
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read queue entry %d: %w", seq, err)
+	}
+	key, data, ok := bytes.Cut(content, []byte("\n"))
+	if !ok {
+		return Entry{}, fmt.Errorf("invalid queue entry %d", seq)
+	}
+	return Entry{Seq: seq, Key: string(key), Data: data}, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,206 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is an ApplyFunc that records applied entries and fails while failing is set.
+type recorder struct {
+	mu      sync.Mutex
+	applied map[string][]string
+	failing bool
+	calls   int
+}
+
+func (r *recorder) apply(ctx context.Context, entries []Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.failing {
+		return errors.New("database is down")
+	}
+	if r.applied == nil {
+		r.applied = make(map[string][]string)
+	}
+	for _, entry := range entries {
+		r.applied[entry.Key] = append(r.applied[entry.Key], string(entry.Data))
+	}
+	return nil
+}
+
+func (r *recorder) setFailing(failing bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failing = failing
+}
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	r := &recorder{}
+	q, err := Open(Options{Dir: dir, Workers: 3, BatchSize: 2, MinRetryInterval: time.Millisecond}, r.apply)
+	require.NoError(t, err)
+
+	for _, data := range []string{"1", "2", "3", "4", "5"} {
+		require.NoError(t, q.Enqueue("a", []byte(data)))
+		require.NoError(t, q.Enqueue("b", []byte("b"+data)))
+	}
+
+	require.NoError(t, q.Drain(context.Background()))
+	assert.Equal(t, map[string][]string{
+		"a": {"1", "2", "3", "4", "5"},
+		"b": {"b1", "b2", "b3", "b4", "b5"},
+	}, r.applied)
+	assert.Equal(t, 0, q.Depth())
+	assert.ErrorIs(t, q.Enqueue("a", []byte("6")), ErrClosed)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestQueueRetry(t *testing.T) {
+	r := &recorder{failing: true}
+	q, err := Open(Options{Dir: t.TempDir(), Workers: 1, MinRetryInterval: time.Millisecond, MaxRetryInterval: 5 * time.Millisecond}, r.apply)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Enqueue("a", []byte("1")))
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.calls >= 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1, q.Depth())
+
+	r.setFailing(false)
+	require.NoError(t, q.Drain(context.Background()))
+	assert.Equal(t, []string{"1"}, r.applied["a"])
+}
+
+func TestQueueRecover(t *testing.T) {
+	dir := t.TempDir()
+	r := &recorder{failing: true}
+	q, err := Open(Options{Dir: dir, Workers: 2, MinRetryInterval: time.Millisecond}, r.apply)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Enqueue("a", []byte("1")))
+	require.NoError(t, q.Enqueue("b", []byte("2")))
+	require.NoError(t, q.Enqueue("a", []byte("3")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Drain(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 3, q.Depth())
+
+	// an entry that was being written when the server stopped
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000004.entry.tmp"), []byte("a\n4"), 0o644))
+
+	r = &recorder{}
+	q, err = Open(Options{Dir: dir, Workers: 2}, r.apply)
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue("a", []byte("5")))
+	require.NoError(t, q.Drain(context.Background()))
+	assert.Equal(t, map[string][]string{"a": {"1", "3", "5"}, "b": {"2"}}, r.applied)
+}
+
+func TestQueueConcurrentOrder(t *testing.T) {
+	var mu sync.Mutex
+	var applied []uint64
+	q, err := Open(Options{Dir: t.TempDir(), Workers: 2, BatchSize: 3}, func(ctx context.Context, entries []Entry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, entry := range entries {
+			applied = append(applied, entry.Seq)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				assert.NoError(t, q.Enqueue("a", []byte("x")))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, q.Drain(context.Background()))
+
+	// entries of a key are applied in the order they got their seq
+	assert.Len(t, applied, 200)
+	assert.IsIncreasing(t, applied)
+}
+
+func TestQueueBrokenEntries(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001.entry"), []byte("a\n1"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000002.entry"), []byte("no key"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000003.entry"), []byte("a\n3"), 0o644))
+
+	// broken entries are set aside instead of failing the start
+	r := &recorder{failing: true}
+	q, err := Open(Options{Dir: dir, Workers: 1, MinRetryInterval: time.Millisecond}, r.apply)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "00000000000000000002.entry.broken"))
+	assert.Equal(t, 2, q.Depth())
+
+	// and so are entries that break while pending
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.calls >= 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, q.Enqueue("a", []byte("4")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000004.entry"), []byte("no key"), 0o644))
+	r.setFailing(false)
+	require.NoError(t, q.Drain(context.Background()))
+	assert.Equal(t, map[string][]string{"a": {"1", "3"}}, r.applied)
+	assert.Equal(t, 0, q.Depth())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"00000000000000000002.entry.broken", "00000000000000000004.entry.broken"}, names)
+}
+
+func TestQueueWait(t *testing.T) {
+	r := &recorder{failing: true}
+	q, err := Open(Options{Dir: t.TempDir(), Workers: 2, MinRetryInterval: time.Millisecond, MaxRetryInterval: time.Millisecond}, r.apply)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Wait(context.Background(), "a"))
+	require.NoError(t, q.Enqueue("a", []byte("1")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Wait(ctx, "a"), context.DeadlineExceeded)
+	require.NoError(t, q.Wait(context.Background(), "b"))
+
+	done := make(chan error)
+	go func() { done <- q.Wait(context.Background(), "a") }()
+	r.setFailing(false)
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"1"}, r.applied["a"])
+
+	// entries that are left when the workers stop are not waited for
+	r.setFailing(true)
+	require.NoError(t, q.Enqueue("a", []byte("2")))
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, q.Drain(ctx))
+	assert.ErrorIs(t, q.Wait(context.Background(), "a"), ErrClosed)
+}
@@ -0,0 +1,434 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"golang.org/x/time/rate"
+)
+
+const dayLayout = "2006-01-02"
+
+// Limits configures ingress rate limits and daily quotas. A zero value disables the limit.
+// Rates are in requests per second; the bursts default to one second worth of requests.
+// User limits apply to all API keys of a user together, and project limits to the API keys
+// of a user restricted to the same project. Quotas reset at midnight UTC.
+type Limits struct {
+	KeyRate                 float64
+	KeyBurst                int
+	UserRate                float64
+	UserBurst               int
+	ProjectRate             float64
+	ProjectBurst            int
+	DailyTestcases          int64
+	DailyOutputBytes        int64
+	ProjectDailyTestcases   int64
+	ProjectDailyOutputBytes int64
+}
+
+func (l Limits) Validate() error {
+	if l.KeyRate < 0 || l.UserRate < 0 || l.ProjectRate < 0 {
+		return fmt.Errorf("rate limits must be non-negative")
+	}
+	if l.KeyBurst < 0 || l.UserBurst < 0 || l.ProjectBurst < 0 {
+		return fmt.Errorf("bursts must be non-negative")
+	}
+	if l.DailyTestcases < 0 || l.DailyOutputBytes < 0 || l.ProjectDailyTestcases < 0 || l.ProjectDailyOutputBytes < 0 {
+		return fmt.Errorf("quotas must be non-negative")
+	}
+	return nil
+}
+
+// ExceededError is returned when a request exceeds a rate limit or quota.
+// RetryAfter is when the request may be retried.
+type ExceededError struct {
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return e.Limit + " exceeded"
+}
+
+// RetryAfterSeconds returns RetryAfter in whole seconds for a Retry-After header, rounded up.
+func (e *ExceededError) RetryAfterSeconds() int {
+	return max(1, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Counters are the ingress usage of an API key or user on a day.
+type Counters struct {
+	Requests    int64
+	Testcases   int64
+	OutputBytes int64
+}
+
+// Limiter enforces Limits. Rate limits use in-memory token buckets, so they apply per server;
+// usage is counted in the database, so quotas apply across servers sharing it.
+type Limiter struct {
+	db     *bun.DB
+	limits Limits
+	now    func() time.Time
+
+	mu       sync.Mutex
+	keys     map[uuid.UUID]*rate.Limiter
+	users    map[uuid.UUID]*rate.Limiter
+	projects map[projectKey]*rate.Limiter
+}
+
+// projectKey identifies the API keys of a user restricted to a project.
+type projectKey struct {
+	userID  uuid.UUID
+	project string
+}
+
+func NewLimiter(db *bun.DB, limits Limits) *Limiter {
+	return &Limiter{
+		db:       db,
+		limits:   limits,
+		now:      time.Now,
+		keys:     make(map[uuid.UUID]*rate.Limiter),
+		users:    make(map[uuid.UUID]*rate.Limiter),
+		projects: make(map[projectKey]*rate.Limiter),
+	}
+}
+
+// Begin admits a request of an API key of a user, restricted to project unless it is empty.
+// It takes a token from the buckets of the key, its user and its project and checks that the
+// daily quotas are not used up. The returned Usage reserves what the request stores and must
+// be flushed when the request is done.
+func (l *Limiter) Begin(ctx context.Context, apiKeyID, userID uuid.UUID, project string) (*Usage, error) {
+	now := l.now()
+	if err := l.take(apiKeyID, userID, project, now); err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{limiter: l, apiKeyID: apiKeyID, userID: userID, project: project, day: Day(now)}
+	for _, q := range usage.quotas() {
+		used, err := usage.load(ctx, l.db, q)
+		if err != nil {
+			return nil, err
+		}
+		// a request is rejected early when it could not store a single testcase
+		if limit := q.exceeded(used, 1, 1); limit != "" {
+			return nil, usage.exceeded(limit)
+		}
+	}
+	return usage, nil
+}
+
+func (l *Limiter) take(apiKeyID, userID uuid.UUID, project string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keyReservation := reserve(l.keys, apiKeyID, l.limits.KeyRate, l.limits.KeyBurst, now)
+	if delay := keyReservation.DelayFrom(now); delay > 0 {
+		keyReservation.CancelAt(now)
+		return &ExceededError{Limit: "API key rate limit", RetryAfter: delay}
+	}
+
+	userReservation := reserve(l.users, userID, l.limits.UserRate, l.limits.UserBurst, now)
+	if delay := userReservation.DelayFrom(now); delay > 0 {
+		userReservation.CancelAt(now)
+		keyReservation.CancelAt(now)
+		return &ExceededError{Limit: "user rate limit", RetryAfter: delay}
+	}
+
+	if project == "" {
+		return nil
+	}
+	projectReservation := reserve(l.projects, projectKey{userID: userID, project: project}, l.limits.ProjectRate, l.limits.ProjectBurst, now)
+	if delay := projectReservation.DelayFrom(now); delay > 0 {
+		projectReservation.CancelAt(now)
+		userReservation.CancelAt(now)
+		keyReservation.CancelAt(now)
+		return &ExceededError{Limit: "project rate limit", RetryAfter: delay}
+	}
+	return nil
+}
+
+// unlimited is the bucket of disabled rate limits.
+var unlimited = rate.NewLimiter(rate.Inf, 0)
+
+// reserve takes a token from the bucket of id, creating the bucket if needed.
+func reserve[K comparable](buckets map[K]*rate.Limiter, id K, limit float64, burst int, now time.Time) *rate.Reservation {
+	if limit == 0 {
+		return unlimited.ReserveN(now, 1)
+	}
+	bucket, ok := buckets[id]
+	if !ok {
+		if burst == 0 {
+			burst = max(1, int(math.Ceil(limit)))
+		}
+		bucket = rate.NewLimiter(rate.Limit(limit), burst)
+		buckets[id] = bucket
+	}
+	return bucket.ReserveN(now, 1)
+}
+
+// dailyQuota is a pair of daily quotas counted in the quota_usage rows of project, where the
+// empty project counts all API keys of a user.
+type dailyQuota struct {
+	name        string
+	project     string
+	testcases   int64
+	outputBytes int64
+}
+
+// exceeded returns the name of the limit of q that storing testcases with outputBytes of output
+// on top of used would exceed, or "" if there is none.
+func (q dailyQuota) exceeded(used Counters, testcases, outputBytes int64) string {
+	if q.testcases > 0 && used.Testcases+testcases > q.testcases {
+		return q.name + " testcase quota"
+	}
+	if q.outputBytes > 0 && used.OutputBytes+outputBytes > q.outputBytes {
+		return q.name + " output quota"
+	}
+	return ""
+}
+
+// Usage counts the usage of a single request. The zero-value-safe methods make a nil *Usage
+// count nothing, for requests that are not limited.
+type Usage struct {
+	limiter  *Limiter
+	apiKeyID uuid.UUID
+	userID   uuid.UUID
+	project  string
+	day      string
+
+	// added is what the request reserved and did not release
+	added Counters
+}
+
+// quotas returns the daily quotas that apply to the request.
+func (u *Usage) quotas() []dailyQuota {
+	limits := u.limiter.limits
+	var quotas []dailyQuota
+	if limits.DailyTestcases > 0 || limits.DailyOutputBytes > 0 {
+		quotas = append(quotas, dailyQuota{name: "daily", testcases: limits.DailyTestcases, outputBytes: limits.DailyOutputBytes})
+	}
+	if u.project != "" && (limits.ProjectDailyTestcases > 0 || limits.ProjectDailyOutputBytes > 0) {
+		quotas = append(quotas, dailyQuota{name: "project daily", project: u.project, testcases: limits.ProjectDailyTestcases, outputBytes: limits.ProjectDailyOutputBytes})
+	}
+	return quotas
+}
+
+func (u *Usage) exceeded(limit string) *ExceededError {
+	now := u.limiter.now()
+	return &ExceededError{Limit: limit, RetryAfter: nextDay(now).Sub(now)}
+}
+
+// Reserve counts testcases with outputBytes of output against the daily quotas before they are
+// stored, and returns an *ExceededError without counting them if they would exceed a quota.
+// Every quota is checked and incremented by a single conditional update, so concurrent requests
+// on any server cannot exceed it together.
+func (u *Usage) Reserve(ctx context.Context, testcases, outputBytes int64) error {
+	if u == nil {
+		return nil
+	}
+	if quotas := u.quotas(); len(quotas) > 0 && (testcases > 0 || outputBytes > 0) {
+		err := u.limiter.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, q := range quotas {
+				if err := u.reserve(ctx, tx, q, testcases, outputBytes); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	u.added.Testcases += testcases
+	u.added.OutputBytes += outputBytes
+	return nil
+}
+
+func (u *Usage) reserve(ctx context.Context, db bun.IDB, q dailyQuota, testcases, outputBytes int64) error {
+	increment := func() (int64, error) {
+		query := db.NewUpdate().
+			Model((*model_db.QuotaUsage)(nil)).
+			Set("? = ? + ?", bun.Ident("testcases"), bun.Ident("testcases"), testcases).
+			Set("? = ? + ?", bun.Ident("output_bytes"), bun.Ident("output_bytes"), outputBytes).
+			Where("? = ?", bun.Ident("user_id"), model_db.BinaryUUID(u.userID)).
+			Where("? = ?", bun.Ident("project"), q.project).
+			Where("? = ?", bun.Ident("day"), u.day)
+		if q.testcases > 0 {
+			query = query.Where("? + ? <= ?", bun.Ident("testcases"), testcases, q.testcases)
+		}
+		if q.outputBytes > 0 {
+			query = query.Where("? + ? <= ?", bun.Ident("output_bytes"), outputBytes, q.outputBytes)
+		}
+		res, err := query.Exec(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update quota usage: %w", err)
+		}
+		return res.RowsAffected()
+	}
+
+	updated, err := increment()
+	if err != nil || updated > 0 {
+		return err
+	}
+
+	// the row of the day may be missing
+	_, err = db.NewInsert().Model(&model_db.QuotaUsage{
+		UserID:  model_db.BinaryUUID(u.userID),
+		Project: q.project,
+		Day:     u.day,
+	}).Ignore().Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to insert quota usage: %w", err)
+	}
+	if updated, err := increment(); err != nil || updated > 0 {
+		return err
+	}
+
+	used, err := u.load(ctx, db, q)
+	if err != nil {
+		return err
+	}
+	limit := q.exceeded(used, testcases, outputBytes)
+	if limit == "" {
+		return fmt.Errorf("failed to update quota usage of %s", q.name)
+	}
+	return u.exceeded(limit)
+}
+
+// Release returns testcases with outputBytes of output reserved by Reserve that were not stored.
+func (u *Usage) Release(ctx context.Context, testcases, outputBytes int64) error {
+	if u == nil || (testcases == 0 && outputBytes == 0) {
+		return nil
+	}
+	u.added.Testcases -= testcases
+	u.added.OutputBytes -= outputBytes
+	for _, q := range u.quotas() {
+		_, err := u.limiter.db.NewUpdate().
+			Model((*model_db.QuotaUsage)(nil)).
+			Set("? = ? - ?", bun.Ident("testcases"), bun.Ident("testcases"), testcases).
+			Set("? = ? - ?", bun.Ident("output_bytes"), bun.Ident("output_bytes"), outputBytes).
+			Where("? = ?", bun.Ident("user_id"), model_db.BinaryUUID(u.userID)).
+			Where("? = ?", bun.Ident("project"), q.project).
+			Where("? = ?", bun.Ident("day"), u.day).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to release quota usage: %w", err)
+		}
+	}
+	return nil
+}
+
+// load returns the usage counted against q on the day of the request.
+func (u *Usage) load(ctx context.Context, db bun.IDB, q dailyQuota) (Counters, error) {
+	var row model_db.QuotaUsage
+	err := db.NewSelect().
+		Model(&row).
+		Where("? = ?", bun.Ident("user_id"), model_db.BinaryUUID(u.userID)).
+		Where("? = ?", bun.Ident("project"), q.project).
+		Where("? = ?", bun.Ident("day"), u.day).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Counters{}, nil
+	}
+	if err != nil {
+		return Counters{}, fmt.Errorf("failed to load quota usage: %w", err)
+	}
+	return Counters{Testcases: row.Testcases, OutputBytes: row.OutputBytes}, nil
+}
+
+// Flush adds the request and what it stored to the usage counters of the API key.
+// The counters are incremented in place, so concurrent requests on any server are all counted.
+func (u *Usage) Flush(ctx context.Context) error {
+	if u == nil {
+		return nil
+	}
+
+	increment := func() (int64, error) {
+		res, err := u.limiter.db.NewUpdate().
+			Model((*model_db.APIKeyUsage)(nil)).
+			Set("? = ? + 1", bun.Ident("requests"), bun.Ident("requests")).
+			Set("? = ? + ?", bun.Ident("testcases"), bun.Ident("testcases"), u.added.Testcases).
+			Set("? = ? + ?", bun.Ident("output_bytes"), bun.Ident("output_bytes"), u.added.OutputBytes).
+			Where("? = ?", bun.Ident("api_key_id"), model_db.BinaryUUID(u.apiKeyID)).
+			Where("? = ?", bun.Ident("day"), u.day).
+			Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	updated, err := increment()
+	if err != nil {
+		return fmt.Errorf("failed to update usage: %w", err)
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	_, err = u.limiter.db.NewInsert().Model(&model_db.APIKeyUsage{
+		APIKeyID:    model_db.BinaryUUID(u.apiKeyID),
+		Day:         u.day,
+		UserID:      model_db.BinaryUUID(u.userID),
+		Requests:    1,
+		Testcases:   u.added.Testcases,
+		OutputBytes: u.added.OutputBytes,
+	}).Exec(ctx)
+	if err == nil {
+		return nil
+	}
+
+	// another request inserted the row first
+	if updated, updateErr := increment(); updateErr != nil || updated == 0 {
+		return fmt.Errorf("failed to insert usage: %w", err)
+	}
+	return nil
+}
+
+type usageContextKey struct{}
+
+func NewContext(ctx context.Context, usage *Usage) context.Context {
+	return context.WithValue(ctx, usageContextKey{}, usage)
+}
+
+// FromContext returns the Usage of the request of ctx, or nil if the request is not limited.
+func FromContext(ctx context.Context) *Usage {
+	usage, _ := ctx.Value(usageContextKey{}).(*Usage)
+	return usage
+}
+
+// KeyUsage returns the usage of the API keys of a user on day, by API key ID.
+func KeyUsage(ctx context.Context, db *bun.DB, userID uuid.UUID, day string) (map[uuid.UUID]Counters, error) {
+	var rows []model_db.APIKeyUsage
+	err := db.NewSelect().
+		Model(&rows).
+		Where("? = ?", bun.Ident("user_id"), model_db.BinaryUUID(userID)).
+		Where("? = ?", bun.Ident("day"), day).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage: %w", err)
+	}
+
+	usage := make(map[uuid.UUID]Counters, len(rows))
+	for _, row := range rows {
+		usage[uuid.UUID(row.APIKeyID)] = Counters{Requests: row.Requests, Testcases: row.Testcases, OutputBytes: row.OutputBytes}
+	}
+	return usage, nil
+}
+
+// Day returns the UTC day of t that usage is counted on.
+func Day(t time.Time) string {
+	return t.UTC().Format(dayLayout)
+}
+
+func nextDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
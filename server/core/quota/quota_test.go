@@ -0,0 +1,87 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterRateLimits(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(nil, Limits{KeyRate: 1, KeyBurst: 2, UserRate: 2})
+	l.now = func() time.Time { return now }
+
+	user := uuid.New()
+	key, otherKey := uuid.New(), uuid.New()
+
+	for range 2 {
+		_, err := l.Begin(context.Background(), key, user, "")
+		require.NoError(t, err)
+	}
+
+	_, err := l.Begin(context.Background(), key, user, "")
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, "API key rate limit", exceeded.Limit)
+	assert.Equal(t, time.Second, exceeded.RetryAfter)
+	assert.Equal(t, 1, exceeded.RetryAfterSeconds())
+
+	// the user bucket (burst 2) was emptied by the first key
+	_, err = l.Begin(context.Background(), otherKey, user, "")
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, "user rate limit", exceeded.Limit)
+
+	// a rejected request does not take tokens
+	now = now.Add(time.Second)
+	_, err = l.Begin(context.Background(), otherKey, user, "")
+	require.NoError(t, err)
+	_, err = l.Begin(context.Background(), key, user, "")
+	require.NoError(t, err)
+
+	_, err = l.Begin(context.Background(), uuid.New(), uuid.New(), "")
+	assert.NoError(t, err)
+}
+
+func TestLimiterProjectRateLimits(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(nil, Limits{ProjectRate: 1})
+	l.now = func() time.Time { return now }
+
+	user, otherUser := uuid.New(), uuid.New()
+
+	_, err := l.Begin(context.Background(), uuid.New(), user, "web")
+	require.NoError(t, err)
+
+	// the bucket is shared by the keys of the user restricted to the project
+	_, err = l.Begin(context.Background(), uuid.New(), user, "web")
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, "project rate limit", exceeded.Limit)
+
+	// keys of other projects, unrestricted keys and other users have their own limits
+	_, err = l.Begin(context.Background(), uuid.New(), user, "mobile")
+	require.NoError(t, err)
+	_, err = l.Begin(context.Background(), uuid.New(), user, "")
+	require.NoError(t, err)
+	_, err = l.Begin(context.Background(), uuid.New(), otherUser, "web")
+	require.NoError(t, err)
+}
+
+func TestLimitsValidate(t *testing.T) {
+	assert.NoError(t, Limits{}.Validate())
+	assert.NoError(t, Limits{KeyRate: 0.5, DailyTestcases: 1000}.Validate())
+	assert.Error(t, Limits{KeyRate: -1}.Validate())
+	assert.Error(t, Limits{UserBurst: -1}.Validate())
+	assert.Error(t, Limits{DailyOutputBytes: -1}.Validate())
+	assert.Error(t, Limits{ProjectRate: -1}.Validate())
+	assert.Error(t, Limits{ProjectDailyTestcases: -1}.Validate())
+}
+
+func TestNextDay(t *testing.T) {
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), nextDay(time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, "2026-10-18", Day(time.Date(2026, 10, 19, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60))))
+}
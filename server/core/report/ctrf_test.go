@@ -0,0 +1,44 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCTRF(t *testing.T) {
+	results := parseFixture(t, "ctrf", "ctrf.json")
+
+	assert.Equal(t, []Result{
+		{
+			Name:       "login works",
+			File:       "tests/auth.spec.ts",
+			Testsuite:  "auth > login",
+			Status:     "pass",
+			Stdout:     "navigating\ndone",
+			Time:       seconds(1.25),
+			Properties: map[string]string{"tags": "@smoke,@auth"},
+		},
+		{
+			Name:           "logout works",
+			File:           "tests/auth.spec.ts",
+			Testsuite:      "auth > logout",
+			Status:         "fail",
+			FailureMessage: "expected url /login",
+			StackTrace:     "at tests/auth.spec.ts:20:5",
+			Stderr:         "console error",
+			Time:           seconds(0.8),
+		},
+		{
+			Name:   "admin page",
+			Status: "skip",
+			Output: "requires admin account",
+			Time:   seconds(0),
+		},
+		{
+			Name:           "flaky network",
+			Status:         "error",
+			FailureMessage: "worker crashed",
+		},
+	}, results)
+}
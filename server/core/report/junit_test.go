@@ -0,0 +1,84 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJUnit(t *testing.T) {
+	results := parseFixture(t, "junit", "junit.xml")
+
+	assert.Equal(t, []Result{
+		{
+			Name:       "test_add",
+			Classname:  "tests.test_math",
+			File:       "tests/test_math.py",
+			Testsuite:  "math",
+			Status:     "pass",
+			Stdout:     "adding",
+			Stderr:     "warning",
+			Time:       seconds(0.012),
+			Properties: map[string]string{"python": "3.12", "host": "ci-1"},
+		},
+		{
+			Name:           "test_div",
+			Classname:      "tests.test_math",
+			File:           "tests/test_math.py",
+			Testsuite:      "math",
+			Status:         "fail",
+			FailureMessage: "expected 1, got 2",
+			FailureType:    "AssertionError",
+			StackTrace:     "test_math.py:12: in test_div",
+			Time:           seconds(1234.5),
+			Properties:     map[string]string{"python": "3.13", "host": "ci-1"},
+		},
+		{
+			Name:           "test_io",
+			Classname:      "tests.test_math",
+			File:           "tests/test_io.py",
+			Testsuite:      "math",
+			Status:         "error",
+			FailureMessage: "disk full",
+			FailureType:    "OSError",
+			StackTrace:     "trace",
+			Properties:     map[string]string{"python": "3.12", "host": "ci-1"},
+		},
+		{
+			Name:       "test_slow",
+			Classname:  "tests.test_math",
+			File:       "tests/test_math.py",
+			Testsuite:  "math",
+			Status:     "skip",
+			Output:     "too slow",
+			Properties: map[string]string{"python": "3.12", "host": "ci-1"},
+		},
+		{
+			Name:      "test_upper",
+			Testsuite: "strings",
+			Status:    "pass",
+		},
+	}, results)
+}
+
+func TestParseJUnitNested(t *testing.T) {
+	results := parseFixture(t, "junit", "junit-nested.xml")
+
+	assert.Equal(t, []Result{
+		{
+			Name:       "top",
+			File:       "spec.js",
+			Testsuite:  "outer",
+			Status:     "pass",
+			Properties: map[string]string{"browser": "firefox"},
+		},
+		{
+			Name:       "nested",
+			File:       "spec.js",
+			Testsuite:  "inner",
+			Status:     "skip",
+			Output:     "pending",
+			Properties: map[string]string{"browser": "firefox"},
+		},
+	}, results)
+}
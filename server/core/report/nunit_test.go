@@ -0,0 +1,56 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNUnit(t *testing.T) {
+	results := parseFixture(t, "nunit", "nunit3.xml")
+
+	assert.Equal(t, []Result{
+		{
+			Name:       "Add",
+			Classname:  "Calc.MathTests",
+			Testsuite:  "Calc.Tests.dll",
+			Status:     "pass",
+			Stdout:     "computing sum",
+			Time:       seconds(0.012),
+			Properties: map[string]string{"Category": "fast"},
+		},
+		{
+			Name:           "Divide",
+			Classname:      "Calc.MathTests",
+			Testsuite:      "Calc.Tests.dll",
+			Status:         "error",
+			FailureMessage: "System.DivideByZeroException : Attempted to divide by zero.",
+			StackTrace:     "at Calc.MathTests.Divide() in /src/MathTests.cs:line 30",
+			Time:           seconds(0.003),
+		},
+		{
+			Name:      "Slow",
+			Classname: "Calc.MathTests",
+			Testsuite: "Calc.Tests.dll",
+			Status:    "skip",
+			Output:    "Too slow for CI",
+			Time:      seconds(0),
+		},
+		{
+			Name:      "Multiply(2,3)",
+			Classname: "Calc.MathTests",
+			Testsuite: "Calc.Tests.dll",
+			Status:    "pass",
+			Time:      seconds(0.001),
+		},
+		{
+			Name:           "Multiply(2,2)",
+			Classname:      "Calc.MathTests",
+			Testsuite:      "Calc.Tests.dll",
+			Status:         "fail",
+			FailureMessage: "Expected: 5\n  But was:  4",
+			StackTrace:     "at Calc.MathTests.Multiply(Int32 a, Int32 b) in /src/MathTests.cs:line 40",
+			Time:           seconds(0.002),
+		},
+	}, results)
+}
@@ -0,0 +1,217 @@
+// Package report parses test reports of other tools (JUnit XML, TRX, NUnit, xUnit.net, TAP, CTRF)
+// into testcase results. Parsers are kept in a registry so that the server ingress and the
+// reporter CLIs accept the same formats; additional formats can be added with Register.
+//
+// The Go reporters build without the server module, so they keep a copy of this package in
+// internal/report; run sync_report_parsers.sh at the repository root after changing it.
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Result is a single testcase of a report. Empty strings mean the value was not reported.
+type Result struct {
+	Name      string
+	Classname string
+	File      string
+	Testsuite string
+	// Status is one of "pass", "fail", "error" or "skip".
+	Status         string
+	FailureMessage string
+	FailureType    string
+	StackTrace     string
+	Stdout         string
+	Stderr         string
+	// Output holds the message of a skipped testcase or other diagnostics of the report.
+	Output string
+	// Time is the reported duration in seconds, nil if absent.
+	Time *float64
+	// Properties holds key-value metadata of the testcase (properties, traits, categories).
+	Properties map[string]string
+}
+
+// Parser reads reports of a single format.
+type Parser interface {
+	// Format is the name the parser is selected by, e.g. "junit".
+	Format() string
+	// Detect reports whether data looks like a report of the parser's format.
+	Detect(data []byte) bool
+	// Parse returns the testcases of the report. Testcases of a suite precede those of its nested suites.
+	Parse(data []byte) ([]Result, error)
+}
+
+var ErrUnknownFormat = errors.New("unknown report format")
+
+var (
+	mu      sync.RWMutex
+	parsers []Parser
+)
+
+func init() {
+	Register(junitParser{})
+	Register(trxParser{})
+	Register(nunitParser{})
+	Register(xunitParser{})
+	Register(ctrfParser{})
+	Register(tapParser{})
+}
+
+// Register adds a parser to the registry. Detection tries parsers in registration order.
+// It panics if a parser for the format is already registered.
+func Register(p Parser) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, existing := range parsers {
+		if existing.Format() == p.Format() {
+			panic(fmt.Sprintf("report: parser for format %q registered twice", p.Format()))
+		}
+	}
+	parsers = append(parsers, p)
+}
+
+// Lookup returns the parser registered for format.
+func Lookup(format string) (Parser, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, p := range parsers {
+		if p.Format() == format {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Formats returns the names of the registered formats, sorted.
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	formats := make([]string, 0, len(parsers))
+	for _, p := range parsers {
+		formats = append(formats, p.Format())
+	}
+	slices.Sort(formats)
+	return formats
+}
+
+// Detect returns the first registered parser that recognizes data.
+func Detect(data []byte) (Parser, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, p := range parsers {
+		if p.Detect(data) {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Parse parses data with the parser of format, or the detected parser if format is empty.
+func Parse(format string, data []byte) ([]Result, error) {
+	var p Parser
+	if format == "" {
+		var err error
+		if p, err = Detect(data); err != nil {
+			return nil, err
+		}
+	} else {
+		var ok bool
+		if p, ok = Lookup(format); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+		}
+	}
+	return p.Parse(data)
+}
+
+// xmlRoot returns the local name of the root element of an XML document, or "" if there is none.
+func xmlRoot(data []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// decodeXMLRoot decodes the root element of data into the value registered for its local name
+// in roots and returns that name.
+func decodeXMLRoot(format string, data []byte, roots map[string]any) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", fmt.Errorf("%s: empty document", format)
+			}
+			return "", fmt.Errorf("%s: %w", format, err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		v, ok := roots[start.Name.Local]
+		if !ok {
+			return "", fmt.Errorf("%s: unexpected root element <%s>", format, start.Name.Local)
+		}
+		if err := dec.DecodeElement(v, &start); err != nil {
+			return "", fmt.Errorf("%s: %w", format, err)
+		}
+		return start.Name.Local, nil
+	}
+}
+
+// property is a key-value pair of an XML report: <property name="k" value="v"/> or <property name="k">v</property>.
+type property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+func mergeProperties(base map[string]string, props []property) map[string]string {
+	if len(props) == 0 {
+		return base
+	}
+
+	merged := make(map[string]string, len(base)+len(props))
+	maps.Copy(merged, base)
+	for _, p := range props {
+		value := p.Value
+		if value == "" {
+			value = strings.TrimSpace(p.Text)
+		}
+		merged[p.Name] = value
+	}
+	return merged
+}
+
+// optionalSeconds parses a duration in seconds, returning nil for an empty string.
+// Thousands separators are ignored.
+func optionalSeconds(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
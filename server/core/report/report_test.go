@@ -0,0 +1,79 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseFixture parses a file from testdata, checking that it is detected as format.
+func parseFixture(t *testing.T, format, name string) []Result {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	p, err := Detect(data)
+	require.NoError(t, err)
+	require.Equal(t, format, p.Format())
+
+	results, err := Parse(format, data)
+	require.NoError(t, err)
+	return results
+}
+
+func seconds(t float64) *float64 {
+	return &t
+}
+
+func TestFormats(t *testing.T) {
+	assert.Equal(t, []string{"ctrf", "junit", "nunit", "tap", "trx", "xunit"}, Formats())
+
+	_, ok := Lookup("junit")
+	assert.True(t, ok)
+	_, ok = Lookup("allure")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Register(junitParser{}) })
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	_, err := Parse("allure", []byte("{}"))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse("", []byte("<html/>"))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse("", []byte(`{"results": {}}`))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{"junit", ""},
+		{"junit", "<testsuites><testsuite>"},
+		{"junit", "<report/>"},
+		{"junit", `<testsuite><testcase name="t" time="fast"/></testsuite>`},
+		{"trx", `<TestRun><Results><UnitTestResult testName="t" outcome="Exploded"/></Results></TestRun>`},
+		{"trx", `<TestRun><Results><UnitTestResult testName="t" outcome="Passed" duration="soon"/></Results></TestRun>`},
+		{"nunit", `<test-run><test-suite type="Assembly"><test-case name="t" result="Exploded"/></test-suite></test-run>`},
+		{"xunit", `<assembly><collection><test name="t" result="Exploded"/></collection></assembly>`},
+		{"ctrf", `{"results": {"tests": [{"name": "t", "status": "exploded"}]}}`},
+		{"ctrf", `{"results": {"tests": [{"name": "t", "status": "passed", "suite": 1}]}}`},
+		{"ctrf", `[]`},
+		{"tap", "no tests here"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			_, err := Parse(tt.format, []byte(tt.data))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package report
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tapParser reads Test Anything Protocol streams (TAP 13 and 14).
+// Indented subtests are reported with the name of their parent test point as testsuite.
+// YAML diagnostics provide the failure message, stack and duration_ms of a test point.
+type tapParser struct{}
+
+var (
+	tapTestPoint = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*(?:-\s+)?(.*)$`)
+	tapPlan      = regexp.MustCompile(`^1\.\.\d+`)
+)
+
+const tapSubtestIndent = "    "
+
+func (tapParser) Format() string { return "tap" }
+
+func (tapParser) Detect(data []byte) bool {
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		return strings.HasPrefix(line, "TAP version") || tapPlan.MatchString(line) || tapTestPoint.MatchString(line)
+	}
+	return false
+}
+
+func (tapParser) Parse(data []byte) ([]Result, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	r := &tapReader{}
+	results := r.parse(strings.Split(text, "\n"), "")
+	if !r.seen {
+		return nil, errors.New("tap: no plan or test points")
+	}
+	return results, nil
+}
+
+type tapReader struct {
+	// seen records whether a plan or test point was found.
+	seen bool
+	// bailedOut stops parsing after "Bail out!".
+	bailedOut bool
+}
+
+func (r *tapReader) parse(lines []string, suite string) []Result {
+	var results []Result
+	var subtest []string
+	count := 0
+
+	for i := 0; i < len(lines) && !r.bailedOut; i++ {
+		line := strings.TrimRight(lines[i], " \t")
+
+		if strings.HasPrefix(line, tapSubtestIndent) {
+			subtest = append(subtest, strings.TrimPrefix(line, tapSubtestIndent))
+			continue
+		}
+
+		if reason, ok := strings.CutPrefix(line, "Bail out!"); ok {
+			r.bailedOut = true
+			results = append(results, Result{
+				Name:           "Bail out!",
+				Testsuite:      suite,
+				Status:         "error",
+				FailureMessage: strings.TrimSpace(reason),
+			})
+			break
+		}
+
+		if tapPlan.MatchString(line) {
+			r.seen = true
+			continue
+		}
+
+		m := tapTestPoint.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		r.seen = true
+		count++
+
+		var yaml []string
+		if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == "---" {
+			indent := lines[i+1][:strings.Index(lines[i+1], "---")]
+			for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "..."; i++ {
+				yaml = append(yaml, strings.TrimPrefix(lines[i], indent))
+			}
+		}
+
+		result := tapResult(m[1] == "ok", m[3], yaml)
+		if result.Name == "" {
+			number := m[2]
+			if number == "" {
+				number = strconv.Itoa(count)
+			}
+			result.Name = "test " + number
+		}
+		result.Testsuite = suite
+
+		if len(subtest) > 0 {
+			childSuite := result.Name
+			if suite != "" {
+				childSuite = suite + " > " + result.Name
+			}
+			results = append(results, r.parse(subtest, childSuite)...)
+			subtest = nil
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func tapResult(ok bool, text string, yaml []string) Result {
+	description, directive := splitTAPDirective(text)
+	result := Result{Name: description, Status: "fail"}
+	if ok {
+		result.Status = "pass"
+	}
+
+	word, reason, _ := strings.Cut(directive, " ")
+	switch word = strings.ToLower(word); {
+	case strings.HasPrefix(word, "skip"):
+		result.Status = "skip"
+		result.Output = strings.TrimSpace(reason)
+	case word == "todo" && !ok:
+		// a failing TODO test is expected to fail
+		result.Status = "skip"
+		result.Output = strings.TrimSpace(reason)
+	}
+
+	diagnostics := parseTAPYAML(yaml)
+	if d, ok := diagnostics["duration_ms"]; ok {
+		if ms, err := strconv.ParseFloat(d, 64); err == nil {
+			t := ms / 1000
+			result.Time = &t
+		}
+	}
+	if result.Status == "fail" {
+		result.FailureMessage = diagnostics["message"]
+		if result.FailureMessage == "" {
+			result.FailureMessage = diagnostics["error"]
+		}
+		result.StackTrace = diagnostics["stack"]
+		if len(yaml) > 0 {
+			result.Output = strings.Join(yaml, "\n")
+		}
+	}
+	return result
+}
+
+// splitTAPDirective splits a test point description from its "# SKIP" or "# TODO" directive.
+// Escaped "\#" and "\\" in the description are unescaped.
+func splitTAPDirective(text string) (string, string) {
+	var description strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && (text[i+1] == '#' || text[i+1] == '\\'):
+			description.WriteByte(text[i+1])
+			i++
+		case c == '#':
+			return strings.TrimSpace(description.String()), strings.TrimSpace(text[i+1:])
+		default:
+			description.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(description.String()), ""
+}
+
+// parseTAPYAML reads the top-level scalar keys of a YAML diagnostics block,
+// including "|" and ">" block scalars. Nested mappings are ignored.
+func parseTAPYAML(lines []string) map[string]string {
+	values := map[string]string{}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" || line[0] == ' ' || line[0] == '#' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			var block []string
+			indent := ""
+			for i+1 < len(lines) && (lines[i+1] == "" || lines[i+1][0] == ' ') {
+				i++
+				if indent == "" {
+					indent = lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " "))]
+				}
+				block = append(block, strings.TrimPrefix(lines[i], indent))
+			}
+			sep := "\n"
+			if value[0] == '>' {
+				sep = " "
+			}
+			values[key] = strings.TrimSpace(strings.Join(block, sep))
+			continue
+		}
+
+		values[key] = unquoteYAML(value)
+	}
+	return values
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		}
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTAP13(t *testing.T) {
+	results := parseFixture(t, "tap", "tap13.tap")
+	require.Len(t, results, 6)
+
+	assert.Equal(t, Result{
+		Name:      "add",
+		Testsuite: "math",
+		Status:    "pass",
+		Time:      seconds(0.0015),
+	}, results[0])
+
+	divide := results[1]
+	assert.Equal(t, "divide", divide.Name)
+	assert.Equal(t, "math", divide.Testsuite)
+	assert.Equal(t, "fail", divide.Status)
+	assert.Equal(t, "Expected values to be strictly equal:\n1 !== 2", divide.FailureMessage)
+	assert.Equal(t, "TestContext.<anonymous> (file:///src/math.test.mjs:8:10)\nTest.runInAsyncScope (node:async_hooks:211:14)", divide.StackTrace)
+	assert.Contains(t, divide.Output, "code: 'ERR_ASSERTION'")
+	assert.Equal(t, seconds(0.00025), divide.Time)
+
+	math := results[2]
+	assert.Equal(t, "math", math.Name)
+	assert.Empty(t, math.Testsuite)
+	assert.Equal(t, "fail", math.Status)
+	assert.Equal(t, "1 subtest failed", math.FailureMessage)
+
+	assert.Equal(t, Result{Name: "network", Status: "skip", Output: "no network in CI"}, results[3])
+	assert.Equal(t, Result{Name: "todo feature", Status: "skip", Output: "not implemented yet"}, results[4])
+	assert.Equal(t, Result{Name: "escaped # hash", Status: "pass"}, results[5])
+}
+
+func TestParseTAP14(t *testing.T) {
+	results := parseFixture(t, "tap", "tap14.tap")
+
+	assert.Equal(t, []Result{
+		{Name: "parses config", Status: "pass"},
+		{
+			Name:           "writes output",
+			Status:         "fail",
+			FailureMessage: "file not writable",
+			Output:         "message: \"file not writable\"\nseverity: fail\nat:\n  file: test/output.sh\n  line: 12",
+		},
+		{Name: "test 3", Status: "pass"},
+		{Name: "Bail out!", Status: "error", FailureMessage: "database unavailable"},
+	}, results)
+}
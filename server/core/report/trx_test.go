@@ -0,0 +1,48 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTRX(t *testing.T) {
+	results := parseFixture(t, "trx", "results.trx")
+
+	assert.Equal(t, []Result{
+		{
+			Name:       "Add_ReturnsSum",
+			Classname:  "Calc.Tests.MathTests",
+			Testsuite:  "Calc.Tests.dll",
+			Status:     "pass",
+			Stdout:     "computing sum",
+			Time:       seconds(0.012),
+			Properties: map[string]string{"category": "fast,math"},
+		},
+		{
+			Name:           "Divide_ByZero_Throws",
+			Classname:      "Calc.Tests.MathTests",
+			Testsuite:      "Calc.Tests.dll",
+			Status:         "fail",
+			FailureMessage: "Assert.Equal() Failure: Expected 1, Actual 2",
+			StackTrace:     "at Calc.Tests.MathTests.Divide_ByZero_Throws() in /src/MathTests.cs:line 21",
+			Time:           seconds(62.5),
+			Properties:     map[string]string{"Owner": "alice"},
+		},
+		{
+			Name:      "Slow_Test",
+			Classname: "Calc.Tests.SlowTests",
+			Testsuite: "Calc.Tests.dll",
+			Status:    "skip",
+			Output:    "Too slow for CI",
+			Time:      seconds(0),
+		},
+		{
+			Name:      "Network_Timeout",
+			Classname: "Calc.Tests.NetworkTests",
+			Testsuite: "Calc.Tests.dll",
+			Status:    "error",
+			Time:      seconds(30),
+		},
+	}, results)
+}
@@ -0,0 +1,57 @@
+package report
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The Go reporters vendor these parsers with sync_report_parsers.sh; the copies must not drift.
+var vendoredCopies = []string{
+	"../../../reporting/greener-reporter-cli/internal/report",
+	"../../../reporting/greener-reporter-junitxml/internal/report",
+}
+
+func TestVendoredCopies(t *testing.T) {
+	sources := parserFiles(t, ".")
+
+	for _, dir := range vendoredCopies {
+		t.Run(filepath.Base(filepath.Dir(filepath.Dir(dir))), func(t *testing.T) {
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				t.Skipf("%s is not checked out", dir)
+			}
+
+			copies := parserFiles(t, dir)
+			require.Equal(t, slices.Sorted(maps.Keys(sources)), slices.Sorted(maps.Keys(copies)),
+				"parser files differ, run sync_report_parsers.sh")
+			for name, data := range sources {
+				assert.True(t, bytes.Equal(data, copies[name]), "%s differs, run sync_report_parsers.sh", name)
+			}
+		})
+	}
+}
+
+// parserFiles reads the non-test Go files of dir by name.
+func parserFiles(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		files[filepath.Base(path)] = data
+	}
+	return files
+}
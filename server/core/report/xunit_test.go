@@ -0,0 +1,48 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseXUnit(t *testing.T) {
+	results := parseFixture(t, "xunit", "xunit2.xml")
+
+	assert.Equal(t, []Result{
+		{
+			Name:       "Calc.Tests.MathTests.Add(a: 1, b: 2)",
+			Classname:  "Calc.Tests.MathTests",
+			Testsuite:  "Calc.Tests.dll",
+			Status:     "pass",
+			Stdout:     "computing sum",
+			Time:       seconds(0.012),
+			Properties: map[string]string{"Category": "fast"},
+		},
+		{
+			Name:           "Calc.Tests.MathTests.Divide",
+			Classname:      "Calc.Tests.MathTests",
+			Testsuite:      "Calc.Tests.dll",
+			Status:         "fail",
+			FailureMessage: "Assert.Equal() Failure\nExpected: 1\nActual:   2",
+			FailureType:    "Xunit.Sdk.EqualException",
+			StackTrace:     "at Calc.Tests.MathTests.Divide() in /src/MathTests.cs:line 21",
+			Time:           seconds(0.003),
+		},
+		{
+			Name:      "Calc.Tests.MathTests.Slow",
+			Classname: "Calc.Tests.MathTests",
+			Testsuite: "Calc.Tests.dll",
+			Status:    "skip",
+			Output:    "Too slow for CI",
+			Time:      seconds(0),
+		},
+		{
+			Name:      "Calc.Tests.MathTests.Explicit",
+			Classname: "Calc.Tests.MathTests",
+			Testsuite: "Calc.Tests.dll",
+			Status:    "skip",
+			Time:      seconds(0),
+		},
+	}, results)
+}
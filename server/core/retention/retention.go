@@ -0,0 +1,411 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/blob"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/uptrace/bun"
+)
+
+const DefaultBatchSize = 500
+
+// KeepRule protects the latest Last sessions having label Key
+// (and Value, if set) from being purged.
+type KeepRule struct {
+	Key   string
+	Value *string
+	Last  int
+}
+
+type Policy struct {
+	MaxAge      time.Duration
+	MaxSessions int
+	GroupLabels []string
+	Keep        []KeepRule
+	BatchSize   int
+}
+
+func (p Policy) IsEmpty() bool {
+	return p.MaxAge <= 0 && p.MaxSessions <= 0
+}
+
+func (p Policy) Validate() error {
+	if p.MaxAge < 0 {
+		return fmt.Errorf("max age must be non-negative")
+	}
+	if p.MaxSessions < 0 {
+		return fmt.Errorf("max sessions must be non-negative")
+	}
+	if p.MaxSessions > 0 && len(p.GroupLabels) == 0 {
+		return fmt.Errorf("max sessions requires at least one group label")
+	}
+	for _, rule := range p.Keep {
+		if rule.Key == "" || rule.Last <= 0 {
+			return fmt.Errorf("invalid keep rule for label %q", rule.Key)
+		}
+	}
+	return nil
+}
+
+// ParseKeepRules parses comma-separated rules of the form "key=value:N" or "key:N".
+func ParseKeepRules(s string) ([]KeepRule, error) {
+	var rules []KeepRule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		idx := strings.LastIndex(part, ":")
+		if idx == -1 {
+			return nil, fmt.Errorf("invalid keep rule %q (expected key=value:N or key:N)", part)
+		}
+
+		last, err := strconv.Atoi(part[idx+1:])
+		if err != nil || last <= 0 {
+			return nil, fmt.Errorf("invalid keep rule %q: count must be a positive number", part)
+		}
+
+		rule := KeepRule{Key: part[:idx], Last: last}
+		if key, value, ok := strings.Cut(part[:idx], "="); ok {
+			rule.Key = key
+			rule.Value = &value
+		}
+		if rule.Key == "" {
+			return nil, fmt.Errorf("invalid keep rule %q: label key is required", part)
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+type Report struct {
+	DryRun      bool
+	SessionIDs  []model_db.BinaryUUID
+	Sessions    int64
+	Labels      int64
+	Testcases   int64
+	Attachments int64
+}
+
+func (r Report) String() string {
+	verb := "Deleted"
+	if r.DryRun {
+		verb = "Would delete"
+	}
+	return fmt.Sprintf("%s %d sessions, %d labels, %d testcases, %d attachments", verb, r.Sessions, r.Labels, r.Testcases, r.Attachments)
+}
+
+// Stores holds the storage that data of purged sessions is deleted from.
+// Nil fields are skipped.
+type Stores struct {
+	Outputs     *output.Storage
+	Attachments blob.Store
+}
+
+type Purger struct {
+	db     *bun.DB
+	policy Policy
+	stores Stores
+	now    func() time.Time
+}
+
+func NewPurger(db *bun.DB, policy Policy, stores Stores) *Purger {
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = DefaultBatchSize
+	}
+	return &Purger{db: db, policy: policy, stores: stores, now: time.Now}
+}
+
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := p.Purge(ctx, false)
+		if err != nil {
+			log.Printf("retention: purge failed: %v", err)
+		} else if report.Sessions > 0 {
+			log.Printf("retention: %s", report)
+			err := audit.Record(ctx, p.db, audit.System, audit.Event{
+				Action:  audit.ActionSessionPurge,
+				Details: report.String(),
+			})
+			if err != nil {
+				log.Printf("retention: failed to record audit entry: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type sessionRow struct {
+	ID        model_db.BinaryUUID `bun:"id"`
+	CreatedAt time.Time           `bun:"created_at"`
+}
+
+// Purge deletes the sessions selected by the policy, or only counts them if dryRun is set.
+// Sessions are selected in SQL and purged in pages of BatchSize, newest first.
+func (p *Purger) Purge(ctx context.Context, dryRun bool) (*Report, error) {
+	if err := p.policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: dryRun}
+	if p.policy.IsEmpty() {
+		return report, nil
+	}
+
+	protected, err := p.protectedSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := p.now().Add(-p.policy.MaxAge)
+	var after *sessionRow
+	for {
+		page, err := p.selectSessions(ctx, cutoff, after)
+		if err != nil {
+			return report, err
+		}
+
+		var ids []model_db.BinaryUUID
+		for _, sess := range page {
+			if !protected[sess.ID] {
+				ids = append(ids, sess.ID)
+			}
+		}
+		if len(ids) > 0 {
+			if err := p.purgeBatch(ctx, ids, dryRun, report); err != nil {
+				return report, err
+			}
+			report.SessionIDs = append(report.SessionIDs, ids...)
+		}
+
+		if len(page) < p.policy.BatchSize {
+			return report, nil
+		}
+		after = &page[len(page)-1]
+	}
+}
+
+// protectedSessions returns the sessions protected by the keep rules.
+func (p *Purger) protectedSessions(ctx context.Context) (map[model_db.BinaryUUID]bool, error) {
+	protected := map[model_db.BinaryUUID]bool{}
+	for _, rule := range p.policy.Keep {
+		q := p.db.NewSelect().
+			TableExpr("? AS ?", bun.Ident("sessions"), bun.Ident("s")).
+			ColumnExpr("?", bun.Ident("s.id")).
+			Join("JOIN ? AS ? ON ? = ?", bun.Ident("labels"), bun.Ident("l"), bun.Ident("l.session_id"), bun.Ident("s.id")).
+			Where("? = ?", bun.Ident("l.key"), rule.Key).
+			OrderExpr("? DESC, ? DESC", bun.Ident("s.created_at"), bun.Ident("s.id")).
+			Limit(rule.Last)
+		if rule.Value != nil {
+			q = q.Where("COALESCE(?, '') = ?", bun.Ident("l.value"), *rule.Value)
+		}
+
+		var ids []model_db.BinaryUUID
+		if err := q.Scan(ctx, &ids); err != nil {
+			return nil, fmt.Errorf("failed to load kept sessions: %w", err)
+		}
+		for _, id := range ids {
+			protected[id] = true
+		}
+	}
+	return protected, nil
+}
+
+// selectSessions returns the next page of sessions, newest first, that are older than cutoff
+// or exceed the sessions kept per group. The page starts after the session after, if set.
+func (p *Purger) selectSessions(ctx context.Context, cutoff time.Time, after *sessionRow) ([]sessionRow, error) {
+	q := p.db.NewSelect().
+		TableExpr("? AS ?", bun.Ident("sessions"), bun.Ident("s")).
+		ColumnExpr("?, ?", bun.Ident("s.id"), bun.Ident("s.created_at")).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			if p.policy.MaxAge > 0 {
+				q = q.WhereOr("? < ?", bun.Ident("s.created_at"), cutoff)
+			}
+			if p.policy.MaxSessions > 0 {
+				q = q.WhereOr("? IN (?)", bun.Ident("s.id"), p.excessSessions())
+			}
+			return q
+		}).
+		OrderExpr("? DESC, ? DESC", bun.Ident("s.created_at"), bun.Ident("s.id")).
+		Limit(p.policy.BatchSize)
+	if after != nil {
+		q = q.Where("(? < ? OR (? = ? AND ? < ?))",
+			bun.Ident("s.created_at"), after.CreatedAt,
+			bun.Ident("s.created_at"), after.CreatedAt,
+			bun.Ident("s.id"), after.ID)
+	}
+
+	var sessions []sessionRow
+	if err := q.Scan(ctx, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// excessSessions selects the sessions beyond the newest MaxSessions of every combination of
+// group label values. Sessions missing any of the group labels do not belong to a combination.
+// Purging them does not change which of the remaining sessions are in excess.
+func (p *Purger) excessSessions() *bun.SelectQuery {
+	var partition []string
+	var args []any
+	ranked := p.db.NewSelect().TableExpr("? AS ?", bun.Ident("sessions"), bun.Ident("g"))
+	for i, key := range p.policy.GroupLabels {
+		alias := "l" + strconv.Itoa(i)
+		ranked = ranked.Join("JOIN ? AS ? ON ? = ? AND ? = ?",
+			bun.Ident("labels"), bun.Ident(alias),
+			bun.Ident(alias+".session_id"), bun.Ident("g.id"),
+			bun.Ident(alias+".key"), key)
+		partition = append(partition, "COALESCE(?, '')")
+		args = append(args, bun.Ident(alias+".value"))
+	}
+	args = append(args, bun.Ident("g.created_at"), bun.Ident("g.id"), bun.Ident("group_position"))
+	ranked = ranked.
+		ColumnExpr("?", bun.Ident("g.id")).
+		ColumnExpr("ROW_NUMBER() OVER (PARTITION BY "+strings.Join(partition, ", ")+" ORDER BY ? DESC, ? DESC) AS ?", args...)
+
+	return p.db.NewSelect().
+		TableExpr("(?) AS ?", ranked, bun.Ident("r")).
+		ColumnExpr("?", bun.Ident("r.id")).
+		Where("? > ?", bun.Ident("r.group_position"), p.policy.MaxSessions)
+}
+
+func (p *Purger) purgeBatch(ctx context.Context, ids []model_db.BinaryUUID, dryRun bool, report *Report) error {
+	if dryRun {
+		testcases, err := p.db.NewSelect().
+			Model((*model_db.Testcase)(nil)).
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count testcases: %w", err)
+		}
+
+		labels, err := p.db.NewSelect().
+			Model((*model_db.Label)(nil)).
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count labels: %w", err)
+		}
+
+		attachments, err := p.db.NewSelect().
+			Model((*model_db.Attachment)(nil)).
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count attachments: %w", err)
+		}
+
+		report.Testcases += int64(testcases)
+		report.Labels += int64(labels)
+		report.Attachments += int64(attachments)
+		report.Sessions += int64(len(ids))
+		return nil
+	}
+
+	var outputRefs, attachmentRefs []string
+	err := p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model((*model_db.Testcase)(nil)).
+			Column("output_ref").
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Where("? IS NOT NULL", bun.Ident("output_ref")).
+			Scan(ctx, &outputRefs)
+		if err != nil {
+			return fmt.Errorf("failed to load output references: %w", err)
+		}
+
+		err = tx.NewSelect().
+			Model((*model_db.Attachment)(nil)).
+			Column("storage_ref").
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Scan(ctx, &attachmentRefs)
+		if err != nil {
+			return fmt.Errorf("failed to load attachment references: %w", err)
+		}
+
+		res, err := tx.NewDelete().
+			Model((*model_db.Attachment)(nil)).
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete attachments: %w", err)
+		}
+		attachments, _ := res.RowsAffected()
+
+		res, err = tx.NewDelete().
+			Model((*model_db.Testcase)(nil)).
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete testcases: %w", err)
+		}
+		testcases, _ := res.RowsAffected()
+
+		res, err = tx.NewDelete().
+			Model((*model_db.Label)(nil)).
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete labels: %w", err)
+		}
+		labels, _ := res.RowsAffected()
+
+		_, err = tx.NewDelete().
+			Model((*model_db.SessionChange)(nil)).
+			Where("? IN (?)", bun.Ident("session_id"), bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete session changes: %w", err)
+		}
+
+		res, err = tx.NewDelete().
+			Model((*model_db.Session)(nil)).
+			Where("? IN (?)", bun.Ident("id"), bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete sessions: %w", err)
+		}
+		sessions, _ := res.RowsAffected()
+
+		report.Testcases += testcases
+		report.Labels += labels
+		report.Sessions += sessions
+		report.Attachments += attachments
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if p.stores.Outputs != nil {
+		if err := p.stores.Outputs.Delete(ctx, outputRefs); err != nil {
+			return err
+		}
+	}
+	if p.stores.Attachments != nil {
+		for _, ref := range attachmentRefs {
+			if err := p.stores.Attachments.Delete(ctx, ref); err != nil {
+				return fmt.Errorf("failed to delete attachment %s: %w", ref, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package retention
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeepRules(t *testing.T) {
+	rules, err := ParseKeepRules("branch=main:50, release:10")
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	assert.Equal(t, "branch", rules[0].Key)
+	require.NotNil(t, rules[0].Value)
+	assert.Equal(t, "main", *rules[0].Value)
+	assert.Equal(t, 50, rules[0].Last)
+
+	assert.Equal(t, "release", rules[1].Key)
+	assert.Nil(t, rules[1].Value)
+	assert.Equal(t, 10, rules[1].Last)
+
+	rules, err = ParseKeepRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, invalid := range []string{"branch=main", "branch:0", "branch:x", ":5", "=main:5"} {
+		_, err := ParseKeepRules(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	assert.NoError(t, Policy{MaxSessions: 10, GroupLabels: []string{"branch"}}.Validate())
+	assert.Error(t, Policy{MaxSessions: 10}.Validate())
+	assert.Error(t, Policy{MaxAge: -1}.Validate())
+	assert.True(t, Policy{Keep: []KeepRule{{Key: "branch", Last: 1}}}.IsEmpty())
+}
@@ -0,0 +1,173 @@
+package core_test
+
+import (
+	"context"
+	"time"
+
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+func (s *BaseSuite) createRetentionSession(ctx context.Context, createdAt time.Time, labels map[string]string) model_db.BinaryUUID {
+	sessionID := model_db.BinaryUUID(uuid.New())
+
+	_, err := s.db.NewInsert().Model(&model_db.Session{
+		ID:        sessionID,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		UserID:    s.userID,
+	}).Exec(ctx)
+	s.Require().NoError(err)
+
+	for key, value := range labels {
+		_, err = s.db.NewInsert().Model(&model_db.Label{
+			SessionID: sessionID,
+			Key:       key,
+			Value:     stringPtr(value),
+			UserID:    s.userID,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		}).Exec(ctx)
+		s.Require().NoError(err)
+	}
+
+	s.createRetentionTestcase(ctx, sessionID)
+
+	return sessionID
+}
+
+func (s *BaseSuite) createRetentionTestcase(ctx context.Context, sessionID model_db.BinaryUUID) uuid.UUID {
+	testcaseID := uuid.New()
+
+	_, err := s.db.NewInsert().Model(&model_db.Testcase{
+		ID:        model_db.BinaryUUID(testcaseID),
+		SessionID: sessionID,
+		Name:      "test_retention",
+		Status:    model_db.StatusPass,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    s.userID,
+	}).Exec(ctx)
+	s.Require().NoError(err)
+
+	return testcaseID
+}
+
+func (s *BaseSuite) sessionExists(ctx context.Context, id model_db.BinaryUUID) bool {
+	exists, err := s.db.NewSelect().
+		Model((*model_db.Session)(nil)).
+		Where("? = ?", bun.Ident("id"), id).
+		Exists(ctx)
+	s.Require().NoError(err)
+	return exists
+}
+
+func (s *BaseSuite) TestRetentionPurge() {
+	ctx := context.Background()
+	now := time.Now()
+
+	var sessions []model_db.BinaryUUID
+	for i := 0; i < 4; i++ {
+		sessions = append(sessions, s.createRetentionSession(ctx, now.Add(-time.Duration(72-i)*time.Hour), map[string]string{
+			"retention": "a",
+			"branch":    "main",
+		}))
+	}
+	other := s.createRetentionSession(ctx, now.Add(-72*time.Hour), map[string]string{"retention": "b"})
+
+	// oldest first: sessions[0] is the oldest, sessions[3] the newest
+	purger := retention.NewPurger(s.db, retention.Policy{
+		MaxSessions: 2,
+		GroupLabels: []string{"retention", "branch"},
+		BatchSize:   1,
+	}, retention.Stores{})
+
+	report, err := purger.Purge(ctx, true)
+	s.Require().NoError(err)
+	s.Equal(int64(2), report.Sessions)
+	s.Equal(int64(4), report.Labels)
+	s.Equal(int64(2), report.Testcases)
+	s.ElementsMatch([]model_db.BinaryUUID{sessions[0], sessions[1]}, report.SessionIDs)
+	s.True(s.sessionExists(ctx, sessions[0]))
+
+	report, err = purger.Purge(ctx, false)
+	s.Require().NoError(err)
+	s.Equal(int64(2), report.Sessions)
+	s.Equal(int64(4), report.Labels)
+	s.Equal(int64(2), report.Testcases)
+	s.False(s.sessionExists(ctx, sessions[0]))
+	s.False(s.sessionExists(ctx, sessions[1]))
+	s.True(s.sessionExists(ctx, sessions[2]))
+	s.True(s.sessionExists(ctx, other))
+
+	testcases, err := s.db.NewSelect().
+		Model((*model_db.Testcase)(nil)).
+		Where("? IN (?)", bun.Ident("session_id"), bun.In(sessions[:2])).
+		Count(ctx)
+	s.Require().NoError(err)
+	s.Equal(0, testcases)
+
+	keep, err := retention.ParseKeepRules("retention=a:1")
+	s.Require().NoError(err)
+	purger = retention.NewPurger(s.db, retention.Policy{
+		MaxAge: 24 * time.Hour,
+		Keep:   keep,
+	}, retention.Stores{})
+
+	report, err = purger.Purge(ctx, false)
+	s.Require().NoError(err)
+	s.Equal(int64(2), report.Sessions)
+	s.False(s.sessionExists(ctx, sessions[2]))
+	s.False(s.sessionExists(ctx, other))
+	s.True(s.sessionExists(ctx, sessions[3]))
+
+	// fixture sessions are recent and must be untouched
+	s.True(s.sessionExists(ctx, model_db.BinaryUUID(s.session1Id)))
+	s.True(s.sessionExists(ctx, model_db.BinaryUUID(s.session2Id)))
+	s.True(s.sessionExists(ctx, model_db.BinaryUUID(s.session3Id)))
+
+	_, err = s.db.NewDelete().Model((*model_db.Testcase)(nil)).Where("? = ?", bun.Ident("session_id"), sessions[3]).Exec(ctx)
+	s.Require().NoError(err)
+	_, err = s.db.NewDelete().Model((*model_db.Label)(nil)).Where("? = ?", bun.Ident("session_id"), sessions[3]).Exec(ctx)
+	s.Require().NoError(err)
+	_, err = s.db.NewDelete().Model((*model_db.Session)(nil)).Where("? = ?", bun.Ident("id"), sessions[3]).Exec(ctx)
+	s.Require().NoError(err)
+}
+
+func (s *BaseSuite) TestRetentionPurgeGroups() {
+	ctx := context.Background()
+	now := time.Now()
+
+	// oldest first; the groups are only told apart by the label values
+	var linux, windows []model_db.BinaryUUID
+	for i := 0; i < 5; i++ {
+		createdAt := now.Add(-time.Duration(100-i) * time.Hour)
+		linux = append(linux, s.createRetentionSession(ctx, createdAt, map[string]string{"purge": "groups", "os": "linux"}))
+		windows = append(windows, s.createRetentionSession(ctx, createdAt, map[string]string{"purge": "groups", "os": "windows", "extra": "x"}))
+	}
+	ungrouped := s.createRetentionSession(ctx, now.Add(-200*time.Hour), map[string]string{"purge": "groups"})
+	defer func() {
+		_, err := retention.NewPurger(s.db, retention.Policy{MaxAge: 72 * time.Hour}, retention.Stores{}).Purge(ctx, false)
+		s.Require().NoError(err)
+	}()
+
+	// pages of two sessions are selected while the previous pages are purged
+	keep, err := retention.ParseKeepRules("os=linux:3")
+	s.Require().NoError(err)
+	report, err := retention.NewPurger(s.db, retention.Policy{
+		MaxSessions: 2,
+		GroupLabels: []string{"os", "purge"},
+		Keep:        keep,
+		BatchSize:   2,
+	}, retention.Stores{}).Purge(ctx, false)
+	s.Require().NoError(err)
+	s.Equal(int64(5), report.Sessions)
+	s.ElementsMatch([]model_db.BinaryUUID{windows[0], windows[1], windows[2], linux[0], linux[1]}, report.SessionIDs)
+
+	for _, id := range append(linux[2:], windows[3:]...) {
+		s.True(s.sessionExists(ctx, id))
+	}
+	s.True(s.sessionExists(ctx, ungrouped))
+}
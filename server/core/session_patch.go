@@ -0,0 +1,328 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/uptrace/bun"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionLimit is wrapped by the errors of patches that would take a session past its
+// label or baggage limits once applied.
+var ErrSessionLimit = errors.New("session limit exceeded")
+
+// SessionActor identifies who modifies a session. APIKeyID is set for changes made via ingress.
+type SessionActor struct {
+	UserID   model_db.BinaryUUID
+	APIKeyID *model_db.BinaryUUID
+}
+
+type SessionPatch struct {
+	Description *string
+	// Baggage is applied as a JSON merge patch (RFC 7386): null values remove keys.
+	// With ReplaceBaggage it replaces the baggage instead.
+	Baggage        map[string]any
+	ReplaceBaggage bool
+	SetLabels      []LabelRequest
+	RemoveLabels   []string
+}
+
+func (p SessionPatch) Validate() error {
+	if len(p.SetLabels) > maxSessionLabels {
+		return fmt.Errorf("more than %d labels", maxSessionLabels)
+	}
+	for _, label := range p.SetLabels {
+		if label.Key == "" {
+			return fmt.Errorf("label key is required")
+		}
+		if utf8.RuneCountInString(label.Key) > maxLabelKeyLength {
+			return fmt.Errorf("label key is longer than %d characters", maxLabelKeyLength)
+		}
+	}
+	for _, key := range p.RemoveLabels {
+		if key == "" {
+			return fmt.Errorf("label key is required")
+		}
+	}
+	if p.Baggage != nil {
+		data, err := json.Marshal(p.Baggage)
+		if err != nil {
+			return fmt.Errorf("invalid baggage format")
+		}
+		if len(data) > maxBaggageSize {
+			return fmt.Errorf("baggage is larger than %d bytes", maxBaggageSize)
+		}
+	}
+	return nil
+}
+
+// summary describes what p changes, for the audit log.
+func (p SessionPatch) summary() string {
+	var parts []string
+	if p.Description != nil {
+		parts = append(parts, "description")
+	}
+	if p.Baggage != nil || p.ReplaceBaggage {
+		parts = append(parts, "baggage")
+	}
+	if len(p.SetLabels) > 0 {
+		keys := make([]string, len(p.SetLabels))
+		for i, label := range p.SetLabels {
+			keys[i] = label.Key
+		}
+		parts = append(parts, "set labels "+strings.Join(keys, ", "))
+	}
+	if len(p.RemoveLabels) > 0 {
+		parts = append(parts, "removed labels "+strings.Join(p.RemoveLabels, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// PatchSession applies patch to a session and records every change made.
+// Authorization is up to the caller.
+func PatchSession(ctx context.Context, db *bun.DB, sessionID model_db.BinaryUUID, patch SessionPatch, actor SessionActor) error {
+	if err := patch.Validate(); err != nil {
+		return err
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var session model_db.Session
+		err := tx.NewSelect().
+			Model(&session).
+			Where("? = ?", bun.Ident("id"), sessionID).
+			Scan(ctx)
+		if err != nil {
+			return ErrSessionNotFound
+		}
+
+		now := time.Now()
+		var changes []model_db.SessionChange
+		record := func(field model_db.SessionChangeField, key, oldValue, newValue *string) {
+			changes = append(changes, model_db.SessionChange{
+				SessionID: sessionID,
+				Field:     field,
+				Key:       key,
+				OldValue:  oldValue,
+				NewValue:  newValue,
+				UserID:    actor.UserID,
+				APIKeyID:  actor.APIKeyID,
+				CreatedAt: now,
+			})
+		}
+
+		sessionUpdated := false
+
+		if patch.Description != nil {
+			newDescription := patch.Description
+			if *newDescription == "" {
+				newDescription = nil
+			}
+			if !equalStringPtr(session.Description, newDescription) {
+				record(model_db.SessionChangeDescription, nil, session.Description, newDescription)
+				session.Description = newDescription
+				sessionUpdated = true
+			}
+		}
+
+		if patch.Baggage != nil || patch.ReplaceBaggage {
+			newBaggage, err := patchBaggage(session.Baggage, patch.Baggage, patch.ReplaceBaggage)
+			if err != nil {
+				return err
+			}
+			if len(newBaggage) > maxBaggageSize {
+				return fmt.Errorf("%w: baggage is larger than %d bytes", ErrSessionLimit, maxBaggageSize)
+			}
+			oldValue, newValue := rawJSONPtr(session.Baggage), rawJSONPtr(newBaggage)
+			if !equalStringPtr(oldValue, newValue) {
+				record(model_db.SessionChangeBaggage, nil, oldValue, newValue)
+				session.Baggage = newBaggage
+				sessionUpdated = true
+			}
+		}
+
+		if sessionUpdated {
+			session.UpdatedAt = now
+			_, err := tx.NewUpdate().
+				Model(&session).
+				Column("description", "baggage", "updated_at").
+				Where("? = ?", bun.Ident("id"), sessionID).
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to update session: %w", err)
+			}
+		}
+
+		if len(patch.SetLabels) > 0 || len(patch.RemoveLabels) > 0 {
+			var labels []model_db.Label
+			err := tx.NewSelect().
+				Model(&labels).
+				Where("? = ?", bun.Ident("session_id"), sessionID).
+				Scan(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to load labels: %w", err)
+			}
+
+			current := map[string]*string{}
+			for _, label := range labels {
+				current[label.Key] = label.Value
+			}
+
+			keys := make(map[string]bool, len(current))
+			for key := range current {
+				keys[key] = true
+			}
+			for _, key := range patch.RemoveLabels {
+				delete(keys, key)
+			}
+			for _, label := range patch.SetLabels {
+				keys[label.Key] = true
+			}
+			if len(keys) > maxSessionLabels {
+				return fmt.Errorf("%w: more than %d labels", ErrSessionLimit, maxSessionLabels)
+			}
+
+			for _, key := range patch.RemoveLabels {
+				oldValue, ok := current[key]
+				if !ok {
+					continue
+				}
+				if err := deleteLabel(ctx, tx, sessionID, key); err != nil {
+					return err
+				}
+				delete(current, key)
+				record(model_db.SessionChangeLabel, &key, labelValuePtr(oldValue), nil)
+			}
+
+			for _, label := range patch.SetLabels {
+				oldValue, ok := current[label.Key]
+				if ok && equalStringPtr(oldValue, label.Value) {
+					continue
+				}
+				if ok {
+					if err := deleteLabel(ctx, tx, sessionID, label.Key); err != nil {
+						return err
+					}
+				}
+
+				_, err := tx.NewInsert().Model(&model_db.Label{
+					SessionID: sessionID,
+					Key:       label.Key,
+					Value:     label.Value,
+					UserID:    session.UserID,
+					CreatedAt: now,
+					UpdatedAt: now,
+				}).Exec(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to insert label: %w", err)
+				}
+
+				key := label.Key
+				current[key] = label.Value
+				var old *string
+				if ok {
+					old = labelValuePtr(oldValue)
+				}
+				record(model_db.SessionChangeLabel, &key, old, labelValuePtr(label.Value))
+			}
+		}
+
+		if len(changes) > 0 {
+			if _, err := tx.NewInsert().Model(&changes).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to record session changes: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func deleteLabel(ctx context.Context, tx bun.Tx, sessionID model_db.BinaryUUID, key string) error {
+	_, err := tx.NewDelete().
+		Model((*model_db.Label)(nil)).
+		Where("? = ?", bun.Ident("session_id"), sessionID).
+		Where("? = ?", bun.Ident("key"), key).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete label: %w", err)
+	}
+	return nil
+}
+
+// labelValuePtr returns the value recorded for a label: labels without a value are recorded as "".
+func labelValuePtr(value *string) *string {
+	if value == nil {
+		empty := ""
+		return &empty
+	}
+	return value
+}
+
+func patchBaggage(current json.RawMessage, patch map[string]any, replace bool) (json.RawMessage, error) {
+	var result map[string]any
+	if replace {
+		result = patch
+	} else {
+		result = map[string]any{}
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &result); err != nil {
+				return nil, fmt.Errorf("existing baggage is not a JSON object")
+			}
+		}
+		mergePatch(result, patch)
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(result)
+}
+
+func mergePatch(target, patch map[string]any) {
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		patchObj, ok := value.(map[string]any)
+		if !ok {
+			target[key] = value
+			continue
+		}
+		targetObj, ok := target[key].(map[string]any)
+		if !ok {
+			targetObj = map[string]any{}
+		}
+		mergePatch(targetObj, patchObj)
+		target[key] = targetObj
+	}
+}
+
+func rawJSONPtr(raw json.RawMessage) *string {
+	if len(raw) == 0 {
+		return nil
+	}
+	// normalize so that equal documents compare equal
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		s := string(raw)
+		return &s
+	}
+	normalized, _ := json.Marshal(v)
+	s := string(normalized)
+	return &s
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
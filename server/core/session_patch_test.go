@@ -0,0 +1,308 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/retention"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+func (s *BaseSuite) sessionLabels(ctx context.Context, sessionID model_db.BinaryUUID) map[string]string {
+	var labels []model_db.Label
+	err := s.db.NewSelect().
+		Model(&labels).
+		Where("? = ?", bun.Ident("session_id"), sessionID).
+		Scan(ctx)
+	s.Require().NoError(err)
+
+	result := map[string]string{}
+	for _, label := range labels {
+		value := ""
+		if label.Value != nil {
+			value = *label.Value
+		}
+		result[label.Key] = value
+	}
+	return result
+}
+
+func (s *BaseSuite) TestPatchSession() {
+	ctx := context.Background()
+
+	sessionID := s.createScratchSession(map[string]string{
+		"patch":  "test",
+		"rerun":  "",
+		"deploy": "pending",
+	})
+	_, err := s.db.NewUpdate().
+		Model((*model_db.Session)(nil)).
+		Set("? = ?", bun.Ident("baggage"), `{"version": "1.0", "ci": {"job": 1, "runner": "a"}}`).
+		Where("? = ?", bun.Ident("id"), sessionID).
+		Exec(ctx)
+	s.Require().NoError(err)
+
+	apiKeyID := model_db.BinaryUUID(uuid.New())
+	_, err = s.db.NewInsert().Model(&model_db.APIKey{
+		ID:          apiKeyID,
+		Description: stringPtr("ci key"),
+		SecretSalt:  []byte("salt"),
+		SecretHash:  []byte("hash"),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		UserID:      s.userID,
+	}).Exec(ctx)
+	s.Require().NoError(err)
+	_, err = s.db.NewUpdate().
+		Model((*model_db.Session)(nil)).
+		Set("? = ?", bun.Ident("api_key_id"), apiKeyID).
+		Where("? = ?", bun.Ident("id"), sessionID).
+		Exec(ctx)
+	s.Require().NoError(err)
+
+	body := `{
+		"description": "nightly",
+		"baggage": {"version": "1.1", "ci": {"runner": null}, "pr": 42},
+		"labels": [{"key": "deploy", "value": "success"}, {"key": "pr", "value": "42"}, {"key": "patch", "value": "test"}],
+		"removeLabels": ["rerun", "missing"]
+	}`
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/ingress/sessions/"+sessionID.String(), strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	apiKey := &model_db.APIKey{ID: apiKeyID, UserID: s.userID}
+	c.SetRequest(req.WithContext(core.WithAPIKey(ctx, apiKey)))
+	c.Set("user_id", s.userID)
+	c.Set("apikey", apiKey)
+
+	handler := core.NewIngressHandler(s.db, nil)
+	s.Require().NoError(handler.PatchSession(c))
+	s.Equal(http.StatusNoContent, rec.Code)
+
+	s.Equal(map[string]string{"patch": "test", "deploy": "success", "pr": "42"}, s.sessionLabels(ctx, sessionID))
+
+	var session model_db.Session
+	err = s.db.NewSelect().Model(&session).Where("? = ?", bun.Ident("id"), sessionID).Scan(ctx)
+	s.Require().NoError(err)
+	s.Require().NotNil(session.Description)
+	s.Equal("nightly", *session.Description)
+	var baggage map[string]any
+	s.Require().NoError(json.Unmarshal(session.Baggage, &baggage))
+	s.Equal(map[string]any{"version": "1.1", "ci": map[string]any{"job": float64(1)}, "pr": float64(42)}, baggage)
+
+	// sessions of other users cannot be patched
+	c = e.NewContext(httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"description": "x"}`)), httptest.NewRecorder())
+	c.Request().Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	c.Set("user_id", model_db.BinaryUUID(uuid.New()))
+	err = handler.PatchSession(c)
+	var httpErr *echo.HTTPError
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(http.StatusNotFound, httpErr.Code)
+
+	// viewers cannot edit from the UI
+	c, rec = setupAPIKeyContext(s.T(), http.MethodPost, "/sessions/"+sessionID.String()+"/labels", "key=rerun", true, s.userID.String(), string(model_db.RoleViewer), s.db)
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	s.Require().NoError(core.SetSessionLabelHandler(c))
+	s.Equal(http.StatusForbidden, rec.Code)
+
+	c, rec = setupAPIKeyContext(s.T(), http.MethodPost, "/sessions/"+sessionID.String()+"/labels", "key=rerun", true, s.userID.String(), string(model_db.RoleEditor), s.db)
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	s.Require().NoError(core.SetSessionLabelHandler(c))
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("/sessions/"+sessionID.String()+"/details", rec.Header().Get("HX-Redirect"))
+
+	c, rec = setupAPIKeyContext(s.T(), http.MethodDelete, "/sessions/"+sessionID.String()+"/labels?key=deploy", "", true, s.userID.String(), string(model_db.RoleEditor), s.db)
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	s.Require().NoError(core.DeleteSessionLabelHandler(c))
+	s.Equal(http.StatusOK, rec.Code)
+
+	c, rec = setupAPIKeyContext(s.T(), http.MethodPost, "/sessions/"+sessionID.String()+"/edit", "description=&baggage=%5B1%5D", true, s.userID.String(), string(model_db.RoleEditor), s.db)
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	s.Require().NoError(core.EditSessionHandler(c))
+	s.Equal(http.StatusBadRequest, rec.Code)
+
+	c, rec = setupAPIKeyContext(s.T(), http.MethodPost, "/sessions/"+sessionID.String()+"/edit", "description=&baggage=", true, s.userID.String(), string(model_db.RoleEditor), s.db)
+	c.SetParamNames("id")
+	c.SetParamValues(sessionID.String())
+	s.Require().NoError(core.EditSessionHandler(c))
+	s.Equal(http.StatusOK, rec.Code)
+
+	s.Equal(map[string]string{"patch": "test", "pr": "42", "rerun": ""}, s.sessionLabels(ctx, sessionID))
+
+	detail, err := core.NewQueryService(s.db).GetSession(ctx, s.userID, uuid.UUID(sessionID))
+	s.Require().NoError(err)
+	s.Empty(detail.Description)
+	s.Nil(detail.Baggage)
+
+	type change struct {
+		Field, Key, Old, New, By string
+	}
+	deref := func(v *string) string {
+		if v == nil {
+			return "<nil>"
+		}
+		return *v
+	}
+	var changes []change
+	for _, ch := range detail.Changes {
+		changes = append(changes, change{ch.Field, ch.Key, deref(ch.OldValue), deref(ch.NewValue), ch.ChangedBy})
+	}
+
+	// newest first
+	s.Equal([]change{
+		{"baggage", "", `{"ci":{"job":1},"pr":42,"version":"1.1"}`, "<nil>", "testuser"},
+		{"description", "", "nightly", "<nil>", "testuser"},
+		{"label", "deploy", "success", "<nil>", "testuser"},
+		{"label", "rerun", "<nil>", "", "testuser"},
+		{"label", "pr", "<nil>", "42", "testuser (API key: ci key)"},
+		{"label", "deploy", "pending", "success", "testuser (API key: ci key)"},
+		{"label", "rerun", "", "<nil>", "testuser (API key: ci key)"},
+		{"baggage", "", `{"ci":{"job":1,"runner":"a"},"version":"1.0"}`, `{"ci":{"job":1},"pr":42,"version":"1.1"}`, "testuser (API key: ci key)"},
+		{"description", "", "<nil>", "nightly", "testuser (API key: ci key)"},
+	}, changes)
+
+	_, err = s.db.NewDelete().Model((*model_db.APIKey)(nil)).Where("? = ?", bun.Ident("id"), apiKeyID).Exec(ctx)
+	s.Require().NoError(err)
+}
+
+func (s *BaseSuite) TestPatchSessionAPIKeys() {
+	ctx := context.Background()
+	handler := core.NewIngressHandler(s.db, output.DefaultStorage())
+	_, creatorKey := s.createAPIKey(ctx, "creator", nil)
+	_, otherKey := s.createAPIKey(ctx, "other", nil)
+	_, adminKey := s.createAPIKey(ctx, "admin", func(k *model_db.APIKey) { k.Scope = model_db.ScopeAdmin })
+
+	send := func(apiKey string, method, path string, handle echo.HandlerFunc, body string) (*httptest.ResponseRecorder, int) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		if id, ok := strings.CutPrefix(path, "/api/v1/ingress/sessions/"); ok {
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+		}
+		err := core.APIKeyAuth(s.db, testSecurity, model_db.ScopeIngest)(handle)(c)
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return rec, httpErr.Code
+		}
+		s.Require().NoError(err)
+		return rec, rec.Code
+	}
+	patch := func(apiKey string, sessionID string) int {
+		_, code := send(apiKey, http.MethodPatch, "/api/v1/ingress/sessions/"+sessionID, handler.PatchSession, `{"description": "patched"}`)
+		return code
+	}
+
+	rec, code := send(creatorKey, http.MethodPost, "/api/v1/ingress/sessions", handler.CreateSession, `{}`)
+	s.Require().Equal(http.StatusCreated, code)
+	var session core.SessionResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &session))
+	legacy := s.createScratchSession(nil)
+	s.purgeOnCleanup(model_db.BinaryUUID(uuid.MustParse(session.ID)), retention.Stores{})
+
+	// other keys of the user cannot patch the session, admin keys act as editors
+	s.Equal(http.StatusForbidden, patch(otherKey, session.ID))
+	s.Equal(http.StatusNoContent, patch(creatorKey, session.ID))
+	s.Equal(http.StatusNoContent, patch(adminKey, session.ID))
+
+	// sessions created before their key was recorded can only be patched by admin keys
+	s.Equal(http.StatusForbidden, patch(creatorKey, legacy.String()))
+	s.Equal(http.StatusNoContent, patch(adminKey, legacy.String()))
+}
+
+func (s *BaseSuite) TestPatchSessionFromUIErrors() {
+	ctx := context.Background()
+	sessionID := s.createScratchSession(map[string]string{"deploy": "pending"})
+
+	edit := func(db *bun.DB, description string) (int, string) {
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/sessions/"+sessionID.String()+"/labels", "key="+description, true, s.userID.String(), string(model_db.RoleEditor), db)
+		c.SetParamNames("id")
+		c.SetParamValues(sessionID.String())
+		s.Require().NoError(core.SetSessionLabelHandler(c))
+		return rec.Code, rec.Body.String()
+	}
+
+	// invalid patches are rejected with the reason
+	code, body := edit(s.db, strings.Repeat("k", 300))
+	s.Equal(http.StatusBadRequest, code)
+	s.Contains(body, "label key is longer than")
+
+	// database errors are not shown
+	hook := &failOnceHook{prefix: "INSERT INTO " + string(s.db.Dialect().IdentQuote()) + "labels"}
+	db := bun.NewDB(s.db.DB, s.db.Dialect())
+	db.AddQueryHook(hook)
+	code, body = edit(db, "rerun")
+	s.True(hook.failed.Load())
+	s.Equal(http.StatusInternalServerError, code)
+	s.Equal("<span>Failed to update session</span>", body)
+	s.Equal(map[string]string{"deploy": "pending"}, s.sessionLabels(ctx, sessionID))
+}
+
+func (s *BaseSuite) TestPatchSessionLimits() {
+	ctx := context.Background()
+	labels := map[string]string{}
+	for i := range 99 {
+		labels[fmt.Sprintf("label_%d", i)] = ""
+	}
+	sessionID := s.createScratchSession(labels)
+	baggage, err := json.Marshal(map[string]string{"first": strings.Repeat("a", 40<<10)})
+	s.Require().NoError(err)
+	_, err = s.db.NewUpdate().
+		Model((*model_db.Session)(nil)).
+		Set("? = ?", bun.Ident("baggage"), string(baggage)).
+		Where("? = ?", bun.Ident("id"), sessionID).
+		Exec(ctx)
+	s.Require().NoError(err)
+	actor := core.SessionActor{UserID: s.userID}
+
+	// the limits apply to the session once patched, not only to the patch
+	err = core.PatchSession(ctx, s.db, sessionID, core.SessionPatch{
+		SetLabels: []core.LabelRequest{{Key: "new_1"}, {Key: "new_2"}},
+	}, actor)
+	s.ErrorIs(err, core.ErrSessionLimit)
+	s.Len(s.sessionLabels(ctx, sessionID), 99)
+
+	err = core.PatchSession(ctx, s.db, sessionID, core.SessionPatch{
+		Description: stringPtr("rolled back"),
+		Baggage:     map[string]any{"second": strings.Repeat("b", 30<<10)},
+	}, actor)
+	s.ErrorIs(err, core.ErrSessionLimit)
+	session, err := core.NewQueryService(s.db).GetSession(ctx, s.userID, uuid.UUID(sessionID))
+	s.Require().NoError(err)
+	s.Empty(session.Description)
+
+	// removed labels make room for new ones, and replaced baggage does not add up
+	err = core.PatchSession(ctx, s.db, sessionID, core.SessionPatch{
+		SetLabels:    []core.LabelRequest{{Key: "new_1"}, {Key: "new_2"}, {Key: "label_0"}},
+		RemoveLabels: []string{"label_0", "label_1"},
+	}, actor)
+	s.Require().NoError(err)
+	s.Len(s.sessionLabels(ctx, sessionID), 100)
+
+	err = core.PatchSession(ctx, s.db, sessionID, core.SessionPatch{
+		Baggage:        map[string]any{"second": strings.Repeat("b", 30<<10)},
+		ReplaceBaggage: true,
+	}, actor)
+	s.Require().NoError(err)
+}
@@ -1,15 +1,19 @@
 package core
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
+	"strings"
 
+	"github.com/cephei8/greener/server/core/audit"
 	model_db "github.com/cephei8/greener/server/core/model/db"
 	"github.com/google/uuid"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
 )
 
 func SessionsHandler(c echo.Context) error {
@@ -21,7 +25,7 @@ func SessionsHandler(c echo.Context) error {
 	}
 
 	svc := c.Get("queryService").(QueryServiceInterface)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
 	queryStr := c.FormValue("query")
 	if queryStr == "" {
@@ -57,12 +61,14 @@ func SessionsHandler(c echo.Context) error {
 		"Query":           queryStr,
 		"ActivePage":      "sessions",
 		"IsAuthenticated": auth,
+		"IsAdmin":         IsAdmin(c),
 	})
 }
 
 func SessionDetailHandler(c echo.Context) error {
 	sess, _ := session.Get("session", c)
 	auth, _ := sess.Values["authenticated"].(bool)
+	role, _ := sess.Values["role"].(string)
 
 	if !auth && !AllowUnauthenticatedViewers(c) {
 		return c.Redirect(http.StatusFound, "/login")
@@ -75,7 +81,7 @@ func SessionDetailHandler(c echo.Context) error {
 	}
 
 	svc := c.Get("queryService").(QueryServiceInterface)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
 	result, err := svc.GetSession(ctx, model_db.BinaryUUID(uuid.Nil), sessionId)
 	if err != nil {
@@ -103,6 +109,11 @@ func SessionDetailHandler(c echo.Context) error {
 		})
 	}
 
+	testcaseTree := map[string]any{
+		"Testcases": result.Testcases,
+		"Truncated": result.TestcasesTruncated,
+	}
+
 	return c.Render(http.StatusOK, "session_detail.html", map[string]any{
 		"Session": map[string]any{
 			"ID":          result.ID,
@@ -112,7 +123,110 @@ func SessionDetailHandler(c echo.Context) error {
 			"CreatedAt":   result.CreatedAt,
 		},
 		"Labels":          labelList,
+		"Attachments":     result.Attachments,
+		"Changes":         result.Changes,
+		"Testcases":       testcaseTree,
+		"CanEdit":         auth && role != string(model_db.RoleViewer),
 		"ActivePage":      "sessions",
 		"IsAuthenticated": auth,
+		"IsAdmin":         IsAdmin(c),
 	})
 }
+
+func sessionEditor(c echo.Context) (SessionActor, bool) {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return SessionActor{}, false
+	}
+
+	role, _ := sess.Values["role"].(string)
+	if role == string(model_db.RoleViewer) {
+		return SessionActor{}, false
+	}
+
+	userIDStr, _ := sess.Values["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return SessionActor{}, false
+	}
+
+	return SessionActor{UserID: model_db.BinaryUUID(userID)}, true
+}
+
+func patchSessionFromUI(c echo.Context, patch SessionPatch) error {
+	actor, ok := sessionEditor(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<span>Editor role is required to edit sessions</span>`)
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, `<span>Invalid session ID</span>`)
+	}
+
+	if err := patch.Validate(); err != nil {
+		return c.HTML(http.StatusBadRequest, fmt.Sprintf("<span>%s</span>", html.EscapeString(err.Error())))
+	}
+
+	db := c.Get("db").(*bun.DB)
+	err = PatchSession(c.Request().Context(), db, model_db.BinaryUUID(sessionID), patch, actor)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return c.HTML(http.StatusNotFound, `<span>Session not found</span>`)
+		}
+		if errors.Is(err, ErrSessionLimit) {
+			return c.HTML(http.StatusBadRequest, fmt.Sprintf("<span>%s</span>", html.EscapeString(err.Error())))
+		}
+		c.Logger().Errorf("Failed to update session: %v", err)
+		return c.HTML(http.StatusInternalServerError, `<span>Failed to update session</span>`)
+	}
+
+	audit.RecordRequest(c, db, audit.RequestActor(c), audit.Event{
+		Action:     audit.ActionSessionUpdate,
+		TargetType: audit.TargetSession,
+		TargetID:   sessionID.String(),
+		Details:    patch.summary(),
+	})
+
+	c.Response().Header().Set("HX-Redirect", "/sessions/"+sessionID.String()+"/details")
+	return c.NoContent(http.StatusOK)
+}
+
+func SetSessionLabelHandler(c echo.Context) error {
+	key := strings.TrimSpace(c.FormValue("key"))
+	if key == "" {
+		return c.HTML(http.StatusBadRequest, `<span>Label key is required</span>`)
+	}
+
+	label := LabelRequest{Key: key}
+	if value := c.FormValue("value"); value != "" {
+		label.Value = &value
+	}
+
+	return patchSessionFromUI(c, SessionPatch{SetLabels: []LabelRequest{label}})
+}
+
+func DeleteSessionLabelHandler(c echo.Context) error {
+	key := c.QueryParam("key")
+	if key == "" {
+		return c.HTML(http.StatusBadRequest, `<span>Label key is required</span>`)
+	}
+
+	return patchSessionFromUI(c, SessionPatch{RemoveLabels: []string{key}})
+}
+
+func EditSessionHandler(c echo.Context) error {
+	description := strings.TrimSpace(c.FormValue("description"))
+	patch := SessionPatch{
+		Description:    &description,
+		ReplaceBaggage: true,
+	}
+
+	if baggageStr := strings.TrimSpace(c.FormValue("baggage")); baggageStr != "" {
+		if err := json.Unmarshal([]byte(baggageStr), &patch.Baggage); err != nil {
+			return c.HTML(http.StatusBadRequest, `<span>Baggage must be a JSON object</span>`)
+		}
+	}
+
+	return patchSessionFromUI(c, patch)
+}
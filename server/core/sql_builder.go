@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cephei8/greener/server/core/model/db"
 	"github.com/cephei8/greener/server/core/query"
@@ -27,6 +28,8 @@ func convertQueryStatusToDBStatus(status query.TestcaseStatus) model_db.Testcase
 		return model_db.StatusError
 	case query.StatusSkip:
 		return model_db.StatusSkip
+	case query.StatusFlaky:
+		return model_db.StatusFlaky
 	default:
 		panic(fmt.Sprintf("unknown status: %s", status))
 	}
@@ -56,16 +59,28 @@ func applyOffsetLimit(bunQuery *bun.SelectQuery, queryAST query.Query) (*bun.Sel
 	return bunQuery, nil
 }
 
+var testcaseListColumns = []string{
+	"id", "session_id", "name", "classname", "file", "testsuite", "output",
+	"status", "baggage", "created_at", "updated_at", "user_id",
+}
+
 func BuildTestcasesQuery(
 	db *bun.DB,
 	userID model_db.BinaryUUID,
 	queryAST query.Query,
 ) (*bun.SelectQuery, error) {
 	cteQuery := db.NewSelect().
-		Column(fmt.Sprintf("%s.*", testcasesTable)).
 		Table(fmt.Sprintf("%s", testcasesTable)).
 		OrderBy(fmt.Sprintf("%s.created_at", testcasesTable), bun.OrderDesc)
 
+	// Stored output (compressed or offloaded) is only loaded by GetTestcase.
+	for _, col := range testcaseListColumns {
+		cteQuery = cteQuery.Column(fmt.Sprintf("%s.%s", testcasesTable, col))
+	}
+
+	// Earlier attempts of retried testcases are only listed on the testcase details page.
+	cteQuery = cteQuery.Where("? = ?", bun.Ident(fmt.Sprintf("%s.superseded", testcasesTable)), false)
+
 	if queryAST.StartDate != nil {
 		cteQuery = cteQuery.Where("? >= ?", bun.Ident(fmt.Sprintf("%s.created_at", testcasesTable)), queryAST.StartDate)
 	}
@@ -139,10 +154,12 @@ func BuildSessionsQuery(
 		).
 		Table(fmt.Sprintf("%s", sessionsTable)).
 		Join(
-			"LEFT JOIN ? ON ? = ?",
+			"LEFT JOIN ? ON ? = ? AND ? = ?",
 			bun.Ident(fmt.Sprintf("%s", testcasesTable)),
 			bun.Ident(fmt.Sprintf("%s.id", sessionsTable)),
 			bun.Ident(fmt.Sprintf("%s.session_id", testcasesTable)),
+			bun.Ident(fmt.Sprintf("%s.superseded", testcasesTable)),
+			false,
 		).
 		Group(fmt.Sprintf("%s.id", sessionsTable)).
 		OrderBy(fmt.Sprintf("%s.created_at", sessionsTable), bun.OrderDesc)
@@ -217,7 +234,8 @@ func BuildGroupsQuery(db *bun.DB, userID model_db.BinaryUUID, queryAST query.Que
 	orderCols := []string{}
 
 	cteQuery := db.NewSelect().
-		Table(fmt.Sprintf("%s", testcasesTable))
+		Table(fmt.Sprintf("%s", testcasesTable)).
+		Where("? = ?", bun.Ident(fmt.Sprintf("%s.superseded", testcasesTable)), false)
 
 	labelJoinIdx := 0
 	for _, token := range groupBy.Tokens {
@@ -333,6 +351,40 @@ func BuildGroupsQuery(db *bun.DB, userID model_db.BinaryUUID, queryAST query.Que
 	return mainQuery, nil
 }
 
+func BuildTestcaseStatsQuery(
+	db *bun.DB,
+	queryAST query.Query,
+	since time.Time,
+) (*bun.SelectQuery, error) {
+	if queryAST.GroupQuery != nil {
+		return nil, fmt.Errorf("group_by is not supported in stats queries")
+	}
+
+	statusCol := bun.Ident(fmt.Sprintf("%s.status", testcasesTable))
+	createdAtCol := bun.Ident(fmt.Sprintf("%s.created_at", testcasesTable))
+
+	q := db.NewSelect().
+		Table(fmt.Sprintf("%s", testcasesTable)).
+		ColumnExpr("COUNT(*) AS ?", bun.Ident("total_count")).
+		ColumnExpr("COALESCE(SUM(CASE WHEN ? IN (?, ?) THEN 1 ELSE 0 END), 0) AS ?", statusCol, model_db.StatusPass, model_db.StatusFlaky, bun.Ident("pass_count")).
+		ColumnExpr("COALESCE(SUM(CASE WHEN ? IN (?, ?) THEN 1 ELSE 0 END), 0) AS ?", statusCol, model_db.StatusFail, model_db.StatusError, bun.Ident("failure_count")).
+		ColumnExpr("COALESCE(SUM(CASE WHEN ? = ? THEN 1 ELSE 0 END), 0) AS ?", statusCol, model_db.StatusSkip, bun.Ident("skip_count")).
+		ColumnExpr("COALESCE(SUM(CASE WHEN ? = ? THEN 1 ELSE 0 END), 0) AS ?", statusCol, model_db.StatusFlaky, bun.Ident("flaky_count")).
+		Where("? >= ?", createdAtCol, since).
+		Where("? = ?", bun.Ident(fmt.Sprintf("%s.superseded", testcasesTable)), false)
+
+	if queryAST.StartDate != nil {
+		q = q.Where("? >= ?", createdAtCol, queryAST.StartDate)
+	}
+	if queryAST.EndDate != nil {
+		q = q.Where("? <= ?", createdAtCol, queryAST.EndDate)
+	}
+
+	q = applySelectQuery(q, queryAST.SelectQuery)
+
+	return q, nil
+}
+
 func applySelectQuery(bunQuery *bun.SelectQuery, csq query.CompoundSelectQuery) *bun.SelectQuery {
 	applyAtomicQuery := func(sq *bun.SelectQuery, atomicQuery query.SelectQuery, useOr bool) *bun.SelectQuery {
 		eqCondition := func(op query.EqualityOperator, ident bun.Ident, arg any) *bun.SelectQuery {
@@ -394,6 +446,13 @@ func applySelectQuery(bunQuery *bun.SelectQuery, csq query.CompoundSelectQuery)
 				qt.File,
 			)
 
+		case query.FailureTypeSelectQuery:
+			return eqCondition(
+				qt.Operator,
+				bun.Ident(fmt.Sprintf("%s.failure_type", testcasesTable)),
+				qt.FailureType,
+			)
+
 		case query.StatusSelectQuery:
 			return eqCondition(
 				qt.Operator,
@@ -742,6 +742,7 @@ func (s *BaseSuite) TestSessions() {
 		CreatedAt        time.Time               `bun:"created_at"`
 		UpdatedAt        time.Time               `bun:"updated_at"`
 		UserID           model_db.BinaryUUID     `bun:"user_id"`
+		APIKeyID         *model_db.BinaryUUID    `bun:"api_key_id"`
 		AggregatedStatus model_db.TestcaseStatus `bun:"aggregated_status"`
 	}
 
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -21,7 +20,7 @@ func TestcasesHandler(c echo.Context) error {
 	}
 
 	svc := c.Get("queryService").(QueryServiceInterface)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
 	queryStr := c.FormValue("query")
 	if queryStr == "" {
@@ -49,6 +48,7 @@ func TestcasesHandler(c echo.Context) error {
 		"Query":           queryStr,
 		"ActivePage":      "testcases",
 		"IsAuthenticated": auth,
+		"IsAdmin":         IsAdmin(c),
 	})
 }
 
@@ -67,7 +67,7 @@ func TestcaseDetailHandler(c echo.Context) error {
 	}
 
 	svc := c.Get("queryService").(QueryServiceInterface)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
 	result, err := svc.GetTestcase(ctx, model_db.BinaryUUID(uuid.Nil), testcaseId)
 	if err != nil {
@@ -97,19 +97,29 @@ func TestcaseDetailHandler(c echo.Context) error {
 
 	return c.Render(http.StatusOK, "testcase_detail.html", map[string]any{
 		"Testcase": map[string]any{
-			"ID":        result.ID,
-			"SessionID": result.SessionID,
-			"Name":      result.Name,
-			"Classname": result.Classname,
-			"File":      result.File,
-			"Testsuite": result.Testsuite,
-			"Status":    result.Status,
-			"Baggage":   baggageStr,
-			"Output":    result.Output,
-			"CreatedAt": result.CreatedAt,
+			"ID":             result.ID,
+			"SessionID":      result.SessionID,
+			"Name":           result.Name,
+			"Classname":      result.Classname,
+			"File":           result.File,
+			"Testsuite":      result.Testsuite,
+			"Status":         result.Status,
+			"Baggage":        baggageStr,
+			"Output":         result.Output,
+			"FailureMessage": result.FailureMessage,
+			"FailureType":    result.FailureType,
+			"StackTrace":     result.StackTrace,
+			"Stdout":         result.Stdout,
+			"Stderr":         result.Stderr,
+			"ParentID":       result.ParentID,
+			"Attempt":        result.Attempt,
+			"CreatedAt":      result.CreatedAt,
 		},
 		"Labels":          labelList,
+		"Attachments":     result.Attachments,
+		"Attempts":        result.Attempts,
 		"ActivePage":      "testcases",
 		"IsAuthenticated": auth,
+		"IsAdmin":         IsAdmin(c),
 	})
 }
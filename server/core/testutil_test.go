@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -11,7 +13,11 @@ import (
 
 	"github.com/cephei8/greener/server/core"
 	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/output"
+	"github.com/cephei8/greener/server/core/quota"
+	"github.com/cephei8/greener/server/core/retention"
 	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/suite"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/mysql"
@@ -427,6 +433,80 @@ func (s *MySQLSuite) TearDownSuite() {
 	s.container.Terminate(context.Background())
 }
 
+// testcasesRequest configures sendTestcases. The zero value posts a JSON batch as the test user.
+type testcasesRequest struct {
+	// Handler stores the testcases; by default it uses s.db and the default output storage.
+	Handler *core.IngressHandler
+	// Query is the query string, e.g. "partial=true".
+	Query string
+	// ContentType defaults to application/json.
+	ContentType     string
+	ContentEncoding string
+	// MaxDecompressedSize limits decompressed bodies; 0 is the default limit of DecompressRequest.
+	MaxDecompressedSize int64
+	// APIKey authenticates the request instead of the test user.
+	APIKey *model_db.APIKey
+	// Limiter applies ingress limits to the request unless it is nil.
+	Limiter *quota.Limiter
+}
+
+// sendTestcases posts body to CreateTestcases behind the middleware of the ingress routes.
+func (s *BaseSuite) sendTestcases(r testcasesRequest, body string) (*httptest.ResponseRecorder, error) {
+	target := "/api/v1/ingress/testcases"
+	if r.Query != "" {
+		target += "?" + r.Query
+	}
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = echo.MIMEApplicationJSON
+	}
+	req.Header.Set(echo.HeaderContentType, contentType)
+	if r.ContentEncoding != "" {
+		req.Header.Set(echo.HeaderContentEncoding, r.ContentEncoding)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user_id", s.userID)
+	if r.APIKey != nil {
+		c.Set("apikey", r.APIKey)
+		c.Set("user_id", r.APIKey.UserID)
+	}
+
+	handler := r.Handler
+	if handler == nil {
+		handler = core.NewIngressHandler(s.db, output.DefaultStorage())
+	}
+	next := core.DecompressRequest(r.MaxDecompressedSize)(handler.CreateTestcases)
+	if r.Limiter != nil {
+		next = core.IngressLimits(r.Limiter)(next)
+	}
+	return rec, next(c)
+}
+
+// createScratchSession creates a session with the given labels and a "test_retention" testcase
+// for a test to store data in. The session is purged when the test ends.
+func (s *BaseSuite) createScratchSession(labels map[string]string) model_db.BinaryUUID {
+	sessionID := s.createRetentionSession(context.Background(), time.Now().Add(-96*time.Hour), labels)
+	s.purgeOnCleanup(sessionID, retention.Stores{})
+	return sessionID
+}
+
+// purgeOnCleanup has retention purge a session created by the test when the test ends, along
+// with any other session older than 72 hours. Data offloaded to stores is removed too.
+func (s *BaseSuite) purgeOnCleanup(sessionID model_db.BinaryUUID, stores retention.Stores) {
+	s.T().Cleanup(func() {
+		ctx := context.Background()
+		_, err := s.db.NewUpdate().Model((*model_db.Session)(nil)).
+			Set("? = ?", bun.Ident("created_at"), time.Now().Add(-96*time.Hour)).
+			Where("? = ?", bun.Ident("id"), sessionID).
+			Exec(ctx)
+		s.Require().NoError(err)
+		_, err = retention.NewPurger(s.db, retention.Policy{MaxAge: 72 * time.Hour}, stores).Purge(ctx, false)
+		s.Require().NoError(err)
+	})
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bunotel"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/cephei8/greener/server"
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs a global tracer provider exporting spans over OTLP/HTTP.
+// The exporter is configured with the standard OTEL_EXPORTER_OTLP_* variables.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	return SetupWithExporter(ctx, exporter)
+}
+
+func SetupWithExporter(ctx context.Context, exporter sdktrace.SpanExporter) (func(context.Context) error, error) {
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "greener"
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+func InstrumentDB(db *bun.DB) {
+	db.AddQueryHook(bunotel.NewQueryHook(bunotel.WithFormattedQueries(true)))
+}
+
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			if route == "" {
+				route = req.URL.Path
+			}
+
+			ctx, span := Tracer().Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				if httpErr, ok := err.(*echo.HTTPError); ok {
+					status = httpErr.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+				span.RecordError(err)
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return err
+		}
+	}
+}
+
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func StatementAttribute(q fmt.Stringer) attribute.KeyValue {
+	return semconv.DBQueryText(q.String())
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware_CreatesServerSpan(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	var childCtxValid bool
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/sessions/:id/details", func(c echo.Context) error {
+		childCtxValid = trace.SpanContextFromContext(c.Request().Context()).IsValid()
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions/123/details", nil))
+
+	assert.True(t, childCtxValid)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /sessions/:id/details", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Equal(t, "/sessions/:id/details", spanAttr(spans[0], "http.route").AsString())
+	assert.Equal(t, int64(404), spanAttr(spans[0], "http.response.status_code").AsInt64())
+}
+
+func TestMiddleware_PropagatesParent(t *testing.T) {
+	recorder := setupRecorder(t)
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
@@ -0,0 +1,58 @@
+package core_test
+
+import (
+	"context"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/tracing"
+	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func (s *BaseSuite) TestQueryServiceTracing() {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	db := bun.NewDB(s.sqlDb, s.db.Dialect())
+	tracing.InstrumentDB(db)
+
+	svc := core.NewQueryService(db)
+	result, err := svc.QueryTestcases(context.Background(), s.userID, core.QueryParams{Query: `status = "pass"`})
+	s.Require().NoError(err)
+	s.Len(result.Results, 4)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	serviceSpan, ok := spans["QueryService.QueryTestcases"]
+	s.Require().True(ok)
+	attrs := map[string]any{}
+	for _, kv := range serviceSpan.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	s.Equal(`status = "pass"`, attrs["greener.query"])
+	s.Equal(int64(4), attrs["greener.rows"])
+
+	buildSpan, ok := spans["BuildTestcasesQuery"]
+	s.Require().True(ok)
+	s.Equal(serviceSpan.SpanContext().SpanID(), buildSpan.Parent().SpanID())
+	var statement string
+	for _, kv := range buildSpan.Attributes() {
+		if kv.Key == "db.query.text" {
+			statement = kv.Value.AsString()
+		}
+	}
+	s.Contains(statement, "testcases")
+
+	dbSpan, ok := spans["SELECT"]
+	s.Require().True(ok)
+	s.Equal(serviceSpan.SpanContext().SpanID(), dbSpan.Parent().SpanID())
+}
@@ -0,0 +1,866 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/lockout"
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/cephei8/greener/server/core/oauth"
+	"github.com/google/uuid"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// maxUsernameLength matches the width of the username column.
+	maxUsernameLength = 128
+	// passwordIterations is the PBKDF2 work factor of user passwords.
+	passwordIterations = 100000
+)
+
+// HashPassword hashes password with a new random salt.
+func HashPassword(password string) (salt, hash []byte, err error) {
+	salt = make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	return salt, pbkdf2.Key([]byte(password), salt, passwordIterations, 32, sha256.New), nil
+}
+
+func checkPassword(user *model_db.User, password string) bool {
+	hash := pbkdf2.Key([]byte(password), user.PasswordSalt, passwordIterations, 32, sha256.New)
+	return subtle.ConstantTimeCompare(hash, user.PasswordHash) == 1
+}
+
+func generatePassword() (string, error) {
+	raw := make([]byte, 18)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// PasswordPolicy is what passwords chosen by users and admins must satisfy; generated
+// passwords are not checked. The zero value accepts any non-empty password.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// MinClasses is the minimum number of character classes used, out of lowercase letters,
+	// uppercase letters, digits and other characters.
+	MinClasses int
+	// RejectUsername rejects passwords containing the username, ignoring case.
+	RejectUsername bool
+}
+
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength < 0 {
+		return fmt.Errorf("minimum password length must be non-negative")
+	}
+	if p.MinClasses < 0 || p.MinClasses > 4 {
+		return fmt.Errorf("minimum number of character classes must be between 0 and 4")
+	}
+	return nil
+}
+
+// Check returns an *echo.HTTPError if password of username does not satisfy p.
+func (p PasswordPolicy) Check(username, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if p.MinClasses > 0 {
+		var lower, upper, digit, other int
+		for _, r := range password {
+			switch {
+			case unicode.IsLower(r):
+				lower = 1
+			case unicode.IsUpper(r):
+				upper = 1
+			case unicode.IsDigit(r):
+				digit = 1
+			default:
+				other = 1
+			}
+		}
+		if lower+upper+digit+other < p.MinClasses {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"Password must contain at least %d of lowercase letters, uppercase letters, digits and other characters", p.MinClasses))
+		}
+	}
+	if p.RejectUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Password must not contain the username")
+	}
+	return nil
+}
+
+// UserResponse describes a user in the users API and on the users page. LockedUntil is set
+// while the user is locked out after too many failed logins.
+type UserResponse struct {
+	ID          string            `json:"id"`
+	Username    string            `json:"username"`
+	Role        model_db.UserRole `json:"role"`
+	Disabled    bool              `json:"disabled"`
+	DisabledAt  *time.Time        `json:"disabled_at,omitempty"`
+	AuthSource  string            `json:"auth_source,omitempty"`
+	LockedUntil *time.Time        `json:"locked_until,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+func newUserResponse(user *model_db.User) UserResponse {
+	return UserResponse{
+		ID:         user.ID.String(),
+		Username:   user.Username,
+		Role:       user.Role,
+		Disabled:   user.DisabledAt != nil,
+		DisabledAt: user.DisabledAt,
+		AuthSource: externalSource(user),
+		CreatedAt:  user.CreatedAt,
+	}
+}
+
+// externalSource returns the identity provider of a user, or "" for local users.
+func externalSource(user *model_db.User) string {
+	if user.AuthSource == nil {
+		return ""
+	}
+	return *user.AuthSource
+}
+
+// describeUsers returns the responses of users, including their lockouts. Errors are
+// returned as *echo.HTTPError.
+func describeUsers(ctx context.Context, db bun.IDB, sec *Security, logger echo.Logger, users []model_db.User) ([]UserResponse, error) {
+	locked, err := sec.loginGuard.Locked(ctx, db, lockout.KindUser)
+	if err != nil {
+		logger.Errorf("Failed to load lockouts: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load users")
+	}
+	resp := make([]UserResponse, len(users))
+	for i := range users {
+		resp[i] = newUserResponse(&users[i])
+		if until, ok := locked[lockout.User(users[i].Username).Name]; ok {
+			resp[i].LockedUntil = &until
+		}
+	}
+	return resp, nil
+}
+
+// UserUpdate changes the role of a user or disables or enables them. Nil fields are left unchanged.
+type UserUpdate struct {
+	Role     *model_db.UserRole `json:"role"`
+	Disabled *bool              `json:"disabled"`
+}
+
+// RevokeRequest selects the credentials of a user that RevokeUserAccess revokes.
+type RevokeRequest struct {
+	Sessions    bool `json:"sessions"`
+	APIKeys     bool `json:"api_keys"`
+	OAuthTokens bool `json:"oauth_tokens"`
+}
+
+// RevokeResponse reports what RevokeUserAccess revoked.
+type RevokeResponse struct {
+	Sessions    bool  `json:"sessions"`
+	APIKeys     int64 `json:"api_keys"`
+	OAuthTokens int64 `json:"oauth_tokens"`
+}
+
+func (r RevokeResponse) String() string {
+	var parts []string
+	if r.Sessions {
+		parts = append(parts, "signed out web sessions")
+	}
+	if r.APIKeys >= 0 {
+		parts = append(parts, fmt.Sprintf("deleted %d API keys", r.APIKeys))
+	}
+	if r.OAuthTokens >= 0 {
+		parts = append(parts, fmt.Sprintf("revoked %d OAuth tokens", r.OAuthTokens))
+	}
+	summary := strings.Join(parts, ", ")
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+// ListUsers returns all users ordered by username. Errors are returned as *echo.HTTPError.
+func ListUsers(ctx context.Context, db bun.IDB, logger echo.Logger) ([]model_db.User, error) {
+	var users []model_db.User
+	err := db.NewSelect().
+		Model(&users).
+		OrderExpr("? ASC", bun.Ident("username")).
+		Scan(ctx)
+	if err != nil {
+		logger.Errorf("Failed to load users: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load users")
+	}
+	return users, nil
+}
+
+// loadUser loads a user for update. Errors are returned as *echo.HTTPError.
+func loadUser(ctx context.Context, db bun.IDB, logger echo.Logger, id model_db.BinaryUUID) (*model_db.User, error) {
+	var user model_db.User
+	err := db.NewSelect().
+		Model(&user).
+		Where("? = ?", bun.Ident("id"), id).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	if err != nil {
+		logger.Errorf("Failed to load user %s: %v", id, err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
+	}
+	return &user, nil
+}
+
+// CreateUser creates a user on behalf of actor, whose password has to satisfy the password
+// policy of sec. Errors are returned as *echo.HTTPError.
+func CreateUser(ctx context.Context, db *bun.DB, sec *Security, logger echo.Logger, actor audit.Actor, username, password string, role model_db.UserRole) (*model_db.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Username is required")
+	}
+	if utf8.RuneCountInString(username) > maxUsernameLength {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Username is longer than %d characters", maxUsernameLength))
+	}
+	if password == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Password is required")
+	}
+	if err := sec.passwordPolicy.Check(username, password); err != nil {
+		return nil, err
+	}
+	if _, err := model_db.ParseUserRole(string(role)); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid role")
+	}
+
+	salt, hash, err := HashPassword(password)
+	if err != nil {
+		logger.Errorf("Failed to hash password: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user")
+	}
+
+	now := time.Now()
+	user := &model_db.User{
+		ID:           model_db.BinaryUUID(uuid.New()),
+		Username:     username,
+		PasswordSalt: salt,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		exists, err := tx.NewSelect().
+			Model((*model_db.User)(nil)).
+			Where("? = ?", bun.Ident("username"), username).
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("User %s already exists", username))
+		}
+		if _, err := tx.NewInsert().Model(user).Exec(ctx); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.Event{
+			Action:     audit.ActionUserCreate,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID.String(),
+			Details:    fmt.Sprintf("username=%s role=%s", username, role),
+		})
+	})
+	if err != nil {
+		return nil, userError(logger, "Failed to create user", err)
+	}
+	return user, nil
+}
+
+// ExternalIdentity is a user as asserted by an external identity provider.
+type ExternalIdentity struct {
+	// Source names the identity provider, e.g. "oidc".
+	Source string
+	// Subject identifies the user at the identity provider and never changes.
+	Subject  string
+	Username string
+	// Role is the role mapped from the identity; nil leaves the role of existing users unchanged.
+	Role *model_db.UserRole
+	// DefaultRole is the role of new users if Role is nil.
+	DefaultRole model_db.UserRole
+}
+
+// ProvisionUser returns the user of identity, creating it on first login and updating its
+// role to identity.Role on later ones. A local user with the same username is not taken over.
+// Errors are returned as *echo.HTTPError.
+func ProvisionUser(ctx context.Context, db *bun.DB, logger echo.Logger, ip string, identity ExternalIdentity) (*model_db.User, error) {
+	username := strings.TrimSpace(identity.Username)
+	if identity.Subject == "" || username == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Identity provider did not return a username")
+	}
+	if utf8.RuneCountInString(username) > maxUsernameLength {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Username is longer than %d characters", maxUsernameLength))
+	}
+
+	var user model_db.User
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model(&user).
+			Where("? = ?", bun.Ident("auth_source"), identity.Source).
+			Where("? = ?", bun.Ident("external_id"), identity.Subject).
+			Scan(ctx)
+		if err == nil {
+			if identity.Role == nil || *identity.Role == user.Role {
+				return nil
+			}
+			previous := user.Role
+			user.Role = *identity.Role
+			user.UpdatedAt = time.Now()
+			_, err := tx.NewUpdate().
+				Model(&user).
+				Column("role", "updated_at").
+				Where("? = ?", bun.Ident("id"), user.ID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			actor := audit.UserActor(user.ID, user.Username)
+			actor.IP = ip
+			return audit.Record(ctx, tx, actor, audit.Event{
+				Action:     audit.ActionUserUpdate,
+				TargetType: audit.TargetUser,
+				TargetID:   user.ID.String(),
+				Details:    fmt.Sprintf("role=%s (was %s) from %s", user.Role, previous, identity.Source),
+			})
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		exists, err := tx.NewSelect().
+			Model((*model_db.User)(nil)).
+			Where("? = ?", bun.Ident("username"), username).
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("User %s already exists", username))
+		}
+
+		// external users cannot log in with a password, so they get an unknown one
+		password, err := generatePassword()
+		if err != nil {
+			return err
+		}
+		salt, hash, err := HashPassword(password)
+		if err != nil {
+			return err
+		}
+		role := identity.DefaultRole
+		if identity.Role != nil {
+			role = *identity.Role
+		}
+		now := time.Now()
+		user = model_db.User{
+			ID:           model_db.BinaryUUID(uuid.New()),
+			Username:     username,
+			PasswordSalt: salt,
+			PasswordHash: hash,
+			Role:         role,
+			AuthSource:   &identity.Source,
+			ExternalID:   &identity.Subject,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if _, err := tx.NewInsert().Model(&user).Exec(ctx); err != nil {
+			return err
+		}
+		actor := audit.UserActor(user.ID, user.Username)
+		actor.IP = ip
+		return audit.Record(ctx, tx, actor, audit.Event{
+			Action:     audit.ActionUserCreate,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID.String(),
+			Details:    fmt.Sprintf("username=%s role=%s source=%s", username, role, identity.Source),
+		})
+	})
+	if err != nil {
+		return nil, userError(logger, "Failed to provision user", err)
+	}
+	return &user, nil
+}
+
+// UpdateUser applies update to a user on behalf of actor. Disabling a user also signs out
+// their web sessions and revokes their OAuth tokens; their API keys are rejected until the
+// user is enabled again. Admins cannot demote or disable themselves, so that an instance
+// keeps an admin. Errors are returned as *echo.HTTPError.
+func UpdateUser(ctx context.Context, db *bun.DB, logger echo.Logger, actor audit.Actor, id model_db.BinaryUUID, update UserUpdate) (*model_db.User, error) {
+	if update.Role != nil {
+		if _, err := model_db.ParseUserRole(string(*update.Role)); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid role")
+		}
+	}
+	if actor.UserID != nil && *actor.UserID == id {
+		if update.Role != nil && *update.Role != model_db.RoleAdmin {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "You cannot change your own role")
+		}
+		if update.Disabled != nil && *update.Disabled {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "You cannot disable yourself")
+		}
+	}
+
+	var user *model_db.User
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		user, err = loadUser(ctx, tx, logger, id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var events []audit.Event
+		if update.Role != nil && *update.Role != user.Role {
+			events = append(events, audit.Event{
+				Action:  audit.ActionUserUpdate,
+				Details: fmt.Sprintf("role=%s (was %s)", *update.Role, user.Role),
+			})
+			user.Role = *update.Role
+		}
+		if update.Disabled != nil && *update.Disabled != (user.DisabledAt != nil) {
+			if *update.Disabled {
+				user.DisabledAt = &now
+				user.SessionsRevokedAt = &now
+				tokens, err := deleteOAuthTokens(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				events = append(events, audit.Event{
+					Action:  audit.ActionUserDisable,
+					Details: fmt.Sprintf("revoked %d OAuth tokens", tokens),
+				})
+			} else {
+				user.DisabledAt = nil
+				events = append(events, audit.Event{Action: audit.ActionUserEnable})
+			}
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		user.UpdatedAt = now
+		_, err = tx.NewUpdate().
+			Model(user).
+			Column("role", "disabled_at", "sessions_revoked_at", "updated_at").
+			Where("? = ?", bun.Ident("id"), id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			event.TargetType = audit.TargetUser
+			event.TargetID = id.String()
+			if err := audit.Record(ctx, tx, actor, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, userError(logger, "Failed to update user", err)
+	}
+	return user, nil
+}
+
+// SetUserPassword sets the password of a user on behalf of actor and signs out their web
+// sessions. An empty password is replaced with a generated one, which is returned; other
+// passwords have to satisfy the password policy.
+// Errors are returned as *echo.HTTPError.
+func SetUserPassword(ctx context.Context, db *bun.DB, sec *Security, logger echo.Logger, actor audit.Actor, id model_db.BinaryUUID, password string) (string, error) {
+	generated := ""
+	if password == "" {
+		var err error
+		if generated, err = generatePassword(); err != nil {
+			logger.Errorf("Failed to generate password: %v", err)
+			return "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset password")
+		}
+		password = generated
+	}
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		user, err := loadUser(ctx, tx, logger, id)
+		if err != nil {
+			return err
+		}
+		if user.AuthSource != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s signs in with %s, which manages their password", user.Username, *user.AuthSource))
+		}
+		if generated == "" {
+			if err := sec.passwordPolicy.Check(user.Username, password); err != nil {
+				return err
+			}
+		}
+		if err := storePassword(ctx, tx, user, password, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.Event{
+			Action:     audit.ActionUserPasswordReset,
+			TargetType: audit.TargetUser,
+			TargetID:   id.String(),
+		})
+	})
+	if err != nil {
+		return "", userError(logger, "Failed to reset password", err)
+	}
+	return generated, nil
+}
+
+// storePassword stores a new password of user and revokes the web sessions started before now.
+func storePassword(ctx context.Context, db bun.IDB, user *model_db.User, password string, now time.Time) error {
+	salt, hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordSalt = salt
+	user.PasswordHash = hash
+	user.SessionsRevokedAt = &now
+	user.UpdatedAt = now
+	_, err = db.NewUpdate().
+		Model(user).
+		Column("password_salt", "password_hash", "sessions_revoked_at", "updated_at").
+		Where("? = ?", bun.Ident("id"), user.ID).
+		Exec(ctx)
+	return err
+}
+
+// RevokeUserAccess revokes credentials of a user on behalf of actor: it signs out their web
+// sessions, deletes their API keys and revokes the OAuth tokens issued to them, as selected by
+// req. Fields of the response that were not selected are negative. Errors are returned as *echo.HTTPError.
+func RevokeUserAccess(ctx context.Context, db *bun.DB, sec *Security, logger echo.Logger, actor audit.Actor, id model_db.BinaryUUID, req RevokeRequest) (*RevokeResponse, error) {
+	if !req.Sessions && !req.APIKeys && !req.OAuthTokens {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Nothing to revoke")
+	}
+
+	resp := &RevokeResponse{APIKeys: -1, OAuthTokens: -1}
+	var keyIDs []model_db.BinaryUUID
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		user, err := loadUser(ctx, tx, logger, id)
+		if err != nil {
+			return err
+		}
+
+		if req.Sessions {
+			now := time.Now()
+			user.SessionsRevokedAt = &now
+			_, err := tx.NewUpdate().
+				Model(user).
+				Column("sessions_revoked_at").
+				Where("? = ?", bun.Ident("id"), id).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			resp.Sessions = true
+		}
+		if req.APIKeys {
+			err := tx.NewSelect().
+				Model((*model_db.APIKey)(nil)).
+				Column("id").
+				Where("? = ?", bun.Ident("user_id"), id).
+				Scan(ctx, &keyIDs)
+			if err != nil {
+				return err
+			}
+			_, err = tx.NewDelete().
+				Model((*model_db.APIKey)(nil)).
+				Where("? = ?", bun.Ident("user_id"), id).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			resp.APIKeys = int64(len(keyIDs))
+		}
+		if req.OAuthTokens {
+			if resp.OAuthTokens, err = deleteOAuthTokens(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+
+		return audit.Record(ctx, tx, actor, audit.Event{
+			Action:     audit.ActionUserRevoke,
+			TargetType: audit.TargetUser,
+			TargetID:   id.String(),
+			Details:    resp.String(),
+		})
+	})
+	if err != nil {
+		return nil, userError(logger, "Failed to revoke access", err)
+	}
+
+	for _, keyID := range keyIDs {
+		sec.verifiedSecrets.Remove(uuid.UUID(keyID))
+	}
+	return resp, nil
+}
+
+// UnlockUser ends the lockout of a user after too many failed logins on behalf of actor and
+// reports whether the user was locked out. Errors are returned as *echo.HTTPError.
+func UnlockUser(ctx context.Context, db *bun.DB, sec *Security, logger echo.Logger, actor audit.Actor, id model_db.BinaryUUID) (*model_db.User, bool, error) {
+	var user *model_db.User
+	var unlocked bool
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		user, err = loadUser(ctx, tx, logger, id)
+		if err != nil {
+			return err
+		}
+		if unlocked, err = sec.loginGuard.Reset(ctx, tx, lockout.User(user.Username)); err != nil {
+			return err
+		}
+		details := "unlocked"
+		if !unlocked {
+			details = "was not locked out"
+		}
+		return audit.Record(ctx, tx, actor, audit.Event{
+			Action:     audit.ActionUserUnlock,
+			TargetType: audit.TargetUser,
+			TargetID:   id.String(),
+			Details:    details,
+		})
+	})
+	if err != nil {
+		return nil, false, userError(logger, "Failed to unlock user", err)
+	}
+	return user, unlocked, nil
+}
+
+func deleteOAuthTokens(ctx context.Context, db bun.IDB, userID model_db.BinaryUUID) (int64, error) {
+	res, err := db.NewDelete().
+		Model((*oauth.OAuthToken)(nil)).
+		Where("? = ?", bun.Ident("user_id"), userID).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// userError passes *echo.HTTPError through and logs other errors as failing action.
+func userError(logger echo.Logger, action string, err error) error {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	logger.Errorf("%s: %v", action, err)
+	return echo.NewHTTPError(http.StatusInternalServerError, action)
+}
+
+// adminActor returns the actor of the admin logged in to the web session of c.
+func adminActor(c echo.Context) (audit.Actor, bool) {
+	if !IsAdmin(c) {
+		return audit.Actor{}, false
+	}
+	actor := audit.RequestActor(c)
+	return actor, actor.UserID != nil
+}
+
+// userErrorHTML renders an error of the user management functions for htmx.
+func userErrorHTML(c echo.Context, err error) error {
+	code, message := http.StatusInternalServerError, "Internal error"
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		code, message = he.Code, fmt.Sprint(he.Message)
+	}
+	return c.HTML(code, fmt.Sprintf(`<div class="alert alert-error">%s</div>`, html.EscapeString(message)))
+}
+
+func userIDParam(c echo.Context) (model_db.BinaryUUID, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return model_db.BinaryUUID{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	return model_db.BinaryUUID(id), nil
+}
+
+func UsersHandler(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	if auth, ok := sess.Values["authenticated"].(bool); !ok || !auth {
+		return c.Redirect(http.StatusFound, "/login")
+	}
+	actor, ok := adminActor(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Admin role is required to manage users</div>`)
+	}
+
+	data, err := usersTableData(c, actor, "")
+	if err != nil {
+		return err
+	}
+	data["ActivePage"] = "users"
+	data["IsAuthenticated"] = true
+	data["IsAdmin"] = true
+	return c.Render(http.StatusOK, "users.html", data)
+}
+
+func usersTableData(c echo.Context, actor audit.Actor, notice string) (map[string]any, error) {
+	db := c.Get("db").(*bun.DB)
+	users, err := ListUsers(c.Request().Context(), db, c.Logger())
+	if err != nil {
+		return nil, err
+	}
+	views, err := describeUsers(c.Request().Context(), db, getSecurity(c), c.Logger(), users)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"Users":         views,
+		"CurrentUserID": actor.UserID.String(),
+		"Notice":        notice,
+	}, nil
+}
+
+// renderUsersTable renders the users table after a change, with notice on top of it.
+func renderUsersTable(c echo.Context, actor audit.Actor, notice string) error {
+	data, err := usersTableData(c, actor, notice)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+	return c.Render(http.StatusOK, "users_table.html", data)
+}
+
+func CreateUserHandler(c echo.Context) error {
+	actor, ok := adminActor(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Admin role is required to manage users</div>`)
+	}
+
+	db := c.Get("db").(*bun.DB)
+	_, err := CreateUser(c.Request().Context(), db, getSecurity(c), c.Logger(), actor,
+		c.FormValue("username"), c.FormValue("password"), model_db.UserRole(c.FormValue("role")))
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+
+	c.Response().Header().Set("HX-Redirect", "/users")
+	return c.NoContent(http.StatusOK)
+}
+
+func SetUserRoleHandler(c echo.Context) error {
+	role := model_db.UserRole(c.FormValue("role"))
+	return updateUserFromUI(c, UserUpdate{Role: &role})
+}
+
+func DisableUserHandler(c echo.Context) error {
+	disabled := true
+	return updateUserFromUI(c, UserUpdate{Disabled: &disabled})
+}
+
+func EnableUserHandler(c echo.Context) error {
+	disabled := false
+	return updateUserFromUI(c, UserUpdate{Disabled: &disabled})
+}
+
+func updateUserFromUI(c echo.Context, update UserUpdate) error {
+	actor, ok := adminActor(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Admin role is required to manage users</div>`)
+	}
+	id, err := userIDParam(c)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+
+	db := c.Get("db").(*bun.DB)
+	user, err := UpdateUser(c.Request().Context(), db, c.Logger(), actor, id, update)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+	return renderUsersTable(c, actor, fmt.Sprintf("Updated %s", user.Username))
+}
+
+// ResetUserPasswordHandler sets a generated password for a user and shows it once.
+func ResetUserPasswordHandler(c echo.Context) error {
+	actor, ok := adminActor(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Admin role is required to manage users</div>`)
+	}
+	id, err := userIDParam(c)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+
+	db := c.Get("db").(*bun.DB)
+	password, err := SetUserPassword(c.Request().Context(), db, getSecurity(c), c.Logger(), actor, id, "")
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+
+	return c.HTML(http.StatusOK, fmt.Sprintf(`
+		<h3 class="font-bold text-lg mb-4">Password Reset</h3>
+		<div role="alert" class="alert alert-warning mb-4">
+			<span><strong>Warning:</strong> The password will not be shown again. Pass it on to the user, who can change it after logging in.</span>
+		</div>
+		<div class="join w-full">
+			<input type="text" value="%s" class="input input-bordered join-item w-full font-mono" readonly />
+			<button class="btn join-item" onclick="copyToClipboard('%s')">Copy</button>
+		</div>
+		<div class="modal-action">
+			<button class="btn btn-primary" onclick="password_modal.close()">Done</button>
+		</div>`, password, password))
+}
+
+// UnlockUserHandler ends the lockout of a user after too many failed logins.
+func UnlockUserHandler(c echo.Context) error {
+	actor, ok := adminActor(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Admin role is required to manage users</div>`)
+	}
+	id, err := userIDParam(c)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+
+	db := c.Get("db").(*bun.DB)
+	user, _, err := UnlockUser(c.Request().Context(), db, getSecurity(c), c.Logger(), actor, id)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+	return renderUsersTable(c, actor, fmt.Sprintf("Unlocked %s", user.Username))
+}
+
+// RevokeUserHandler revokes the credentials selected by the "sessions", "api_keys" and
+// "oauth_tokens" form values.
+func RevokeUserHandler(c echo.Context) error {
+	actor, ok := adminActor(c)
+	if !ok {
+		return c.HTML(http.StatusForbidden, `<div class="alert alert-error">Admin role is required to manage users</div>`)
+	}
+	id, err := userIDParam(c)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+
+	req := RevokeRequest{
+		Sessions:    c.FormValue("sessions") == "true",
+		APIKeys:     c.FormValue("api_keys") == "true",
+		OAuthTokens: c.FormValue("oauth_tokens") == "true",
+	}
+	db := c.Get("db").(*bun.DB)
+	resp, err := RevokeUserAccess(c.Request().Context(), db, getSecurity(c), c.Logger(), actor, id, req)
+	if err != nil {
+		return userErrorHTML(c, err)
+	}
+	return renderUsersTable(c, actor, resp.String())
+}
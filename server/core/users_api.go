@@ -0,0 +1,182 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/clientip"
+	"github.com/cephei8/greener/server/core/model/db"
+	"github.com/labstack/echo/v4"
+	"github.com/uptrace/bun"
+)
+
+// UsersAPI serves the JSON endpoints of user management. Requests are authenticated with
+// an API key of the admin scope that belongs to an admin (see RequireAdminUser).
+type UsersAPI struct {
+	db  *bun.DB
+	sec *Security
+}
+
+func NewUsersAPI(db *bun.DB, sec *Security) *UsersAPI {
+	return &UsersAPI{db: db, sec: sec}
+}
+
+type CreateUserRequest struct {
+	Username string            `json:"username"`
+	Password string            `json:"password"`
+	Role     model_db.UserRole `json:"role"`
+}
+
+type SetPasswordRequest struct {
+	// Password is the new password; if empty, a password is generated and returned
+	Password string `json:"password"`
+}
+
+type SetPasswordResponse struct {
+	Password string `json:"password,omitempty"`
+}
+
+type UnlockResponse struct {
+	// Unlocked reports whether the user was locked out
+	Unlocked bool `json:"unlocked"`
+}
+
+// RequireAdminUser allows requests authenticated by APIKeyAuth only if the key belongs
+// to an admin and is not restricted to a project.
+func RequireAdminUser(db *bun.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiKey := GetAPIKey(c)
+			if apiKey == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing API key")
+			}
+			if apiKey.Project != nil {
+				return echo.NewHTTPError(http.StatusForbidden, "API keys restricted to a project cannot manage users")
+			}
+
+			user, err := loadUser(c.Request().Context(), db, c.Logger(), apiKey.UserID)
+			if err != nil {
+				return err
+			}
+			if user.Role != model_db.RoleAdmin {
+				return echo.NewHTTPError(http.StatusForbidden, "Admin role is required to manage users")
+			}
+			return next(c)
+		}
+	}
+}
+
+func (a *UsersAPI) actor(c echo.Context) audit.Actor {
+	apiKey := GetAPIKey(c)
+	actor := audit.APIKeyActor(apiKey.UserID, apiKey.ID)
+	actor.IP = clientip.FromContext(c)
+	return actor
+}
+
+func (a *UsersAPI) List(c echo.Context) error {
+	users, err := ListUsers(c.Request().Context(), a.db, c.Logger())
+	if err != nil {
+		return err
+	}
+	resp, err := describeUsers(c.Request().Context(), a.db, a.sec, c.Logger(), users)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (a *UsersAPI) Get(c echo.Context) error {
+	id, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+	user, err := loadUser(c.Request().Context(), a.db, c.Logger(), id)
+	if err != nil {
+		return err
+	}
+	resp, err := describeUsers(c.Request().Context(), a.db, a.sec, c.Logger(), []model_db.User{*user})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp[0])
+}
+
+func (a *UsersAPI) Create(c echo.Context) error {
+	var req CreateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.Role == "" {
+		req.Role = model_db.RoleViewer
+	}
+
+	user, err := CreateUser(c.Request().Context(), a.db, a.sec, c.Logger(), a.actor(c), req.Username, req.Password, req.Role)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, newUserResponse(user))
+}
+
+func (a *UsersAPI) Update(c echo.Context) error {
+	id, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+	var req UserUpdate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	user, err := UpdateUser(c.Request().Context(), a.db, c.Logger(), a.actor(c), id, req)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+func (a *UsersAPI) SetPassword(c echo.Context) error {
+	id, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+	var req SetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	generated, err := SetUserPassword(c.Request().Context(), a.db, a.sec, c.Logger(), a.actor(c), id, req.Password)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, SetPasswordResponse{Password: generated})
+}
+
+func (a *UsersAPI) Unlock(c echo.Context) error {
+	id, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	_, unlocked, err := UnlockUser(c.Request().Context(), a.db, a.sec, c.Logger(), a.actor(c), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, UnlockResponse{Unlocked: unlocked})
+}
+
+func (a *UsersAPI) Revoke(c echo.Context) error {
+	id, err := userIDParam(c)
+	if err != nil {
+		return err
+	}
+	var req RevokeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	resp, err := RevokeUserAccess(c.Request().Context(), a.db, a.sec, c.Logger(), a.actor(c), id, req)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
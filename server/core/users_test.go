@@ -0,0 +1,268 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cephei8/greener/server/core"
+	"github.com/cephei8/greener/server/core/audit"
+	"github.com/cephei8/greener/server/core/lockout"
+	model_db "github.com/cephei8/greener/server/core/model/db"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+func httpErrorCode(err error) int {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he.Code
+	}
+	return 0
+}
+
+func (s *BaseSuite) TestUserManagement() {
+	ctx := context.Background()
+	logger := log.New("test")
+
+	admin, err := core.CreateUser(ctx, s.db, testSecurity, logger, audit.System, "useradmin", "admin-password", model_db.RoleAdmin)
+	s.Require().NoError(err)
+	actor := audit.UserActor(admin.ID, admin.Username)
+	member, err := core.CreateUser(ctx, s.db, testSecurity, logger, actor, "member", "member-password", model_db.RoleViewer)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		for _, id := range []model_db.BinaryUUID{admin.ID, member.ID} {
+			_, err := s.db.NewDelete().Model((*model_db.User)(nil)).Where("id = ?", id).Exec(ctx)
+			s.Require().NoError(err)
+		}
+	})
+
+	_, err = core.CreateUser(ctx, s.db, testSecurity, logger, actor, "member", "other", model_db.RoleViewer)
+	s.Equal(http.StatusConflict, httpErrorCode(err))
+	_, err = core.CreateUser(ctx, s.db, testSecurity, logger, actor, "nobody", "secret", "owner")
+	s.Equal(http.StatusBadRequest, httpErrorCode(err))
+	_, err = core.CreateUser(ctx, s.db, testSecurity, logger, actor, " ", "secret", model_db.RoleViewer)
+	s.Equal(http.StatusBadRequest, httpErrorCode(err))
+
+	// admins cannot lock themselves out
+	viewer := model_db.RoleViewer
+	disabled, enabled := true, false
+	_, err = core.UpdateUser(ctx, s.db, logger, actor, admin.ID, core.UserUpdate{Role: &viewer})
+	s.Equal(http.StatusBadRequest, httpErrorCode(err))
+	_, err = core.UpdateUser(ctx, s.db, logger, actor, admin.ID, core.UserUpdate{Disabled: &disabled})
+	s.Equal(http.StatusBadRequest, httpErrorCode(err))
+
+	editor := model_db.RoleEditor
+	updated, err := core.UpdateUser(ctx, s.db, logger, actor, member.ID, core.UserUpdate{Role: &editor})
+	s.Require().NoError(err)
+	s.Equal(model_db.RoleEditor, updated.Role)
+
+	login := func(username, password string) int {
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username="+username+"&password="+password, false, "", "", s.db)
+		s.Require().NoError(core.LoginHandler(c))
+		return rec.Code
+	}
+	auth := func(header string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		req.Header.Set("X-API-Key", header)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		err := core.APIKeyAuth(s.db, testSecurity, model_db.ScopeAdmin)(core.RequireAdminUser(s.db)(func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}))(c)
+		if err != nil {
+			return httpErrorCode(err)
+		}
+		return rec.Code
+	}
+
+	_, memberKey := s.createAPIKey(ctx, "member-key", func(k *model_db.APIKey) {
+		k.UserID = member.ID
+		k.Scope = model_db.ScopeAdmin
+	})
+	_, adminKey := s.createAPIKey(ctx, "admin-key", func(k *model_db.APIKey) {
+		k.UserID = admin.ID
+		k.Scope = model_db.ScopeAdmin
+	})
+	s.Equal(http.StatusNoContent, auth(adminKey))
+	s.Equal(http.StatusForbidden, auth(memberKey))
+
+	// disabled users cannot log in and their API keys are rejected
+	_, err = core.UpdateUser(ctx, s.db, logger, actor, member.ID, core.UserUpdate{Disabled: &disabled})
+	s.Require().NoError(err)
+	s.Equal(http.StatusForbidden, login("member", "member-password"))
+	s.Equal(http.StatusUnauthorized, auth(memberKey))
+
+	_, err = core.UpdateUser(ctx, s.db, logger, actor, member.ID, core.UserUpdate{Disabled: &enabled})
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, login("member", "member-password"))
+	s.Equal(http.StatusForbidden, auth(memberKey))
+
+	// a password reset signs out the sessions started before it
+	checkSession := func(loginAt time.Time) bool {
+		c, _ := setupAPIKeyContext(s.T(), http.MethodGet, "/sessions", "", true, member.ID.String(), string(member.Role), s.db)
+		sess, _ := session.Get("session", c)
+		sess.Values["login_at"] = loginAt.Unix()
+		s.Require().NoError(core.CheckUserSession(s.db)(func(c echo.Context) error { return nil })(c))
+		return sess.Values["authenticated"].(bool)
+	}
+	s.True(checkSession(time.Now()))
+
+	password, err := core.SetUserPassword(ctx, s.db, testSecurity, logger, actor, member.ID, "")
+	s.Require().NoError(err)
+	s.NotEmpty(password)
+	s.False(checkSession(time.Now().Add(-time.Minute)))
+	s.Equal(http.StatusUnauthorized, login("member", "member-password"))
+	s.Equal(http.StatusOK, login("member", password))
+
+	resp, err := core.RevokeUserAccess(ctx, s.db, testSecurity, logger, actor, member.ID, core.RevokeRequest{APIKeys: true})
+	s.Require().NoError(err)
+	s.False(resp.Sessions)
+	s.Equal(int64(1), resp.APIKeys)
+	s.Equal(int64(-1), resp.OAuthTokens)
+	s.Equal(http.StatusUnauthorized, auth(memberKey))
+
+	_, err = core.RevokeUserAccess(ctx, s.db, testSecurity, logger, actor, member.ID, core.RevokeRequest{})
+	s.Equal(http.StatusBadRequest, httpErrorCode(err))
+
+	entries, err := audit.List(ctx, s.db, audit.Filter{Action: "user", Actor: "useradmin"})
+	s.Require().NoError(err)
+	var actions []string
+	for _, entry := range entries {
+		if entry.Outcome == model_db.AuditSuccess {
+			actions = append(actions, entry.Action)
+		}
+	}
+	s.Equal([]string{
+		audit.ActionUserRevoke,
+		audit.ActionUserPasswordReset,
+		audit.ActionUserEnable,
+		audit.ActionUserDisable,
+		audit.ActionUserUpdate,
+		audit.ActionUserCreate,
+	}, actions)
+}
+
+func (s *BaseSuite) TestUsersUI() {
+	ctx := context.Background()
+	logger := log.New("test")
+
+	admin, err := core.CreateUser(ctx, s.db, testSecurity, logger, audit.System, "uiadmin", "admin-password", model_db.RoleAdmin)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		_, err := s.db.NewDelete().Model((*model_db.User)(nil)).Where("username IN (?, ?)", "uiadmin", "uimember").Exec(ctx)
+		s.Require().NoError(err)
+	})
+
+	post := func(path, body string, userID model_db.BinaryUUID, role model_db.UserRole, handler echo.HandlerFunc, params ...string) *httptest.ResponseRecorder {
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, path, body, true, userID.String(), string(role), s.db)
+		sess, _ := session.Get("session", c)
+		sess.Values["username"] = "uiadmin"
+		if len(params) > 0 {
+			c.SetParamNames("id")
+			c.SetParamValues(params...)
+		}
+		s.Require().NoError(handler(c))
+		return rec
+	}
+
+	rec := post("/users/create", "username=uimember&password=secret&role=viewer", s.userID, model_db.RoleEditor, core.CreateUserHandler)
+	s.Equal(http.StatusForbidden, rec.Code)
+
+	rec = post("/users/create", "username=uimember&password=secret&role=viewer", admin.ID, model_db.RoleAdmin, core.CreateUserHandler)
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("/users", rec.Header().Get("HX-Redirect"))
+
+	var member model_db.User
+	s.Require().NoError(s.db.NewSelect().Model(&member).Where("username = ?", "uimember").Scan(ctx))
+	s.Equal(model_db.RoleViewer, member.Role)
+
+	rec = post("/users/create", "username=uimember&password=secret&role=viewer", admin.ID, model_db.RoleAdmin, core.CreateUserHandler)
+	s.Equal(http.StatusConflict, rec.Code)
+
+	rec = post("/users/"+member.ID.String()+"/role", "role=editor", admin.ID, model_db.RoleAdmin, core.SetUserRoleHandler, member.ID.String())
+	s.Equal(http.StatusOK, rec.Code)
+	rec = post("/users/"+member.ID.String()+"/password", "", admin.ID, model_db.RoleAdmin, core.ResetUserPasswordHandler, member.ID.String())
+	s.Equal(http.StatusOK, rec.Code)
+	s.Contains(rec.Body.String(), "Password Reset")
+
+	s.Require().NoError(s.db.NewSelect().Model(&member).Where("username = ?", "uimember").Scan(ctx))
+	s.Equal(model_db.RoleEditor, member.Role)
+	s.NotNil(member.SessionsRevokedAt)
+
+	// self-service password change
+	change := func(current, password, confirm string) *httptest.ResponseRecorder {
+		body := "current_password=" + current + "&new_password=" + password + "&confirm_password=" + confirm
+		return post("/account/password", body, admin.ID, model_db.RoleAdmin, core.ChangePasswordHandler)
+	}
+	s.Equal(http.StatusBadRequest, change("wrong", "new-password", "new-password").Code)
+	s.Equal(http.StatusBadRequest, change("admin-password", "new-password", "other").Code)
+	rec = change("admin-password", "new-password", "new-password")
+	s.Equal(http.StatusOK, rec.Code)
+	s.True(strings.Contains(rec.Body.String(), "alert-success"))
+
+	c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/login", "username=uiadmin&password=new-password", false, "", "", s.db)
+	s.Require().NoError(core.LoginHandler(c))
+	s.Equal(http.StatusOK, rec.Code)
+
+	entries, err := audit.List(ctx, s.db, audit.Filter{Action: audit.ActionUserPasswordChange, Actor: "uiadmin"})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	s.Equal(model_db.AuditSuccess, entries[0].Outcome)
+	s.Equal(model_db.AuditFailure, entries[1].Outcome)
+}
+
+func (s *BaseSuite) TestPasswordPolicy() {
+	ctx := context.Background()
+	logger := log.New("test")
+
+	policy := core.PasswordPolicy{MinLength: 10, MinClasses: 3, RejectUsername: true}
+	s.Require().NoError(policy.Validate())
+	s.Error(core.PasswordPolicy{MinClasses: 5}.Validate())
+	sec := core.NewSecurity(lockout.Policy{}, policy, 0)
+	s.T().Cleanup(func() {
+		_, err := s.db.NewDelete().Model((*model_db.User)(nil)).Where("username = ?", "policyuser").Exec(ctx)
+		s.Require().NoError(err)
+	})
+
+	for password, message := range map[string]string{
+		"Sh0rt!":           "Password must be at least 10 characters long",
+		"lowercase-only":   "Password must contain at least 3 of lowercase letters, uppercase letters, digits and other characters",
+		"PolicyUser-2026":  "Password must not contain the username",
+		"ünïcödé-pässwörd": "Password must contain at least 3 of lowercase letters, uppercase letters, digits and other characters",
+	} {
+		_, err := core.CreateUser(ctx, s.db, sec, logger, audit.System, "policyuser", password, model_db.RoleViewer)
+		var he *echo.HTTPError
+		s.Require().ErrorAs(err, &he, password)
+		s.Equal(http.StatusBadRequest, he.Code, password)
+		s.Equal(message, he.Message, password)
+	}
+
+	user, err := core.CreateUser(ctx, s.db, sec, logger, audit.System, "policyuser", "Good-passw0rd", model_db.RoleViewer)
+	s.Require().NoError(err)
+
+	// admins setting passwords are held to the policy, generated passwords are not checked
+	_, err = core.SetUserPassword(ctx, s.db, sec, logger, audit.System, user.ID, "weak")
+	s.Equal(http.StatusBadRequest, httpErrorCode(err))
+	generated, err := core.SetUserPassword(ctx, s.db, sec, logger, audit.System, user.ID, "")
+	s.Require().NoError(err)
+	s.NotEmpty(generated)
+
+	change := func(password string) *httptest.ResponseRecorder {
+		body := "current_password=" + generated + "&new_password=" + password + "&confirm_password=" + password
+		c, rec := setupAPIKeyContext(s.T(), http.MethodPost, "/account/password", body, true, user.ID.String(), string(user.Role), s.db)
+		sess, _ := session.Get("session", c)
+		sess.Values["username"] = "policyuser"
+		c.Set("security", sec)
+		s.Require().NoError(core.ChangePasswordHandler(c))
+		return rec
+	}
+	rec := change("weak")
+	s.Equal(http.StatusBadRequest, rec.Code)
+	s.Contains(rec.Body.String(), "at least 10 characters")
+	s.Equal(http.StatusOK, change("Better-passw0rd").Code)
+}
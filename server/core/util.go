@@ -2,6 +2,9 @@ package core
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cephei8/greener/server/core/model/db"
 )
@@ -29,9 +32,29 @@ func TestcaseStatusToString(status model_db.TestcaseStatus) string {
 		return "fail"
 	case model_db.StatusError:
 		return "error"
+	case model_db.StatusFlaky:
+		return "flaky"
 	case model_db.StatusSkip:
 		return "skip"
 	default:
 		panic(fmt.Sprintf("unknown status: %d", status))
 	}
 }
+
+// ParseDuration extends time.ParseDuration with a "d" (days) suffix, e.g. "7d".
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
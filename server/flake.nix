@@ -21,6 +21,9 @@
             go-tools
             golangci-lint
             go-mockery
+            protobuf
+            protoc-gen-go
+            protoc-gen-go-grpc
 
             gcc
             pkg-config
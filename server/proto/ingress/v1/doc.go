@@ -0,0 +1,2 @@
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative ingress/v1/ingress.proto
+package ingressv1
@@ -0,0 +1,730 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: ingress/v1/ingress.proto
+
+package ingressv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Label struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         *string                `protobuf:"bytes,2,opt,name=value,proto3,oneof" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Label) Reset() {
+	*x = Label{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Label) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Label) ProtoMessage() {}
+
+func (x *Label) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Label.ProtoReflect.Descriptor instead.
+func (*Label) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Label) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Label) GetValue() string {
+	if x != nil && x.Value != nil {
+		return *x.Value
+	}
+	return ""
+}
+
+type CreateSessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id is a UUID; a new one is generated when absent.
+	Id            *string          `protobuf:"bytes,1,opt,name=id,proto3,oneof" json:"id,omitempty"`
+	Description   *string          `protobuf:"bytes,2,opt,name=description,proto3,oneof" json:"description,omitempty"`
+	Baggage       *structpb.Struct `protobuf:"bytes,3,opt,name=baggage,proto3" json:"baggage,omitempty"`
+	Labels        []*Label         `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSessionRequest) Reset() {
+	*x = CreateSessionRequest{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSessionRequest) ProtoMessage() {}
+
+func (x *CreateSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSessionRequest.ProtoReflect.Descriptor instead.
+func (*CreateSessionRequest) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateSessionRequest) GetId() string {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return ""
+}
+
+func (x *CreateSessionRequest) GetDescription() string {
+	if x != nil && x.Description != nil {
+		return *x.Description
+	}
+	return ""
+}
+
+func (x *CreateSessionRequest) GetBaggage() *structpb.Struct {
+	if x != nil {
+		return x.Baggage
+	}
+	return nil
+}
+
+func (x *CreateSessionRequest) GetLabels() []*Label {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type CreateSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSessionResponse) Reset() {
+	*x = CreateSessionResponse{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSessionResponse) ProtoMessage() {}
+
+func (x *CreateSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSessionResponse.ProtoReflect.Descriptor instead.
+func (*CreateSessionResponse) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateSessionResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Testcase struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        *string                `protobuf:"bytes,1,opt,name=id,proto3,oneof" json:"id,omitempty"`
+	ParentId  *string                `protobuf:"bytes,2,opt,name=parent_id,json=parentId,proto3,oneof" json:"parent_id,omitempty"`
+	SessionId string                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Name      string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Classname *string                `protobuf:"bytes,5,opt,name=classname,proto3,oneof" json:"classname,omitempty"`
+	File      *string                `protobuf:"bytes,6,opt,name=file,proto3,oneof" json:"file,omitempty"`
+	Testsuite *string                `protobuf:"bytes,7,opt,name=testsuite,proto3,oneof" json:"testsuite,omitempty"`
+	// status is one of pass, fail, error or skip.
+	Status         string           `protobuf:"bytes,8,opt,name=status,proto3" json:"status,omitempty"`
+	Output         *string          `protobuf:"bytes,9,opt,name=output,proto3,oneof" json:"output,omitempty"`
+	FailureMessage *string          `protobuf:"bytes,10,opt,name=failure_message,json=failureMessage,proto3,oneof" json:"failure_message,omitempty"`
+	FailureType    *string          `protobuf:"bytes,11,opt,name=failure_type,json=failureType,proto3,oneof" json:"failure_type,omitempty"`
+	StackTrace     *string          `protobuf:"bytes,12,opt,name=stack_trace,json=stackTrace,proto3,oneof" json:"stack_trace,omitempty"`
+	Stdout         *string          `protobuf:"bytes,13,opt,name=stdout,proto3,oneof" json:"stdout,omitempty"`
+	Stderr         *string          `protobuf:"bytes,14,opt,name=stderr,proto3,oneof" json:"stderr,omitempty"`
+	Attempt        *int32           `protobuf:"varint,15,opt,name=attempt,proto3,oneof" json:"attempt,omitempty"`
+	RetryOf        *string          `protobuf:"bytes,16,opt,name=retry_of,json=retryOf,proto3,oneof" json:"retry_of,omitempty"`
+	Baggage        *structpb.Struct `protobuf:"bytes,17,opt,name=baggage,proto3" json:"baggage,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Testcase) Reset() {
+	*x = Testcase{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Testcase) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Testcase) ProtoMessage() {}
+
+func (x *Testcase) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Testcase.ProtoReflect.Descriptor instead.
+func (*Testcase) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Testcase) GetId() string {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return ""
+}
+
+func (x *Testcase) GetParentId() string {
+	if x != nil && x.ParentId != nil {
+		return *x.ParentId
+	}
+	return ""
+}
+
+func (x *Testcase) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Testcase) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Testcase) GetClassname() string {
+	if x != nil && x.Classname != nil {
+		return *x.Classname
+	}
+	return ""
+}
+
+func (x *Testcase) GetFile() string {
+	if x != nil && x.File != nil {
+		return *x.File
+	}
+	return ""
+}
+
+func (x *Testcase) GetTestsuite() string {
+	if x != nil && x.Testsuite != nil {
+		return *x.Testsuite
+	}
+	return ""
+}
+
+func (x *Testcase) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Testcase) GetOutput() string {
+	if x != nil && x.Output != nil {
+		return *x.Output
+	}
+	return ""
+}
+
+func (x *Testcase) GetFailureMessage() string {
+	if x != nil && x.FailureMessage != nil {
+		return *x.FailureMessage
+	}
+	return ""
+}
+
+func (x *Testcase) GetFailureType() string {
+	if x != nil && x.FailureType != nil {
+		return *x.FailureType
+	}
+	return ""
+}
+
+func (x *Testcase) GetStackTrace() string {
+	if x != nil && x.StackTrace != nil {
+		return *x.StackTrace
+	}
+	return ""
+}
+
+func (x *Testcase) GetStdout() string {
+	if x != nil && x.Stdout != nil {
+		return *x.Stdout
+	}
+	return ""
+}
+
+func (x *Testcase) GetStderr() string {
+	if x != nil && x.Stderr != nil {
+		return *x.Stderr
+	}
+	return ""
+}
+
+func (x *Testcase) GetAttempt() int32 {
+	if x != nil && x.Attempt != nil {
+		return *x.Attempt
+	}
+	return 0
+}
+
+func (x *Testcase) GetRetryOf() string {
+	if x != nil && x.RetryOf != nil {
+		return *x.RetryOf
+	}
+	return ""
+}
+
+func (x *Testcase) GetBaggage() *structpb.Struct {
+	if x != nil {
+		return x.Baggage
+	}
+	return nil
+}
+
+type ReportTestcasesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Testcases     []*Testcase            `protobuf:"bytes,1,rep,name=testcases,proto3" json:"testcases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportTestcasesRequest) Reset() {
+	*x = ReportTestcasesRequest{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportTestcasesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportTestcasesRequest) ProtoMessage() {}
+
+func (x *ReportTestcasesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportTestcasesRequest.ProtoReflect.Descriptor instead.
+func (*ReportTestcasesRequest) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ReportTestcasesRequest) GetTestcases() []*Testcase {
+	if x != nil {
+		return x.Testcases
+	}
+	return nil
+}
+
+type ReportTestcasesResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// testcases is the number of testcases stored.
+	Testcases     int64 `protobuf:"varint,1,opt,name=testcases,proto3" json:"testcases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportTestcasesResponse) Reset() {
+	*x = ReportTestcasesResponse{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportTestcasesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportTestcasesResponse) ProtoMessage() {}
+
+func (x *ReportTestcasesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportTestcasesResponse.ProtoReflect.Descriptor instead.
+func (*ReportTestcasesResponse) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ReportTestcasesResponse) GetTestcases() int64 {
+	if x != nil {
+		return x.Testcases
+	}
+	return 0
+}
+
+type FinalizeSessionRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	SessionId   string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Description *string                `protobuf:"bytes,2,opt,name=description,proto3,oneof" json:"description,omitempty"`
+	// baggage is merged into the session baggage; null values remove keys.
+	Baggage       *structpb.Struct `protobuf:"bytes,3,opt,name=baggage,proto3" json:"baggage,omitempty"`
+	Labels        []*Label         `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty"`
+	RemoveLabels  []string         `protobuf:"bytes,5,rep,name=remove_labels,json=removeLabels,proto3" json:"remove_labels,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FinalizeSessionRequest) Reset() {
+	*x = FinalizeSessionRequest{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FinalizeSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FinalizeSessionRequest) ProtoMessage() {}
+
+func (x *FinalizeSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FinalizeSessionRequest.ProtoReflect.Descriptor instead.
+func (*FinalizeSessionRequest) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FinalizeSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *FinalizeSessionRequest) GetDescription() string {
+	if x != nil && x.Description != nil {
+		return *x.Description
+	}
+	return ""
+}
+
+func (x *FinalizeSessionRequest) GetBaggage() *structpb.Struct {
+	if x != nil {
+		return x.Baggage
+	}
+	return nil
+}
+
+func (x *FinalizeSessionRequest) GetLabels() []*Label {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *FinalizeSessionRequest) GetRemoveLabels() []string {
+	if x != nil {
+		return x.RemoveLabels
+	}
+	return nil
+}
+
+type FinalizeSessionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// status is the aggregated status of the session's testcases.
+	Status string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// testcases is the number of testcases in the session, excluding retried attempts.
+	Testcases     int64 `protobuf:"varint,2,opt,name=testcases,proto3" json:"testcases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FinalizeSessionResponse) Reset() {
+	*x = FinalizeSessionResponse{}
+	mi := &file_ingress_v1_ingress_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FinalizeSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FinalizeSessionResponse) ProtoMessage() {}
+
+func (x *FinalizeSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ingress_v1_ingress_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FinalizeSessionResponse.ProtoReflect.Descriptor instead.
+func (*FinalizeSessionResponse) Descriptor() ([]byte, []int) {
+	return file_ingress_v1_ingress_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FinalizeSessionResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *FinalizeSessionResponse) GetTestcases() int64 {
+	if x != nil {
+		return x.Testcases
+	}
+	return 0
+}
+
+var File_ingress_v1_ingress_proto protoreflect.FileDescriptor
+
+const file_ingress_v1_ingress_proto_rawDesc = "" +
+	"\n" +
+	"\x18ingress/v1/ingress.proto\x12\x12greener.ingress.v1\x1a\x1cgoogle/protobuf/struct.proto\">\n" +
+	"\x05Label\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x19\n" +
+	"\x05value\x18\x02 \x01(\tH\x00R\x05value\x88\x01\x01B\b\n" +
+	"\x06_value\"\xcf\x01\n" +
+	"\x14CreateSessionRequest\x12\x13\n" +
+	"\x02id\x18\x01 \x01(\tH\x00R\x02id\x88\x01\x01\x12%\n" +
+	"\vdescription\x18\x02 \x01(\tH\x01R\vdescription\x88\x01\x01\x121\n" +
+	"\abaggage\x18\x03 \x01(\v2\x17.google.protobuf.StructR\abaggage\x121\n" +
+	"\x06labels\x18\x04 \x03(\v2\x19.greener.ingress.v1.LabelR\x06labelsB\x05\n" +
+	"\x03_idB\x0e\n" +
+	"\f_description\"'\n" +
+	"\x15CreateSessionResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xd9\x05\n" +
+	"\bTestcase\x12\x13\n" +
+	"\x02id\x18\x01 \x01(\tH\x00R\x02id\x88\x01\x01\x12 \n" +
+	"\tparent_id\x18\x02 \x01(\tH\x01R\bparentId\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\tR\tsessionId\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12!\n" +
+	"\tclassname\x18\x05 \x01(\tH\x02R\tclassname\x88\x01\x01\x12\x17\n" +
+	"\x04file\x18\x06 \x01(\tH\x03R\x04file\x88\x01\x01\x12!\n" +
+	"\ttestsuite\x18\a \x01(\tH\x04R\ttestsuite\x88\x01\x01\x12\x16\n" +
+	"\x06status\x18\b \x01(\tR\x06status\x12\x1b\n" +
+	"\x06output\x18\t \x01(\tH\x05R\x06output\x88\x01\x01\x12,\n" +
+	"\x0ffailure_message\x18\n" +
+	" \x01(\tH\x06R\x0efailureMessage\x88\x01\x01\x12&\n" +
+	"\ffailure_type\x18\v \x01(\tH\aR\vfailureType\x88\x01\x01\x12$\n" +
+	"\vstack_trace\x18\f \x01(\tH\bR\n" +
+	"stackTrace\x88\x01\x01\x12\x1b\n" +
+	"\x06stdout\x18\r \x01(\tH\tR\x06stdout\x88\x01\x01\x12\x1b\n" +
+	"\x06stderr\x18\x0e \x01(\tH\n" +
+	"R\x06stderr\x88\x01\x01\x12\x1d\n" +
+	"\aattempt\x18\x0f \x01(\x05H\vR\aattempt\x88\x01\x01\x12\x1e\n" +
+	"\bretry_of\x18\x10 \x01(\tH\fR\aretryOf\x88\x01\x01\x121\n" +
+	"\abaggage\x18\x11 \x01(\v2\x17.google.protobuf.StructR\abaggageB\x05\n" +
+	"\x03_idB\f\n" +
+	"\n" +
+	"_parent_idB\f\n" +
+	"\n" +
+	"_classnameB\a\n" +
+	"\x05_fileB\f\n" +
+	"\n" +
+	"_testsuiteB\t\n" +
+	"\a_outputB\x12\n" +
+	"\x10_failure_messageB\x0f\n" +
+	"\r_failure_typeB\x0e\n" +
+	"\f_stack_traceB\t\n" +
+	"\a_stdoutB\t\n" +
+	"\a_stderrB\n" +
+	"\n" +
+	"\b_attemptB\v\n" +
+	"\t_retry_of\"T\n" +
+	"\x16ReportTestcasesRequest\x12:\n" +
+	"\ttestcases\x18\x01 \x03(\v2\x1c.greener.ingress.v1.TestcaseR\ttestcases\"7\n" +
+	"\x17ReportTestcasesResponse\x12\x1c\n" +
+	"\ttestcases\x18\x01 \x01(\x03R\ttestcases\"\xf9\x01\n" +
+	"\x16FinalizeSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12%\n" +
+	"\vdescription\x18\x02 \x01(\tH\x00R\vdescription\x88\x01\x01\x121\n" +
+	"\abaggage\x18\x03 \x01(\v2\x17.google.protobuf.StructR\abaggage\x121\n" +
+	"\x06labels\x18\x04 \x03(\v2\x19.greener.ingress.v1.LabelR\x06labels\x12#\n" +
+	"\rremove_labels\x18\x05 \x03(\tR\fremoveLabelsB\x0e\n" +
+	"\f_description\"O\n" +
+	"\x17FinalizeSessionResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1c\n" +
+	"\ttestcases\x18\x02 \x01(\x03R\ttestcases2\xd0\x02\n" +
+	"\x0eIngressService\x12d\n" +
+	"\rCreateSession\x12(.greener.ingress.v1.CreateSessionRequest\x1a).greener.ingress.v1.CreateSessionResponse\x12l\n" +
+	"\x0fReportTestcases\x12*.greener.ingress.v1.ReportTestcasesRequest\x1a+.greener.ingress.v1.ReportTestcasesResponse(\x01\x12j\n" +
+	"\x0fFinalizeSession\x12*.greener.ingress.v1.FinalizeSessionRequest\x1a+.greener.ingress.v1.FinalizeSessionResponseB>Z<github.com/cephei8/greener/server/proto/ingress/v1;ingressv1b\x06proto3"
+
+var (
+	file_ingress_v1_ingress_proto_rawDescOnce sync.Once
+	file_ingress_v1_ingress_proto_rawDescData []byte
+)
+
+func file_ingress_v1_ingress_proto_rawDescGZIP() []byte {
+	file_ingress_v1_ingress_proto_rawDescOnce.Do(func() {
+		file_ingress_v1_ingress_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_ingress_v1_ingress_proto_rawDesc), len(file_ingress_v1_ingress_proto_rawDesc)))
+	})
+	return file_ingress_v1_ingress_proto_rawDescData
+}
+
+var file_ingress_v1_ingress_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_ingress_v1_ingress_proto_goTypes = []any{
+	(*Label)(nil),                   // 0: greener.ingress.v1.Label
+	(*CreateSessionRequest)(nil),    // 1: greener.ingress.v1.CreateSessionRequest
+	(*CreateSessionResponse)(nil),   // 2: greener.ingress.v1.CreateSessionResponse
+	(*Testcase)(nil),                // 3: greener.ingress.v1.Testcase
+	(*ReportTestcasesRequest)(nil),  // 4: greener.ingress.v1.ReportTestcasesRequest
+	(*ReportTestcasesResponse)(nil), // 5: greener.ingress.v1.ReportTestcasesResponse
+	(*FinalizeSessionRequest)(nil),  // 6: greener.ingress.v1.FinalizeSessionRequest
+	(*FinalizeSessionResponse)(nil), // 7: greener.ingress.v1.FinalizeSessionResponse
+	(*structpb.Struct)(nil),         // 8: google.protobuf.Struct
+}
+var file_ingress_v1_ingress_proto_depIdxs = []int32{
+	8, // 0: greener.ingress.v1.CreateSessionRequest.baggage:type_name -> google.protobuf.Struct
+	0, // 1: greener.ingress.v1.CreateSessionRequest.labels:type_name -> greener.ingress.v1.Label
+	8, // 2: greener.ingress.v1.Testcase.baggage:type_name -> google.protobuf.Struct
+	3, // 3: greener.ingress.v1.ReportTestcasesRequest.testcases:type_name -> greener.ingress.v1.Testcase
+	8, // 4: greener.ingress.v1.FinalizeSessionRequest.baggage:type_name -> google.protobuf.Struct
+	0, // 5: greener.ingress.v1.FinalizeSessionRequest.labels:type_name -> greener.ingress.v1.Label
+	1, // 6: greener.ingress.v1.IngressService.CreateSession:input_type -> greener.ingress.v1.CreateSessionRequest
+	4, // 7: greener.ingress.v1.IngressService.ReportTestcases:input_type -> greener.ingress.v1.ReportTestcasesRequest
+	6, // 8: greener.ingress.v1.IngressService.FinalizeSession:input_type -> greener.ingress.v1.FinalizeSessionRequest
+	2, // 9: greener.ingress.v1.IngressService.CreateSession:output_type -> greener.ingress.v1.CreateSessionResponse
+	5, // 10: greener.ingress.v1.IngressService.ReportTestcases:output_type -> greener.ingress.v1.ReportTestcasesResponse
+	7, // 11: greener.ingress.v1.IngressService.FinalizeSession:output_type -> greener.ingress.v1.FinalizeSessionResponse
+	9, // [9:12] is the sub-list for method output_type
+	6, // [6:9] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_ingress_v1_ingress_proto_init() }
+func file_ingress_v1_ingress_proto_init() {
+	if File_ingress_v1_ingress_proto != nil {
+		return
+	}
+	file_ingress_v1_ingress_proto_msgTypes[0].OneofWrappers = []any{}
+	file_ingress_v1_ingress_proto_msgTypes[1].OneofWrappers = []any{}
+	file_ingress_v1_ingress_proto_msgTypes[3].OneofWrappers = []any{}
+	file_ingress_v1_ingress_proto_msgTypes[6].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_ingress_v1_ingress_proto_rawDesc), len(file_ingress_v1_ingress_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_ingress_v1_ingress_proto_goTypes,
+		DependencyIndexes: file_ingress_v1_ingress_proto_depIdxs,
+		MessageInfos:      file_ingress_v1_ingress_proto_msgTypes,
+	}.Build()
+	File_ingress_v1_ingress_proto = out.File
+	file_ingress_v1_ingress_proto_goTypes = nil
+	file_ingress_v1_ingress_proto_depIdxs = nil
+}
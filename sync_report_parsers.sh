@@ -0,0 +1,28 @@
+#!/usr/bin/env bash
+
+set -euo pipefail
+
+ROOT="$(cd "$(dirname "$0")" && pwd)"
+
+log() { echo "==> $*"; }
+
+# The Go reporters vendor the report parsers of the server, so that they build
+# without the server module. Run this after changing server/core/report;
+# TestVendoredCopies in server/core/report fails until the copies match.
+SOURCE="$ROOT/server/core/report"
+REPORTERS=(
+  reporting/greener-reporter-cli
+  reporting/greener-reporter-junitxml
+)
+
+for reporter in "${REPORTERS[@]}"; do
+  dir="$ROOT/$reporter/internal/report"
+  log "Copying report parsers: $reporter"
+  rm -rf "$dir"
+  mkdir -p "$dir"
+  for file in "$SOURCE"/*.go; do
+    [[ "$file" == *_test.go ]] || cp "$file" "$dir/"
+  done
+done
+
+log "Report parsers copied."